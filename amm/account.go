@@ -0,0 +1,26 @@
+package amm
+
+import (
+	"fmt"
+	"strings"
+
+	"raydium-go/spl"
+)
+
+var dataSize = uint64(165)
+
+// accountShape 描述账户的创建、wrap 和关闭，它们改变 swap 消耗的 compute units
+func accountShape(t spl.AccountInstructions) string {
+	var steps []string
+	if t.Rent > 0 {
+		steps = append(steps, "create")
+	}
+	if t.Wrapped > 0 {
+		steps = append(steps, "wrap")
+	}
+	if len(t.Cleanup) > 0 {
+		steps = append(steps, "close")
+	}
+	// 临时账户和 ATA 的创建指令数不同
+	return fmt.Sprintf("%s/%d", strings.Join(steps, "+"), len(t.Setup))
+}
@@ -8,22 +8,20 @@ import (
 	"encoding/hex"
 	"errors"
 	"fmt"
-	"raydium-go/config"
-	"strconv"
+	"log/slog"
+	"raydium-go/jito"
+	"raydium-go/spl"
+	"raydium-go/txn"
 
 	bin "github.com/gagliardetto/binary"
 	"github.com/gagliardetto/solana-go"
-	associatedtokenaccount "github.com/gagliardetto/solana-go/programs/associated-token-account"
 	computebudget "github.com/gagliardetto/solana-go/programs/compute-budget"
-	"github.com/gagliardetto/solana-go/programs/system"
-	"github.com/gagliardetto/solana-go/programs/token"
 	"github.com/gagliardetto/solana-go/rpc"
 )
 
 var (
 	computeUnitLimit               = uint32(68000)
 	priorityFee                    = uint64(100)
-	dataSize                       = uint64(165)
 	WSOL                           = solana.MustPublicKeyFromBase58("So11111111111111111111111111111111111111112")
 	PC2Coin          SwapDirection = "pc2coin"
 	Coin2PC          SwapDirection = "coin2Pc"
@@ -76,152 +74,279 @@ const (
 	LogSwapBaseOut = 4
 )
 
-func Swap(client *rpc.Client, network string, poolAddress string, inputTokenAddress string, amountSpecified uint64, baseIn bool, slippage float64, privateKey string) (string, error) {
-	pool, err := solana.PublicKeyFromBase58(poolAddress)
+func Swap(ctx context.Context, client *rpc.Client, network string, poolAddress string, inputTokenAddress string, amountSpecified uint64, baseIn bool, slippage float64, privateKey string) (string, error) {
+	return SwapWithOptions(ctx, client, network, poolAddress, inputTokenAddress, amountSpecified, baseIn, slippage, privateKey, SwapOptions{})
+}
+
+func SwapWithOptions(ctx context.Context, client *rpc.Client, network string, poolAddress string, inputTokenAddress string, amountSpecified uint64, baseIn bool, slippage float64, privateKey string, opts SwapOptions) (signature string, err error) {
+	signer, err := solana.PrivateKeyFromBase58(privateKey)
 	if err != nil {
-		return "", err
+		return "", fmt.Errorf("invalid private key: %w", err)
 	}
-	poolState, err := GetPoolState(client, pool)
+	obs := newSwapObserver(ctx, opts, network, poolAddress, signer.PublicKey())
+	defer func() { obs.done(ctx, err) }()
+	tx, plan, err := buildSwapTransaction(ctx, client, network, poolAddress, inputTokenAddress, amountSpecified, baseIn, slippage, signer.PublicKey(), opts, nil, obs)
 	if err != nil {
 		return "", err
 	}
-	marketState, err := GetMarketState(client, poolState.Market)
-	if err != nil {
+	if err := signTransaction(tx, signer); err != nil {
 		return "", err
 	}
-	signer, err := solana.PrivateKeyFromBase58(privateKey)
+	obs.emit(ctx, StageSigned, func(e *SwapEvent) { e.Signature = tx.Signatures[0] })
+	return sendTransaction(ctx, client, tx, plan, obs)
+}
+
+func signTransaction(tx *solana.Transaction, signer solana.PrivateKey) error {
+	_, err := tx.Sign(func(key solana.PublicKey) *solana.PrivateKey {
+		if signer.PublicKey().Equals(key) {
+			return &signer
+		}
+		return nil
+	})
 	if err != nil {
-		return "", fmt.Errorf("invalid private key: %w", err)
+		return fmt.Errorf("failed to sign transaction: %w", err)
 	}
-	payerPubKey := signer.PublicKey()
-	var inputMint solana.PublicKey
-	var outputMint solana.PublicKey
-	var direction SwapDirection
-	if inputTokenAddress == poolState.CoinVaultMint.String() {
-		inputMint = poolState.CoinVaultMint
-		outputMint = poolState.PcVaultMint
-		direction = Coin2PC
-	} else {
-		inputMint = poolState.PcVaultMint
-		outputMint = poolState.CoinVaultMint
-		direction = PC2Coin
-	}
-	fmt.Println("inputMint:", inputMint)
-	fmt.Println("outputMint:", outputMint)
-	var instructions []solana.Instruction
-	inputAta, inputAtaCreateInstruction, err := getOrCreateTokenAccountInstruction(client, inputMint, signer, amountSpecified, true)
+	return nil
+}
+
+// BuildSwapTransaction builds the unsigned swap transaction signed by owner. Fees and rent are paid by
+// owner unless opts.FeePayer or opts.RentPayer is set.
+func BuildSwapTransaction(ctx context.Context, client *rpc.Client, network string, poolAddress string, inputTokenAddress string, amountSpecified uint64, baseIn bool, slippage float64, owner solana.PublicKey, opts SwapOptions) (tx *solana.Transaction, err error) {
+	obs := newSwapObserver(ctx, opts, network, poolAddress, owner)
+	defer func() { obs.done(ctx, err) }()
+	tx, _, err = buildSwapTransaction(ctx, client, network, poolAddress, inputTokenAddress, amountSpecified, baseIn, slippage, owner, opts, nil, obs)
+	return tx, err
+}
+
+func buildSwapTransaction(ctx context.Context, client *rpc.Client, network string, poolAddress string, inputTokenAddress string, amountSpecified uint64, baseIn bool, slippage float64, owner solana.PublicKey, opts SwapOptions, tip *jito.Tip, obs *swapObserver) (*solana.Transaction, *swapPlan, error) {
+	plan, err := buildSwapPlan(ctx, client, network, poolAddress, inputTokenAddress, amountSpecified, baseIn, slippage, owner, opts, obs)
 	if err != nil {
-		return "", fmt.Errorf("failed to find associated token address: %v", err)
+		return nil, nil, err
+	}
+	if tip != nil {
+		plan.Instructions = append(plan.Instructions, tip.Instruction(plan.FeePayer))
+		plan.TipLamports = tip.Lamports
 	}
-	if inputAtaCreateInstruction != nil {
-		for _, inst := range inputAtaCreateInstruction {
-			instructions = append(instructions, inst)
+	// 余额不足时返回 InsufficientFundsError，而不是模拟失败
+	var balances *swapBalances
+	if !opts.SkipBalanceCheck {
+		if balances, err = checkSwapBalances(ctx, client, plan, opts.Nonce); err != nil {
+			return nil, nil, err
 		}
 	}
-
-	outputAta, outputAtaCreateInstruction, err := getOrCreateTokenAccountInstruction(client, outputMint, signer, amountSpecified, false)
+	tables, err := txn.GetLookupTables(ctx, client, opts.AddressLookupTables)
 	if err != nil {
-		return "", err
+		return nil, nil, err
 	}
-	if outputAtaCreateInstruction != nil {
-		for _, inst := range outputAtaCreateInstruction {
-			instructions = append(instructions, inst)
-		}
+	limit, err := opts.computeUnitLimit(ctx, client, plan, tables)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to estimate compute unit limit: %w", err)
 	}
-
-	ammAuthority, _, _ := solana.FindProgramAddress([][]byte{{97, 109, 109, 32, 97, 117, 116, 104, 111, 114, 105, 116, 121}}, config.Raydium_AMM_Program[network])
-	vaultSigner, _, err := GetAssociatedAuthority(poolState.MarketProgram, poolState.Market)
-	pcVaultAccount, err := client.GetTokenAccountBalance(context.Background(), poolState.PcVault, rpc.CommitmentFinalized)
-	pcVaultBalance, err := strconv.ParseUint(pcVaultAccount.Value.Amount, 10, 64)
-	coinVaultAccount, err := client.GetTokenAccountBalance(context.Background(), poolState.CoinVault, rpc.CommitmentFinalized)
-	coinVaultBalance, err := strconv.ParseUint(coinVaultAccount.Value.Amount, 10, 64)
-	pcVaultAmount := pcVaultBalance - poolState.StateData.NeedTakePnlPc
-	coinVaultAmount := coinVaultBalance - poolState.StateData.NeedTakePnlCoin
-	var data []byte
-	if baseIn {
-		swapFee := uint64(float64(amountSpecified) * float64(poolState.Fees.SwapFeeNumerator) / float64(poolState.Fees.SwapFeeDenominator))
-		swapInAfterDeductFee := amountSpecified - swapFee
-		var minAmountOut uint64
-		if direction == Coin2PC {
-			minAmountOut = uint64(float64(pcVaultAmount) * float64(swapInAfterDeductFee) / float64(coinVaultAmount+swapInAfterDeductFee) * float64(1-slippage))
-		} else {
-			minAmountOut = uint64(float64(coinVaultAmount) * float64(swapInAfterDeductFee) / float64(pcVaultAmount+swapInAfterDeductFee) * float64(1-slippage))
+	fee, err := opts.priorityFee(ctx, client, plan.WritableAccounts, limit)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to estimate priority fee: %w", err)
+	}
+	if balances != nil {
+		if err := balances.checkFees(limit, fee); err != nil {
+			return nil, nil, err
 		}
-		data, err = baseInDataFrom(amountSpecified, minAmountOut)
+	}
+	plan.ComputeUnitLimit, plan.PriorityFee, plan.LookupTables, plan.Nonce = limit, fee, tables, opts.Nonce
+	if opts.Nonce != nil {
+		plan.Blockhash, err = opts.Nonce.GetNonce(ctx, client)
 		if err != nil {
-			return "", err
+			return nil, nil, fmt.Errorf("failed to fetch durable nonce: %w", err)
 		}
 	} else {
-		var maxAmountIn uint64
-		if direction == Coin2PC {
-			maxAmountIn = uint64(float64(coinVaultAmount) * float64(amountSpecified) / float64(pcVaultAmount-amountSpecified) * float64(poolState.Fees.SwapFeeDenominator) / float64(poolState.Fees.SwapFeeDenominator-poolState.Fees.SwapFeeNumerator) * float64(1+slippage))
-		} else {
-			maxAmountIn = uint64(float64(pcVaultAmount) * float64(amountSpecified) / float64(coinVaultAmount-amountSpecified) * float64(poolState.Fees.SwapFeeDenominator) / float64(poolState.Fees.SwapFeeDenominator-poolState.Fees.SwapFeeNumerator) * float64(1+slippage))
-		}
-		data, err = baseOutDataFrom(maxAmountIn, amountSpecified)
+		latest, err := getLatestBlockhash(ctx, client, plan.Commitment)
 		if err != nil {
-			return "", err
+			return nil, nil, fmt.Errorf("failed to fetch recent blockhash: %w", err)
 		}
+		plan.Blockhash = latest.Blockhash
+		plan.LastValidBlockHeight = latest.LastValidBlockHeight
 	}
-	swapInstruction := solana.NewInstruction(
-		config.Raydium_AMM_Program[network],
-		swapAccountsFrom(pool, ammAuthority, poolState.OpenOrders, poolState.TargetOrders, poolState.CoinVault, poolState.PcVault, poolState.MarketProgram, poolState.Market, marketState.Bids, marketState.Asks, marketState.EventQueue, marketState.BaseVault, marketState.QuoteVault, vaultSigner, inputAta, outputAta, payerPubKey),
-		data,
-	)
-	instructions = append(instructions, swapInstruction)
-	for _, a := range swapInstruction.AccountValues {
-		fmt.Println(a.PublicKey.String())
+	tx, err := plan.transaction()
+	if err != nil {
+		return nil, nil, err
 	}
-	if inputMint.Equals(WSOL) {
-		closeAccInst, err := token.NewCloseAccountInstruction(
-			inputAta,
-			signer.PublicKey(),
-			signer.PublicKey(),
-			[]solana.PublicKey{},
-		).ValidateAndBuild()
+	obs.emit(ctx, StageTxBuilt, func(e *SwapEvent) {
+		e.ComputeUnitLimit = limit
+		e.PriorityFee = fee
+		e.TipLamports = plan.TipLamports
+	})
+	return tx, plan, nil
+}
 
-		if err != nil {
-			return "", err
-		}
-		instructions = append(instructions, closeAccInst)
-	} else if outputMint.Equals(WSOL) {
-		closeAccInst, err := token.NewCloseAccountInstruction(
-			outputAta,
-			signer.PublicKey(),
-			signer.PublicKey(),
-			[]solana.PublicKey{},
-		).ValidateAndBuild()
+// swapPlan 汇总构建一笔 swap 所需的状态、报价和指令
+type swapPlan struct {
+	Network         string
+	ProgramID       solana.PublicKey
+	Pool            solana.PublicKey
+	BaseIn          bool
+	InstructionType string
+	Owner           solana.PublicKey
+	FeePayer        solana.PublicKey
+	RentPayer       solana.PublicKey
+	InputMint       spl.Mint
+	OutputMint      spl.Mint
+	Quote           SwapQuote
+	MaxAmountIn     uint64
+	InputAccount    spl.AccountInstructions
+	OutputAccount   spl.AccountInstructions
+	Instructions    []solana.Instruction
+	// 池子、金库和市场等可写账户，用于估算优先费
+	WritableAccounts []solana.PublicKey
+	// 附加的 bundle 小费
+	TipLamports uint64
+	// MinContextSlot 不低于读取池子状态时的 slot
+	Commitment Commitment
+	// blockhash 的最后有效区块高度，使用 durable nonce 时为 0
+	LastValidBlockHeight uint64
+	// 以下在估算 compute units 和优先费后设置，用于构建交易
+	ComputeUnitLimit uint32
+	PriorityFee      uint64
+	// 最新的 blockhash，或 durable nonce 的值
+	Blockhash    solana.Hash
+	LookupTables map[solana.PublicKey]solana.PublicKeySlice
+	Nonce        *txn.DurableNonce
+}
 
-		if err != nil {
-			return "", err
-		}
-		instructions = append(instructions, closeAccInst)
+// transaction 用 plan 的 compute budget、blockhash 和指令构建交易
+func (p *swapPlan) transaction() (*solana.Transaction, error) {
+	instructions := swapInstructionsFrom(p.ComputeUnitLimit, p.PriorityFee, p.Instructions)
+	if p.Nonce != nil {
+		instructions = p.Nonce.WithNonce(instructions)
+	}
+	return txn.NewTransaction(instructions, p.Blockhash, p.FeePayer, p.LookupTables)
+}
+
+// withoutTip 返回去掉末尾 bundle 小费指令的 plan
+func (p *swapPlan) withoutTip() *swapPlan {
+	if p.TipLamports == 0 {
+		return p
 	}
+	q := *p
+	q.Instructions = p.Instructions[:len(p.Instructions)-1]
+	q.TipLamports = 0
+	return &q
+}
+
+// computeUnitKey 为 compute unit 缓存的 key，区分池子、指令和输入输出账户的准备方式
+func (p *swapPlan) computeUnitKey() string {
+	return txn.CacheKey(p.Pool, p.InstructionType+":"+accountShape(p.InputAccount)+":"+accountShape(p.OutputAccount))
+}
 
-	blockhash, err := client.GetLatestBlockhash(context.Background(), rpc.CommitmentFinalized)
+func buildSwapPlan(ctx context.Context, client *rpc.Client, network string, poolAddress string, inputTokenAddress string, amountSpecified uint64, baseIn bool, slippage float64, owner solana.PublicKey, opts SwapOptions, obs *swapObserver) (*swapPlan, error) {
+	programID, err := opts.programID(network)
 	if err != nil {
-		return "", fmt.Errorf("failed to fetch recent blockhash: %w", err)
-	}
-	tx, err := solana.NewTransaction(
-		swapInstructionsFrom(computeUnitLimit, priorityFee, instructions),
-		blockhash.Value.Blockhash,
-		solana.TransactionPayer(payerPubKey),
-	)
-	_, err = tx.Sign(func(key solana.PublicKey) *solana.PrivateKey {
-		if signer.PublicKey().Equals(key) {
-			return &signer
-		}
-		return nil
+		return nil, err
+	}
+	pool, err := solana.PublicKeyFromBase58(poolAddress)
+	if err != nil {
+		return nil, err
+	}
+	poolState, slot, err := getPoolState(ctx, client, pool, opts.Commitment)
+	if err != nil {
+		return nil, err
+	}
+	// 之后的读取不早于池子状态所在的 slot
+	commitment := opts.Commitment.atLeast(slot)
+	marketState, err := GetMarketState(ctx, client, poolState.Market, commitment)
+	if err != nil {
+		return nil, err
+	}
+	obs.emit(ctx, StageStateFetched, func(e *SwapEvent) {
+		e.Pool = pool
+		e.Slot = slot
 	})
+	var inputMintAddress solana.PublicKey
+	var outputMintAddress solana.PublicKey
+	if inputTokenAddress == poolState.CoinVaultMint.String() {
+		inputMintAddress = poolState.CoinVaultMint
+		outputMintAddress = poolState.PcVaultMint
+	} else {
+		inputMintAddress = poolState.PcVaultMint
+		outputMintAddress = poolState.CoinVaultMint
+	}
+	inputMint, outputMint, quote, err := quoteSwap(ctx, client, poolState, inputMintAddress, outputMintAddress, amountSpecified, baseIn, slippage, commitment)
 	if err != nil {
-		return "", fmt.Errorf("failed to sign transaction: %w", err)
+		return nil, err
 	}
-	txHash, err := client.SendTransaction(context.Background(), tx)
+	obs.emit(ctx, StageQuoteComputed, func(e *SwapEvent) {
+		e.InputMint = inputMint.Address
+		e.OutputMint = outputMint.Address
+		e.BaseIn = baseIn
+		e.Quote = quote
+	})
+	maxAmountIn := quote.AmountIn
+	if !baseIn {
+		maxAmountIn = quote.OtherAmountThreshold
+	}
+
+	var instructions []solana.Instruction
+	rentPayer := opts.rentPayer(owner)
+	inputAccount, err := spl.TokenAccountInstructions(ctx, client, commitment.state(), owner, rentPayer, inputMint, maxAmountIn, opts.WSOLPolicy)
 	if err != nil {
-		return "", fmt.Errorf("failed to send transaction: %w", err)
+		return nil, fmt.Errorf("failed to find associated token address: %v", err)
 	}
+	instructions = append(instructions, inputAccount.Setup...)
+	outputAccount, err := spl.TokenAccountInstructions(ctx, client, commitment.state(), owner, rentPayer, outputMint, 0, opts.WSOLPolicy)
+	if err != nil {
+		return nil, err
+	}
+	instructions = append(instructions, outputAccount.Setup...)
 
-	return txHash.String(), nil
+	amount := quote.AmountIn
+	if !baseIn {
+		amount = quote.AmountOut
+	}
+	swapInstruction, err := NewSwapInstruction(programID, pool, poolState, marketState, inputMint.Program, inputAccount.Address, outputAccount.Address, owner, baseIn, amount, quote.OtherAmountThreshold)
+	if err != nil {
+		return nil, err
+	}
+	instructions = append(instructions, swapInstruction)
+	if obs.logger.Enabled(ctx, slog.LevelDebug) {
+		var accounts []string
+		for _, a := range swapInstruction.Accounts() {
+			accounts = append(accounts, a.PublicKey.String())
+		}
+		obs.logger.DebugContext(ctx, "swap accounts", "pool", pool.String(), "accounts", accounts)
+	}
+	instructions = append(instructions, inputAccount.Cleanup...)
+	instructions = append(instructions, outputAccount.Cleanup...)
+	instructionType := "swap_base_in"
+	if !baseIn {
+		instructionType = "swap_base_out"
+	}
+	return &swapPlan{
+		Network:         network,
+		ProgramID:       programID,
+		Pool:            pool,
+		BaseIn:          baseIn,
+		InstructionType: instructionType,
+		Owner:           owner,
+		FeePayer:        opts.feePayer(owner),
+		RentPayer:       rentPayer,
+		InputMint:       inputMint,
+		OutputMint:      outputMint,
+		Quote:           quote,
+		MaxAmountIn:     maxAmountIn,
+		InputAccount:    inputAccount,
+		OutputAccount:   outputAccount,
+		Instructions:    instructions,
+		Commitment:      commitment,
+		WritableAccounts: []solana.PublicKey{
+			pool,
+			poolState.OpenOrders,
+			poolState.TargetOrders,
+			poolState.CoinVault,
+			poolState.PcVault,
+			poolState.Market,
+			marketState.Bids,
+			marketState.Asks,
+			marketState.EventQueue,
+		},
+	}, nil
 }
 
 func baseInDataFrom(amountIn uint64, minAmountOut uint64) ([]byte, error) {
@@ -280,6 +405,7 @@ func swapInstructionsFrom(
 }
 
 func swapAccountsFrom(
+	tokenProgram solana.PublicKey,
 	pool solana.PublicKey,
 	ammAuthority solana.PublicKey,
 	openOrders solana.PublicKey,
@@ -299,27 +425,59 @@ func swapAccountsFrom(
 	payer solana.PublicKey,
 ) []*solana.AccountMeta {
 	return []*solana.AccountMeta{
-		solana.NewAccountMeta(token.ProgramID, false, false), // TOKEN PROGRAM
-		solana.NewAccountMeta(pool, true, false),             // AMM
-		solana.NewAccountMeta(ammAuthority, false, false),    // AMM Authority
-		solana.NewAccountMeta(openOrders, true, false),       // Amm Open Orders
-		solana.NewAccountMeta(targetOrders, true, false),     // Amm Target Orders
-		solana.NewAccountMeta(coinVault, true, false),        // Pool Coin Token Account
-		solana.NewAccountMeta(pcVault, true, false),          // Pool Pc Token Account
-		solana.NewAccountMeta(marketProgram, false, false),   // Serum PROGRAM
-		solana.NewAccountMeta(market, true, false),           // Serum Market
-		solana.NewAccountMeta(bids, true, false),             // Serum Bids
-		solana.NewAccountMeta(asks, true, false),             // Serum Asks
-		solana.NewAccountMeta(eventQueue, true, false),       // Serum Event Queue
-		solana.NewAccountMeta(baseVault, true, false),        // Serum Coin Vault Account
-		solana.NewAccountMeta(quoteVault, true, false),       // Serum Pc Vault Account
-		solana.NewAccountMeta(vaultSigner, false, false),     // Serum Vault Signer
-		solana.NewAccountMeta(inputMint, true, false),        // User Source Token Account
-		solana.NewAccountMeta(outputMint, true, false),       // User Destination Token Account
-		solana.NewAccountMeta(payer, true, true),             // User Source Owner
+		solana.NewAccountMeta(tokenProgram, false, false),  // TOKEN PROGRAM
+		solana.NewAccountMeta(pool, true, false),           // AMM
+		solana.NewAccountMeta(ammAuthority, false, false),  // AMM Authority
+		solana.NewAccountMeta(openOrders, true, false),     // Amm Open Orders
+		solana.NewAccountMeta(targetOrders, true, false),   // Amm Target Orders
+		solana.NewAccountMeta(coinVault, true, false),      // Pool Coin Token Account
+		solana.NewAccountMeta(pcVault, true, false),        // Pool Pc Token Account
+		solana.NewAccountMeta(marketProgram, false, false), // Serum PROGRAM
+		solana.NewAccountMeta(market, true, false),         // Serum Market
+		solana.NewAccountMeta(bids, true, false),           // Serum Bids
+		solana.NewAccountMeta(asks, true, false),           // Serum Asks
+		solana.NewAccountMeta(eventQueue, true, false),     // Serum Event Queue
+		solana.NewAccountMeta(baseVault, true, false),      // Serum Coin Vault Account
+		solana.NewAccountMeta(quoteVault, true, false),     // Serum Pc Vault Account
+		solana.NewAccountMeta(vaultSigner, false, false),   // Serum Vault Signer
+		solana.NewAccountMeta(inputMint, true, false),      // User Source Token Account
+		solana.NewAccountMeta(outputMint, true, false),     // User Destination Token Account
+		solana.NewAccountMeta(payer, true, true),           // User Source Owner
 	}
 }
 
+// NewSwapInstruction builds the swap_base_in or swap_base_out instruction of owner on pool. amount is
+// the input of swap_base_in and the output of swap_base_out.
+func NewSwapInstruction(programID solana.PublicKey, pool solana.PublicKey, poolState AmmInfo, marketState MarketState, tokenProgram solana.PublicKey, userSource solana.PublicKey, userDestination solana.PublicKey, owner solana.PublicKey, baseIn bool, amount uint64, otherAmountThreshold uint64) (solana.Instruction, error) {
+	var data []byte
+	var err error
+	if baseIn {
+		data, err = baseInDataFrom(amount, otherAmountThreshold)
+	} else {
+		data, err = baseOutDataFrom(otherAmountThreshold, amount)
+	}
+	if err != nil {
+		return nil, err
+	}
+	ammAuthority, _, err := GetAmmAuthority(programID)
+	if err != nil {
+		return nil, err
+	}
+	vaultSigner, _, err := GetAssociatedAuthority(poolState.MarketProgram, poolState.Market)
+	if err != nil {
+		return nil, err
+	}
+	return solana.NewInstruction(
+		programID,
+		swapAccountsFrom(tokenProgram, pool, ammAuthority, poolState.OpenOrders, poolState.TargetOrders, poolState.CoinVault, poolState.PcVault, poolState.MarketProgram, poolState.Market, marketState.Bids, marketState.Asks, marketState.EventQueue, marketState.BaseVault, marketState.QuoteVault, vaultSigner, userSource, userDestination, owner),
+		data,
+	), nil
+}
+
+func GetAmmAuthority(programID solana.PublicKey) (solana.PublicKey, uint8, error) {
+	return solana.FindProgramAddress([][]byte{{97, 109, 109, 32, 97, 117, 116, 104, 111, 114, 105, 116, 121}}, programID)
+}
+
 func GetAssociatedAuthority(programID solana.PublicKey, marketID solana.PublicKey) (solana.PublicKey, uint8, error) {
 	seeds := [][]byte{marketID.Bytes()}
 	var nonce uint8 = 0
@@ -346,71 +504,6 @@ func int8ToBuf(value uint8) []byte {
 	return buf.Bytes()
 }
 
-func getOrCreateTokenAccountInstruction(client *rpc.Client, tokenMintPubKey solana.PublicKey, ownerPrivateKey solana.PrivateKey, amountSpecified uint64, input bool) (solana.PublicKey, []solana.Instruction, error) {
-	var res []solana.Instruction
-	owner := ownerPrivateKey.PublicKey()
-
-	if tokenMintPubKey.Equals(WSOL) {
-		accountLamport, err := client.GetMinimumBalanceForRentExemption(context.Background(), dataSize, rpc.CommitmentConfirmed)
-		if err != nil {
-			return solana.PublicKey{}, res, err
-		}
-		if input {
-			accountLamport += amountSpecified
-		}
-		seed := solana.NewWallet().PublicKey().String()[0:32]
-		publicKey, err := solana.CreateWithSeed(owner, seed, token.ProgramID)
-		if err != nil {
-			return solana.PublicKey{}, res, err
-		}
-
-		createInst, err := system.NewCreateAccountWithSeedInstruction(
-			owner,
-			seed,
-			accountLamport,
-			dataSize,
-			token.ProgramID,
-			owner,
-			publicKey,
-			owner,
-		).ValidateAndBuild()
-
-		if err != nil {
-			return solana.PublicKey{}, res, err
-		}
-		res = append(res, createInst)
-
-		initInst, err := token.NewInitializeAccountInstruction(
-			publicKey,
-			WSOL,
-			owner,
-			solana.SysVarRentPubkey,
-		).ValidateAndBuild()
-		res = append(res, initInst)
-		return publicKey, res, nil
-	}
-	// Find the associated token account address
-	ata, _, err := solana.FindAssociatedTokenAddress(owner, tokenMintPubKey)
-	if err != nil {
-		return solana.PublicKey{}, nil, fmt.Errorf("failed to find associated token address: %v", err)
-	}
-
-	// Check if the account already exists
-	account, err := client.GetAccountInfo(context.TODO(), ata)
-	if err == nil && account != nil {
-		return ata, nil, nil // Account already exists, so no transaction signature
-	}
-
-	// Create the instruction to create the associated token account
-	createATAIx := associatedtokenaccount.NewCreateInstruction(
-		owner,           // payer
-		owner,           // wallet owner
-		tokenMintPubKey, // token mint
-	).Build()
-	res = append(res, createATAIx)
-	return ata, res, nil
-}
-
 // Fees 对应 Rust 中的 Fees
 type Fees struct {
 	MinSeparateNumerator   uint64 `bin:""`
@@ -477,13 +570,18 @@ type AmmInfo struct {
 	Padding2           uint64           `bin:""`
 }
 
-func GetPoolState(client *rpc.Client, pool solana.PublicKey) (AmmInfo, error) {
+func GetPoolState(ctx context.Context, client *rpc.Client, pool solana.PublicKey, commitment Commitment) (AmmInfo, error) {
+	ammInfo, _, err := getPoolState(ctx, client, pool, commitment)
+	return ammInfo, err
+}
+
+func getPoolState(ctx context.Context, client *rpc.Client, pool solana.PublicKey, commitment Commitment) (AmmInfo, uint64, error) {
 	var ammInfo AmmInfo
-	err := client.GetAccountDataInto(context.TODO(), pool, &ammInfo)
+	slot, err := getAccountInto(ctx, client, commitment, pool, &ammInfo)
 	if err != nil {
-		return ammInfo, err
+		return ammInfo, 0, err
 	}
-	return ammInfo, nil
+	return ammInfo, slot, nil
 }
 
 type MarketState struct {
@@ -511,9 +609,50 @@ type MarketState struct {
 	PaddingEnd             [7]byte
 }
 
-func GetMarketState(client *rpc.Client, market solana.PublicKey) (MarketState, error) {
+const (
+	// AmmInfo 账户大小及 coin / pc mint 的偏移
+	poolStateSize       = 752
+	coinVaultMintOffset = 400
+	pcVaultMintOffset   = 432
+)
+
+// PoolAccount 为按 mint 查找到的池子
+type PoolAccount struct {
+	Address solana.PublicKey
+	State   AmmInfo
+}
+
+// FindPools returns the pools of programID trading mintA against mintB, in either order.
+func FindPools(ctx context.Context, client *rpc.Client, programID solana.PublicKey, mintA solana.PublicKey, mintB solana.PublicKey) ([]PoolAccount, error) {
+	var res []PoolAccount
+	for _, pair := range [][2]solana.PublicKey{{mintA, mintB}, {mintB, mintA}} {
+		accounts, err := client.GetProgramAccountsWithOpts(ctx, programID, &rpc.GetProgramAccountsOpts{
+			Filters: []rpc.RPCFilter{
+				{DataSize: poolStateSize},
+				{Memcmp: &rpc.RPCFilterMemcmp{Offset: coinVaultMintOffset, Bytes: pair[0].Bytes()}},
+				{Memcmp: &rpc.RPCFilterMemcmp{Offset: pcVaultMintOffset, Bytes: pair[1].Bytes()}},
+			},
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to find pools of %s and %s: %w", pair[0], pair[1], err)
+		}
+		for _, account := range accounts {
+			var state AmmInfo
+			if err := bin.NewBinDecoder(account.Account.Data.GetBinary()).Decode(&state); err != nil {
+				return nil, fmt.Errorf("pool %s: %w", account.Pubkey, err)
+			}
+			res = append(res, PoolAccount{Address: account.Pubkey, State: state})
+		}
+		if mintA.Equals(mintB) {
+			break
+		}
+	}
+	return res, nil
+}
+
+func GetMarketState(ctx context.Context, client *rpc.Client, market solana.PublicKey, commitment Commitment) (MarketState, error) {
 	var state MarketState
-	err := client.GetAccountDataInto(context.Background(), market, &state)
+	_, err := getAccountInto(ctx, client, commitment, market, &state)
 	return state, err
 }
 
@@ -1,13 +1,27 @@
 package amm
 
 import (
+	"bytes"
 	"context"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
 	"log"
+	"log/slog"
 	"raydium-go/config"
+	"raydium-go/internal/rpctest"
+	"raydium-go/jito"
+	"raydium-go/spl"
+	"raydium-go/txn"
 	"strconv"
 	"testing"
+	"time"
 
+	bin "github.com/gagliardetto/binary"
 	"github.com/gagliardetto/solana-go"
+	"github.com/gagliardetto/solana-go/programs/system"
+	"github.com/gagliardetto/solana-go/programs/token"
 	"github.com/gagliardetto/solana-go/rpc"
 )
 
@@ -26,7 +40,7 @@ func TestSwap(t *testing.T) {
 	baseIn := true
 	client := rpc.New(rpcUrl)
 	slippage := float64(0.1)
-	res, err := Swap(client, network, poolAddress, inputToken, amount, baseIn, slippage, privateKey.String())
+	res, err := Swap(context.Background(), client, network, poolAddress, inputToken, amount, baseIn, slippage, privateKey.String())
 	if err != nil {
 		t.Error(err)
 		return
@@ -39,7 +53,7 @@ func TestGetPoolState(t *testing.T) {
 	poolAddress := "A73Z4EHWUaSrvL9AjFc22akNjenho2V2bYVafZNtSC5K"
 	pool, _ := solana.PublicKeyFromBase58(poolAddress)
 	client := rpc.New(rpcUrl)
-	resp, err := GetPoolState(client, pool)
+	resp, err := GetPoolState(context.Background(), client, pool, Commitment{})
 	if err != nil {
 		t.Error(err)
 		return
@@ -66,7 +80,7 @@ func TestGetMarketState(t *testing.T) {
 	marketAddress := "D5iPRhi6sEjbpanrbxGVvxp3voNR5fZ1jtMGWDX2qBbB"
 	market, _ := solana.PublicKeyFromBase58(marketAddress)
 	client := rpc.New(rpcUrl)
-	resp, err := GetMarketState(client, market)
+	resp, err := GetMarketState(context.Background(), client, market, Commitment{})
 	if err != nil {
 		t.Error(err)
 		return
@@ -86,6 +100,76 @@ func TestGetAssociatedAuthority(t *testing.T) {
 	t.Log(nonce)
 }
 
+func TestPoolStateLayout(t *testing.T) {
+	state := AmmInfo{CoinVaultMint: solana.NewWallet().PublicKey(), PcVaultMint: solana.NewWallet().PublicKey()}
+	buf := new(bytes.Buffer)
+	if err := bin.NewBinEncoder(buf).Encode(&state); err != nil {
+		t.Fatal(err)
+	}
+	data := buf.Bytes()
+	if len(data) != poolStateSize {
+		t.Fatalf("AmmInfo size = %d, want %d", len(data), poolStateSize)
+	}
+	if !bytes.Equal(data[coinVaultMintOffset:coinVaultMintOffset+32], state.CoinVaultMint.Bytes()) || !bytes.Equal(data[pcVaultMintOffset:pcVaultMintOffset+32], state.PcVaultMint.Bytes()) {
+		t.Error("mint offsets do not match the layout")
+	}
+}
+
+func TestUnknownNetwork(t *testing.T) {
+	// 未知网络在任何 RPC 请求之前报错
+	client := rpc.New("http://127.0.0.1:0")
+	_, err := BuildSwapTransaction(context.Background(), client, "nowhere", solana.NewWallet().PublicKey().String(), WSOL.String(), 1, true, 0.01, solana.NewWallet().PublicKey(), SwapOptions{})
+	if !errors.Is(err, config.ErrUnknownNetwork) {
+		t.Errorf("expected ErrUnknownNetwork, got %v", err)
+	}
+	if _, err := PoolLookupTableAddresses("nowhere", solana.PublicKey{}, AmmInfo{}, MarketState{}); !errors.Is(err, config.ErrUnknownNetwork) {
+		t.Errorf("expected ErrUnknownNetwork, got %v", err)
+	}
+}
+
+func TestSwapHooks(t *testing.T) {
+	var stages []SwapStage
+	var failure error
+	var logs bytes.Buffer
+	owner := solana.NewWallet().PublicKey()
+	pool := solana.NewWallet().PublicKey()
+	opts := SwapOptions{
+		Logger: slog.New(slog.NewJSONHandler(&logs, nil)),
+		Hooks: []SwapHook{func(ctx context.Context, event SwapEvent) {
+			stages = append(stages, event.Stage)
+			if !event.Pool.Equals(pool) || !event.Owner.Equals(owner) {
+				t.Errorf("unexpected event %+v", event)
+			}
+			failure = event.Err
+		}},
+	}
+	_, err := BuildSwapTransaction(context.Background(), rpc.New("http://127.0.0.1:0"), "nowhere", pool.String(), WSOL.String(), 1, true, 0.01, owner, opts)
+	if !errors.Is(err, config.ErrUnknownNetwork) || failure != err {
+		t.Errorf("expected ErrUnknownNetwork, got %v and %v", err, failure)
+	}
+	if len(stages) != 2 || stages[0] != StageStarted || stages[1] != StageFailed {
+		t.Errorf("stages = %v", stages)
+	}
+	// 只有失败达到默认的 info 级别
+	var entry map[string]interface{}
+	if err := json.Unmarshal(logs.Bytes(), &entry); err != nil {
+		t.Fatalf("expected one log entry: %v", err)
+	}
+	if entry["msg"] != "swap failed" || entry["pool"] != pool.String() || entry["error"] != err.Error() {
+		t.Errorf("unexpected log %v", entry)
+	}
+}
+
+func TestCanceledContext(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	client := rpc.New(rpc.MainNetBeta_RPC)
+	_, err := BuildSwapTransaction(ctx, client, "mainnet", solana.NewWallet().PublicKey().String(), WSOL.String(), 1, true, 0.01, solana.NewWallet().PublicKey(), SwapOptions{})
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("expected context.Canceled, got %v", err)
+	}
+}
+
 func TestParseRayLog(t *testing.T) {
 	msg := "ray_log: A0BCDwAAAAAAS8elcVACAAABAAAAAAAAAEBCDwAAAAAAziWk9e+IKAK1TovGDAAAAHDdYkWSAgAA"
 	resp, err := ParseRayLog(msg)
@@ -95,3 +179,761 @@ func TestParseRayLog(t *testing.T) {
 	}
 	t.Log(resp)
 }
+
+func TestQuoteSwap(t *testing.T) {
+	poolState := AmmInfo{
+		Fees:          Fees{SwapFeeNumerator: 25, SwapFeeDenominator: 10000},
+		CoinVaultMint: solana.NewWallet().PublicKey(),
+		PcVaultMint:   solana.NewWallet().PublicKey(),
+	}
+	coin := spl.Mint{Address: poolState.CoinVaultMint, Program: solana.TokenProgramID}
+	pc := spl.Mint{Address: poolState.PcVaultMint, Program: solana.TokenProgramID}
+	quote, err := QuoteSwap(poolState, 1000000000, 2000000000, coin, pc, 0, 1000000, true, 0.01)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if quote.Direction != Coin2PC || quote.AmountOut != 1993011 || quote.OtherAmountThreshold != 1973080 {
+		t.Errorf("unexpected base in quote: %+v", quote)
+	}
+	quote, err = QuoteSwap(poolState, 1000000000, 2000000000, pc, coin, 0, 1000000, false, 0.01)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if quote.Direction != PC2Coin || quote.AmountOut != 1000000 || quote.AmountIn != 2007021 {
+		t.Errorf("unexpected base out quote: %+v", quote)
+	}
+	if out, _ := ComputeAmountOut(poolState.Fees, 1000000000, 2000000000, quote.AmountIn); out < quote.AmountOut {
+		t.Errorf("base out amount in %d only yields %d", quote.AmountIn, out)
+	}
+}
+
+func TestQuoteSwapTransferFee(t *testing.T) {
+	poolState := AmmInfo{
+		Fees:          Fees{SwapFeeNumerator: 25, SwapFeeDenominator: 10000},
+		CoinVaultMint: solana.NewWallet().PublicKey(),
+		PcVaultMint:   solana.NewWallet().PublicKey(),
+	}
+	fee := &spl.TransferFeeConfig{NewerTransferFee: spl.TransferFee{MaximumFee: 1 << 40, TransferFeeBasisPoints: 100}}
+	coin := spl.Mint{Address: poolState.CoinVaultMint, Program: solana.Token2022ProgramID, TransferFeeConfig: fee}
+	pc := spl.Mint{Address: poolState.PcVaultMint, Program: solana.TokenProgramID}
+	quote, err := QuoteSwap(poolState, 1000000000, 2000000000, coin, pc, 0, 1000000, true, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected, _ := ComputeAmountOut(poolState.Fees, 1000000000, 2000000000, 990000)
+	if quote.InputTransferFee != 10000 || quote.AmountOut != expected {
+		t.Errorf("unexpected quote: %+v", quote)
+	}
+	quote, err = QuoteSwap(poolState, 1000000000, 2000000000, pc, coin, 0, 990000, false, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if quote.ReceivedAmount() != 990000 || quote.AmountOut != 1000000 {
+		t.Errorf("unexpected quote: %+v", quote)
+	}
+}
+
+func TestRequiredLamports(t *testing.T) {
+	required := RequiredLamports(1, 68000, 100, 2039280, 1000000)
+	if required != 5000+7+2039280+1000000 {
+		t.Errorf("required = %d", required)
+	}
+	var err error = &InsufficientFundsError{Required: required, Available: 1}
+	if !errors.Is(err, ErrInsufficientFunds) {
+		t.Error("expected ErrInsufficientFunds")
+	}
+}
+
+var testOutputMint = solana.MustPublicKeyFromBase58("4zMMC9srt5Ri5X14GAgXhaHii3GnPAEERYPJgZJDncDU")
+
+// testSwapTransaction 构建 owner 从 WSOL ATA 换到 testOutputMint ATA 的 swap，extra 在 swap 之前
+func testSwapTransaction(t *testing.T, owner solana.PublicKey, pool solana.PublicKey, data []byte, extra ...solana.Instruction) *solana.Transaction {
+	source, _, _ := spl.FindAssociatedTokenAddress(owner, WSOL, solana.TokenProgramID)
+	destination, _, _ := spl.FindAssociatedTokenAddress(owner, testOutputMint, solana.TokenProgramID)
+	return testSwapTransactionFrom(t, owner, pool, source, destination, data, computeUnitLimit, priorityFee, extra...)
+}
+
+func testSwapTransactionFrom(t *testing.T, owner solana.PublicKey, pool solana.PublicKey, source solana.PublicKey, destination solana.PublicKey, data []byte, limit uint32, price uint64, extra ...solana.Instruction) *solana.Transaction {
+	programID := config.Raydium_AMM_Program["devnet"]
+	accounts := make([]solana.PublicKey, 18)
+	for i := range accounts {
+		accounts[i] = solana.NewWallet().PublicKey()
+	}
+	swap := solana.NewInstruction(
+		programID,
+		swapAccountsFrom(solana.TokenProgramID, pool, accounts[2], accounts[3], accounts[4], accounts[5], accounts[6], accounts[7], accounts[8], accounts[9], accounts[10], accounts[11], accounts[12], accounts[13], accounts[14], source, destination, owner),
+		data,
+	)
+	tx, err := solana.NewTransaction(swapInstructionsFrom(limit, price, append(extra, swap)), solana.Hash{}, solana.TransactionPayer(owner))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return tx
+}
+
+// newPoolServer 应答 owner 持有的 pool 的状态和 token 账户的免租金额
+func newPoolServer(t *testing.T, pool solana.PublicKey, owner solana.PublicKey, poolState AmmInfo) *rpctest.Server {
+	var buf bytes.Buffer
+	if err := bin.NewBinEncoder(&buf).Encode(poolState); err != nil {
+		t.Fatal(err)
+	}
+	return rpctest.NewServer(t, func(method string, params []json.RawMessage) interface{} {
+		switch method {
+		case "getAccountInfo":
+			return map[string]interface{}{
+				"context": map[string]interface{}{"slot": 1},
+				"value": map[string]interface{}{
+					"data":       []string{base64.StdEncoding.EncodeToString(buf.Bytes()), "base64"},
+					"owner":      owner.String(),
+					"lamports":   1,
+					"executable": false,
+					"rentEpoch":  0,
+				},
+			}
+		case "getMinimumBalanceForRentExemption":
+			return 2039280
+		}
+		t.Errorf("unexpected method %s", method)
+		return nil
+	})
+}
+
+func TestDecodeSwapInstructions(t *testing.T) {
+	owner, pool := solana.NewWallet().PublicKey(), solana.NewWallet().PublicKey()
+	data, _ := baseOutDataFrom(2007021, 1000000)
+	tx := testSwapTransaction(t, owner, pool, data)
+	swaps, err := DecodeSwapInstructions(tx, config.Raydium_AMM_Program["devnet"])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(swaps) != 1 {
+		t.Fatalf("decoded %d swaps", len(swaps))
+	}
+	swap := swaps[0]
+	if swap.BaseIn || swap.AmountIn != 2007021 || swap.AmountOut != 1000000 || !swap.Pool.Equals(pool) || !swap.UserOwner.Equals(owner) {
+		t.Errorf("unexpected swap: %+v", swap)
+	}
+}
+
+func TestSwapEnvelope(t *testing.T) {
+	owner := solana.NewWallet()
+	pool := solana.NewWallet().PublicKey()
+	data, _ := baseInDataFrom(1000000, 1973080)
+	tx := testSwapTransaction(t, owner.PublicKey(), pool, data)
+	meta := SwapMetadata{Network: "devnet", Pool: pool, Owner: owner.PublicKey(), InputMint: WSOL, OutputMint: testOutputMint, BaseIn: true, AmountIn: 1000000, AmountOut: 1973080, ExpiresAt: time.Now().Add(time.Minute)}
+	client := rpc.New(newPoolServer(t, pool, config.Raydium_AMM_Program["devnet"], AmmInfo{CoinVaultMint: testOutputMint, PcVaultMint: WSOL}).URL)
+
+	for _, encoding := range []string{EncodingBase64, EncodingBase58} {
+		envelope, err := NewSwapEnvelope(tx, meta, encoding)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := envelope.Sign(owner.PrivateKey); err != nil {
+			t.Fatal(err)
+		}
+		raw, err := envelope.Marshal()
+		if err != nil {
+			t.Fatal(err)
+		}
+		imported, decoded, err := ImportSwapEnvelope(context.Background(), client, raw, VerifyOptions{Network: "devnet"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if imported.Metadata.AmountOut != meta.AmountOut || len(txn.MissingSigners(decoded)) != 0 {
+			t.Errorf("unexpected import: %+v", imported.Metadata)
+		}
+	}
+
+	strict := meta
+	strict.AmountOut++
+	if err := VerifySwapTransaction(context.Background(), client, tx, strict, VerifyOptions{Network: "devnet"}); !errors.Is(err, ErrSwapMismatch) {
+		t.Errorf("expected ErrSwapMismatch, got %v", err)
+	}
+	expired := meta
+	expired.ExpiresAt = time.Now().Add(-time.Minute)
+	if err := VerifySwapTransaction(context.Background(), client, tx, expired, VerifyOptions{Network: "devnet"}); !errors.Is(err, ErrSwapExpired) {
+		t.Errorf("expected ErrSwapExpired, got %v", err)
+	}
+}
+
+func TestVerifySwapTransaction(t *testing.T) {
+	owner := solana.NewWallet().PublicKey()
+	relayer := solana.NewWallet().PublicKey()
+	pool := solana.NewWallet().PublicKey()
+	data, _ := baseInDataFrom(1000000, 1973080)
+	meta := SwapMetadata{Network: "devnet", Pool: pool, Owner: owner, InputMint: WSOL, OutputMint: testOutputMint, BaseIn: true, AmountIn: 1000000, AmountOut: 1973080}
+	client := rpc.New(newPoolServer(t, pool, config.Raydium_AMM_Program["devnet"], AmmInfo{CoinVaultMint: testOutputMint, PcVaultMint: WSOL}).URL)
+	source, _, _ := spl.FindAssociatedTokenAddress(owner, WSOL, solana.TokenProgramID)
+	destination, _, _ := spl.FindAssociatedTokenAddress(owner, testOutputMint, solana.TokenProgramID)
+	build := func(inst solana.Instruction, _ error) solana.Instruction {
+		if inst == nil {
+			t.Fatal("failed to build instruction")
+		}
+		return inst
+	}
+	createATA := func(wallet solana.PublicKey, mint solana.PublicKey) solana.Instruction {
+		return build(spl.NewCreateAssociatedTokenAccountInstruction(relayer, wallet, mint, solana.TokenProgramID))
+	}
+	// 临时 WSOL 账户：relayer 付租金，owner 转入输入
+	seedAccount, _ := solana.CreateWithSeed(owner, "seed", solana.TokenProgramID)
+	createSeed := func(base solana.PublicKey, funder solana.PublicKey, lamports uint64) solana.Instruction {
+		account, _ := solana.CreateWithSeed(base, "seed", solana.TokenProgramID)
+		return build(system.NewCreateAccountWithSeedInstruction(base, "seed", lamports, dataSize, solana.TokenProgramID, funder, account, base).ValidateAndBuild())
+	}
+	initSeed := build(token.NewInitializeAccountInstruction(seedAccount, WSOL, owner, solana.SysVarRentPubkey).ValidateAndBuild())
+	wrap := build(system.NewTransferInstruction(1000000, owner, seedAccount).ValidateAndBuild())
+	refund := func(lamports uint64) solana.Instruction {
+		return build(system.NewTransferInstruction(lamports, owner, relayer).ValidateAndBuild())
+	}
+	nonce := build(system.NewAdvanceNonceAccountInstruction(solana.NewWallet().PublicKey(), solana.SysVarRecentBlockHashesPubkey, owner).ValidateAndBuild())
+
+	valid := []*solana.Transaction{
+		testSwapTransaction(t, owner, pool, data, createATA(owner, testOutputMint)),
+		testSwapTransactionFrom(t, owner, pool, seedAccount, destination, data, computeUnitLimit, priorityFee, createSeed(owner, relayer, 2039280), initSeed, wrap),
+		testSwapTransactionFrom(t, owner, pool, seedAccount, destination, data, computeUnitLimit, priorityFee, createSeed(owner, owner, 2039280+1000000), initSeed),
+		testSwapTransactionFrom(t, owner, pool, seedAccount, destination, data, computeUnitLimit, priorityFee, createSeed(owner, relayer, 2039280), initSeed, wrap, refund(2039280)),
+	}
+	for i, tx := range valid {
+		if err := VerifySwapTransaction(context.Background(), client, tx, meta, VerifyOptions{Network: "devnet"}); err != nil {
+			t.Errorf("valid transaction %d: %v", i, err)
+		}
+	}
+
+	otherDestination, _, _ := spl.FindAssociatedTokenAddress(relayer, testOutputMint, solana.TokenProgramID)
+	foreignMint := solana.NewWallet().PublicKey()
+	flipped := meta
+	flipped.InputMint, flipped.OutputMint = testOutputMint, WSOL
+	foreignPool := meta
+	foreignPool.OutputMint = foreignMint
+	cases := map[string]struct {
+		tx   *solana.Transaction
+		meta SwapMetadata
+	}{
+		"foreign transfer":       {testSwapTransaction(t, owner, pool, data, build(system.NewTransferInstruction(1, owner, relayer).ValidateAndBuild())), meta},
+		"redirected destination": {testSwapTransactionFrom(t, owner, pool, source, otherDestination, data, computeUnitLimit, priorityFee), meta},
+		"flipped direction":      {testSwapTransaction(t, owner, pool, data), flipped},
+		"mints not in pool":      {testSwapTransaction(t, owner, pool, data), foreignPool},
+		"ata for another wallet": {testSwapTransaction(t, owner, pool, data, createATA(relayer, testOutputMint)), meta},
+		"ata for another mint":   {testSwapTransaction(t, owner, pool, data, createATA(owner, foreignMint)), meta},
+		"non-idempotent ata":     {testSwapTransaction(t, owner, pool, data, solana.NewInstruction(solana.SPLAssociatedTokenAccountProgramID, createATA(owner, testOutputMint).Accounts(), []byte{2})), meta},
+		"seed from relayer":      {testSwapTransaction(t, owner, pool, data, createSeed(relayer, relayer, 2039280)), meta},
+		"seed overfunded":        {testSwapTransaction(t, owner, pool, data, createSeed(owner, owner, 2039280+1000001)), meta},
+		"sponsored seed funding": {testSwapTransaction(t, owner, pool, data, createSeed(owner, relayer, 2039280+1)), meta},
+		"refund above rent":      {testSwapTransactionFrom(t, owner, pool, seedAccount, destination, data, computeUnitLimit, priorityFee, createSeed(owner, relayer, 2039280), initSeed, refund(2039281)), meta},
+		"refund without rent":    {testSwapTransaction(t, owner, pool, data, refund(2039280)), meta},
+		"late nonce advance":     {testSwapTransaction(t, owner, pool, data, nonce), meta},
+		"compute unit price":     {testSwapTransactionFrom(t, owner, pool, source, destination, data, computeUnitLimit, 100001), meta},
+		"compute unit limit":     {testSwapTransactionFrom(t, owner, pool, source, destination, data, 400001, priorityFee), meta},
+	}
+	for name, c := range cases {
+		if err := VerifySwapTransaction(context.Background(), client, c.tx, c.meta, VerifyOptions{Network: "devnet"}); !errors.Is(err, ErrSwapMismatch) {
+			t.Errorf("%s: expected ErrSwapMismatch, got %v", name, err)
+		}
+	}
+	// 预期的程序和网络由验证方决定
+	tx := testSwapTransaction(t, owner, pool, data)
+	foreignProgram := meta
+	foreignProgram.ProgramID = solana.NewWallet().PublicKey()
+	foreignNetwork := meta
+	foreignNetwork.Network = "mainnet"
+	for name, meta := range map[string]SwapMetadata{"foreign program": foreignProgram, "foreign network": foreignNetwork} {
+		if err := VerifySwapTransaction(context.Background(), client, tx, meta, VerifyOptions{Network: "devnet"}); !errors.Is(err, ErrSwapMismatch) {
+			t.Errorf("%s: expected ErrSwapMismatch, got %v", name, err)
+		}
+	}
+	if err := VerifySwapTransaction(context.Background(), client, tx, meta, VerifyOptions{}); err == nil {
+		t.Error("expected an error without the expected network")
+	}
+	forged := rpc.New(newPoolServer(t, pool, solana.NewWallet().PublicKey(), AmmInfo{CoinVaultMint: testOutputMint, PcVaultMint: WSOL}).URL)
+	if err := VerifySwapTransaction(context.Background(), forged, tx, meta, VerifyOptions{Network: "devnet"}); !errors.Is(err, ErrSwapMismatch) {
+		t.Errorf("forged pool: expected ErrSwapMismatch, got %v", err)
+	}
+	tx = testSwapTransactionFrom(t, owner, pool, source, destination, data, computeUnitLimit, 100001)
+	if err := VerifySwapTransaction(context.Background(), client, tx, meta, VerifyOptions{Network: "devnet", MaxComputeUnitPrice: 200000}); err != nil {
+		t.Errorf("raised price cap: %v", err)
+	}
+}
+
+// tokenAccount 返回余额为 amount 的 token 账户
+func tokenAccount(amount uint64) interface{} {
+	data := make([]byte, 165)
+	binary.LittleEndian.PutUint64(data[64:72], amount)
+	return map[string]interface{}{
+		"data":       []string{base64.StdEncoding.EncodeToString(data), "base64"},
+		"owner":      solana.TokenProgramID.String(),
+		"lamports":   2039280,
+		"executable": false,
+		"rentEpoch":  0,
+	}
+}
+
+func TestPoolReservesCommitment(t *testing.T) {
+	server := rpctest.NewServer(t, func(method string, params []json.RawMessage) interface{} {
+		var config struct {
+			Commitment     string `json:"commitment"`
+			MinContextSlot uint64 `json:"minContextSlot"`
+		}
+		if method != "getMultipleAccounts" || len(params) != 2 || json.Unmarshal(params[1], &config) != nil {
+			t.Errorf("unexpected request %s %s", method, params)
+			return nil
+		}
+		if config.Commitment != "processed" || config.MinContextSlot != 42 {
+			t.Errorf("unexpected config %+v", config)
+		}
+		return map[string]interface{}{
+			"context": map[string]interface{}{"slot": 42},
+			"value":   []interface{}{tokenAccount(1000), tokenAccount(2000)},
+		}
+	})
+	poolState := AmmInfo{CoinVault: solana.NewWallet().PublicKey(), PcVault: solana.NewWallet().PublicKey()}
+	poolState.StateData.NeedTakePnlPc = 500
+	coin, pc, err := GetPoolReserves(context.Background(), rpc.New(server.URL), poolState, Commitment{State: rpc.CommitmentProcessed, MinContextSlot: 42})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if coin != 1000 || pc != 1500 {
+		t.Errorf("reserves = %d, %d", coin, pc)
+	}
+	if c := (Commitment{MinContextSlot: 50}).atLeast(42); c.MinContextSlot != 50 || c.state() != rpc.CommitmentConfirmed || c.blockhash() != rpc.CommitmentFinalized {
+		t.Errorf("unexpected commitment %+v", c)
+	}
+}
+
+func TestBlockhashMinContextSlot(t *testing.T) {
+	// finalized 落后 confirmed 32 个 slot
+	slots := rpctest.Slots{"processed": 101, "confirmed": 100, "finalized": 68}
+	var forwarded bool
+	server := rpctest.NewServer(t, func(method string, params []json.RawMessage) interface{} {
+		if err := slots.Check(params); err != nil {
+			return err
+		}
+		ctx := map[string]interface{}{"slot": 100}
+		switch method {
+		case "getLatestBlockhash":
+			forwarded = bytes.Contains(params[0], []byte("minContextSlot"))
+			return map[string]interface{}{"context": ctx, "value": map[string]interface{}{
+				"blockhash":            solana.Hash{1}.String(),
+				"lastValidBlockHeight": 150,
+			}}
+		case "getBalance":
+			return map[string]interface{}{"context": ctx, "value": 1}
+		}
+		t.Errorf("unexpected method %s", method)
+		return nil
+	})
+	client := rpc.New(server.URL)
+	// 读取池子状态后 MinContextSlot 为 confirmed 的 slot
+	c := Commitment{}.atLeast(100)
+	if _, err := getBalance(context.Background(), client, c, solana.PublicKey{}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := getLatestBlockhash(context.Background(), client, c); err != nil || forwarded {
+		t.Errorf("finalized blockhash: forwarded %v, %v", forwarded, err)
+	}
+	if _, err := getLatestBlockhash(context.Background(), client, Commitment{Blockhash: rpc.CommitmentConfirmed}.atLeast(100)); err != nil || !forwarded {
+		t.Errorf("confirmed blockhash: forwarded %v, %v", forwarded, err)
+	}
+	if _, err := getBalance(context.Background(), client, Commitment{State: rpc.CommitmentFinalized}.atLeast(100), solana.PublicKey{}); err == nil {
+		t.Error("expected the finalized read at slot 100 to be rejected")
+	}
+}
+
+func TestConfirmTransaction(t *testing.T) {
+	confirmInterval = time.Millisecond
+	var polls int
+	server := rpctest.NewServer(t, func(method string, params []json.RawMessage) interface{} {
+		var status interface{}
+		switch method {
+		case "getSignatureStatuses":
+			polls++
+			switch {
+			case polls == 1:
+			case polls < 4:
+				status = map[string]interface{}{"slot": 10, "confirmationStatus": "processed", "err": nil}
+			default:
+				status = map[string]interface{}{"slot": 10, "confirmationStatus": "confirmed", "err": nil}
+			}
+		case "getBlockHeight":
+			return 5
+		default:
+			t.Errorf("unexpected method %s", method)
+		}
+		return map[string]interface{}{"context": map[string]interface{}{"slot": 10}, "value": []interface{}{status}}
+	})
+	client := rpc.New(server.URL)
+	status, err := ConfirmTransaction(context.Background(), client, solana.Signature{}, rpc.CommitmentConfirmed, 100)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if status.ConfirmationStatus != rpc.ConfirmationStatusConfirmed || polls != 4 {
+		t.Errorf("status %s after %d polls", status.ConfirmationStatus, polls)
+	}
+
+	polls = 0
+	if _, err := ConfirmTransaction(context.Background(), client, solana.Signature{}, rpc.CommitmentConfirmed, 1); !errors.Is(err, ErrBlockhashExpired) {
+		t.Errorf("expected ErrBlockhashExpired, got %v", err)
+	}
+
+	failed := rpctest.NewServer(t, func(method string, params []json.RawMessage) interface{} {
+		status := map[string]interface{}{"slot": 10, "confirmationStatus": "processed", "err": map[string]interface{}{"InstructionError": []interface{}{3, map[string]interface{}{"Custom": 30}}}}
+		return map[string]interface{}{"context": map[string]interface{}{"slot": 10}, "value": []interface{}{status}}
+	})
+	if _, err := ConfirmTransaction(context.Background(), rpc.New(failed.URL), solana.Signature{}, rpc.CommitmentFinalized, 0); !errors.Is(err, ErrSwapFailed) {
+		t.Errorf("expected ErrSwapFailed, got %v", err)
+	}
+}
+
+func TestGetSwapResult(t *testing.T) {
+	server := rpctest.NewServer(t, func(method string, params []json.RawMessage) interface{} {
+		if method != "getTransaction" {
+			t.Errorf("unexpected method %s", method)
+		}
+		return map[string]interface{}{
+			"slot": 10,
+			"meta": map[string]interface{}{
+				"err":                  nil,
+				"fee":                  6000,
+				"computeUnitsConsumed": 45000,
+				"logMessages": []string{
+					"Program 675kPX9MHTjS2zt1qfr1NYHuzeLXfQM9H24wFSUt1Mp8 invoke [1]",
+					"Program log: ray_log: A0BCDwAAAAAAS8elcVACAAABAAAAAAAAAEBCDwAAAAAAziWk9e+IKAK1TovGDAAAAHDdYkWSAgAA",
+				},
+			},
+		}
+	})
+	res, err := GetSwapResult(context.Background(), rpc.New(server.URL), solana.Signature{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Fee != 6000 || res.ComputeUnitsConsumed != 45000 || res.AmountIn != 1000000 || res.AmountOut == 0 {
+		t.Errorf("unexpected result: %+v", res)
+	}
+	e := SwapEvent{BaseIn: true, Quote: SwapQuote{SwapAmounts: spl.SwapAmounts{AmountOut: res.AmountOut * 2}}, Result: res}
+	if slippage, ok := e.RealizedSlippage(); !ok || slippage != 0.5 {
+		t.Errorf("slippage = %v, %v", slippage, ok)
+	}
+}
+
+func TestSponsoredWSOLRent(t *testing.T) {
+	server := rpctest.NewServer(t, func(method string, params []json.RawMessage) interface{} {
+		switch method {
+		case "getMinimumBalanceForRentExemption":
+			return 2039280
+		case "simulateTransaction":
+			// 模拟交易的 fee payer 必须是 plan.FeePayer
+			var encoded string
+			json.Unmarshal(params[0], &encoded)
+			tx, err := solana.TransactionFromBase64(encoded)
+			if err != nil {
+				t.Error(err)
+				return nil
+			}
+			return map[string]interface{}{"context": map[string]interface{}{"slot": 1}, "value": map[string]interface{}{"err": nil, "unitsConsumed": 1000 + int(tx.Message.AccountKeys[0][0])}}
+		}
+		t.Errorf("unexpected method %s", method)
+		return nil
+	})
+	client := rpc.New(server.URL)
+	owner, payer := solana.NewWallet().PublicKey(), solana.NewWallet().PublicKey()
+
+	res, err := spl.TokenAccountInstructions(context.Background(), client, "", owner, payer, spl.Mint{Address: WSOL, Program: solana.TokenProgramID}, 0, WSOLTemporaryAccount)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(res.Cleanup) != 2 || !res.Cleanup[1].ProgramID().Equals(solana.SystemProgramID) {
+		t.Fatalf("expected close and refund, got %d cleanup instructions", len(res.Cleanup))
+	}
+	refund, err := system.DecodeInstruction(res.Cleanup[1].Accounts(), mustData(t, res.Cleanup[1]))
+	if err != nil {
+		t.Fatal(err)
+	}
+	transfer := refund.Impl.(*system.Transfer)
+	if *transfer.Lamports != 2039280 || !transfer.GetFundingAccount().PublicKey.Equals(owner) || !transfer.GetRecipientAccount().PublicKey.Equals(payer) {
+		t.Errorf("unexpected refund %d from %s to %s", *transfer.Lamports, transfer.GetFundingAccount().PublicKey, transfer.GetRecipientAccount().PublicKey)
+	}
+	if res, err := spl.TokenAccountInstructions(context.Background(), client, "", owner, owner, spl.Mint{Address: WSOL, Program: solana.TokenProgramID}, 0, WSOLTemporaryAccount); err != nil || len(res.Cleanup) != 1 {
+		t.Errorf("unsponsored cleanup = %d, %v", len(res.Cleanup), err)
+	}
+
+	opts := SwapOptions{ComputeUnitEstimator: &txn.ComputeUnitEstimator{Margin: 1}}
+	plan := &swapPlan{Owner: owner, FeePayer: payer, Instructions: res.Setup}
+	limit, err := opts.computeUnitLimit(context.Background(), client, plan, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if limit != 1000+uint32(payer[0]) {
+		t.Errorf("simulated with the wrong fee payer: limit %d", limit)
+	}
+}
+
+func TestCheckSwapBalances(t *testing.T) {
+	owner, sponsor := solana.NewWallet().PublicKey(), solana.NewWallet().PublicKey()
+	token := spl.Mint{Address: solana.NewWallet().PublicKey(), Program: solana.TokenProgramID}
+	wsol := spl.Mint{Address: WSOL, Program: solana.TokenProgramID}
+	inputAccount := spl.AccountInstructions{Address: solana.NewWallet().PublicKey()}
+	tests := []struct {
+		name     string
+		plan     swapPlan
+		lamports map[solana.PublicKey]uint64
+		tokens   uint64
+		want     *InsufficientFundsError
+	}{
+		{
+			name:     "enough",
+			plan:     swapPlan{Owner: owner, FeePayer: owner, RentPayer: owner, InputMint: token, MaxAmountIn: 1000, InputAccount: inputAccount, OutputAccount: spl.AccountInstructions{Rent: 2039280}},
+			lamports: map[solana.PublicKey]uint64{owner: 2044280},
+			tokens:   1000,
+		},
+		{
+			name:     "sol",
+			plan:     swapPlan{Owner: owner, FeePayer: owner, RentPayer: owner, InputMint: token, MaxAmountIn: 1000, InputAccount: inputAccount, OutputAccount: spl.AccountInstructions{Rent: 2039280}},
+			lamports: map[solana.PublicKey]uint64{owner: 2044279},
+			tokens:   1000,
+			want:     &InsufficientFundsError{Account: owner, Required: 2044280, Available: 2044279},
+		},
+		{
+			name:     "input token",
+			plan:     swapPlan{Owner: owner, FeePayer: owner, RentPayer: owner, InputMint: token, MaxAmountIn: 1000, InputAccount: inputAccount},
+			lamports: map[solana.PublicKey]uint64{owner: 1000000},
+			tokens:   900,
+			want:     &InsufficientFundsError{Account: owner, Mint: token.Address, Required: 1000, Available: 900},
+		},
+		{
+			name:     "missing input account",
+			plan:     swapPlan{Owner: owner, FeePayer: owner, RentPayer: owner, InputMint: token, MaxAmountIn: 1000, InputAccount: spl.AccountInstructions{Address: inputAccount.Address, Rent: 2039280}},
+			lamports: map[solana.PublicKey]uint64{owner: 10000000},
+			want:     &InsufficientFundsError{Account: owner, Mint: token.Address, Required: 1000, Available: 0},
+		},
+		{
+			name:     "sponsored fee payer",
+			plan:     swapPlan{Owner: owner, FeePayer: sponsor, RentPayer: sponsor, InputMint: wsol, MaxAmountIn: 1000000, InputAccount: spl.AccountInstructions{Rent: 2039280, Wrapped: 1000000}},
+			lamports: map[solana.PublicKey]uint64{owner: 1000000, sponsor: 2049279},
+			// 两个签名的手续费和租金由 sponsor 支付
+			want: &InsufficientFundsError{Account: sponsor, Required: 2049280, Available: 2049279},
+		},
+		{
+			name:     "sponsored owner wrap",
+			plan:     swapPlan{Owner: owner, FeePayer: sponsor, RentPayer: sponsor, InputMint: wsol, MaxAmountIn: 1000000, InputAccount: spl.AccountInstructions{Rent: 2039280, Wrapped: 1000000}},
+			lamports: map[solana.PublicKey]uint64{owner: 999999, sponsor: 2049280},
+			want:     &InsufficientFundsError{Account: owner, Required: 1000000, Available: 999999},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := rpctest.NewServer(t, func(method string, params []json.RawMessage) interface{} {
+				switch method {
+				case "getBalance":
+					var account solana.PublicKey
+					json.Unmarshal(params[0], &account)
+					lamports, ok := tt.lamports[account]
+					if !ok {
+						t.Errorf("unexpected balance request for %s", account)
+					}
+					return map[string]interface{}{"context": map[string]interface{}{"slot": 1}, "value": lamports}
+				case "getMultipleAccounts":
+					return map[string]interface{}{"context": map[string]interface{}{"slot": 1}, "value": []interface{}{tokenAccount(tt.tokens)}}
+				}
+				t.Errorf("unexpected method %s", method)
+				return nil
+			})
+			b, err := checkSwapBalances(context.Background(), rpc.New(server.URL), &tt.plan, nil)
+			if tt.want == nil {
+				if err != nil {
+					t.Fatal(err)
+				}
+				return
+			}
+			var shortfall *InsufficientFundsError
+			if !errors.Is(err, ErrInsufficientFunds) || !errors.As(err, &shortfall) {
+				t.Fatalf("err = %v, want ErrInsufficientFunds", err)
+			}
+			if *shortfall != *tt.want {
+				t.Errorf("shortfall = %+v, want %+v", *shortfall, *tt.want)
+			}
+			if b != nil {
+				t.Errorf("balances returned with an error")
+			}
+		})
+	}
+}
+
+func TestCheckFees(t *testing.T) {
+	owner, sponsor := solana.NewWallet().PublicKey(), solana.NewWallet().PublicKey()
+	plan := &swapPlan{Owner: owner, FeePayer: sponsor, RentPayer: sponsor, InputMint: spl.Mint{Address: WSOL}, InputAccount: spl.AccountInstructions{Rent: 2039280, Wrapped: 1000000}}
+	b := &swapBalances{plan: plan, signatures: 2, lamports: map[solana.PublicKey]uint64{owner: 1000000, sponsor: 2149280}}
+	if err := b.checkFees(100000, 1000000); err != nil {
+		t.Errorf("fees within balance: %v", err)
+	}
+	// 200000 compute units 每个 1 lamport 的优先费超出 sponsor 余额
+	err := b.checkFees(200000, 1000000)
+	var shortfall *InsufficientFundsError
+	if !errors.Is(err, ErrInsufficientFunds) || !errors.As(err, &shortfall) {
+		t.Fatalf("err = %v, want ErrInsufficientFunds", err)
+	}
+	if want := (InsufficientFundsError{Account: sponsor, Required: 2249280, Available: 2149280}); *shortfall != want {
+		t.Errorf("shortfall = %+v, want %+v", *shortfall, want)
+	}
+}
+
+func TestProfileOptions(t *testing.T) {
+	profile := config.Profile{Name: "test", Network: "devnet", Fees: config.FeePolicy{ComputeUnitMargin: 1.2}}
+	first, second := ProfileOptions(profile), ProfileOptions(profile)
+	if first.ComputeUnitEstimator == nil || first.ComputeUnitEstimator != second.ComputeUnitEstimator {
+		t.Error("profile options should share one compute unit estimator")
+	}
+	profile.Fees.ComputeUnitMargin = 1.5
+	if ProfileOptions(profile).ComputeUnitEstimator == first.ComputeUnitEstimator {
+		t.Error("a different margin needs its own estimator")
+	}
+}
+
+func TestSendBundleFallback(t *testing.T) {
+	signer := solana.NewWallet().PrivateKey
+	owner := signer.PublicKey()
+	tip := jito.Tip{Lamports: 10000, Account: jito.TipAccounts[0], Timeout: time.Millisecond, PollInterval: time.Millisecond, FallbackToRPC: true}
+	tests := []struct {
+		name   string
+		bundle interface{}
+		landed bool
+		nonce  bool
+		err    error
+	}{
+		{name: "resend"},
+		{name: "landed", landed: true},
+		{name: "nonce", nonce: true},
+		{name: "bundle failed", bundle: map[string]interface{}{
+			"bundle_id": "bundle-1", "slot": 5, "confirmation_status": "confirmed",
+			"err": map[string]interface{}{"Err": "InstructionError"},
+		}, err: ErrSwapFailed},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			engine := rpctest.NewServer(t, func(method string, params []json.RawMessage) interface{} {
+				switch method {
+				case "sendBundle":
+					return "bundle-1"
+				case "getBundleStatuses":
+					return map[string]interface{}{"context": map[string]interface{}{"slot": 1}, "value": []interface{}{test.bundle}}
+				}
+				t.Errorf("unexpected block engine method %s", method)
+				return nil
+			})
+			plan := &swapPlan{
+				Owner:            owner,
+				FeePayer:         owner,
+				Instructions:     []solana.Instruction{system.NewTransferInstruction(1, owner, solana.NewWallet().PublicKey()).Build(), tip.Instruction(owner)},
+				TipLamports:      tip.Lamports,
+				ComputeUnitLimit: 200000,
+				PriorityFee:      1,
+				Blockhash:        solana.Hash(solana.NewWallet().PublicKey()),
+			}
+			if test.nonce {
+				plan.Nonce = &txn.DurableNonce{Account: solana.NewWallet().PublicKey(), Authority: owner}
+			}
+			tx, err := plan.transaction()
+			if err != nil {
+				t.Fatal(err)
+			}
+			var sent *solana.Transaction
+			server := rpctest.NewServer(t, func(method string, params []json.RawMessage) interface{} {
+				switch method {
+				case "getSignatureStatuses":
+					var status interface{}
+					if test.landed {
+						status = map[string]interface{}{"slot": 5, "confirmations": nil, "err": nil, "confirmationStatus": "confirmed"}
+					}
+					return map[string]interface{}{"context": map[string]interface{}{"slot": 5}, "value": []interface{}{status}}
+				case "sendTransaction":
+					var encoded string
+					json.Unmarshal(params[0], &encoded)
+					if sent, err = solana.TransactionFromBase64(encoded); err != nil {
+						t.Error(err)
+						return nil
+					}
+					return sent.Signatures[0].String()
+				case "getTransaction":
+					// 结果读取失败只记录日志
+					return nil
+				}
+				t.Errorf("unexpected method %s", method)
+				return nil
+			})
+			var sentEvents int
+			hook := func(ctx context.Context, event SwapEvent) {
+				if event.Stage == StageSent {
+					sentEvents++
+				}
+			}
+			obs := newSwapObserver(context.Background(), SwapOptions{Hooks: []SwapHook{hook}}, "devnet", "", owner)
+			signature, err := sendBundle(context.Background(), rpc.New(server.URL), jito.NewClient(engine.URL), tx, plan, signer, tip, obs)
+			if test.err != nil {
+				if !errors.Is(err, test.err) || sent != nil {
+					t.Errorf("expected %v without resending, got %v", test.err, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if sentEvents != 1 {
+				t.Errorf("StageSent emitted %d times", sentEvents)
+			}
+			if test.landed {
+				// bundle 的交易已上链，不再通过 RPC 发送
+				if sent != nil || signature != tx.Signatures[0].String() {
+					t.Errorf("landed bundle was sent again as %s", signature)
+				}
+				return
+			}
+			if sent == nil || signature != sent.Signatures[0].String() {
+				t.Fatalf("fallback signature %s, sent %v", signature, sent)
+			}
+			if !test.nonce {
+				// 重发同一笔交易
+				if signature != tx.Signatures[0].String() {
+					t.Errorf("fallback signature %s, bundle signature %s", signature, tx.Signatures[0])
+				}
+				return
+			}
+			if err := sent.VerifySignatures(); err != nil {
+				t.Error(err)
+			}
+			for _, key := range sent.Message.AccountKeys {
+				if key.Equals(tip.Account) {
+					t.Error("fallback transaction still pays the tip")
+				}
+			}
+			if len(sent.Message.Instructions) != 4 || sent.Message.RecentBlockhash != plan.Blockhash {
+				t.Errorf("fallback has %d instructions and blockhash %s", len(sent.Message.Instructions), sent.Message.RecentBlockhash)
+			}
+		})
+	}
+}
+
+func mustData(t *testing.T, inst solana.Instruction) []byte {
+	data, err := inst.Data()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+func TestComputeUnitKey(t *testing.T) {
+	pool := solana.NewWallet().PublicKey()
+	create, _ := spl.NewCreateAssociatedTokenAccountInstruction(pool, pool, WSOL, solana.TokenProgramID)
+	wrap, _ := spl.WrapInstructions(pool, pool, 1)
+	plans := []*swapPlan{
+		{Pool: pool, InstructionType: "swap_base_in"},
+		{Pool: pool, InstructionType: "swap_base_in", OutputAccount: spl.AccountInstructions{Setup: []solana.Instruction{create}, Rent: 1}},
+		{Pool: pool, InstructionType: "swap_base_in", InputAccount: spl.AccountInstructions{Setup: wrap, Wrapped: 1}},
+		{Pool: pool, InstructionType: "swap_base_in", InputAccount: spl.AccountInstructions{Setup: wrap, Wrapped: 1, Cleanup: []solana.Instruction{create}}},
+		{Pool: pool, InstructionType: "swap_base_out"},
+	}
+	keys := map[string]bool{}
+	for _, plan := range plans {
+		keys[plan.computeUnitKey()] = true
+	}
+	if len(keys) != len(plans) {
+		t.Errorf("expected %d distinct keys, got %v", len(plans), keys)
+	}
+}
@@ -0,0 +1,87 @@
+package amm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"raydium-go/jito"
+
+	"github.com/gagliardetto/solana-go"
+	"github.com/gagliardetto/solana-go/rpc"
+)
+
+// SwapWithBundle builds and signs the swap like SwapWithOptions, appends the tip transfer and submits
+// the transaction as a bundle. When the bundle does not land before tip.Timeout and tip.FallbackToRPC
+// is set, the same transaction is sent through client, unless it turns out to have landed meanwhile.
+// With opts.Nonce the swap is signed again without the tip instead, both transactions advance the same
+// nonce so only one of them can execute. A bundle that landed with a failed transaction returns
+// ErrSwapFailed.
+func SwapWithBundle(ctx context.Context, client *rpc.Client, bundleClient *jito.Client, network string, poolAddress string, inputTokenAddress string, amountSpecified uint64, baseIn bool, slippage float64, privateKey string, opts SwapOptions, tip jito.Tip) (signature string, err error) {
+	signer, err := solana.PrivateKeyFromBase58(privateKey)
+	if err != nil {
+		return "", fmt.Errorf("invalid private key: %w", err)
+	}
+	obs := newSwapObserver(ctx, opts, network, poolAddress, signer.PublicKey())
+	defer func() { obs.done(ctx, err) }()
+	tx, plan, err := buildSwapTransaction(ctx, client, network, poolAddress, inputTokenAddress, amountSpecified, baseIn, slippage, signer.PublicKey(), opts, &tip, obs)
+	if err != nil {
+		return "", err
+	}
+	return sendBundle(ctx, client, bundleClient, tx, plan, signer, tip, obs)
+}
+
+func sendBundle(ctx context.Context, client *rpc.Client, bundleClient *jito.Client, tx *solana.Transaction, plan *swapPlan, signer solana.PrivateKey, tip jito.Tip, obs *swapObserver) (string, error) {
+	if err := signTransaction(tx, signer); err != nil {
+		return "", err
+	}
+	obs.emit(ctx, StageSigned, func(e *SwapEvent) {
+		e.Signature = tx.Signatures[0]
+		e.Bundle = true
+	})
+	signature := tx.Signatures[0].String()
+
+	bundleID, err := bundleClient.SendBundle(ctx, tx)
+	if err == nil {
+		obs.sentTo(ctx, tx.Signatures[0], true)
+		var status *jito.BundleStatus
+		status, err = bundleClient.WaitForBundle(ctx, bundleID, tip.Timeout, tip.PollInterval)
+		if err == nil {
+			if status.Failed() {
+				return signature, fmt.Errorf("%w: bundle %s: %s", ErrSwapFailed, bundleID, status.Err)
+			}
+			obs.confirmed(ctx, client, status.Slot, rpc.ConfirmationStatusType(status.ConfirmationStatus))
+			return signature, nil
+		}
+	}
+	if !tip.FallbackToRPC {
+		return signature, err
+	}
+	if !errors.Is(err, jito.ErrBundleNotLanded) {
+		obs.logger.WarnContext(ctx, "bundle submission failed, falling back to rpc", "signature", signature, "error", err)
+	}
+	// bundle 的交易可能在等待结束后才上链，已上链时不再发送
+	statuses, err := client.GetSignatureStatuses(ctx, false, tx.Signatures[0])
+	if err != nil && !errors.Is(err, rpc.ErrNotFound) {
+		return signature, fmt.Errorf("failed to fetch signature status: %w", err)
+	}
+	if err == nil && len(statuses.Value) > 0 && statuses.Value[0] != nil {
+		status := statuses.Value[0]
+		if status.Err != nil {
+			return signature, fmt.Errorf("%w: %v", ErrSwapFailed, status.Err)
+		}
+		obs.confirmed(ctx, client, status.Slot, status.ConfirmationStatus)
+		return signature, nil
+	}
+	// 没有 nonce 时重发同一笔交易，签名相同只会执行一次；有 nonce 时去掉小费重新签名
+	if plan.Nonce != nil {
+		plan = plan.withoutTip()
+		if tx, err = plan.transaction(); err != nil {
+			return signature, err
+		}
+		if err := signTransaction(tx, signer); err != nil {
+			return signature, err
+		}
+	}
+	return sendTransaction(ctx, client, tx, plan, obs)
+}
@@ -0,0 +1,153 @@
+package amm
+
+import (
+	"context"
+	"fmt"
+
+	"raydium-go/spl"
+
+	bin "github.com/gagliardetto/binary"
+	"github.com/gagliardetto/solana-go"
+	"github.com/gagliardetto/solana-go/rpc"
+)
+
+// Commitment 控制读取状态、获取 blockhash 和等待确认使用的 commitment，零值字段使用默认值
+type Commitment struct {
+	// 池子、市场、金库、mint 和用户账户的读取以及发送前的模拟，默认 confirmed
+	State rpc.CommitmentType
+	// 最新 blockhash 的读取，默认 finalized
+	Blockhash rpc.CommitmentType
+	// 设置后发送交易时等待交易达到该 commitment，为空时发送后立即返回
+	Confirmation rpc.CommitmentType
+	// 大于 0 时节点处理到该 slot 之前拒绝读取，保证不会读到比已见过的更旧的状态
+	MinContextSlot uint64
+}
+
+func (c Commitment) state() rpc.CommitmentType {
+	if c.State == "" {
+		return rpc.CommitmentConfirmed
+	}
+	return c.State
+}
+
+func (c Commitment) blockhash() rpc.CommitmentType {
+	if c.Blockhash == "" {
+		return rpc.CommitmentFinalized
+	}
+	return c.Blockhash
+}
+
+// atLeast 返回 MinContextSlot 不低于 slot 的副本
+func (c Commitment) atLeast(slot uint64) Commitment {
+	if slot > c.MinContextSlot {
+		c.MinContextSlot = slot
+	}
+	return c
+}
+
+func (c Commitment) minContextSlot() *uint64 {
+	if c.MinContextSlot == 0 {
+		return nil
+	}
+	slot := c.MinContextSlot
+	return &slot
+}
+
+// config 为 getBalance 等方法的配置参数
+func (c Commitment) config(commitment rpc.CommitmentType) rpc.M {
+	config := rpc.M{"commitment": commitment}
+	if c.MinContextSlot > 0 {
+		config["minContextSlot"] = c.MinContextSlot
+	}
+	return config
+}
+
+// getAccountInto 读取并解码账户，返回读取时的 slot
+func getAccountInto(ctx context.Context, client *rpc.Client, c Commitment, account solana.PublicKey, out interface{}) (uint64, error) {
+	resp, err := client.GetAccountInfoWithOpts(ctx, account, &rpc.GetAccountInfoOpts{
+		Encoding:       solana.EncodingBase64,
+		Commitment:     c.state(),
+		MinContextSlot: c.minContextSlot(),
+	})
+	if err != nil {
+		return 0, err
+	}
+	return resp.Context.Slot, bin.NewBinDecoder(resp.GetBinary()).Decode(out)
+}
+
+// getAccounts 用一次请求读取多个账户，不存在的账户返回 rpc.ErrNotFound
+func getAccounts(ctx context.Context, client *rpc.Client, c Commitment, accounts ...solana.PublicKey) ([]*rpc.Account, error) {
+	resp, err := client.GetMultipleAccountsWithOpts(ctx, accounts, &rpc.GetMultipleAccountsOpts{
+		Encoding:       solana.EncodingBase64,
+		Commitment:     c.state(),
+		MinContextSlot: c.minContextSlot(),
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(resp.Value) != len(accounts) {
+		return nil, fmt.Errorf("expected %d accounts, got %d", len(accounts), len(resp.Value))
+	}
+	for i, account := range resp.Value {
+		if account == nil {
+			return nil, fmt.Errorf("account %s: %w", accounts[i], rpc.ErrNotFound)
+		}
+	}
+	return resp.Value, nil
+}
+
+func getMints(ctx context.Context, client *rpc.Client, c Commitment, mints ...solana.PublicKey) ([]spl.Mint, error) {
+	accounts, err := getAccounts(ctx, client, c, mints...)
+	if err != nil {
+		return nil, err
+	}
+	res := make([]spl.Mint, len(mints))
+	for i, account := range accounts {
+		if res[i], err = spl.DecodeMint(mints[i], account.Owner, account.Data.GetBinary()); err != nil {
+			return nil, err
+		}
+	}
+	return res, nil
+}
+
+// getTokenAmounts 用一次请求读取多个 token 账户的余额
+func getTokenAmounts(ctx context.Context, client *rpc.Client, c Commitment, accounts ...solana.PublicKey) ([]uint64, error) {
+	res, err := getAccounts(ctx, client, c, accounts...)
+	if err != nil {
+		return nil, err
+	}
+	amounts := make([]uint64, len(accounts))
+	for i, account := range res {
+		if amounts[i], err = spl.TokenAccountAmount(account.Data.GetBinary()); err != nil {
+			return nil, fmt.Errorf("%s: %w", accounts[i], err)
+		}
+	}
+	return amounts, nil
+}
+
+func getBalance(ctx context.Context, client *rpc.Client, c Commitment, account solana.PublicKey) (uint64, error) {
+	var out *rpc.GetBalanceResult
+	if err := client.RPCCallForInto(ctx, &out, "getBalance", []interface{}{account, c.config(c.state())}); err != nil {
+		return 0, err
+	}
+	if out == nil {
+		return 0, fmt.Errorf("empty getBalance result")
+	}
+	return out.Value, nil
+}
+
+func getLatestBlockhash(ctx context.Context, client *rpc.Client, c Commitment) (*rpc.LatestBlockhashResult, error) {
+	var out *rpc.GetLatestBlockhashResult
+	config := c.config(c.blockhash())
+	// finalized 落后 confirmed 约 32 个 slot，blockhash 的 commitment 高于状态的时节点会拒绝状态的 MinContextSlot
+	if commitmentRank[string(c.blockhash())] > commitmentRank[string(c.state())] {
+		delete(config, "minContextSlot")
+	}
+	if err := client.RPCCallForInto(ctx, &out, "getLatestBlockhash", []interface{}{config}); err != nil {
+		return nil, err
+	}
+	if out == nil || out.Value == nil {
+		return nil, fmt.Errorf("empty getLatestBlockhash result")
+	}
+	return out.Value, nil
+}
@@ -0,0 +1,132 @@
+package amm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/gagliardetto/solana-go"
+	"github.com/gagliardetto/solana-go/rpc"
+)
+
+var (
+	ErrSwapFailed       = errors.New("swap transaction failed")
+	ErrBlockhashExpired = errors.New("blockhash expired before the transaction was confirmed")
+)
+
+var confirmInterval = 500 * time.Millisecond
+
+// commitment 的先后顺序
+var commitmentRank = map[string]int{
+	string(rpc.CommitmentProcessed): 1,
+	string(rpc.CommitmentConfirmed): 2,
+	string(rpc.CommitmentFinalized): 3,
+}
+
+// ConfirmTransaction polls signature until it reaches commitment and returns its status. The wait
+// stops with ErrBlockhashExpired once the block height passes lastValidBlockHeight; 0 waits until ctx
+// is done, e.g. for durable nonce transactions.
+func ConfirmTransaction(ctx context.Context, client *rpc.Client, signature solana.Signature, commitment rpc.CommitmentType, lastValidBlockHeight uint64) (*rpc.SignatureStatusesResult, error) {
+	ticker := time.NewTicker(confirmInterval)
+	defer ticker.Stop()
+	for {
+		resp, err := client.GetSignatureStatuses(ctx, false, signature)
+		if err != nil && !errors.Is(err, rpc.ErrNotFound) {
+			return nil, fmt.Errorf("failed to fetch signature status: %w", err)
+		}
+		if err == nil && len(resp.Value) > 0 && resp.Value[0] != nil {
+			status := resp.Value[0]
+			if status.Err != nil {
+				return status, fmt.Errorf("%w: %v", ErrSwapFailed, status.Err)
+			}
+			if commitmentRank[string(status.ConfirmationStatus)] >= commitmentRank[string(commitment)] {
+				return status, nil
+			}
+		} else if lastValidBlockHeight > 0 {
+			height, err := client.GetBlockHeight(ctx, rpc.CommitmentConfirmed)
+			if err != nil {
+				return nil, fmt.Errorf("failed to fetch block height: %w", err)
+			}
+			if height > lastValidBlockHeight {
+				return nil, fmt.Errorf("%s: %w", signature, ErrBlockhashExpired)
+			}
+		}
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// sendTransaction 以 plan 的状态 commitment 做发送前的模拟，设置了 Confirmation 时等待确认
+func sendTransaction(ctx context.Context, client *rpc.Client, tx *solana.Transaction, plan *swapPlan, obs *swapObserver) (string, error) {
+	c := plan.Commitment
+	txHash, err := client.SendTransactionWithOpts(ctx, tx, rpc.TransactionOpts{
+		PreflightCommitment: c.state(),
+		MinContextSlot:      c.minContextSlot(),
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to send transaction: %w", err)
+	}
+	obs.sentTo(ctx, txHash, false)
+	if c.Confirmation == "" {
+		return txHash.String(), nil
+	}
+	status, err := ConfirmTransaction(ctx, client, txHash, c.Confirmation, plan.LastValidBlockHeight)
+	if err != nil {
+		return txHash.String(), err
+	}
+	obs.confirmed(ctx, client, status.Slot, status.ConfirmationStatus)
+	return txHash.String(), nil
+}
+
+// SwapResult 为从确认的交易中读取的实际结果
+type SwapResult struct {
+	// 交易手续费（lamports），含优先费
+	Fee                  uint64
+	ComputeUnitsConsumed uint64
+	// 来自 ray_log：swap_base_in 时为输入和实际输出，swap_base_out 时为实际扣除的输入和输出
+	AmountIn  uint64
+	AmountOut uint64
+}
+
+// GetSwapResult reads the fee, compute units consumed and executed amounts of a confirmed swap.
+func GetSwapResult(ctx context.Context, client *rpc.Client, signature solana.Signature) (*SwapResult, error) {
+	maxVersion := uint64(0)
+	tx, err := client.GetTransaction(ctx, signature, &rpc.GetTransactionOpts{
+		Encoding:                       solana.EncodingBase64,
+		Commitment:                     rpc.CommitmentConfirmed,
+		MaxSupportedTransactionVersion: &maxVersion,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch transaction: %w", err)
+	}
+	if tx.Meta == nil {
+		return nil, fmt.Errorf("transaction %s has no meta", signature)
+	}
+	res := &SwapResult{Fee: tx.Meta.Fee}
+	if tx.Meta.ComputeUnitsConsumed != nil {
+		res.ComputeUnitsConsumed = *tx.Meta.ComputeUnitsConsumed
+	}
+	for _, msg := range tx.Meta.LogMessages {
+		if !strings.HasPrefix(msg, "Program log: ray_log: ") {
+			continue
+		}
+		log, err := ParseRayLog(strings.TrimPrefix(msg, "Program log: "))
+		if err != nil {
+			continue
+		}
+		switch log := log.(type) {
+		case SwapBaseInLog:
+			res.AmountIn, res.AmountOut = log.AmountIn, log.OutAmount
+			return res, nil
+		case SwapBaseOutLog:
+			res.AmountIn, res.AmountOut = log.DeductIn, log.AmountOut
+			return res, nil
+		}
+	}
+	return res, nil
+}
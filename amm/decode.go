@@ -0,0 +1,110 @@
+package amm
+
+import (
+	"encoding/binary"
+	"fmt"
+
+	"github.com/gagliardetto/solana-go"
+)
+
+const (
+	instructionSwapBaseIn  = 9
+	instructionSwapBaseOut = 11
+)
+
+// SwapInstruction 为解码后的 swap_base_in / swap_base_out 指令
+type SwapInstruction struct {
+	BaseIn bool
+	// baseIn 时为输入数量，baseOut 时为最大输入
+	AmountIn uint64
+	// baseIn 时为最小输出，baseOut 时为输出数量
+	AmountOut uint64
+
+	TokenProgram    solana.PublicKey
+	Pool            solana.PublicKey
+	AmmAuthority    solana.PublicKey
+	OpenOrders      solana.PublicKey
+	TargetOrders    solana.PublicKey
+	CoinVault       solana.PublicKey
+	PcVault         solana.PublicKey
+	MarketProgram   solana.PublicKey
+	Market          solana.PublicKey
+	UserSource      solana.PublicKey
+	UserDestination solana.PublicKey
+	UserOwner       solana.PublicKey
+}
+
+// DecodeSwapInstruction decodes a swap instruction of the AMM program. Both the 18-account layout built
+// by swapAccountsFrom and the 17-account layout without target orders are accepted.
+func DecodeSwapInstruction(accounts []solana.PublicKey, data []byte) (*SwapInstruction, error) {
+	if len(data) != 17 {
+		return nil, fmt.Errorf("invalid swap instruction data length %d", len(data))
+	}
+	var inst SwapInstruction
+	switch data[0] {
+	case instructionSwapBaseIn:
+		inst.BaseIn = true
+	case instructionSwapBaseOut:
+	default:
+		return nil, fmt.Errorf("not a swap instruction: %d", data[0])
+	}
+	inst.AmountIn = binary.LittleEndian.Uint64(data[1:9])
+	inst.AmountOut = binary.LittleEndian.Uint64(data[9:17])
+
+	switch len(accounts) {
+	case 18:
+		inst.TargetOrders = accounts[4]
+		accounts = append(accounts[:4:4], accounts[5:]...)
+	case 17:
+	default:
+		return nil, fmt.Errorf("invalid swap instruction account count %d", len(accounts))
+	}
+	inst.TokenProgram = accounts[0]
+	inst.Pool = accounts[1]
+	inst.AmmAuthority = accounts[2]
+	inst.OpenOrders = accounts[3]
+	inst.CoinVault = accounts[4]
+	inst.PcVault = accounts[5]
+	inst.MarketProgram = accounts[6]
+	inst.Market = accounts[7]
+	inst.UserSource = accounts[14]
+	inst.UserDestination = accounts[15]
+	inst.UserOwner = accounts[16]
+	return &inst, nil
+}
+
+// DecodeSwapInstructions returns the swap instructions of programID found in tx. Versioned messages
+// with lookups need their address tables set on the message first.
+func DecodeSwapInstructions(tx *solana.Transaction, programID solana.PublicKey) ([]*SwapInstruction, error) {
+	keys, err := tx.Message.GetAllKeys()
+	if err != nil {
+		return nil, err
+	}
+	var res []*SwapInstruction
+	for _, ci := range tx.Message.Instructions {
+		if int(ci.ProgramIDIndex) >= len(keys) || !keys[ci.ProgramIDIndex].Equals(programID) {
+			continue
+		}
+		accounts, err := compiledAccounts(keys, ci)
+		if err != nil {
+			return nil, err
+		}
+		inst, err := DecodeSwapInstruction(accounts, ci.Data)
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, inst)
+	}
+	return res, nil
+}
+
+func compiledAccounts(keys solana.PublicKeySlice, ci solana.CompiledInstruction) ([]solana.PublicKey, error) {
+	accounts := make([]solana.PublicKey, len(ci.Accounts))
+	for i, idx := range ci.Accounts {
+		if int(idx) >= len(keys) {
+			return nil, fmt.Errorf("account index %d out of range", idx)
+		}
+		accounts[i] = keys[idx]
+	}
+	return accounts, nil
+}
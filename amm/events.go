@@ -0,0 +1,216 @@
+package amm
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/gagliardetto/solana-go"
+	"github.com/gagliardetto/solana-go/rpc"
+)
+
+// SwapStage 为 swap 生命周期中的阶段
+type SwapStage string
+
+const (
+	StageStarted       SwapStage = "started"
+	StageStateFetched  SwapStage = "state_fetched"
+	StageQuoteComputed SwapStage = "quote_computed"
+	StageTxBuilt       SwapStage = "tx_built"
+	StageSigned        SwapStage = "signed"
+	StageSent          SwapStage = "sent"
+	StageConfirmed     SwapStage = "confirmed"
+	StageFailed        SwapStage = "failed"
+)
+
+// SwapEvent 描述 swap 到达的阶段，字段在之后的阶段中保留，如 StageSent 的事件也带有报价和费用
+type SwapEvent struct {
+	Stage   SwapStage
+	Network string
+	Pool    solana.PublicKey
+	Owner   solana.PublicKey
+	// StageStateFetched 起为读取池子状态时的 slot，StageConfirmed 时为交易所在的 slot
+	Slot uint64
+	// StageQuoteComputed 起设置
+	InputMint  solana.PublicKey
+	OutputMint solana.PublicKey
+	BaseIn     bool
+	Quote      SwapQuote
+	// StageTxBuilt 起设置，PriorityFee 为 compute unit 价格（micro-lamports）
+	ComputeUnitLimit uint32
+	PriorityFee      uint64
+	TipLamports      uint64
+	// StageSigned 起设置
+	Signature solana.Signature
+	// 通过 bundle 提交时为 true
+	Bundle bool
+	// StageConfirmed 时为达到的 commitment
+	ConfirmationStatus rpc.ConfirmationStatusType
+	// StageConfirmed 时为交易的实际结果，没有 Logger 和 hooks 或读取失败时为 nil
+	Result *SwapResult
+	// StageFailed 时为失败的原因
+	Err error
+	// 距 StageStarted 的时间
+	Elapsed time.Duration
+}
+
+// PriorityFeeLamports returns the priority fee charged for the compute unit limit and price.
+func (e SwapEvent) PriorityFeeLamports() uint64 {
+	return priorityFeeLamports(e.ComputeUnitLimit, e.PriorityFee)
+}
+
+// RealizedSlippage returns how much worse than quoted the swap executed, as a fraction: the output
+// shortfall of swap_base_in or the extra input of swap_base_out. Negative values are price improvement.
+func (e SwapEvent) RealizedSlippage() (float64, bool) {
+	if e.Result == nil {
+		return 0, false
+	}
+	if e.BaseIn {
+		if e.Quote.AmountOut == 0 {
+			return 0, false
+		}
+		return (float64(e.Quote.AmountOut) - float64(e.Result.AmountOut)) / float64(e.Quote.AmountOut), true
+	}
+	// ray_log 中的输入不含转账手续费
+	quoted := e.Quote.AmountIn - e.Quote.InputTransferFee
+	if quoted == 0 {
+		return 0, false
+	}
+	return (float64(e.Result.AmountIn) - float64(quoted)) / float64(quoted), true
+}
+
+// SwapHook is called synchronously at every stage of a swap, so it should return quickly.
+type SwapHook func(ctx context.Context, event SwapEvent)
+
+var discardLogger = slog.New(slog.DiscardHandler)
+
+// swapObserver 记录一笔 swap 的事件，写入日志并通知 hooks
+type swapObserver struct {
+	logger *slog.Logger
+	hooks  []SwapHook
+	start  time.Time
+	event  SwapEvent
+	// 已发出 StageSent
+	sent bool
+}
+
+func newSwapObserver(ctx context.Context, opts SwapOptions, network string, poolAddress string, owner solana.PublicKey) *swapObserver {
+	o := &swapObserver{logger: opts.Logger, hooks: opts.Hooks, start: time.Now()}
+	if o.logger == nil {
+		o.logger = discardLogger
+	}
+	o.event.Network = network
+	o.event.Owner = owner
+	// 地址无效时在构建时报错
+	o.event.Pool, _ = solana.PublicKeyFromBase58(poolAddress)
+	o.emit(ctx, StageStarted, nil)
+	return o
+}
+
+// emit 先用 update 更新事件再通知
+func (o *swapObserver) emit(ctx context.Context, stage SwapStage, update func(*SwapEvent)) {
+	if update != nil {
+		update(&o.event)
+	}
+	o.event.Stage = stage
+	o.event.Elapsed = time.Since(o.start)
+	level := slog.LevelDebug
+	switch stage {
+	case StageSent, StageConfirmed:
+		level = slog.LevelInfo
+	case StageFailed:
+		level = slog.LevelError
+	}
+	if o.logger.Enabled(ctx, level) {
+		o.logger.LogAttrs(ctx, level, "swap "+string(stage), o.event.attrs()...)
+	}
+	for _, hook := range o.hooks {
+		hook(ctx, o.event)
+	}
+}
+
+// confirmed 发出 StageConfirmed，有 Logger 或 hooks 时先读取交易的实际结果
+func (o *swapObserver) confirmed(ctx context.Context, client *rpc.Client, slot uint64, status rpc.ConfirmationStatusType) {
+	var result *SwapResult
+	if len(o.hooks) > 0 || o.logger != discardLogger {
+		var err error
+		if result, err = GetSwapResult(ctx, client, o.event.Signature); err != nil {
+			o.logger.WarnContext(ctx, "failed to read swap result", "signature", o.event.Signature.String(), "error", err)
+		}
+	}
+	o.emit(ctx, StageConfirmed, func(e *SwapEvent) {
+		e.Slot = slot
+		e.ConfirmationStatus = status
+		e.Result = result
+	})
+}
+
+// sentTo 记录发送的交易并发出 StageSent，bundle 回退到 RPC 时只更新签名，每笔 swap 只发出一次
+func (o *swapObserver) sentTo(ctx context.Context, signature solana.Signature, bundle bool) {
+	o.event.Signature = signature
+	o.event.Bundle = bundle
+	if o.sent {
+		return
+	}
+	o.sent = true
+	o.emit(ctx, StageSent, nil)
+}
+
+// done 在 err 不为空时发出 StageFailed
+func (o *swapObserver) done(ctx context.Context, err error) {
+	if err != nil {
+		o.emit(ctx, StageFailed, func(e *SwapEvent) { e.Err = err })
+	}
+}
+
+func (e SwapEvent) attrs() []slog.Attr {
+	attrs := []slog.Attr{
+		slog.String("network", e.Network),
+		slog.String("pool", e.Pool.String()),
+		slog.String("owner", e.Owner.String()),
+		slog.Duration("elapsed", e.Elapsed),
+	}
+	if e.Slot != 0 {
+		attrs = append(attrs, slog.Uint64("slot", e.Slot))
+	}
+	if !e.InputMint.IsZero() {
+		attrs = append(attrs,
+			slog.String("input_mint", e.InputMint.String()),
+			slog.String("output_mint", e.OutputMint.String()),
+			slog.Bool("base_in", e.BaseIn),
+			slog.Uint64("amount_in", e.Quote.AmountIn),
+			slog.Uint64("amount_out", e.Quote.AmountOut),
+			slog.Uint64("other_amount_threshold", e.Quote.OtherAmountThreshold),
+		)
+	}
+	if e.ComputeUnitLimit != 0 {
+		attrs = append(attrs,
+			slog.Uint64("compute_unit_limit", uint64(e.ComputeUnitLimit)),
+			slog.Uint64("priority_fee", e.PriorityFee),
+		)
+	}
+	if e.TipLamports != 0 {
+		attrs = append(attrs, slog.Uint64("tip_lamports", e.TipLamports))
+	}
+	if !e.Signature.IsZero() {
+		attrs = append(attrs, slog.String("signature", e.Signature.String()))
+	}
+	if e.Bundle {
+		attrs = append(attrs, slog.Bool("bundle", true))
+	}
+	if e.ConfirmationStatus != "" {
+		attrs = append(attrs, slog.String("confirmation_status", string(e.ConfirmationStatus)))
+	}
+	if e.Result != nil {
+		attrs = append(attrs,
+			slog.Uint64("fee", e.Result.Fee),
+			slog.Uint64("compute_units_consumed", e.Result.ComputeUnitsConsumed),
+			slog.Uint64("executed_amount_in", e.Result.AmountIn),
+			slog.Uint64("executed_amount_out", e.Result.AmountOut),
+		)
+	}
+	if e.Err != nil {
+		attrs = append(attrs, slog.String("error", e.Err.Error()))
+	}
+	return attrs
+}
@@ -0,0 +1,510 @@
+package amm
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"raydium-go/config"
+	"raydium-go/jito"
+	"raydium-go/spl"
+	"raydium-go/txn"
+
+	bin "github.com/gagliardetto/binary"
+	"github.com/gagliardetto/solana-go"
+	"github.com/gagliardetto/solana-go/rpc"
+	"github.com/mr-tron/base58"
+)
+
+const (
+	EncodingBase64 = "base64"
+	EncodingBase58 = "base58"
+)
+
+var (
+	ErrSwapExpired  = errors.New("swap transaction expired")
+	ErrSwapMismatch = errors.New("swap transaction does not match metadata")
+)
+
+// SwapMetadata 描述交给其他签名方的 swap 的预期内容
+type SwapMetadata struct {
+	Network string `json:"network"`
+	// 使用覆盖的程序 ID 构建时设置
+	ProgramID  solana.PublicKey `json:"programId,omitempty"`
+	Pool       solana.PublicKey `json:"pool"`
+	Owner      solana.PublicKey `json:"owner"`
+	InputMint  solana.PublicKey `json:"inputMint"`
+	OutputMint solana.PublicKey `json:"outputMint"`
+	BaseIn     bool             `json:"baseIn"`
+	// baseIn 时为输入数量，baseOut 时为最大输入
+	AmountIn uint64 `json:"amountIn,string"`
+	// baseIn 时为最小输出，baseOut 时为输出数量
+	AmountOut uint64    `json:"amountOut,string"`
+	ExpiresAt time.Time `json:"expiresAt"`
+}
+
+// SwapEnvelope carries a possibly partially signed swap in wire format together with its metadata.
+type SwapEnvelope struct {
+	Encoding    string       `json:"encoding"`
+	Transaction string       `json:"transaction"`
+	Metadata    SwapMetadata `json:"metadata"`
+}
+
+// BuildSwapEnvelope builds a swap like BuildSwapTransaction and wraps it for hand-off. The envelope
+// expires after ttl; a zero ttl means it never expires.
+func BuildSwapEnvelope(ctx context.Context, client *rpc.Client, network string, poolAddress string, inputTokenAddress string, amountSpecified uint64, baseIn bool, slippage float64, owner solana.PublicKey, opts SwapOptions, ttl time.Duration) (envelope *SwapEnvelope, err error) {
+	obs := newSwapObserver(ctx, opts, network, poolAddress, owner)
+	defer func() { obs.done(ctx, err) }()
+	tx, plan, err := buildSwapTransaction(ctx, client, network, poolAddress, inputTokenAddress, amountSpecified, baseIn, slippage, owner, opts, nil, obs)
+	if err != nil {
+		return nil, err
+	}
+	meta := SwapMetadata{
+		Network:    network,
+		ProgramID:  opts.ProgramID,
+		Pool:       plan.Pool,
+		Owner:      owner,
+		InputMint:  plan.InputMint.Address,
+		OutputMint: plan.OutputMint.Address,
+		BaseIn:     baseIn,
+		AmountIn:   plan.MaxAmountIn,
+		AmountOut:  plan.Quote.OtherAmountThreshold,
+	}
+	if !baseIn {
+		meta.AmountOut = plan.Quote.AmountOut
+	}
+	if ttl > 0 {
+		meta.ExpiresAt = time.Now().Add(ttl).UTC()
+	}
+	return NewSwapEnvelope(tx, meta, EncodingBase64)
+}
+
+func NewSwapEnvelope(tx *solana.Transaction, meta SwapMetadata, encoding string) (*SwapEnvelope, error) {
+	envelope := &SwapEnvelope{Encoding: encoding, Metadata: meta}
+	if err := envelope.SetTransaction(tx); err != nil {
+		return nil, err
+	}
+	return envelope, nil
+}
+
+// SetTransaction replaces the wire bytes of the envelope, e.g. after adding a signature.
+func (e *SwapEnvelope) SetTransaction(tx *solana.Transaction) error {
+	data, err := tx.MarshalBinary()
+	if err != nil {
+		return err
+	}
+	switch e.Encoding {
+	case EncodingBase64:
+		e.Transaction = base64.StdEncoding.EncodeToString(data)
+	case EncodingBase58:
+		e.Transaction = base58.Encode(data)
+	default:
+		return fmt.Errorf("unsupported encoding %q", e.Encoding)
+	}
+	return nil
+}
+
+func (e *SwapEnvelope) DecodeTransaction() (*solana.Transaction, error) {
+	var data []byte
+	var err error
+	switch e.Encoding {
+	case EncodingBase64:
+		data, err = base64.StdEncoding.DecodeString(e.Transaction)
+	case EncodingBase58:
+		data, err = base58.Decode(e.Transaction)
+	default:
+		return nil, fmt.Errorf("unsupported encoding %q", e.Encoding)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode transaction: %w", err)
+	}
+	return solana.TransactionFromBytes(data)
+}
+
+// Sign adds the signatures of signers to the enveloped transaction.
+func (e *SwapEnvelope) Sign(signers ...solana.PrivateKey) error {
+	tx, err := e.DecodeTransaction()
+	if err != nil {
+		return err
+	}
+	if err := txn.PartialSign(tx, signers...); err != nil {
+		return err
+	}
+	return e.SetTransaction(tx)
+}
+
+func (e *SwapEnvelope) Marshal() ([]byte, error) {
+	return json.Marshal(e)
+}
+
+// ImportSwapEnvelope parses an envelope received from another signer and verifies it with
+// VerifySwapTransaction before it is co-signed.
+func ImportSwapEnvelope(ctx context.Context, client *rpc.Client, data []byte, opts VerifyOptions) (*SwapEnvelope, *solana.Transaction, error) {
+	var envelope SwapEnvelope
+	if err := json.Unmarshal(data, &envelope); err != nil {
+		return nil, nil, fmt.Errorf("invalid swap envelope: %w", err)
+	}
+	tx, err := envelope.DecodeTransaction()
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := VerifySwapTransaction(ctx, client, tx, envelope.Metadata, opts); err != nil {
+		return nil, nil, err
+	}
+	return &envelope, tx, nil
+}
+
+// VerifyOptions 为验证方信任的网络和程序，以及 VerifySwapTransaction 接受的费用上限，零值的上限使用默认值
+type VerifyOptions struct {
+	// 必须设置 Network 或 ProgramID，信封中的 network 和 programId 只用于比对
+	Network string
+	// 覆盖 Network 的 AMM 程序 ID
+	ProgramID solana.PublicKey
+	// 默认 400000
+	MaxComputeUnitLimit uint32
+	// compute unit 价格上限（micro-lamports），默认 100000
+	MaxComputeUnitPrice uint64
+	// bundle 小费上限，默认 1000000
+	MaxTipLamports uint64
+}
+
+// programID 返回验证方预期的 AMM 程序
+func (o VerifyOptions) programID() (solana.PublicKey, error) {
+	if !o.ProgramID.IsZero() {
+		return o.ProgramID, nil
+	}
+	if o.Network == "" {
+		return solana.PublicKey{}, fmt.Errorf("verify options must set the network or program id")
+	}
+	return config.AMMProgram(o.Network)
+}
+
+func (o VerifyOptions) withDefaults() VerifyOptions {
+	if o.MaxComputeUnitLimit == 0 {
+		o.MaxComputeUnitLimit = 400000
+	}
+	if o.MaxComputeUnitPrice == 0 {
+		o.MaxComputeUnitPrice = 100000
+	}
+	if o.MaxTipLamports == 0 {
+		o.MaxTipLamports = 1000000
+	}
+	return o
+}
+
+// VerifySwapTransaction checks that tx contains exactly one swap of the AMM program given by opts on
+// meta.Pool within the bounds of meta, that the pool is owned by that program, that the swap goes from
+// and to the owner's accounts for the pool's mints, that its other instructions only set up the owner's
+// token accounts and pay fees within opts, and that the signatures already present are valid. Envelopes
+// built for another network or program are rejected. client reads the pool state and resolves lookup
+// tables.
+func VerifySwapTransaction(ctx context.Context, client *rpc.Client, tx *solana.Transaction, meta SwapMetadata, opts VerifyOptions) error {
+	if !meta.ExpiresAt.IsZero() && time.Now().After(meta.ExpiresAt) {
+		return ErrSwapExpired
+	}
+	if err := verifyPresentSignatures(tx); err != nil {
+		return err
+	}
+	if client == nil {
+		return fmt.Errorf("rpc client required to verify the pool state")
+	}
+	if tx.Message.IsVersioned() && len(tx.Message.GetAddressTableLookups()) > 0 {
+		tables, err := txn.GetLookupTables(ctx, client, tx.Message.GetAddressTableLookups().GetTableIDs())
+		if err != nil {
+			return err
+		}
+		if err := tx.Message.SetAddressTables(tables); err != nil {
+			return err
+		}
+	}
+	programID, err := opts.programID()
+	if err != nil {
+		return err
+	}
+	if !meta.ProgramID.IsZero() && !meta.ProgramID.Equals(programID) {
+		return fmt.Errorf("%w: program %s", ErrSwapMismatch, meta.ProgramID)
+	}
+	if opts.ProgramID.IsZero() && meta.Network != opts.Network {
+		return fmt.Errorf("%w: network %q", ErrSwapMismatch, meta.Network)
+	}
+	swaps, err := DecodeSwapInstructions(tx, programID)
+	if err != nil {
+		return err
+	}
+	if len(swaps) != 1 {
+		return fmt.Errorf("%w: expected one swap instruction, found %d", ErrSwapMismatch, len(swaps))
+	}
+	swap := swaps[0]
+	switch {
+	case !swap.Pool.Equals(meta.Pool):
+		return fmt.Errorf("%w: pool %s", ErrSwapMismatch, swap.Pool)
+	case !swap.UserOwner.Equals(meta.Owner):
+		return fmt.Errorf("%w: owner %s", ErrSwapMismatch, swap.UserOwner)
+	case swap.BaseIn != meta.BaseIn:
+		return fmt.Errorf("%w: swap direction", ErrSwapMismatch)
+	case meta.BaseIn && (swap.AmountIn != meta.AmountIn || swap.AmountOut < meta.AmountOut):
+		return fmt.Errorf("%w: amount in %d, min amount out %d", ErrSwapMismatch, swap.AmountIn, swap.AmountOut)
+	case !meta.BaseIn && (swap.AmountIn > meta.AmountIn || swap.AmountOut != meta.AmountOut):
+		return fmt.Errorf("%w: max amount in %d, amount out %d", ErrSwapMismatch, swap.AmountIn, swap.AmountOut)
+	}
+	resp, err := client.GetAccountInfoWithOpts(ctx, meta.Pool, &rpc.GetAccountInfoOpts{Encoding: solana.EncodingBase64, Commitment: Commitment{}.state()})
+	if err != nil {
+		return fmt.Errorf("failed to fetch pool state: %w", err)
+	}
+	// 其他程序的账户可以伪造成相同的布局
+	if !resp.Value.Owner.Equals(programID) {
+		return fmt.Errorf("%w: pool %s is owned by %s", ErrSwapMismatch, meta.Pool, resp.Value.Owner)
+	}
+	var poolState AmmInfo
+	if err := bin.NewBinDecoder(resp.GetBinary()).Decode(&poolState); err != nil {
+		return fmt.Errorf("failed to decode pool state: %w", err)
+	}
+	coin, pc := poolState.CoinVaultMint, poolState.PcVaultMint
+	if !(meta.InputMint.Equals(coin) && meta.OutputMint.Equals(pc)) && !(meta.InputMint.Equals(pc) && meta.OutputMint.Equals(coin)) {
+		return fmt.Errorf("%w: mints %s -> %s are not the pool's", ErrSwapMismatch, meta.InputMint, meta.OutputMint)
+	}
+	rent, err := client.GetMinimumBalanceForRentExemption(ctx, dataSize, rpc.CommitmentConfirmed)
+	if err != nil {
+		return err
+	}
+	v := &swapVerifier{
+		meta:      meta,
+		opts:      opts.withDefaults(),
+		swap:      swap,
+		feePayer:  tx.Message.AccountKeys[0],
+		rent:      rent,
+		created:   make(map[solana.PublicKey]bool),
+		wsol:      make(map[solana.PublicKey]bool),
+		sponsored: make(map[solana.PublicKey]uint64),
+	}
+	if err := v.verifyInstructions(ctx, client, tx, programID); err != nil {
+		return err
+	}
+	// 程序按 UserSource 的 mint 决定方向，输入输出账户都必须是 owner 对应 mint 的账户
+	if !v.ownerAccount(swap.UserSource, meta.InputMint) {
+		return fmt.Errorf("%w: source %s is not the owner's %s account", ErrSwapMismatch, swap.UserSource, meta.InputMint)
+	}
+	if !v.ownerAccount(swap.UserDestination, meta.OutputMint) {
+		return fmt.Errorf("%w: destination %s is not the owner's %s account", ErrSwapMismatch, swap.UserDestination, meta.OutputMint)
+	}
+	return nil
+}
+
+// verifyPresentSignatures verifies the signatures already added and ignores the empty slots.
+func verifyPresentSignatures(tx *solana.Transaction) error {
+	message, err := tx.Message.MarshalBinary()
+	if err != nil {
+		return err
+	}
+	signers := tx.Message.Signers()
+	for i, sig := range tx.Signatures {
+		if sig.IsZero() {
+			continue
+		}
+		if i >= len(signers) || !sig.Verify(signers[i], message) {
+			return fmt.Errorf("invalid signature at index %d", i)
+		}
+	}
+	return nil
+}
+
+// swapVerifier 检查 swap 以外的指令
+type swapVerifier struct {
+	meta     SwapMetadata
+	opts     VerifyOptions
+	swap     *SwapInstruction
+	feePayer solana.PublicKey
+	// token 账户的免租金额
+	rent uint64
+	// 本交易中以 owner 为 base 创建的账户，初始化为 owner 的 WSOL 账户后记入 wsol
+	created map[solana.PublicKey]bool
+	wsol    map[solana.PublicKey]bool
+	// 其他 payer 在本交易中为 owner 预付的租金，owner 关闭账户后可以转回
+	sponsored map[solana.PublicKey]uint64
+}
+
+// ownerAccount 判断 account 是否为 owner 持有 mint 的 ATA 或本交易创建的临时 WSOL 账户
+func (v *swapVerifier) ownerAccount(account solana.PublicKey, mint solana.PublicKey) bool {
+	ata, _, err := spl.FindAssociatedTokenAddress(v.meta.Owner, mint, v.swap.TokenProgram)
+	if err == nil && ata.Equals(account) {
+		return true
+	}
+	return mint.Equals(WSOL) && v.wsol[account]
+}
+
+func (v *swapVerifier) verifyInstructions(ctx context.Context, client *rpc.Client, tx *solana.Transaction, programID solana.PublicKey) error {
+	keys, err := tx.Message.GetAllKeys()
+	if err != nil {
+		return err
+	}
+	for i, ci := range tx.Message.Instructions {
+		program := keys[ci.ProgramIDIndex]
+		accounts, err := compiledAccounts(keys, ci)
+		if err != nil {
+			return err
+		}
+		if len(ci.Data) == 0 {
+			return fmt.Errorf("%w: instruction %d has no data", ErrSwapMismatch, i)
+		}
+		switch {
+		case program.Equals(programID):
+		case program.Equals(solana.ComputeBudget):
+			err = v.verifyComputeBudgetInstruction(ci.Data)
+		case program.Equals(solana.SPLAssociatedTokenAccountProgramID):
+			err = v.verifyAssociatedTokenInstruction(ctx, client, accounts, ci.Data)
+		case program.Equals(solana.SystemProgramID):
+			err = v.verifySystemInstruction(i, accounts, ci.Data)
+		case program.Equals(solana.TokenProgramID), program.Equals(solana.Token2022ProgramID):
+			err = v.verifyTokenInstruction(accounts, ci.Data)
+		default:
+			err = fmt.Errorf("%w: unexpected program %s", ErrSwapMismatch, program)
+		}
+		if err != nil {
+			return fmt.Errorf("instruction %d: %w", i, err)
+		}
+	}
+	return nil
+}
+
+func (v *swapVerifier) verifyComputeBudgetInstruction(data []byte) error {
+	switch {
+	case data[0] == 2 && len(data) == 5:
+		// SetComputeUnitLimit
+		if limit := binary.LittleEndian.Uint32(data[1:]); limit > v.opts.MaxComputeUnitLimit {
+			return fmt.Errorf("%w: compute unit limit %d above %d", ErrSwapMismatch, limit, v.opts.MaxComputeUnitLimit)
+		}
+		return nil
+	case data[0] == 3 && len(data) == 9:
+		// SetComputeUnitPrice
+		if price := binary.LittleEndian.Uint64(data[1:]); price > v.opts.MaxComputeUnitPrice {
+			return fmt.Errorf("%w: compute unit price %d above %d", ErrSwapMismatch, price, v.opts.MaxComputeUnitPrice)
+		}
+		return nil
+	}
+	return fmt.Errorf("%w: unexpected compute budget instruction %d", ErrSwapMismatch, data[0])
+}
+
+// verifyAssociatedTokenInstruction 只接受为 owner 创建输入或输出 mint 的 ATA 的 CreateIdempotent
+func (v *swapVerifier) verifyAssociatedTokenInstruction(ctx context.Context, client *rpc.Client, accounts []solana.PublicKey, data []byte) error {
+	if len(data) != 1 || data[0] != 1 || len(accounts) < 6 {
+		return fmt.Errorf("%w: unexpected associated token instruction", ErrSwapMismatch)
+	}
+	payer, ata, wallet, mint, tokenProgram := accounts[0], accounts[1], accounts[2], accounts[3], accounts[5]
+	if !wallet.Equals(v.meta.Owner) || (!mint.Equals(v.meta.InputMint) && !mint.Equals(v.meta.OutputMint)) {
+		return fmt.Errorf("%w: associated token account of %s for %s", ErrSwapMismatch, wallet, mint)
+	}
+	if expected, _, err := spl.FindAssociatedTokenAddress(wallet, mint, tokenProgram); err != nil || !expected.Equals(ata) {
+		return fmt.Errorf("%w: associated token account %s", ErrSwapMismatch, ata)
+	}
+	// 其他 payer 新建的 WSOL ATA 会在交易末尾关闭，租金可以退回；已存在的账户不收租金
+	if mint.Equals(WSOL) && !payer.Equals(wallet) {
+		_, err := getAccounts(ctx, client, Commitment{}, ata)
+		switch {
+		case errors.Is(err, rpc.ErrNotFound):
+			v.sponsored[payer] += v.rent
+		case err != nil:
+			return err
+		}
+	}
+	return nil
+}
+
+func (v *swapVerifier) verifySystemInstruction(index int, accounts []solana.PublicKey, data []byte) error {
+	if len(data) < 4 {
+		return ErrSwapMismatch
+	}
+	owner := v.meta.Owner
+	switch binary.LittleEndian.Uint32(data[:4]) {
+	case 2:
+		// Transfer: wrap SOL into the swap source, or a bundle tip from the fee payer
+		if len(accounts) < 2 || len(data) != 12 {
+			return ErrSwapMismatch
+		}
+		from, to, lamports := accounts[0], accounts[1], binary.LittleEndian.Uint64(data[4:])
+		if from.Equals(owner) && to.Equals(v.swap.UserSource) && lamports <= v.meta.AmountIn {
+			return nil
+		}
+		// 退回 payer 预付的租金
+		if from.Equals(owner) && lamports <= v.sponsored[to] {
+			v.sponsored[to] -= lamports
+			return nil
+		}
+		if from.Equals(v.feePayer) && lamports <= v.opts.MaxTipLamports {
+			for _, tip := range jito.TipAccounts {
+				if to.Equals(tip) {
+					return nil
+				}
+			}
+		}
+		return fmt.Errorf("%w: transfer of %d lamports from %s to %s", ErrSwapMismatch, lamports, from, to)
+	case 3:
+		return v.verifyCreateAccountWithSeed(accounts, data)
+	case 4:
+		// AdvanceNonceAccount 只能是第一条指令
+		if index == 0 {
+			return nil
+		}
+		return fmt.Errorf("%w: nonce advance is not the first instruction", ErrSwapMismatch)
+	}
+	return fmt.Errorf("%w: unexpected system instruction %d", ErrSwapMismatch, binary.LittleEndian.Uint32(data[:4]))
+}
+
+// verifyCreateAccountWithSeed 只接受以 owner 为 base 的 token 账户：其他人出资时只能付租金，owner
+// 出资时最多再加上 wrap 的输入
+func (v *swapVerifier) verifyCreateAccountWithSeed(accounts []solana.PublicKey, data []byte) error {
+	// base(32) + seed 长度(8) + seed + lamports(8) + space(8) + program(32)
+	if len(accounts) < 2 || len(data) < 44 {
+		return ErrSwapMismatch
+	}
+	base := solana.PublicKeyFromBytes(data[4:36])
+	seedLen := binary.LittleEndian.Uint64(data[36:44])
+	if seedLen > 32 || uint64(len(data)) != 44+seedLen+48 {
+		return ErrSwapMismatch
+	}
+	rest := data[44+seedLen:]
+	lamports, space := binary.LittleEndian.Uint64(rest[0:8]), binary.LittleEndian.Uint64(rest[8:16])
+	program := solana.PublicKeyFromBytes(rest[16:48])
+	funder, account := accounts[0], accounts[1]
+	maxLamports := v.rent
+	if funder.Equals(v.meta.Owner) {
+		maxLamports += v.meta.AmountIn
+	}
+	switch {
+	case !base.Equals(v.meta.Owner) || !program.Equals(solana.TokenProgramID) || space != dataSize:
+		return fmt.Errorf("%w: account %s created with seed from %s", ErrSwapMismatch, account, base)
+	case lamports < v.rent || lamports > maxLamports:
+		return fmt.Errorf("%w: account %s funded with %d lamports by %s", ErrSwapMismatch, account, lamports, funder)
+	}
+	v.created[account] = true
+	if !funder.Equals(v.meta.Owner) {
+		v.sponsored[funder] += lamports
+	}
+	return nil
+}
+
+func (v *swapVerifier) verifyTokenInstruction(accounts []solana.PublicKey, data []byte) error {
+	owner := v.meta.Owner
+	switch data[0] {
+	case 1:
+		// InitializeAccount 只用于本交易创建的 owner 的 WSOL 账户
+		if len(accounts) >= 3 && v.created[accounts[0]] && accounts[1].Equals(WSOL) && accounts[2].Equals(owner) {
+			v.wsol[accounts[0]] = true
+			return nil
+		}
+		return fmt.Errorf("%w: initialize account", ErrSwapMismatch)
+	case 17:
+		// SyncNative
+		return nil
+	case 9:
+		// CloseAccount 的租金和剩余 SOL 只能退回 owner
+		if len(accounts) >= 2 && accounts[1].Equals(owner) {
+			return nil
+		}
+		return fmt.Errorf("%w: close account destination", ErrSwapMismatch)
+	}
+	return fmt.Errorf("%w: unexpected token instruction %d", ErrSwapMismatch, data[0])
+}
@@ -0,0 +1,76 @@
+package amm
+
+import (
+	"context"
+	"fmt"
+
+	"raydium-go/config"
+	"raydium-go/txn"
+
+	"github.com/gagliardetto/solana-go"
+	"github.com/gagliardetto/solana-go/rpc"
+)
+
+// PoolLookupTableAddresses returns the accounts shared by every swap on the pool, which is what
+// a per-pool lookup table should hold.
+func PoolLookupTableAddresses(network string, pool solana.PublicKey, poolState AmmInfo, marketState MarketState) ([]solana.PublicKey, error) {
+	programID, err := config.AMMProgram(network)
+	if err != nil {
+		return nil, err
+	}
+	ammAuthority, _, err := GetAmmAuthority(programID)
+	if err != nil {
+		return nil, err
+	}
+	vaultSigner, _, err := GetAssociatedAuthority(poolState.MarketProgram, poolState.Market)
+	if err != nil {
+		return nil, err
+	}
+	return []solana.PublicKey{
+		programID,
+		solana.TokenProgramID,
+		solana.ComputeBudget,
+		pool,
+		ammAuthority,
+		poolState.OpenOrders,
+		poolState.TargetOrders,
+		poolState.CoinVault,
+		poolState.PcVault,
+		poolState.CoinVaultMint,
+		poolState.PcVaultMint,
+		poolState.MarketProgram,
+		poolState.Market,
+		marketState.Bids,
+		marketState.Asks,
+		marketState.EventQueue,
+		marketState.BaseVault,
+		marketState.QuoteVault,
+		vaultSigner,
+	}, nil
+}
+
+// CreatePoolLookupTable creates a lookup table holding the static accounts of poolAddress and returns
+// its address and the transaction signature.
+func CreatePoolLookupTable(ctx context.Context, client *rpc.Client, network string, poolAddress string, privateKey string) (solana.PublicKey, string, error) {
+	signer, err := solana.PrivateKeyFromBase58(privateKey)
+	if err != nil {
+		return solana.PublicKey{}, "", fmt.Errorf("invalid private key: %w", err)
+	}
+	pool, err := solana.PublicKeyFromBase58(poolAddress)
+	if err != nil {
+		return solana.PublicKey{}, "", err
+	}
+	poolState, err := GetPoolState(ctx, client, pool, Commitment{})
+	if err != nil {
+		return solana.PublicKey{}, "", err
+	}
+	marketState, err := GetMarketState(ctx, client, poolState.Market, Commitment{})
+	if err != nil {
+		return solana.PublicKey{}, "", err
+	}
+	addresses, err := PoolLookupTableAddresses(network, pool, poolState, marketState)
+	if err != nil {
+		return solana.PublicKey{}, "", err
+	}
+	return txn.CreateLookupTable(ctx, client, signer, addresses)
+}
@@ -0,0 +1,94 @@
+package amm
+
+import (
+	"context"
+	"log/slog"
+
+	"raydium-go/config"
+	"raydium-go/spl"
+	"raydium-go/txn"
+
+	"github.com/gagliardetto/solana-go"
+	"github.com/gagliardetto/solana-go/rpc"
+)
+
+// WSOLPolicy 决定交易中 WSOL 账户的处理方式，与 cpmm、clmm 等包共用
+type WSOLPolicy = spl.WSOLPolicy
+
+const (
+	WSOLTemporaryAccount = spl.WSOLTemporaryAccount
+	WSOLWrapUnwrap       = spl.WSOLWrapUnwrap
+	WSOLKeepWrapped      = spl.WSOLKeepWrapped
+)
+
+// SwapOptions 控制单次 swap 的可选行为，零值与 Swap 的默认行为一致
+type SwapOptions struct {
+	WSOLPolicy WSOLPolicy
+	// 跳过签名前的余额检查
+	SkipBalanceCheck bool
+	// 固定的 compute unit 价格（micro-lamports），为 0 时使用默认值
+	PriorityFee uint64
+	// 设置后根据池子相关的可写账户估算 compute unit 价格，优先于 PriorityFee
+	PriorityFeeEstimator *txn.PriorityFeeEstimator
+	// 固定的 compute unit limit，为 0 时使用默认值
+	ComputeUnitLimit uint32
+	// 设置后先模拟交易再按实际消耗设置 limit，结果按池子和指令类型缓存，优先于 ComputeUnitLimit
+	ComputeUnitEstimator *txn.ComputeUnitEstimator
+	// 设置后生成使用这些地址查找表的 v0 交易
+	AddressLookupTables []solana.PublicKey
+	// 设置后使用 durable nonce 代替最新 blockhash，便于离线签名
+	Nonce *txn.DurableNonce
+	// 交易手续费、优先费和小费的支付者，零值为 owner
+	FeePayer solana.PublicKey
+	// 新建 token 账户租金的支付者，零值为 owner
+	RentPayer solana.PublicKey
+	// 覆盖网络默认的 AMM 程序 ID
+	ProgramID solana.PublicKey
+	// 读取状态、blockhash 和等待确认的 commitment
+	Commitment Commitment
+	// swap 各阶段的日志，为 nil 时不输出
+	Logger *slog.Logger
+	// 在 swap 的每个阶段依次调用
+	Hooks []SwapHook
+}
+
+func (opts SwapOptions) feePayer(owner solana.PublicKey) solana.PublicKey {
+	if opts.FeePayer.IsZero() {
+		return owner
+	}
+	return opts.FeePayer
+}
+
+func (opts SwapOptions) programID(network string) (solana.PublicKey, error) {
+	if !opts.ProgramID.IsZero() {
+		return opts.ProgramID, nil
+	}
+	return config.AMMProgram(network)
+}
+
+func (opts SwapOptions) rentPayer(owner solana.PublicKey) solana.PublicKey {
+	if opts.RentPayer.IsZero() {
+		return owner
+	}
+	return opts.RentPayer
+}
+
+func (opts SwapOptions) computeUnitLimit(ctx context.Context, client *rpc.Client, plan *swapPlan, tables map[solana.PublicKey]solana.PublicKeySlice) (uint32, error) {
+	if opts.ComputeUnitEstimator != nil {
+		return opts.ComputeUnitEstimator.Estimate(ctx, client, plan.computeUnitKey(), plan.Instructions, plan.FeePayer, tables)
+	}
+	if opts.ComputeUnitLimit > 0 {
+		return opts.ComputeUnitLimit, nil
+	}
+	return computeUnitLimit, nil
+}
+
+func (opts SwapOptions) priorityFee(ctx context.Context, client *rpc.Client, accounts []solana.PublicKey, computeUnitLimit uint32) (uint64, error) {
+	if opts.PriorityFeeEstimator != nil {
+		return opts.PriorityFeeEstimator.Estimate(ctx, client, accounts, computeUnitLimit)
+	}
+	if opts.PriorityFee > 0 {
+		return opts.PriorityFee, nil
+	}
+	return priorityFee, nil
+}
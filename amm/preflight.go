@@ -0,0 +1,121 @@
+package amm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"raydium-go/txn"
+
+	"github.com/gagliardetto/solana-go"
+	"github.com/gagliardetto/solana-go/rpc"
+)
+
+const lamportsPerSignature = uint64(5000)
+
+var ErrInsufficientFunds = errors.New("insufficient funds")
+
+// InsufficientFundsError 表示 Account 的余额不足以完成交易，Mint 为零值时表示原生 SOL
+type InsufficientFundsError struct {
+	Account   solana.PublicKey
+	Mint      solana.PublicKey
+	Required  uint64
+	Available uint64
+}
+
+func (e *InsufficientFundsError) Error() string {
+	if e.Mint.IsZero() {
+		return fmt.Sprintf("insufficient SOL in %s: required %d lamports, available %d", e.Account, e.Required, e.Available)
+	}
+	return fmt.Sprintf("insufficient %s balance in %s: required %d, available %d", e.Mint, e.Account, e.Required, e.Available)
+}
+
+func (e *InsufficientFundsError) Is(target error) bool {
+	return target == ErrInsufficientFunds
+}
+
+// RequiredLamports returns the SOL a transaction needs up front: signature fees, the priority
+// fee (compute unit price in micro-lamports times the limit), rent and SOL to wrap.
+func RequiredLamports(signatures int, computeUnitLimit uint32, priorityFee uint64, rent uint64, wrapped uint64) uint64 {
+	return uint64(signatures)*lamportsPerSignature + priorityFeeLamports(computeUnitLimit, priorityFee) + rent + wrapped
+}
+
+func priorityFeeLamports(computeUnitLimit uint32, priorityFee uint64) uint64 {
+	return (uint64(computeUnitLimit)*priorityFee + 999999) / 1000000
+}
+
+// swapBalances 为预检时读取的 SOL 余额，估算出优先费后用它复核 fee payer，不再请求 RPC
+type swapBalances struct {
+	plan       *swapPlan
+	signatures int
+	lamports   map[solana.PublicKey]uint64
+}
+
+// requiredLamports 按账户汇总需要的 SOL：手续费和小费由 fee payer 支付，租金由 rent payer 支付，wrap 的 SOL 来自 owner
+func requiredLamports(plan *swapPlan, signatures int, computeUnitLimit uint32, priorityFee uint64) (map[solana.PublicKey]uint64, []solana.PublicKey) {
+	required := map[solana.PublicKey]uint64{}
+	var order []solana.PublicKey
+	add := func(account solana.PublicKey, lamports uint64) {
+		if _, ok := required[account]; !ok {
+			order = append(order, account)
+		}
+		required[account] += lamports
+	}
+	add(plan.FeePayer, RequiredLamports(signatures, computeUnitLimit, priorityFee, 0, plan.TipLamports))
+	add(plan.RentPayer, plan.InputAccount.Rent+plan.OutputAccount.Rent)
+	add(plan.Owner, plan.InputAccount.Wrapped)
+	return required, order
+}
+
+// checkSwapBalances 在估算 compute units 之前检查 SOL 和输入 token 的余额，此时还不含优先费
+func checkSwapBalances(ctx context.Context, client *rpc.Client, plan *swapPlan, nonce *txn.DurableNonce) (*swapBalances, error) {
+	signers := []solana.PublicKey{plan.FeePayer}
+	if !plan.Owner.Equals(plan.FeePayer) {
+		signers = append(signers, plan.Owner)
+	}
+	if nonce != nil && !nonce.Authority.Equals(plan.Owner) && !nonce.Authority.Equals(plan.FeePayer) {
+		signers = append(signers, nonce.Authority)
+	}
+	b := &swapBalances{plan: plan, signatures: len(signers), lamports: map[solana.PublicKey]uint64{}}
+	required, order := requiredLamports(plan, b.signatures, 0, 0)
+	for _, account := range order {
+		if required[account] == 0 {
+			continue
+		}
+		balance, err := getBalance(ctx, client, plan.Commitment, account)
+		if err != nil {
+			return nil, fmt.Errorf("failed to fetch SOL balance: %w", err)
+		}
+		if balance < required[account] {
+			return nil, &InsufficientFundsError{Account: account, Required: required[account], Available: balance}
+		}
+		b.lamports[account] = balance
+	}
+
+	if plan.InputMint.Address.Equals(WSOL) {
+		return b, nil
+	}
+	var available uint64
+	if plan.InputAccount.Rent == 0 {
+		balances, err := getTokenAmounts(ctx, client, plan.Commitment, plan.InputAccount.Address)
+		if err != nil {
+			return nil, fmt.Errorf("failed to fetch token balance: %w", err)
+		}
+		available = balances[0]
+	}
+	if available < plan.MaxAmountIn {
+		return nil, &InsufficientFundsError{Account: plan.Owner, Mint: plan.InputMint.Address, Required: plan.MaxAmountIn, Available: available}
+	}
+	return b, nil
+}
+
+// checkFees 加上优先费后复核已读取余额的账户
+func (b *swapBalances) checkFees(computeUnitLimit uint32, priorityFee uint64) error {
+	required, order := requiredLamports(b.plan, b.signatures, computeUnitLimit, priorityFee)
+	for _, account := range order {
+		if balance, ok := b.lamports[account]; ok && balance < required[account] {
+			return &InsufficientFundsError{Account: account, Required: required[account], Available: balance}
+		}
+	}
+	return nil
+}
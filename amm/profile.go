@@ -0,0 +1,58 @@
+package amm
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"raydium-go/config"
+	"raydium-go/txn"
+)
+
+var (
+	estimatorsMu sync.Mutex
+	// 同一 profile 共用一个 estimator，模拟得到的 compute units 才能在多次 swap 之间复用
+	estimators = map[string]*txn.ComputeUnitEstimator{}
+)
+
+func profileEstimator(profile config.Profile) *txn.ComputeUnitEstimator {
+	key := fmt.Sprintf("%s/%s/%g", profile.Name, profile.Network, profile.Fees.ComputeUnitMargin)
+	estimatorsMu.Lock()
+	defer estimatorsMu.Unlock()
+	estimator, ok := estimators[key]
+	if !ok {
+		estimator = txn.NewComputeUnitEstimator(profile.Fees.ComputeUnitMargin)
+		estimators[key] = estimator
+	}
+	return estimator
+}
+
+// ProfileOptions returns the swap options of the fee policy and AMM program override of profile.
+func ProfileOptions(profile config.Profile) SwapOptions {
+	fees := profile.Fees
+	opts := SwapOptions{
+		PriorityFee:      fees.PriorityFee,
+		ComputeUnitLimit: fees.ComputeUnitLimit,
+		ProgramID:        profile.Programs.AMM,
+	}
+	if fees.PriorityFeePercentile > 0 {
+		opts.PriorityFeeEstimator = &txn.PriorityFeeEstimator{Percentile: fees.PriorityFeePercentile, MaxFee: fees.MaxPriorityFee}
+	}
+	if fees.ComputeUnitMargin > 0 {
+		opts.ComputeUnitEstimator = profileEstimator(profile)
+	}
+	return opts
+}
+
+// SwapWithProfile swaps with the endpoint, network, wallet, slippage and fee policy of profile.
+func SwapWithProfile(ctx context.Context, profile config.Profile, poolAddress string, inputTokenAddress string, amountSpecified uint64, baseIn bool) (string, error) {
+	client, err := profile.Client()
+	if err != nil {
+		return "", err
+	}
+	signer, err := profile.PrivateKey()
+	if err != nil {
+		return "", err
+	}
+	return SwapWithOptions(ctx, client, profile.Network, poolAddress, inputTokenAddress, amountSpecified, baseIn, profile.Slippage(), signer.String(), ProfileOptions(profile))
+}
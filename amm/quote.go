@@ -0,0 +1,147 @@
+package amm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+
+	"raydium-go/spl"
+
+	"github.com/gagliardetto/solana-go"
+	"github.com/gagliardetto/solana-go/rpc"
+)
+
+var ErrInsufficientLiquidity = errors.New("insufficient liquidity")
+
+type SwapQuote struct {
+	Direction SwapDirection
+	spl.SwapAmounts
+}
+
+// ComputeAmountOut 对应链上 swap_base_in 的计算
+func ComputeAmountOut(fees Fees, reserveIn uint64, reserveOut uint64, amountIn uint64) (uint64, error) {
+	if fees.SwapFeeDenominator == 0 {
+		return 0, fmt.Errorf("invalid swap fee denominator")
+	}
+	swapFee := ceilDiv(mul(amountIn, fees.SwapFeeNumerator), u(fees.SwapFeeDenominator))
+	afterFee := new(big.Int).Sub(u(amountIn), swapFee)
+	denominator := new(big.Int).Add(u(reserveIn), afterFee)
+	if denominator.Sign() == 0 {
+		return 0, ErrInsufficientLiquidity
+	}
+	out := new(big.Int).Quo(new(big.Int).Mul(u(reserveOut), afterFee), denominator)
+	return out.Uint64(), nil
+}
+
+// ComputeAmountIn 对应链上 swap_base_out 的计算
+func ComputeAmountIn(fees Fees, reserveIn uint64, reserveOut uint64, amountOut uint64) (uint64, error) {
+	if fees.SwapFeeDenominator <= fees.SwapFeeNumerator {
+		return 0, fmt.Errorf("invalid swap fee %d/%d", fees.SwapFeeNumerator, fees.SwapFeeDenominator)
+	}
+	if amountOut >= reserveOut {
+		return 0, ErrInsufficientLiquidity
+	}
+	beforeFee := ceilDiv(mul(reserveIn, amountOut), u(reserveOut-amountOut))
+	amountIn := ceilDiv(beforeFee.Mul(beforeFee, u(fees.SwapFeeDenominator)), u(fees.SwapFeeDenominator-fees.SwapFeeNumerator))
+	if !amountIn.IsUint64() {
+		return 0, ErrInsufficientLiquidity
+	}
+	return amountIn.Uint64(), nil
+}
+
+// QuoteSwap computes the amounts and slippage bound of a swap, including Token-2022 transfer fees
+// charged on the input and output mints.
+func QuoteSwap(poolState AmmInfo, coinReserve uint64, pcReserve uint64, inputMint spl.Mint, outputMint spl.Mint, epoch uint64, amountSpecified uint64, baseIn bool, slippage float64) (SwapQuote, error) {
+	quote := SwapQuote{Direction: PC2Coin}
+	reserveIn, reserveOut := pcReserve, coinReserve
+	if inputMint.Address.Equals(poolState.CoinVaultMint) {
+		quote.Direction = Coin2PC
+		reserveIn, reserveOut = coinReserve, pcReserve
+	}
+	if baseIn {
+		quote.AmountIn = amountSpecified
+		quote.InputTransferFee = inputMint.TransferFee(epoch, amountSpecified)
+		amountOut, err := ComputeAmountOut(poolState.Fees, reserveIn, reserveOut, amountSpecified-quote.InputTransferFee)
+		if err != nil {
+			return quote, err
+		}
+		quote.AmountOut = amountOut
+		quote.OutputTransferFee = outputMint.TransferFee(epoch, amountOut)
+		quote.SetThreshold(true, slippage)
+		return quote, nil
+	}
+	quote.OutputTransferFee = outputMint.InverseTransferFee(epoch, amountSpecified)
+	quote.AmountOut = amountSpecified + quote.OutputTransferFee
+	amountIn, err := ComputeAmountIn(poolState.Fees, reserveIn, reserveOut, quote.AmountOut)
+	if err != nil {
+		return quote, err
+	}
+	quote.InputTransferFee = inputMint.InverseTransferFee(epoch, amountIn)
+	quote.AmountIn = amountIn + quote.InputTransferFee
+	quote.SetThreshold(false, slippage)
+	return quote, nil
+}
+
+// GetSwapQuote loads the mints and reserves of poolState and quotes a swap of inputMint.
+func GetSwapQuote(ctx context.Context, client *rpc.Client, poolState AmmInfo, inputMint solana.PublicKey, amountSpecified uint64, baseIn bool, slippage float64, commitment Commitment) (SwapQuote, error) {
+	var outputMint solana.PublicKey
+	switch {
+	case inputMint.Equals(poolState.CoinVaultMint):
+		outputMint = poolState.PcVaultMint
+	case inputMint.Equals(poolState.PcVaultMint):
+		outputMint = poolState.CoinVaultMint
+	default:
+		return SwapQuote{}, fmt.Errorf("mint %s is not in the pool", inputMint)
+	}
+	_, _, quote, err := quoteSwap(ctx, client, poolState, inputMint, outputMint, amountSpecified, baseIn, slippage, commitment)
+	return quote, err
+}
+
+func quoteSwap(ctx context.Context, client *rpc.Client, poolState AmmInfo, inputMintAddress solana.PublicKey, outputMintAddress solana.PublicKey, amountSpecified uint64, baseIn bool, slippage float64, commitment Commitment) (spl.Mint, spl.Mint, SwapQuote, error) {
+	mints, err := getMints(ctx, client, commitment, inputMintAddress, outputMintAddress)
+	if err != nil {
+		return spl.Mint{}, spl.Mint{}, SwapQuote{}, err
+	}
+	inputMint, outputMint := mints[0], mints[1]
+	coinReserve, pcReserve, err := GetPoolReserves(ctx, client, poolState, commitment)
+	if err != nil {
+		return inputMint, outputMint, SwapQuote{}, err
+	}
+	var epoch uint64
+	if inputMint.TransferFeeConfig != nil || outputMint.TransferFeeConfig != nil {
+		epochInfo, err := client.GetEpochInfo(ctx, commitment.state())
+		if err != nil {
+			return inputMint, outputMint, SwapQuote{}, err
+		}
+		epoch = epochInfo.Epoch
+	}
+	quote, err := QuoteSwap(poolState, coinReserve, pcReserve, inputMint, outputMint, epoch, amountSpecified, baseIn, slippage)
+	return inputMint, outputMint, quote, err
+}
+
+// GetPoolReserves returns the coin and pc vault balances without the pnl still owed to the pool owner.
+// Both vaults are read in one request, so they come from the same slot.
+func GetPoolReserves(ctx context.Context, client *rpc.Client, poolState AmmInfo, commitment Commitment) (uint64, uint64, error) {
+	balances, err := getTokenAmounts(ctx, client, commitment, poolState.CoinVault, poolState.PcVault)
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to fetch vault balances: %w", err)
+	}
+	return balances[0] - poolState.StateData.NeedTakePnlCoin, balances[1] - poolState.StateData.NeedTakePnlPc, nil
+}
+
+func u(v uint64) *big.Int {
+	return new(big.Int).SetUint64(v)
+}
+
+func mul(a uint64, b uint64) *big.Int {
+	return new(big.Int).Mul(u(a), u(b))
+}
+
+func ceilDiv(a *big.Int, b *big.Int) *big.Int {
+	q, r := new(big.Int).QuoRem(a, b, new(big.Int))
+	if r.Sign() != 0 {
+		q.Add(q, big.NewInt(1))
+	}
+	return q
+}
@@ -0,0 +1,34 @@
+package amm
+
+import (
+	"context"
+	"fmt"
+
+	"raydium-go/txn"
+
+	"github.com/gagliardetto/solana-go"
+	"github.com/gagliardetto/solana-go/rpc"
+)
+
+// BuildSponsoredSwapTransaction builds a swap for owner whose fees are paid by the relayer holding
+// feePayerKey. Rent for new token accounts goes to opts.RentPayer, or to the fee payer when unset.
+// The returned transaction carries the fee payer's signature only; the owner adds theirs with
+// txn.PartialSign before it is sent.
+func BuildSponsoredSwapTransaction(ctx context.Context, client *rpc.Client, network string, poolAddress string, inputTokenAddress string, amountSpecified uint64, baseIn bool, slippage float64, owner solana.PublicKey, feePayerKey string, opts SwapOptions) (*solana.Transaction, error) {
+	feePayer, err := solana.PrivateKeyFromBase58(feePayerKey)
+	if err != nil {
+		return nil, fmt.Errorf("invalid fee payer key: %w", err)
+	}
+	opts.FeePayer = feePayer.PublicKey()
+	if opts.RentPayer.IsZero() {
+		opts.RentPayer = opts.FeePayer
+	}
+	tx, err := BuildSwapTransaction(ctx, client, network, poolAddress, inputTokenAddress, amountSpecified, baseIn, slippage, owner, opts)
+	if err != nil {
+		return nil, err
+	}
+	if err := txn.PartialSign(tx, feePayer); err != nil {
+		return nil, err
+	}
+	return tx, nil
+}
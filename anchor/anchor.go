@@ -0,0 +1,78 @@
+package anchor
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"strings"
+
+	bin "github.com/gagliardetto/binary"
+)
+
+const programDataPrefix = "Program data: "
+
+type Discriminator [8]byte
+
+func discriminator(namespace string, name string) Discriminator {
+	var d Discriminator
+	sum := sha256.Sum256([]byte(namespace + ":" + name))
+	copy(d[:], sum[:8])
+	return d
+}
+
+// AccountDiscriminator 对应 anchor 中 sha256("account:<Name>")[..8]
+func AccountDiscriminator(name string) Discriminator {
+	return discriminator("account", name)
+}
+
+// InstructionDiscriminator 对应 anchor 中 sha256("global:<name>")[..8]
+func InstructionDiscriminator(name string) Discriminator {
+	return discriminator("global", name)
+}
+
+// EventDiscriminator 对应 anchor 中 sha256("event:<Name>")[..8]
+func EventDiscriminator(name string) Discriminator {
+	return discriminator("event", name)
+}
+
+// DecodeAccount checks the discriminator of an anchor account and borsh-decodes the rest into v.
+func DecodeAccount(data []byte, d Discriminator, v interface{}) error {
+	if len(data) < 8 || !bytes.Equal(data[:8], d[:]) {
+		return fmt.Errorf("account discriminator mismatch")
+	}
+	return bin.NewBorshDecoder(data[8:]).Decode(v)
+}
+
+// InstructionData encodes args with borsh after the instruction discriminator.
+func InstructionData(d Discriminator, args interface{}) ([]byte, error) {
+	buf := new(bytes.Buffer)
+	buf.Write(d[:])
+	if args != nil {
+		if err := bin.NewBorshEncoder(buf).Encode(args); err != nil {
+			return nil, err
+		}
+	}
+	return buf.Bytes(), nil
+}
+
+// DecodeEvent decodes an event emitted with emit! from a "Program data: " log line. ok is false when
+// the line is not an event of type d.
+func DecodeEvent(msg string, d Discriminator, v interface{}) (bool, error) {
+	data, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(msg, programDataPrefix))
+	if err != nil {
+		return false, fmt.Errorf("base64 decode failed: %w", err)
+	}
+	if len(data) < 8 || !bytes.Equal(data[:8], d[:]) {
+		return false, nil
+	}
+	if err := bin.NewBorshDecoder(data[8:]).Decode(v); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// IsProgramData reports whether a log line carries anchor event data.
+func IsProgramData(msg string) bool {
+	return strings.HasPrefix(msg, programDataPrefix)
+}
@@ -0,0 +1,34 @@
+package anchor
+
+import (
+	"encoding/base64"
+	"testing"
+)
+
+func TestInstructionDiscriminator(t *testing.T) {
+	expected := Discriminator{143, 190, 90, 218, 196, 30, 51, 222}
+	if d := InstructionDiscriminator("swap_base_input"); d != expected {
+		t.Errorf("discriminator = %v", d)
+	}
+}
+
+func TestDecodeEvent(t *testing.T) {
+	type event struct {
+		Amount uint64
+		Flag   bool
+	}
+	d := EventDiscriminator("Test")
+	data, err := InstructionData(d, event{Amount: 42, Flag: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	msg := "Program data: " + base64.StdEncoding.EncodeToString(data)
+	var decoded event
+	ok, err := DecodeEvent(msg, d, &decoded)
+	if err != nil || !ok || decoded.Amount != 42 || !decoded.Flag {
+		t.Errorf("decoded = %+v, ok = %v, err = %v", decoded, ok, err)
+	}
+	if ok, _ := DecodeEvent(msg, EventDiscriminator("Other"), &decoded); ok {
+		t.Error("expected discriminator mismatch")
+	}
+}
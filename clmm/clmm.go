@@ -0,0 +1,246 @@
+package clmm
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+
+	"raydium-go/anchor"
+	"raydium-go/config"
+	"raydium-go/spl"
+	"raydium-go/txn"
+
+	bin "github.com/gagliardetto/binary"
+	"github.com/gagliardetto/solana-go"
+	"github.com/gagliardetto/solana-go/rpc"
+)
+
+var (
+	computeUnitLimit = uint32(300000)
+	priorityFee      = uint64(100)
+	// 报价时最多加载的 tick array 数
+	maxTickArrays = 10
+
+	swapV2Discriminator = anchor.InstructionDiscriminator("swap_v2")
+)
+
+type SwapV2Args struct {
+	Amount               uint64
+	OtherAmountThreshold uint64
+	SqrtPriceLimitX64    bin.Uint128
+	IsBaseInput          bool
+}
+
+// SwapV2Accounts 为 swap_v2 指令的账户，TickArrays 按 swap 方向排列。TickArrayBitmapExtension 为零值时
+// 不传入：程序会加载与其地址相同的账户，账户不存在时 swap 失败
+type SwapV2Accounts struct {
+	Payer                    solana.PublicKey
+	AmmConfig                solana.PublicKey
+	PoolState                solana.PublicKey
+	InputTokenAccount        solana.PublicKey
+	OutputTokenAccount       solana.PublicKey
+	InputVault               solana.PublicKey
+	OutputVault              solana.PublicKey
+	ObservationState         solana.PublicKey
+	InputVaultMint           solana.PublicKey
+	OutputVaultMint          solana.PublicKey
+	TickArrayBitmapExtension solana.PublicKey
+	TickArrays               []solana.PublicKey
+}
+
+// SwapV2AccountsFrom fills the swap_v2 accounts of pool for the direction and tick arrays of quote.
+// The bitmap extension is only included when quote found one.
+func SwapV2AccountsFrom(programID solana.PublicKey, pool solana.PublicKey, poolState PoolState, payer solana.PublicKey, quote SwapQuote, inputAccount solana.PublicKey, outputAccount solana.PublicKey) (SwapV2Accounts, error) {
+	accounts := SwapV2Accounts{
+		Payer:              payer,
+		AmmConfig:          poolState.AmmConfig,
+		PoolState:          pool,
+		InputTokenAccount:  inputAccount,
+		OutputTokenAccount: outputAccount,
+		InputVault:         poolState.TokenVault0,
+		OutputVault:        poolState.TokenVault1,
+		ObservationState:   poolState.ObservationKey,
+		InputVaultMint:     poolState.TokenMint0,
+		OutputVaultMint:    poolState.TokenMint1,
+	}
+	if quote.BitmapExtension {
+		extension, _, err := GetTickArrayBitmapExtensionAddress(programID, pool)
+		if err != nil {
+			return SwapV2Accounts{}, err
+		}
+		accounts.TickArrayBitmapExtension = extension
+	}
+	if !quote.ZeroForOne {
+		accounts.InputVault, accounts.OutputVault = poolState.TokenVault1, poolState.TokenVault0
+		accounts.InputVaultMint, accounts.OutputVaultMint = poolState.TokenMint1, poolState.TokenMint0
+	}
+	for _, start := range quote.TickArrayStartIndexes {
+		tickArray, _, err := GetTickArrayAddress(programID, pool, start)
+		if err != nil {
+			return SwapV2Accounts{}, err
+		}
+		accounts.TickArrays = append(accounts.TickArrays, tickArray)
+	}
+	return accounts, nil
+}
+
+func NewSwapV2Instruction(programID solana.PublicKey, accounts SwapV2Accounts, args SwapV2Args) (solana.Instruction, error) {
+	data, err := anchor.InstructionData(swapV2Discriminator, args)
+	if err != nil {
+		return nil, err
+	}
+	metas := []*solana.AccountMeta{
+		solana.NewAccountMeta(accounts.Payer, false, true),
+		solana.NewAccountMeta(accounts.AmmConfig, false, false),
+		solana.NewAccountMeta(accounts.PoolState, true, false),
+		solana.NewAccountMeta(accounts.InputTokenAccount, true, false),
+		solana.NewAccountMeta(accounts.OutputTokenAccount, true, false),
+		solana.NewAccountMeta(accounts.InputVault, true, false),
+		solana.NewAccountMeta(accounts.OutputVault, true, false),
+		solana.NewAccountMeta(accounts.ObservationState, true, false),
+		solana.NewAccountMeta(solana.TokenProgramID, false, false),
+		solana.NewAccountMeta(solana.Token2022ProgramID, false, false),
+		solana.NewAccountMeta(solana.MemoProgramID, false, false),
+		solana.NewAccountMeta(accounts.InputVaultMint, false, false),
+		solana.NewAccountMeta(accounts.OutputVaultMint, false, false),
+	}
+	if !accounts.TickArrayBitmapExtension.IsZero() {
+		metas = append(metas, solana.NewAccountMeta(accounts.TickArrayBitmapExtension, true, false))
+	}
+	for _, tickArray := range accounts.TickArrays {
+		metas = append(metas, solana.NewAccountMeta(tickArray, true, false))
+	}
+	return solana.NewInstruction(programID, metas, data), nil
+}
+
+// GetSwapQuote loads the config, bitmap extension and tick arrays a swap on pool may cross and
+// quotes it with QuoteSwap.
+func GetSwapQuote(ctx context.Context, client *rpc.Client, programID solana.PublicKey, pool solana.PublicKey, poolState PoolState, inputMint spl.Mint, outputMint spl.Mint, amountSpecified uint64, baseIn bool, slippage float64, commitment rpc.CommitmentType) (SwapQuote, error) {
+	ammConfig, err := GetAmmConfig(ctx, client, poolState.AmmConfig, commitment)
+	if err != nil {
+		return SwapQuote{}, err
+	}
+	extension, err := GetTickArrayBitmapExtension(ctx, client, programID, pool, commitment)
+	if err != nil {
+		return SwapQuote{}, err
+	}
+	zeroForOne := inputMint.Address.Equals(poolState.TokenMint0)
+	tickArrays, err := GetTickArrays(ctx, client, programID, pool, NextInitializedTickArrays(poolState, extension, zeroForOne, maxTickArrays), commitment)
+	if err != nil {
+		return SwapQuote{}, err
+	}
+	var epoch uint64
+	if inputMint.TransferFeeConfig != nil || outputMint.TransferFeeConfig != nil {
+		epochInfo, err := client.GetEpochInfo(ctx, commitment)
+		if err != nil {
+			return SwapQuote{}, err
+		}
+		epoch = epochInfo.Epoch
+	}
+	return QuoteSwap(poolState, ammConfig, extension, tickArrays, inputMint, outputMint, epoch, amountSpecified, baseIn, slippage)
+}
+
+func Swap(ctx context.Context, client *rpc.Client, network string, poolAddress string, inputTokenAddress string, amountSpecified uint64, baseIn bool, slippage float64, privateKey string) (string, error) {
+	return SwapWithOptions(ctx, client, network, poolAddress, inputTokenAddress, amountSpecified, baseIn, slippage, privateKey, txn.SwapOptions{})
+}
+
+func SwapWithOptions(ctx context.Context, client *rpc.Client, network string, poolAddress string, inputTokenAddress string, amountSpecified uint64, baseIn bool, slippage float64, privateKey string, opts txn.SwapOptions) (string, error) {
+	signer, err := solana.PrivateKeyFromBase58(privateKey)
+	if err != nil {
+		return "", fmt.Errorf("invalid private key: %w", err)
+	}
+	tx, err := BuildSwapTransaction(ctx, client, network, poolAddress, inputTokenAddress, amountSpecified, baseIn, slippage, signer.PublicKey(), opts)
+	if err != nil {
+		return "", err
+	}
+	if err := txn.PartialSign(tx, signer); err != nil {
+		return "", err
+	}
+	txHash, err := client.SendTransaction(ctx, tx)
+	if err != nil {
+		return "", fmt.Errorf("failed to send transaction: %w", err)
+	}
+	return txHash.String(), nil
+}
+
+// BuildSwapTransaction builds the unsigned swap_v2 transaction of owner on a CLMM pool. SOL goes
+// through the WSOL account picked by opts.WSOLPolicy.
+func BuildSwapTransaction(ctx context.Context, client *rpc.Client, network string, poolAddress string, inputTokenAddress string, amountSpecified uint64, baseIn bool, slippage float64, owner solana.PublicKey, opts txn.SwapOptions) (*solana.Transaction, error) {
+	programID, err := config.CLMMProgram(network)
+	if err != nil {
+		return nil, err
+	}
+	pool, err := solana.PublicKeyFromBase58(poolAddress)
+	if err != nil {
+		return nil, err
+	}
+	commitment := opts.StateCommitment()
+	poolState, err := GetPoolState(ctx, client, pool, commitment)
+	if err != nil {
+		return nil, err
+	}
+	inputMintAddress, outputMintAddress := poolState.TokenMint0, poolState.TokenMint1
+	switch inputTokenAddress {
+	case poolState.TokenMint0.String():
+	case poolState.TokenMint1.String():
+		inputMintAddress, outputMintAddress = poolState.TokenMint1, poolState.TokenMint0
+	default:
+		return nil, fmt.Errorf("mint %s is not in pool %s", inputTokenAddress, pool)
+	}
+	mints, err := spl.GetMints(ctx, client, inputMintAddress, outputMintAddress)
+	if err != nil {
+		return nil, err
+	}
+	inputMint, outputMint := mints[0], mints[1]
+	quote, err := GetSwapQuote(ctx, client, programID, pool, poolState, inputMint, outputMint, amountSpecified, baseIn, slippage, commitment)
+	if err != nil {
+		return nil, err
+	}
+
+	inputAccount, err := spl.TokenAccountInstructions(ctx, client, commitment, owner, owner, inputMint, quote.MaxAmountIn(baseIn), opts.WSOLPolicy)
+	if err != nil {
+		return nil, err
+	}
+	outputAccount, err := spl.TokenAccountInstructions(ctx, client, commitment, owner, owner, outputMint, 0, opts.WSOLPolicy)
+	if err != nil {
+		return nil, err
+	}
+	accounts, err := SwapV2AccountsFrom(programID, pool, poolState, owner, quote, inputAccount.Address, outputAccount.Address)
+	if err != nil {
+		return nil, err
+	}
+	args := SwapV2Args{Amount: quote.AmountIn, OtherAmountThreshold: quote.OtherAmountThreshold, IsBaseInput: baseIn}
+	if !baseIn {
+		args.Amount = quote.ReceivedAmount()
+	}
+	swapInstruction, err := NewSwapV2Instruction(programID, accounts, args)
+	if err != nil {
+		return nil, err
+	}
+
+	var instructions []solana.Instruction
+	instructions = append(instructions, inputAccount.Setup...)
+	instructions = append(instructions, outputAccount.Setup...)
+	instructions = append(instructions, swapInstruction)
+	instructions = append(instructions, inputAccount.Cleanup...)
+	instructions = append(instructions, outputAccount.Cleanup...)
+	// 跨越的 tick array 数和账户的创建、wrap、关闭都改变 compute units
+	key := txn.CacheKey(pool, fmt.Sprintf("swap_v2/%t/%d/%d", baseIn, len(accounts.TickArrays), len(instructions)))
+	writable := append([]solana.PublicKey{pool, accounts.InputVault, accounts.OutputVault}, accounts.TickArrays...)
+	return opts.Transaction(ctx, client, key, instructions, owner, writable, computeUnitLimit, priorityFee)
+}
+
+// PriceFromSqrtPriceX64 converts a Q64.64 sqrt price into the price of token 0 in token 1, adjusted
+// for the mint decimals.
+func PriceFromSqrtPriceX64(sqrtPriceX64 *big.Int, decimals0 uint8, decimals1 uint8) *big.Float {
+	price := new(big.Float).Quo(new(big.Float).SetInt(sqrtPriceX64), new(big.Float).SetInt(q64))
+	price.Mul(price, price)
+	scale := new(big.Float).SetFloat64(1)
+	for i := 0; i < int(decimals0); i++ {
+		scale.Mul(scale, big.NewFloat(10))
+	}
+	for i := 0; i < int(decimals1); i++ {
+		scale.Quo(scale, big.NewFloat(10))
+	}
+	return price.Mul(price, scale)
+}
@@ -0,0 +1,351 @@
+package clmm
+
+import (
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"math/big"
+	"testing"
+
+	"raydium-go/anchor"
+	"raydium-go/config"
+	"raydium-go/internal/rpctest"
+	"raydium-go/spl"
+	"raydium-go/txn"
+
+	bin "github.com/gagliardetto/binary"
+	"github.com/gagliardetto/solana-go"
+	"github.com/gagliardetto/solana-go/rpc"
+)
+
+func TestSqrtPriceAtTick(t *testing.T) {
+	for tick, expected := range map[int32]*big.Int{0: q64, MinTick: MinSqrtPriceX64, MaxTick: MaxSqrtPriceX64} {
+		price, err := GetSqrtPriceAtTick(tick)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if price.Cmp(expected) != 0 {
+			t.Errorf("sqrt price at %d = %s, want %s", tick, price, expected)
+		}
+	}
+	if _, err := GetSqrtPriceAtTick(MaxTick + 1); err == nil {
+		t.Error("expected ErrTickOutOfRange")
+	}
+	for _, tick := range []int32{MinTick, -1000, -1, 0, 1, 12345, MaxTick - 1} {
+		price, _ := GetSqrtPriceAtTick(tick)
+		if got, err := GetTickAtSqrtPrice(price); err != nil || got != tick {
+			t.Errorf("tick at sqrt price of %d = %d, %v", tick, got, err)
+		}
+		next, _ := GetSqrtPriceAtTick(tick + 1)
+		if got, _ := GetTickAtSqrtPrice(next.Sub(next, big.NewInt(1))); got != tick {
+			t.Errorf("tick below %d = %d", tick+1, got)
+		}
+	}
+}
+
+func TestTickArrayStartIndex(t *testing.T) {
+	cases := []struct {
+		tick     int32
+		expected int32
+	}{{0, 0}, {599, 0}, {600, 600}, {-1, -600}, {-600, -600}, {-601, -1200}}
+	for _, c := range cases {
+		if got := TickArrayStartIndex(c.tick, 10); got != c.expected {
+			t.Errorf("start index of %d = %d, want %d", c.tick, got, c.expected)
+		}
+	}
+}
+
+func TestAccountSizes(t *testing.T) {
+	for name, c := range map[string]struct {
+		d anchor.Discriminator
+		v interface{}
+		n int
+	}{
+		"PoolState":                {poolStateDiscriminator, PoolState{}, 1544},
+		"AmmConfig":                {ammConfigDiscriminator, AmmConfig{}, 117},
+		"TickArrayState":           {tickArrayDiscriminator, TickArrayState{}, 10240},
+		"TickArrayBitmapExtension": {bitmapExtensionDiscriminator, TickArrayBitmapExtension{}, 1832},
+		"PersonalPositionState":    {personalPositionDiscriminator, PersonalPositionState{}, 281},
+	} {
+		data, err := anchor.InstructionData(c.d, c.v)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(data) != c.n {
+			t.Errorf("%s size = %d, want %d", name, len(data), c.n)
+		}
+	}
+}
+
+func testPool(liquidity uint64) (PoolState, []TickArrayState) {
+	pool := PoolState{
+		TickSpacing:  10,
+		Liquidity:    bin.Uint128{Lo: liquidity},
+		SqrtPriceX64: bin.Uint128{Hi: 1},
+	}
+	lower := TickArrayState{StartTickIndex: -600}
+	lower.Ticks[0] = TickState{Tick: -600, LiquidityNet: bin.Int128{Lo: liquidity}, LiquidityGross: bin.Uint128{Lo: liquidity}}
+	upper := TickArrayState{StartTickIndex: 600}
+	upper.Ticks[0] = TickState{Tick: 600, LiquidityNet: bin.Int128(bin.Uint128{Lo: -liquidity, Hi: ^uint64(0)}), LiquidityGross: bin.Uint128{Lo: liquidity}}
+	pool.TickArrayBitmap[511/64] |= 1 << (511 % 64)
+	pool.TickArrayBitmap[513/64] |= 1 << (513 % 64)
+	return pool, []TickArrayState{lower, upper}
+}
+
+func TestSimulateSwap(t *testing.T) {
+	pool, tickArrays := testPool(1000000000000)
+	ammConfig := AmmConfig{TradeFeeRate: 2500}
+	if got := NextInitializedTickArrays(pool, nil, true, 5); len(got) != 1 || got[0] != -600 {
+		t.Fatalf("zero for one tick arrays = %v", got)
+	}
+
+	res, err := SimulateSwap(pool, ammConfig, nil, tickArrays, true, 1000000, true, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.AmountIn != 1000000 || res.AmountOut < 997000 || res.AmountOut >= 997500 || res.FeeAmount < 2500 {
+		t.Errorf("unexpected result: %+v", res)
+	}
+	if len(res.TickArrayStartIndexes) != 1 || res.TickArrayStartIndexes[0] != -600 || res.Tick != -1 {
+		t.Errorf("tick arrays = %v, tick = %d", res.TickArrayStartIndexes, res.Tick)
+	}
+
+	out, err := SimulateSwap(pool, ammConfig, nil, tickArrays, false, res.AmountOut, false, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out.AmountOut != res.AmountOut || out.TickArrayStartIndexes[0] != 600 {
+		t.Errorf("unexpected base output result: %+v", out)
+	}
+
+	if _, err := SimulateSwap(pool, ammConfig, nil, tickArrays, true, 100000000000, true, nil); err != ErrInsufficientLiquidity {
+		t.Errorf("expected ErrInsufficientLiquidity, got %v", err)
+	}
+	if _, err := SimulateSwap(pool, ammConfig, nil, tickArrays[1:], true, 1000000, true, nil); err == nil {
+		t.Error("expected ErrTickArraysNotLoaded")
+	}
+}
+
+func TestSwapV2Instruction(t *testing.T) {
+	programID := config.Raydium_CLMM_Program["mainnet"]
+	pool := solana.NewWallet().PublicKey()
+	poolState, _ := testPool(1)
+	quote := SwapQuote{ZeroForOne: true, TickArrayStartIndexes: []int32{-600}}
+	accounts, err := SwapV2AccountsFrom(programID, pool, poolState, solana.NewWallet().PublicKey(), quote, solana.PublicKey{}, solana.PublicKey{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected, _, _ := GetTickArrayAddress(programID, pool, -600)
+	if len(accounts.TickArrays) != 1 || !accounts.TickArrays[0].Equals(expected) {
+		t.Errorf("tick arrays = %v", accounts.TickArrays)
+	}
+	inst, err := NewSwapV2Instruction(programID, accounts, SwapV2Args{Amount: 1, IsBaseInput: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, _ := inst.Data()
+	if len(data) != 41 || len(inst.Accounts()) != 14 {
+		t.Errorf("data = %d bytes, accounts = %d", len(data), len(inst.Accounts()))
+	}
+
+	// 有 bitmap extension 时在 tick arrays 之前传入
+	quote.BitmapExtension = true
+	if accounts, err = SwapV2AccountsFrom(programID, pool, poolState, solana.NewWallet().PublicKey(), quote, solana.PublicKey{}, solana.PublicKey{}); err != nil {
+		t.Fatal(err)
+	}
+	if inst, err = NewSwapV2Instruction(programID, accounts, SwapV2Args{Amount: 1, IsBaseInput: true}); err != nil {
+		t.Fatal(err)
+	}
+	extension, _, _ := GetTickArrayBitmapExtensionAddress(programID, pool)
+	if metas := inst.Accounts(); len(metas) != 15 || !metas[13].PublicKey.Equals(extension) || !metas[13].IsWritable || !metas[14].PublicKey.Equals(expected) {
+		t.Errorf("accounts with extension = %v", metas)
+	}
+}
+
+func TestLiquidityAmounts(t *testing.T) {
+	pool, _ := testPool(0)
+	liquidity, err := LiquidityFromSingleAmount(pool, -600, 600, 1000000, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	amount0, amount1, err := AmountsFromLiquidity(pool, -600, 600, liquidity, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 价格为 1 且区间对称时两边数量相同
+	if amount0 > 1000000 || amount0 < 999999 || amount1 < amount0-1 || amount1 > amount0+1 {
+		t.Errorf("amounts = %d, %d", amount0, amount1)
+	}
+	if got := LiquidityFromAmounts(u128(pool.SqrtPriceX64), mustSqrtPrice(t, -600), mustSqrtPrice(t, 600), amount0, amount1); got.Cmp(liquidity) < 0 {
+		t.Errorf("liquidity from amounts = %s, want at least %s", got, liquidity)
+	}
+	if _, err := LiquidityFromSingleAmount(pool, 10, 600, 1000000, false); err == nil {
+		t.Error("expected an error for a token 1 deposit above the current tick")
+	}
+	if _, _, err := AmountsFromLiquidity(pool, 600, -600, liquidity, true); err == nil {
+		t.Error("expected an error for an inverted range")
+	}
+}
+
+func mustSqrtPrice(t *testing.T, tick int32) *big.Int {
+	price, err := GetSqrtPriceAtTick(tick)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return price
+}
+
+func TestPositionInstructions(t *testing.T) {
+	programID := config.Raydium_CLMM_Program["mainnet"]
+	pool := solana.NewWallet().PublicKey()
+	poolState, _ := testPool(1)
+	owner, nftMint := solana.NewWallet().PublicKey(), solana.NewWallet().PublicKey()
+	if _, err := PositionAccountsFrom(programID, pool, poolState, owner, nftMint, -605, 600, solana.PublicKey{}, solana.PublicKey{}, true); err == nil {
+		t.Error("expected an error for a tick off the tick spacing")
+	}
+	accounts, err := PositionAccountsFrom(programID, pool, poolState, owner, nftMint, -600, 600, solana.PublicKey{}, solana.PublicKey{}, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	withoutExtension, err := PositionAccountsFrom(programID, pool, poolState, owner, nftMint, -600, 600, solana.PublicKey{}, solana.PublicKey{}, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected, _, _ := GetPersonalPositionAddress(programID, nftMint)
+	if !accounts.PersonalPosition.Equals(expected) {
+		t.Errorf("personal position = %s, want %s", accounts.PersonalPosition, expected)
+	}
+
+	baseFlag := true
+	for name, c := range map[string]struct {
+		build    func() (solana.Instruction, error)
+		data     int
+		accounts int
+	}{
+		"open": {func() (solana.Instruction, error) {
+			return NewOpenPositionInstruction(programID, accounts, OpenPositionArgs{TickLowerIndex: -600, TickUpperIndex: 600, BaseFlag: &baseFlag})
+		}, 59, 21},
+		"increase": {func() (solana.Instruction, error) {
+			return NewIncreaseLiquidityInstruction(programID, accounts, IncreaseLiquidityArgs{})
+		}, 41, 16},
+		"decrease": {func() (solana.Instruction, error) {
+			return NewDecreaseLiquidityInstruction(programID, accounts, DecreaseLiquidityArgs{}, make([]solana.PublicKey, 3))
+		}, 40, 20},
+		"close": {func() (solana.Instruction, error) {
+			return NewClosePositionInstruction(programID, accounts, solana.Token2022ProgramID)
+		}, 8, 6},
+	} {
+		inst, err := c.build()
+		if err != nil {
+			t.Fatal(err)
+		}
+		data, _ := inst.Data()
+		if len(data) != c.data || len(inst.Accounts()) != c.accounts {
+			t.Errorf("%s: data = %d bytes, accounts = %d", name, len(data), len(inst.Accounts()))
+		}
+	}
+
+	// 池子没有 bitmap extension 时不传入该账户
+	for name, build := range map[string]func() (solana.Instruction, error){
+		"open": func() (solana.Instruction, error) {
+			return NewOpenPositionInstruction(programID, withoutExtension, OpenPositionArgs{TickLowerIndex: -600, TickUpperIndex: 600, BaseFlag: &baseFlag})
+		},
+		"increase": func() (solana.Instruction, error) {
+			return NewIncreaseLiquidityInstruction(programID, withoutExtension, IncreaseLiquidityArgs{})
+		},
+		"decrease": func() (solana.Instruction, error) {
+			return NewDecreaseLiquidityInstruction(programID, withoutExtension, DecreaseLiquidityArgs{}, nil)
+		},
+	} {
+		inst, err := build()
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, meta := range inst.Accounts() {
+			if meta.PublicKey.Equals(accounts.TickArrayBitmapExtension) {
+				t.Errorf("%s: bitmap extension passed without one", name)
+			}
+		}
+	}
+}
+
+func TestPositionRewardAccounts(t *testing.T) {
+	poolState, _ := testPool(1)
+	other := solana.NewWallet().PublicKey()
+	poolState.TokenMint0, poolState.TokenMint1 = spl.NativeMint, other
+	poolState.RewardInfos[0].TokenMint, poolState.RewardInfos[0].TokenVault = spl.NativeMint, solana.NewWallet().PublicKey()
+	poolState.RewardInfos[1].TokenMint, poolState.RewardInfos[1].TokenVault = other, solana.NewWallet().PublicKey()
+	pc := &positionContext{
+		poolState: poolState,
+		mint0:     spl.Mint{Address: spl.NativeMint, Program: solana.TokenProgramID},
+		mint1:     spl.Mint{Address: other, Program: solana.TokenProgramID},
+	}
+	server := rpctest.NewServer(t, func(method string, params []json.RawMessage) interface{} {
+		switch method {
+		case "getAccountInfo":
+			return map[string]interface{}{"context": map[string]interface{}{"slot": 1}, "value": nil}
+		case "getMinimumBalanceForRentExemption":
+			return 2039280
+		case "getLatestBlockhash":
+			var opts map[string]interface{}
+			json.Unmarshal(params[0], &opts)
+			if opts["commitment"] != string(rpc.CommitmentConfirmed) {
+				t.Errorf("blockhash commitment %v", opts["commitment"])
+			}
+			return map[string]interface{}{"context": map[string]interface{}{"slot": 1}, "value": map[string]interface{}{
+				"blockhash":            solana.Hash{1}.String(),
+				"lastValidBlockHeight": 150,
+			}}
+		}
+		t.Errorf("unexpected request %s", method)
+		return nil
+	})
+	client := rpc.New(server.URL)
+	owner := solana.NewWallet().PublicKey()
+	if err := pc.tokenAccounts(context.Background(), client, owner, 0, 0); err != nil {
+		t.Fatal(err)
+	}
+	instrs, cleanup := len(pc.instrs), len(pc.cleanup)
+	// 奖励 mint 与池子 mint 相同时复用池子的 token 账户，不会重复创建或关闭
+	accounts, err := pc.rewardAccounts(context.Background(), client, owner)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(accounts) != 6 || !accounts[1].Equals(pc.tokenAcct0) || !accounts[4].Equals(pc.tokenAcct1) {
+		t.Errorf("reward accounts = %v", accounts)
+	}
+	if len(pc.instrs) != instrs || len(pc.cleanup) != cleanup || cleanup != 1 {
+		t.Errorf("instructions = %d, cleanup = %d", len(pc.instrs), len(pc.cleanup))
+	}
+	// 仓位交易和 swap 一样按 opts 设置 compute budget 和 blockhash
+	pc.opts = txn.SwapOptions{ComputeUnitLimit: 500000, PriorityFee: 7, BlockhashCommitment: rpc.CommitmentConfirmed}
+	tx, err := pc.transaction(context.Background(), client, owner, "decrease_liquidity", solana.NewInstruction(pc.programID, nil, []byte{1}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	limit, price := tx.Message.Instructions[0].Data, tx.Message.Instructions[1].Data
+	if binary.LittleEndian.Uint32(limit[1:]) != 500000 || binary.LittleEndian.Uint64(price[1:]) != 7 || tx.Message.RecentBlockhash != (solana.Hash{1}) {
+		t.Errorf("limit %v, price %v, blockhash %s", limit, price, tx.Message.RecentBlockhash)
+	}
+}
+
+func TestCheckPositionEmpty(t *testing.T) {
+	owed := func(f func(*PersonalPositionState)) Position {
+		position := Position{NftMint: solana.NewWallet().PublicKey()}
+		f(&position.State)
+		return position
+	}
+	if err := checkPositionEmpty(owed(func(*PersonalPositionState) {})); err != nil {
+		t.Errorf("empty position: %v", err)
+	}
+	for name, position := range map[string]Position{
+		"liquidity": owed(func(s *PersonalPositionState) { s.Liquidity = bin.Uint128{Lo: 1} }),
+		"fees0":     owed(func(s *PersonalPositionState) { s.TokenFeesOwed0 = 1 }),
+		"fees1":     owed(func(s *PersonalPositionState) { s.TokenFeesOwed1 = 1 }),
+		"reward":    owed(func(s *PersonalPositionState) { s.RewardInfos[2].RewardAmountOwed = 1 }),
+	} {
+		if err := checkPositionEmpty(position); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+}
@@ -0,0 +1,110 @@
+package clmm
+
+import (
+	"fmt"
+	"math/big"
+)
+
+// LiquidityFromAmount0 对应链上 get_liquidity_from_amount_0
+func LiquidityFromAmount0(sqrtPriceA *big.Int, sqrtPriceB *big.Int, amount0 uint64) *big.Int {
+	if sqrtPriceA.Cmp(sqrtPriceB) > 0 {
+		sqrtPriceA, sqrtPriceB = sqrtPriceB, sqrtPriceA
+	}
+	intermediate := new(big.Int).Mul(sqrtPriceA, sqrtPriceB)
+	intermediate.Quo(intermediate, q64)
+	liquidity := intermediate.Mul(intermediate, new(big.Int).SetUint64(amount0))
+	return liquidity.Quo(liquidity, new(big.Int).Sub(sqrtPriceB, sqrtPriceA))
+}
+
+// LiquidityFromAmount1 对应链上 get_liquidity_from_amount_1
+func LiquidityFromAmount1(sqrtPriceA *big.Int, sqrtPriceB *big.Int, amount1 uint64) *big.Int {
+	if sqrtPriceA.Cmp(sqrtPriceB) > 0 {
+		sqrtPriceA, sqrtPriceB = sqrtPriceB, sqrtPriceA
+	}
+	liquidity := new(big.Int).Lsh(new(big.Int).SetUint64(amount1), 64)
+	return liquidity.Quo(liquidity, new(big.Int).Sub(sqrtPriceB, sqrtPriceA))
+}
+
+// LiquidityFromAmounts 对应链上 get_liquidity_from_amounts，取两个数量中能提供的较小流动性
+func LiquidityFromAmounts(sqrtPrice *big.Int, sqrtPriceA *big.Int, sqrtPriceB *big.Int, amount0 uint64, amount1 uint64) *big.Int {
+	if sqrtPriceA.Cmp(sqrtPriceB) > 0 {
+		sqrtPriceA, sqrtPriceB = sqrtPriceB, sqrtPriceA
+	}
+	switch {
+	case sqrtPrice.Cmp(sqrtPriceA) <= 0:
+		return LiquidityFromAmount0(sqrtPriceA, sqrtPriceB, amount0)
+	case sqrtPrice.Cmp(sqrtPriceB) < 0:
+		liquidity0 := LiquidityFromAmount0(sqrtPrice, sqrtPriceB, amount0)
+		liquidity1 := LiquidityFromAmount1(sqrtPriceA, sqrtPrice, amount1)
+		if liquidity0.Cmp(liquidity1) < 0 {
+			return liquidity0
+		}
+		return liquidity1
+	default:
+		return LiquidityFromAmount1(sqrtPriceA, sqrtPriceB, amount1)
+	}
+}
+
+// LiquidityFromSingleAmount returns the liquidity that amount of token 0 (or token 1) provides over
+// [tickLower, tickUpper] at the current pool price.
+func LiquidityFromSingleAmount(poolState PoolState, tickLower int32, tickUpper int32, amount uint64, baseToken0 bool) (*big.Int, error) {
+	sqrtPriceLower, sqrtPriceUpper, err := rangeSqrtPrices(tickLower, tickUpper)
+	if err != nil {
+		return nil, err
+	}
+	sqrtPrice := u128(poolState.SqrtPriceX64)
+	if baseToken0 {
+		if poolState.TickCurrent >= tickUpper {
+			return nil, fmt.Errorf("range [%d, %d] is below the current tick and takes token 1 only", tickLower, tickUpper)
+		}
+		if sqrtPrice.Cmp(sqrtPriceLower) < 0 {
+			sqrtPrice = sqrtPriceLower
+		}
+		return LiquidityFromAmount0(sqrtPrice, sqrtPriceUpper, amount), nil
+	}
+	if poolState.TickCurrent < tickLower {
+		return nil, fmt.Errorf("range [%d, %d] is above the current tick and takes token 0 only", tickLower, tickUpper)
+	}
+	if sqrtPrice.Cmp(sqrtPriceUpper) > 0 {
+		sqrtPrice = sqrtPriceUpper
+	}
+	return LiquidityFromAmount1(sqrtPriceLower, sqrtPrice, amount), nil
+}
+
+// AmountsFromLiquidity 对应链上 get_delta_amounts_signed，增加流动性时向上取整，减少时向下取整
+func AmountsFromLiquidity(poolState PoolState, tickLower int32, tickUpper int32, liquidity *big.Int, roundUp bool) (uint64, uint64, error) {
+	sqrtPriceLower, sqrtPriceUpper, err := rangeSqrtPrices(tickLower, tickUpper)
+	if err != nil {
+		return 0, 0, err
+	}
+	sqrtPrice := u128(poolState.SqrtPriceX64)
+	amount0, amount1 := new(big.Int), new(big.Int)
+	switch {
+	case poolState.TickCurrent < tickLower:
+		amount0 = GetDeltaAmount0(sqrtPriceLower, sqrtPriceUpper, liquidity, roundUp)
+	case poolState.TickCurrent < tickUpper:
+		amount0 = GetDeltaAmount0(sqrtPrice, sqrtPriceUpper, liquidity, roundUp)
+		amount1 = GetDeltaAmount1(sqrtPriceLower, sqrtPrice, liquidity, roundUp)
+	default:
+		amount1 = GetDeltaAmount1(sqrtPriceLower, sqrtPriceUpper, liquidity, roundUp)
+	}
+	if amount0.Cmp(maxU64) > 0 || amount1.Cmp(maxU64) > 0 {
+		return 0, 0, fmt.Errorf("liquidity %s overflows token amounts", liquidity)
+	}
+	return amount0.Uint64(), amount1.Uint64(), nil
+}
+
+func rangeSqrtPrices(tickLower int32, tickUpper int32) (*big.Int, *big.Int, error) {
+	if tickLower >= tickUpper {
+		return nil, nil, fmt.Errorf("invalid tick range [%d, %d]", tickLower, tickUpper)
+	}
+	sqrtPriceLower, err := GetSqrtPriceAtTick(tickLower)
+	if err != nil {
+		return nil, nil, err
+	}
+	sqrtPriceUpper, err := GetSqrtPriceAtTick(tickUpper)
+	if err != nil {
+		return nil, nil, err
+	}
+	return sqrtPriceLower, sqrtPriceUpper, nil
+}
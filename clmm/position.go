@@ -0,0 +1,670 @@
+package clmm
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"math/big"
+
+	"raydium-go/anchor"
+	"raydium-go/config"
+	"raydium-go/spl"
+	"raydium-go/txn"
+
+	bin "github.com/gagliardetto/binary"
+	"github.com/gagliardetto/solana-go"
+	"github.com/gagliardetto/solana-go/rpc"
+)
+
+var (
+	positionSeed                  = []byte("position")
+	personalPositionDiscriminator = anchor.AccountDiscriminator("PersonalPositionState")
+
+	openPositionDiscriminator      = anchor.InstructionDiscriminator("open_position_with_token22_nft")
+	increaseLiquidityDiscriminator = anchor.InstructionDiscriminator("increase_liquidity_v2")
+	decreaseLiquidityDiscriminator = anchor.InstructionDiscriminator("decrease_liquidity_v2")
+	closePositionDiscriminator     = anchor.InstructionDiscriminator("close_position")
+)
+
+// PositionRewardInfo 对应 Rust 中的 PositionRewardInfo
+type PositionRewardInfo struct {
+	GrowthInsideLastX64 bin.Uint128
+	RewardAmountOwed    uint64
+}
+
+// PersonalPositionState 对应 Rust 中的 PersonalPositionState
+type PersonalPositionState struct {
+	Bump                    [1]uint8
+	NftMint                 solana.PublicKey
+	PoolID                  solana.PublicKey
+	TickLowerIndex          int32
+	TickUpperIndex          int32
+	Liquidity               bin.Uint128
+	FeeGrowthInside0LastX64 bin.Uint128
+	FeeGrowthInside1LastX64 bin.Uint128
+	TokenFeesOwed0          uint64
+	TokenFeesOwed1          uint64
+	RewardInfos             [RewardNum]PositionRewardInfo
+	RecentEpoch             uint64
+	Padding                 [7]uint64
+}
+
+// Position 为钱包持有的一个 CLMM 仓位
+type Position struct {
+	Address solana.PublicKey
+	NftMint solana.PublicKey
+	// 持有 NFT 的 token 账户及其所属 token program
+	NftAccount      solana.PublicKey
+	NftTokenProgram solana.PublicKey
+	State           PersonalPositionState
+}
+
+func DecodePersonalPosition(data []byte) (PersonalPositionState, error) {
+	var position PersonalPositionState
+	err := anchor.DecodeAccount(data, personalPositionDiscriminator, &position)
+	return position, err
+}
+
+func GetPersonalPositionAddress(programID solana.PublicKey, nftMint solana.PublicKey) (solana.PublicKey, uint8, error) {
+	return solana.FindProgramAddress([][]byte{positionSeed, nftMint[:]}, programID)
+}
+
+func GetProtocolPositionAddress(programID solana.PublicKey, pool solana.PublicKey, tickLower int32, tickUpper int32) (solana.PublicKey, uint8, error) {
+	lower, upper := make([]byte, 4), make([]byte, 4)
+	binary.BigEndian.PutUint32(lower, uint32(tickLower))
+	binary.BigEndian.PutUint32(upper, uint32(tickUpper))
+	return solana.FindProgramAddress([][]byte{positionSeed, pool[:], lower, upper}, programID)
+}
+
+// GetPositions lists the positions of owner by looking up the personal position of every NFT held
+// under the token and Token-2022 programs.
+func GetPositions(ctx context.Context, client *rpc.Client, programID solana.PublicKey, owner solana.PublicKey) ([]Position, error) {
+	var candidates []Position
+	for _, tokenProgram := range []solana.PublicKey{solana.TokenProgramID, solana.Token2022ProgramID} {
+		program := tokenProgram
+		resp, err := client.GetTokenAccountsByOwner(ctx, owner, &rpc.GetTokenAccountsConfig{ProgramId: &program}, &rpc.GetTokenAccountsOpts{Encoding: solana.EncodingBase64})
+		if err != nil {
+			return nil, err
+		}
+		for _, account := range resp.Value {
+			data := account.Account.Data.GetBinary()
+			if len(data) < 72 || binary.LittleEndian.Uint64(data[64:72]) != 1 {
+				continue
+			}
+			mint := solana.PublicKeyFromBytes(data[0:32])
+			address, _, err := GetPersonalPositionAddress(programID, mint)
+			if err != nil {
+				return nil, err
+			}
+			candidates = append(candidates, Position{Address: address, NftMint: mint, NftAccount: account.Pubkey, NftTokenProgram: program})
+		}
+	}
+	var positions []Position
+	for start := 0; start < len(candidates); start += 100 {
+		batch := candidates[start:min(start+100, len(candidates))]
+		addresses := make([]solana.PublicKey, len(batch))
+		for i := range batch {
+			addresses[i] = batch[i].Address
+		}
+		resp, err := client.GetMultipleAccounts(ctx, addresses...)
+		if err != nil {
+			return nil, err
+		}
+		for i, account := range resp.Value {
+			if account == nil || !account.Owner.Equals(programID) {
+				continue
+			}
+			state, err := DecodePersonalPosition(account.Data.GetBinary())
+			if err != nil {
+				continue
+			}
+			batch[i].State = state
+			positions = append(positions, batch[i])
+		}
+	}
+	return positions, nil
+}
+
+// GetPosition returns the position of nftMint held by owner.
+func GetPosition(ctx context.Context, client *rpc.Client, programID solana.PublicKey, owner solana.PublicKey, nftMint solana.PublicKey) (Position, error) {
+	positions, err := GetPositions(ctx, client, programID, owner)
+	if err != nil {
+		return Position{}, err
+	}
+	for _, position := range positions {
+		if position.NftMint.Equals(nftMint) {
+			return position, nil
+		}
+	}
+	return Position{}, fmt.Errorf("position %s not held by %s", nftMint, owner)
+}
+
+type OpenPositionArgs struct {
+	TickLowerIndex           int32
+	TickUpperIndex           int32
+	TickArrayLowerStartIndex int32
+	TickArrayUpperStartIndex int32
+	Liquidity                bin.Uint128
+	Amount0Max               uint64
+	Amount1Max               uint64
+	WithMetadata             bool
+	BaseFlag                 *bool `bin:"optional"`
+}
+
+type IncreaseLiquidityArgs struct {
+	Liquidity  bin.Uint128
+	Amount0Max uint64
+	Amount1Max uint64
+	BaseFlag   *bool `bin:"optional"`
+}
+
+type DecreaseLiquidityArgs struct {
+	Liquidity  bin.Uint128
+	Amount0Min uint64
+	Amount1Min uint64
+}
+
+// PositionAccounts 为仓位相关指令共用的账户
+type PositionAccounts struct {
+	Owner                    solana.PublicKey
+	NftMint                  solana.PublicKey
+	NftAccount               solana.PublicKey
+	PoolState                solana.PublicKey
+	ProtocolPosition         solana.PublicKey
+	PersonalPosition         solana.PublicKey
+	TickArrayLower           solana.PublicKey
+	TickArrayUpper           solana.PublicKey
+	TokenAccount0            solana.PublicKey
+	TokenAccount1            solana.PublicKey
+	TokenVault0              solana.PublicKey
+	TokenVault1              solana.PublicKey
+	Vault0Mint               solana.PublicKey
+	Vault1Mint               solana.PublicKey
+	TickArrayBitmapExtension solana.PublicKey
+}
+
+// PositionAccountsFrom derives the PDAs of the position of nftMint over [tickLower, tickUpper].
+// The NFT account is the owner's Token-2022 ATA and the token accounts are the owner's ATAs. The
+// bitmap extension is only included when the pool has one.
+func PositionAccountsFrom(programID solana.PublicKey, pool solana.PublicKey, poolState PoolState, owner solana.PublicKey, nftMint solana.PublicKey, tickLower int32, tickUpper int32, tokenAccount0 solana.PublicKey, tokenAccount1 solana.PublicKey, extension bool) (PositionAccounts, error) {
+	if tickLower%int32(poolState.TickSpacing) != 0 || tickUpper%int32(poolState.TickSpacing) != 0 {
+		return PositionAccounts{}, fmt.Errorf("ticks must be multiples of the tick spacing %d", poolState.TickSpacing)
+	}
+	accounts := PositionAccounts{
+		Owner:         owner,
+		NftMint:       nftMint,
+		PoolState:     pool,
+		TokenAccount0: tokenAccount0,
+		TokenAccount1: tokenAccount1,
+		TokenVault0:   poolState.TokenVault0,
+		TokenVault1:   poolState.TokenVault1,
+		Vault0Mint:    poolState.TokenMint0,
+		Vault1Mint:    poolState.TokenMint1,
+	}
+	var err error
+	if accounts.NftAccount, _, err = spl.FindAssociatedTokenAddress(owner, nftMint, solana.Token2022ProgramID); err != nil {
+		return accounts, err
+	}
+	if accounts.ProtocolPosition, _, err = GetProtocolPositionAddress(programID, pool, tickLower, tickUpper); err != nil {
+		return accounts, err
+	}
+	if accounts.PersonalPosition, _, err = GetPersonalPositionAddress(programID, nftMint); err != nil {
+		return accounts, err
+	}
+	if accounts.TickArrayLower, _, err = GetTickArrayAddress(programID, pool, TickArrayStartIndex(tickLower, poolState.TickSpacing)); err != nil {
+		return accounts, err
+	}
+	if accounts.TickArrayUpper, _, err = GetTickArrayAddress(programID, pool, TickArrayStartIndex(tickUpper, poolState.TickSpacing)); err != nil {
+		return accounts, err
+	}
+	if extension {
+		if accounts.TickArrayBitmapExtension, _, err = GetTickArrayBitmapExtensionAddress(programID, pool); err != nil {
+			return accounts, err
+		}
+	}
+	return accounts, nil
+}
+
+func NewOpenPositionInstruction(programID solana.PublicKey, accounts PositionAccounts, args OpenPositionArgs) (solana.Instruction, error) {
+	data, err := anchor.InstructionData(openPositionDiscriminator, args)
+	if err != nil {
+		return nil, err
+	}
+	metas := []*solana.AccountMeta{
+		solana.NewAccountMeta(accounts.Owner, true, true),
+		solana.NewAccountMeta(accounts.Owner, false, false),
+		solana.NewAccountMeta(accounts.NftMint, true, true),
+		solana.NewAccountMeta(accounts.NftAccount, true, false),
+		solana.NewAccountMeta(accounts.PoolState, true, false),
+		solana.NewAccountMeta(accounts.ProtocolPosition, true, false),
+		solana.NewAccountMeta(accounts.TickArrayLower, true, false),
+		solana.NewAccountMeta(accounts.TickArrayUpper, true, false),
+		solana.NewAccountMeta(accounts.PersonalPosition, true, false),
+		solana.NewAccountMeta(accounts.TokenAccount0, true, false),
+		solana.NewAccountMeta(accounts.TokenAccount1, true, false),
+		solana.NewAccountMeta(accounts.TokenVault0, true, false),
+		solana.NewAccountMeta(accounts.TokenVault1, true, false),
+		solana.NewAccountMeta(solana.SysVarRentPubkey, false, false),
+		solana.NewAccountMeta(solana.SystemProgramID, false, false),
+		solana.NewAccountMeta(solana.TokenProgramID, false, false),
+		solana.NewAccountMeta(solana.SPLAssociatedTokenAccountProgramID, false, false),
+		solana.NewAccountMeta(solana.Token2022ProgramID, false, false),
+		solana.NewAccountMeta(accounts.Vault0Mint, false, false),
+		solana.NewAccountMeta(accounts.Vault1Mint, false, false),
+	}
+	return solana.NewInstruction(programID, withBitmapExtension(metas, accounts), data), nil
+}
+
+func NewIncreaseLiquidityInstruction(programID solana.PublicKey, accounts PositionAccounts, args IncreaseLiquidityArgs) (solana.Instruction, error) {
+	data, err := anchor.InstructionData(increaseLiquidityDiscriminator, args)
+	if err != nil {
+		return nil, err
+	}
+	metas := []*solana.AccountMeta{
+		solana.NewAccountMeta(accounts.Owner, false, true),
+		solana.NewAccountMeta(accounts.NftAccount, false, false),
+		solana.NewAccountMeta(accounts.PoolState, true, false),
+		solana.NewAccountMeta(accounts.ProtocolPosition, true, false),
+		solana.NewAccountMeta(accounts.PersonalPosition, true, false),
+		solana.NewAccountMeta(accounts.TickArrayLower, true, false),
+		solana.NewAccountMeta(accounts.TickArrayUpper, true, false),
+		solana.NewAccountMeta(accounts.TokenAccount0, true, false),
+		solana.NewAccountMeta(accounts.TokenAccount1, true, false),
+		solana.NewAccountMeta(accounts.TokenVault0, true, false),
+		solana.NewAccountMeta(accounts.TokenVault1, true, false),
+		solana.NewAccountMeta(solana.TokenProgramID, false, false),
+		solana.NewAccountMeta(solana.Token2022ProgramID, false, false),
+		solana.NewAccountMeta(accounts.Vault0Mint, false, false),
+		solana.NewAccountMeta(accounts.Vault1Mint, false, false),
+	}
+	return solana.NewInstruction(programID, withBitmapExtension(metas, accounts), data), nil
+}
+
+// NewDecreaseLiquidityInstruction also collects the fees and, for every reward passed as
+// (vault, recipient account, mint), the rewards of the position. A zero liquidity only collects.
+func NewDecreaseLiquidityInstruction(programID solana.PublicKey, accounts PositionAccounts, args DecreaseLiquidityArgs, rewardAccounts []solana.PublicKey) (solana.Instruction, error) {
+	data, err := anchor.InstructionData(decreaseLiquidityDiscriminator, args)
+	if err != nil {
+		return nil, err
+	}
+	metas := []*solana.AccountMeta{
+		solana.NewAccountMeta(accounts.Owner, false, true),
+		solana.NewAccountMeta(accounts.NftAccount, false, false),
+		solana.NewAccountMeta(accounts.PersonalPosition, true, false),
+		solana.NewAccountMeta(accounts.PoolState, true, false),
+		solana.NewAccountMeta(accounts.ProtocolPosition, true, false),
+		solana.NewAccountMeta(accounts.TokenVault0, true, false),
+		solana.NewAccountMeta(accounts.TokenVault1, true, false),
+		solana.NewAccountMeta(accounts.TickArrayLower, true, false),
+		solana.NewAccountMeta(accounts.TickArrayUpper, true, false),
+		solana.NewAccountMeta(accounts.TokenAccount0, true, false),
+		solana.NewAccountMeta(accounts.TokenAccount1, true, false),
+		solana.NewAccountMeta(solana.TokenProgramID, false, false),
+		solana.NewAccountMeta(solana.Token2022ProgramID, false, false),
+		solana.NewAccountMeta(solana.MemoProgramID, false, false),
+		solana.NewAccountMeta(accounts.Vault0Mint, false, false),
+		solana.NewAccountMeta(accounts.Vault1Mint, false, false),
+	}
+	metas = withBitmapExtension(metas, accounts)
+	for i, account := range rewardAccounts {
+		// 奖励 mint 只读，vault 和接收账户可写
+		metas = append(metas, solana.NewAccountMeta(account, i%3 != 2, false))
+	}
+	return solana.NewInstruction(programID, metas, data), nil
+}
+
+// withBitmapExtension 在池子有 bitmap extension 时把它加在固定账户之后
+func withBitmapExtension(metas []*solana.AccountMeta, accounts PositionAccounts) []*solana.AccountMeta {
+	if accounts.TickArrayBitmapExtension.IsZero() {
+		return metas
+	}
+	return append(metas, solana.NewAccountMeta(accounts.TickArrayBitmapExtension, true, false))
+}
+
+func NewClosePositionInstruction(programID solana.PublicKey, accounts PositionAccounts, nftTokenProgram solana.PublicKey) (solana.Instruction, error) {
+	data, err := anchor.InstructionData(closePositionDiscriminator, nil)
+	if err != nil {
+		return nil, err
+	}
+	return solana.NewInstruction(programID, []*solana.AccountMeta{
+		solana.NewAccountMeta(accounts.Owner, true, true),
+		solana.NewAccountMeta(accounts.NftMint, true, false),
+		solana.NewAccountMeta(accounts.NftAccount, true, false),
+		solana.NewAccountMeta(accounts.PersonalPosition, true, false),
+		solana.NewAccountMeta(solana.SystemProgramID, false, false),
+		solana.NewAccountMeta(nftTokenProgram, false, false),
+	}, data), nil
+}
+
+// positionContext 汇总仓位交易需要的链上状态
+type positionContext struct {
+	programID solana.PublicKey
+	pool      solana.PublicKey
+	poolState PoolState
+	mint0     spl.Mint
+	mint1     spl.Mint
+	epoch     uint64
+	// 池子是否有 tick array bitmap extension 账户
+	extension  bool
+	instrs     []solana.Instruction
+	cleanup    []solana.Instruction
+	tokenAcct0 solana.PublicKey
+	tokenAcct1 solana.PublicKey
+	opts       txn.SwapOptions
+}
+
+// loadPositionContext 读取池子状态，token 账户由 tokenAccounts 在数量确定后补上
+func loadPositionContext(ctx context.Context, client *rpc.Client, network string, pool solana.PublicKey, opts txn.SwapOptions) (*positionContext, error) {
+	programID, err := config.CLMMProgram(network)
+	if err != nil {
+		return nil, err
+	}
+	commitment := opts.StateCommitment()
+	poolState, err := GetPoolState(ctx, client, pool, commitment)
+	if err != nil {
+		return nil, err
+	}
+	mints, err := spl.GetMints(ctx, client, poolState.TokenMint0, poolState.TokenMint1)
+	if err != nil {
+		return nil, err
+	}
+	extension, err := GetTickArrayBitmapExtension(ctx, client, programID, pool, commitment)
+	if err != nil {
+		return nil, err
+	}
+	pc := &positionContext{programID: programID, pool: pool, poolState: poolState, mint0: mints[0], mint1: mints[1], extension: extension != nil, opts: opts}
+	if pc.mint0.TransferFeeConfig != nil || pc.mint1.TransferFeeConfig != nil {
+		epochInfo, err := client.GetEpochInfo(ctx, commitment)
+		if err != nil {
+			return nil, err
+		}
+		pc.epoch = epochInfo.Epoch
+	}
+	return pc, nil
+}
+
+// tokenAccounts 准备 owner 的两个 token 账户，wrap0/wrap1 为需要包装的 SOL 数量
+func (pc *positionContext) tokenAccounts(ctx context.Context, client *rpc.Client, owner solana.PublicKey, wrap0 uint64, wrap1 uint64) error {
+	pc.instrs = nil
+	for i, mint := range []spl.Mint{pc.mint0, pc.mint1} {
+		wrap := wrap0
+		if i == 1 {
+			wrap = wrap1
+		}
+		account, err := spl.TokenAccountInstructions(ctx, client, pc.opts.StateCommitment(), owner, owner, mint, wrap, pc.opts.WSOLPolicy)
+		if err != nil {
+			return err
+		}
+		pc.instrs = append(pc.instrs, account.Setup...)
+		pc.cleanup = append(pc.cleanup, account.Cleanup...)
+		if i == 0 {
+			pc.tokenAcct0 = account.Address
+		} else {
+			pc.tokenAcct1 = account.Address
+		}
+	}
+	return nil
+}
+
+// rewardAccounts 返回 decrease_liquidity_v2 的奖励账户（vault、接收账户、mint）。奖励 mint 与池子
+// mint 相同时复用池子的 token 账户，避免重复创建和关闭
+func (pc *positionContext) rewardAccounts(ctx context.Context, client *rpc.Client, owner solana.PublicKey) ([]solana.PublicKey, error) {
+	recipients := map[solana.PublicKey]solana.PublicKey{
+		pc.poolState.TokenMint0: pc.tokenAcct0,
+		pc.poolState.TokenMint1: pc.tokenAcct1,
+	}
+	var accounts []solana.PublicKey
+	for _, reward := range pc.poolState.RewardInfos {
+		if reward.TokenMint.IsZero() {
+			continue
+		}
+		recipient, ok := recipients[reward.TokenMint]
+		if !ok {
+			rewardMint, err := spl.GetMint(ctx, client, reward.TokenMint)
+			if err != nil {
+				return nil, err
+			}
+			account, err := spl.TokenAccountInstructions(ctx, client, pc.opts.StateCommitment(), owner, owner, rewardMint, 0, pc.opts.WSOLPolicy)
+			if err != nil {
+				return nil, err
+			}
+			pc.instrs = append(pc.instrs, account.Setup...)
+			pc.cleanup = append(pc.cleanup, account.Cleanup...)
+			recipient = account.Address
+			recipients[reward.TokenMint] = recipient
+		}
+		accounts = append(accounts, reward.TokenVault, recipient, reward.TokenMint)
+	}
+	return accounts, nil
+}
+
+// transaction 通过 opts.Transaction 构建，name 为指令名，与指令数一起作为 compute unit 的缓存 key
+func (pc *positionContext) transaction(ctx context.Context, client *rpc.Client, owner solana.PublicKey, name string, instruction solana.Instruction) (*solana.Transaction, error) {
+	instructions := append(append([]solana.Instruction{}, pc.instrs...), instruction)
+	instructions = append(instructions, pc.cleanup...)
+	key := txn.CacheKey(pc.pool, fmt.Sprintf("%s/%d", name, len(instructions)))
+	writable := []solana.PublicKey{pc.pool, pc.poolState.TokenVault0, pc.poolState.TokenVault1}
+	return pc.opts.Transaction(ctx, client, key, instructions, owner, writable, computeUnitLimit, priorityFee)
+}
+
+// maxAmount adds slippage and the Token-2022 fee needed for amount to reach the pool.
+func maxAmount(mint spl.Mint, epoch uint64, amount uint64, slippage float64) uint64 {
+	amount = uint64(float64(amount) * float64(1+slippage))
+	return amount + mint.InverseTransferFee(epoch, amount)
+}
+
+// minAmount removes the Token-2022 fee and slippage from an amount leaving the pool.
+func minAmount(mint spl.Mint, epoch uint64, amount uint64, slippage float64) uint64 {
+	amount -= mint.TransferFee(epoch, amount)
+	return uint64(float64(amount) * float64(1-slippage))
+}
+
+// BuildOpenPositionTransaction builds a transaction that opens a position over [tickLower, tickUpper]
+// funded with amount of token 0 (or token 1) and the matching amount of the other token. The returned
+// key is the new position NFT mint, which signs the transaction together with owner.
+func BuildOpenPositionTransaction(ctx context.Context, client *rpc.Client, network string, poolAddress string, tickLower int32, tickUpper int32, amount uint64, baseToken0 bool, slippage float64, owner solana.PublicKey, opts txn.SwapOptions) (*solana.Transaction, solana.PrivateKey, error) {
+	pool, err := solana.PublicKeyFromBase58(poolAddress)
+	if err != nil {
+		return nil, nil, err
+	}
+	pc, err := loadPositionContext(ctx, client, network, pool, opts)
+	if err != nil {
+		return nil, nil, err
+	}
+	liquidity, err := LiquidityFromSingleAmount(pc.poolState, tickLower, tickUpper, amount, baseToken0)
+	if err != nil {
+		return nil, nil, err
+	}
+	amount0, amount1, err := AmountsFromLiquidity(pc.poolState, tickLower, tickUpper, liquidity, true)
+	if err != nil {
+		return nil, nil, err
+	}
+	amount0Max, amount1Max := maxAmount(pc.mint0, pc.epoch, amount0, slippage), maxAmount(pc.mint1, pc.epoch, amount1, slippage)
+	if err := pc.tokenAccounts(ctx, client, owner, amount0Max, amount1Max); err != nil {
+		return nil, nil, err
+	}
+	nftMint := solana.NewWallet().PrivateKey
+	accounts, err := PositionAccountsFrom(pc.programID, pool, pc.poolState, owner, nftMint.PublicKey(), tickLower, tickUpper, pc.tokenAcct0, pc.tokenAcct1, pc.extension)
+	if err != nil {
+		return nil, nil, err
+	}
+	instruction, err := NewOpenPositionInstruction(pc.programID, accounts, OpenPositionArgs{
+		TickLowerIndex:           tickLower,
+		TickUpperIndex:           tickUpper,
+		TickArrayLowerStartIndex: TickArrayStartIndex(tickLower, pc.poolState.TickSpacing),
+		TickArrayUpperStartIndex: TickArrayStartIndex(tickUpper, pc.poolState.TickSpacing),
+		Liquidity:                uint128(liquidity),
+		Amount0Max:               amount0Max,
+		Amount1Max:               amount1Max,
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	tx, err := pc.transaction(ctx, client, owner, "open_position", instruction)
+	if err != nil {
+		return nil, nil, err
+	}
+	return tx, nftMint, nil
+}
+
+// BuildIncreaseLiquidityTransaction adds amount of token 0 (or token 1) and the matching amount of
+// the other token to an existing position.
+func BuildIncreaseLiquidityTransaction(ctx context.Context, client *rpc.Client, network string, nftMint solana.PublicKey, amount uint64, baseToken0 bool, slippage float64, owner solana.PublicKey, opts txn.SwapOptions) (*solana.Transaction, error) {
+	position, pc, err := loadPosition(ctx, client, network, nftMint, owner, opts)
+	if err != nil {
+		return nil, err
+	}
+	liquidity, err := LiquidityFromSingleAmount(pc.poolState, position.State.TickLowerIndex, position.State.TickUpperIndex, amount, baseToken0)
+	if err != nil {
+		return nil, err
+	}
+	amount0, amount1, err := AmountsFromLiquidity(pc.poolState, position.State.TickLowerIndex, position.State.TickUpperIndex, liquidity, true)
+	if err != nil {
+		return nil, err
+	}
+	amount0Max, amount1Max := maxAmount(pc.mint0, pc.epoch, amount0, slippage), maxAmount(pc.mint1, pc.epoch, amount1, slippage)
+	if err := pc.tokenAccounts(ctx, client, owner, amount0Max, amount1Max); err != nil {
+		return nil, err
+	}
+	accounts, err := pc.positionAccounts(position, owner)
+	if err != nil {
+		return nil, err
+	}
+	instruction, err := NewIncreaseLiquidityInstruction(pc.programID, accounts, IncreaseLiquidityArgs{
+		Liquidity:  uint128(liquidity),
+		Amount0Max: amount0Max,
+		Amount1Max: amount1Max,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return pc.transaction(ctx, client, owner, "increase_liquidity", instruction)
+}
+
+// BuildDecreaseLiquidityTransaction removes liquidity from a position and collects its fees and
+// rewards. A nil liquidity removes everything, a zero liquidity only collects.
+func BuildDecreaseLiquidityTransaction(ctx context.Context, client *rpc.Client, network string, nftMint solana.PublicKey, liquidity *big.Int, slippage float64, owner solana.PublicKey, opts txn.SwapOptions) (*solana.Transaction, error) {
+	position, pc, err := loadPosition(ctx, client, network, nftMint, owner, opts)
+	if err != nil {
+		return nil, err
+	}
+	if err := pc.tokenAccounts(ctx, client, owner, 0, 0); err != nil {
+		return nil, err
+	}
+	accounts, err := pc.positionAccounts(position, owner)
+	if err != nil {
+		return nil, err
+	}
+	if liquidity == nil {
+		liquidity = u128(position.State.Liquidity)
+	}
+	amount0, amount1, err := AmountsFromLiquidity(pc.poolState, position.State.TickLowerIndex, position.State.TickUpperIndex, liquidity, false)
+	if err != nil {
+		return nil, err
+	}
+	rewardAccounts, err := pc.rewardAccounts(ctx, client, owner)
+	if err != nil {
+		return nil, err
+	}
+	instruction, err := NewDecreaseLiquidityInstruction(pc.programID, accounts, DecreaseLiquidityArgs{
+		Liquidity:  uint128(liquidity),
+		Amount0Min: minAmount(pc.mint0, pc.epoch, amount0, slippage),
+		Amount1Min: minAmount(pc.mint1, pc.epoch, amount1, slippage),
+	}, rewardAccounts)
+	if err != nil {
+		return nil, err
+	}
+	return pc.transaction(ctx, client, owner, "decrease_liquidity", instruction)
+}
+
+// BuildCollectFeesTransaction collects the fees and rewards owed to a position without touching its
+// liquidity.
+func BuildCollectFeesTransaction(ctx context.Context, client *rpc.Client, network string, nftMint solana.PublicKey, owner solana.PublicKey, opts txn.SwapOptions) (*solana.Transaction, error) {
+	return BuildDecreaseLiquidityTransaction(ctx, client, network, nftMint, new(big.Int), 0, owner, opts)
+}
+
+// BuildClosePositionTransaction burns the NFT of an empty position and reclaims its rent. Fees and
+// rewards still owed to the position must be collected first, closing would forfeit them.
+func BuildClosePositionTransaction(ctx context.Context, client *rpc.Client, network string, nftMint solana.PublicKey, owner solana.PublicKey, opts txn.SwapOptions) (*solana.Transaction, error) {
+	position, pc, err := loadPosition(ctx, client, network, nftMint, owner, opts)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkPositionEmpty(position); err != nil {
+		return nil, err
+	}
+	accounts, err := pc.positionAccounts(position, owner)
+	if err != nil {
+		return nil, err
+	}
+	instruction, err := NewClosePositionInstruction(pc.programID, accounts, position.NftTokenProgram)
+	if err != nil {
+		return nil, err
+	}
+	return pc.transaction(ctx, client, owner, "close_position", instruction)
+}
+
+// checkPositionEmpty 检查仓位没有流动性，也没有未领取的手续费和奖励
+func checkPositionEmpty(position Position) error {
+	if u128(position.State.Liquidity).Sign() != 0 {
+		return fmt.Errorf("position %s still has liquidity", position.NftMint)
+	}
+	if position.State.TokenFeesOwed0 != 0 || position.State.TokenFeesOwed1 != 0 {
+		return fmt.Errorf("position %s has uncollected fees, collect them before closing", position.NftMint)
+	}
+	for _, reward := range position.State.RewardInfos {
+		if reward.RewardAmountOwed != 0 {
+			return fmt.Errorf("position %s has uncollected rewards, collect them before closing", position.NftMint)
+		}
+	}
+	return nil
+}
+
+func loadPosition(ctx context.Context, client *rpc.Client, network string, nftMint solana.PublicKey, owner solana.PublicKey, opts txn.SwapOptions) (Position, *positionContext, error) {
+	programID, err := config.CLMMProgram(network)
+	if err != nil {
+		return Position{}, nil, err
+	}
+	position, err := GetPosition(ctx, client, programID, owner, nftMint)
+	if err != nil {
+		return Position{}, nil, err
+	}
+	pc, err := loadPositionContext(ctx, client, network, position.State.PoolID, opts)
+	if err != nil {
+		return Position{}, nil, err
+	}
+	return position, pc, nil
+}
+
+// positionAccounts 为已有仓位填充指令账户，token 账户取自 tokenAccounts
+func (pc *positionContext) positionAccounts(position Position, owner solana.PublicKey) (PositionAccounts, error) {
+	accounts, err := PositionAccountsFrom(pc.programID, pc.pool, pc.poolState, owner, position.NftMint, position.State.TickLowerIndex, position.State.TickUpperIndex, pc.tokenAcct0, pc.tokenAcct1, pc.extension)
+	if err != nil {
+		return PositionAccounts{}, err
+	}
+	accounts.NftAccount = position.NftAccount
+	return accounts, nil
+}
+
+// SendPositionTransaction signs tx with the owner key and any extra signers, such as the NFT mint of
+// BuildOpenPositionTransaction, and sends it.
+func SendPositionTransaction(ctx context.Context, client *rpc.Client, tx *solana.Transaction, privateKey string, signers ...solana.PrivateKey) (string, error) {
+	signer, err := solana.PrivateKeyFromBase58(privateKey)
+	if err != nil {
+		return "", fmt.Errorf("invalid private key: %w", err)
+	}
+	if err := txn.PartialSign(tx, append(signers, signer)...); err != nil {
+		return "", err
+	}
+	txHash, err := client.SendTransaction(ctx, tx)
+	if err != nil {
+		return "", fmt.Errorf("failed to send transaction: %w", err)
+	}
+	return txHash.String(), nil
+}
+
+func uint128(v *big.Int) bin.Uint128 {
+	lo := new(big.Int).And(v, maxU64)
+	hi := new(big.Int).Rsh(v, 64)
+	return bin.Uint128{Lo: lo.Uint64(), Hi: hi.Uint64()}
+}
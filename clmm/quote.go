@@ -0,0 +1,216 @@
+package clmm
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+
+	"raydium-go/spl"
+)
+
+var ErrTickArraysNotLoaded = errors.New("swap needs tick arrays that were not loaded")
+
+// SwapResult 为链上 swap 循环的模拟结果
+type SwapResult struct {
+	AmountIn  uint64
+	AmountOut uint64
+	FeeAmount uint64
+	// 未被消耗的指定数量，撞到价格限制时不为零
+	AmountRemaining uint64
+	SqrtPriceX64    *big.Int
+	Tick            int32
+	Liquidity       *big.Int
+	// swap 经过的 tick array 起始索引，按顺序作为 remaining accounts 传入
+	TickArrayStartIndexes []int32
+}
+
+type SwapQuote struct {
+	ZeroForOne bool
+	spl.SwapAmounts
+	FeeAmount             uint64
+	SqrtPriceAfterX64     *big.Int
+	TickAfter             int32
+	TickArrayStartIndexes []int32
+	// 池子有 tick array bitmap extension 账户，swap 时需要传入
+	BitmapExtension bool
+}
+
+// SimulateSwap replays the swap loop of the program over the loaded tick arrays. A nil
+// sqrtPriceLimitX64 uses the widest limit allowed, as the program does for a zero limit.
+func SimulateSwap(poolState PoolState, ammConfig AmmConfig, extension *TickArrayBitmapExtension, tickArrays []TickArrayState, zeroForOne bool, amountSpecified uint64, baseInput bool, sqrtPriceLimitX64 *big.Int) (SwapResult, error) {
+	res := SwapResult{
+		AmountRemaining: amountSpecified,
+		SqrtPriceX64:    u128(poolState.SqrtPriceX64),
+		Tick:            poolState.TickCurrent,
+		Liquidity:       u128(poolState.Liquidity),
+	}
+	if amountSpecified == 0 {
+		return res, fmt.Errorf("amount specified must be positive")
+	}
+	if sqrtPriceLimitX64 == nil || sqrtPriceLimitX64.Sign() == 0 {
+		if zeroForOne {
+			sqrtPriceLimitX64 = new(big.Int).Add(MinSqrtPriceX64, big.NewInt(1))
+		} else {
+			sqrtPriceLimitX64 = new(big.Int).Sub(MaxSqrtPriceX64, big.NewInt(1))
+		}
+	}
+	if zeroForOne && (sqrtPriceLimitX64.Cmp(res.SqrtPriceX64) >= 0 || sqrtPriceLimitX64.Cmp(MinSqrtPriceX64) <= 0) ||
+		!zeroForOne && (sqrtPriceLimitX64.Cmp(res.SqrtPriceX64) <= 0 || sqrtPriceLimitX64.Cmp(MaxSqrtPriceX64) >= 0) {
+		return res, ErrSqrtPriceOutOfRange
+	}
+
+	loaded := make(map[int32]*TickArrayState, len(tickArrays))
+	for i := range tickArrays {
+		loaded[tickArrays[i].StartTickIndex] = &tickArrays[i]
+	}
+	ordered := NextInitializedTickArrays(poolState, extension, zeroForOne, len(tickArrays)+1)
+	if len(ordered) == 0 {
+		return res, ErrInsufficientLiquidity
+	}
+	arrayIndex := 0
+	var used []int32
+	var amountCalculated uint64
+
+	for res.AmountRemaining != 0 && res.SqrtPriceX64.Cmp(sqrtPriceLimitX64) != 0 {
+		var next *TickState
+		for ; arrayIndex < len(ordered); arrayIndex++ {
+			tickArray, ok := loaded[ordered[arrayIndex]]
+			if !ok {
+				return res, fmt.Errorf("%w: start index %d", ErrTickArraysNotLoaded, ordered[arrayIndex])
+			}
+			if len(used) == 0 || used[len(used)-1] != tickArray.StartTickIndex {
+				used = append(used, tickArray.StartTickIndex)
+			}
+			if next = nextInitializedTick(tickArray, res.Tick, zeroForOne); next != nil {
+				break
+			}
+		}
+		if next == nil {
+			return res, ErrInsufficientLiquidity
+		}
+
+		tickNext := next.Tick
+		if tickNext < MinTick {
+			tickNext = MinTick
+		} else if tickNext > MaxTick {
+			tickNext = MaxTick
+		}
+		sqrtPriceStart := res.SqrtPriceX64
+		sqrtPriceNext, err := GetSqrtPriceAtTick(tickNext)
+		if err != nil {
+			return res, err
+		}
+		target := sqrtPriceNext
+		if zeroForOne && target.Cmp(sqrtPriceLimitX64) < 0 || !zeroForOne && target.Cmp(sqrtPriceLimitX64) > 0 {
+			target = sqrtPriceLimitX64
+		}
+		step, err := ComputeSwapStep(res.SqrtPriceX64, target, res.Liquidity, res.AmountRemaining, ammConfig.TradeFeeRate, baseInput, zeroForOne)
+		if err != nil {
+			return res, err
+		}
+		res.SqrtPriceX64 = step.SqrtPriceNextX64
+		res.FeeAmount += step.FeeAmount
+		if baseInput {
+			res.AmountRemaining -= step.AmountIn + step.FeeAmount
+			amountCalculated += step.AmountOut
+		} else {
+			res.AmountRemaining -= step.AmountOut
+			amountCalculated += step.AmountIn + step.FeeAmount
+		}
+
+		if res.SqrtPriceX64.Cmp(sqrtPriceNext) == 0 {
+			if next.IsInitialized() {
+				liquidityNet := next.LiquidityNet.BigInt()
+				if zeroForOne {
+					liquidityNet.Neg(liquidityNet)
+				}
+				res.Liquidity = new(big.Int).Add(res.Liquidity, liquidityNet)
+				if res.Liquidity.Sign() < 0 {
+					return res, ErrInsufficientLiquidity
+				}
+			}
+			if zeroForOne {
+				res.Tick = tickNext - 1
+			} else {
+				res.Tick = tickNext
+			}
+		} else if res.SqrtPriceX64.Cmp(sqrtPriceStart) != 0 {
+			if res.Tick, err = GetTickAtSqrtPrice(res.SqrtPriceX64); err != nil {
+				return res, err
+			}
+		}
+	}
+
+	if baseInput {
+		res.AmountIn = amountSpecified - res.AmountRemaining
+		res.AmountOut = amountCalculated
+	} else {
+		res.AmountIn = amountCalculated
+		res.AmountOut = amountSpecified - res.AmountRemaining
+	}
+	res.TickArrayStartIndexes = used
+	return res, nil
+}
+
+// nextInitializedTick 对应链上 TickArrayState::next_initialized_tick，当前 tick 不在该 array 内时
+// 返回该 array 在 swap 方向上的第一个已初始化 tick
+func nextInitializedTick(tickArray *TickArrayState, tick int32, zeroForOne bool) *TickState {
+	if zeroForOne {
+		for i := TickArraySize - 1; i >= 0; i-- {
+			t := &tickArray.Ticks[i]
+			if t.IsInitialized() && t.Tick <= tick {
+				return t
+			}
+		}
+		return nil
+	}
+	for i := 0; i < TickArraySize; i++ {
+		t := &tickArray.Ticks[i]
+		if t.IsInitialized() && t.Tick > tick {
+			return t
+		}
+	}
+	return nil
+}
+
+// QuoteSwap computes an exact quote over the loaded tick arrays, including Token-2022 transfer fees
+// charged on the input and output mints.
+func QuoteSwap(poolState PoolState, ammConfig AmmConfig, extension *TickArrayBitmapExtension, tickArrays []TickArrayState, inputMint spl.Mint, outputMint spl.Mint, epoch uint64, amountSpecified uint64, baseIn bool, slippage float64) (SwapQuote, error) {
+	quote := SwapQuote{ZeroForOne: inputMint.Address.Equals(poolState.TokenMint0), BitmapExtension: extension != nil}
+	if baseIn {
+		quote.InputTransferFee = inputMint.TransferFee(epoch, amountSpecified)
+		res, err := SimulateSwap(poolState, ammConfig, extension, tickArrays, quote.ZeroForOne, amountSpecified-quote.InputTransferFee, true, nil)
+		if err != nil {
+			return quote, err
+		}
+		if res.AmountRemaining != 0 {
+			return quote, ErrInsufficientLiquidity
+		}
+		quote.AmountIn = amountSpecified
+		quote.AmountOut = res.AmountOut
+		quote.OutputTransferFee = outputMint.TransferFee(epoch, res.AmountOut)
+		quote.SetThreshold(true, slippage)
+		return quote.withResult(res), nil
+	}
+	quote.OutputTransferFee = outputMint.InverseTransferFee(epoch, amountSpecified)
+	res, err := SimulateSwap(poolState, ammConfig, extension, tickArrays, quote.ZeroForOne, amountSpecified+quote.OutputTransferFee, false, nil)
+	if err != nil {
+		return quote, err
+	}
+	if res.AmountRemaining != 0 {
+		return quote, ErrInsufficientLiquidity
+	}
+	quote.AmountOut = res.AmountOut
+	quote.InputTransferFee = inputMint.InverseTransferFee(epoch, res.AmountIn)
+	quote.AmountIn = res.AmountIn + quote.InputTransferFee
+	quote.SetThreshold(false, slippage)
+	return quote.withResult(res), nil
+}
+
+func (q SwapQuote) withResult(res SwapResult) SwapQuote {
+	q.FeeAmount = res.FeeAmount
+	q.SqrtPriceAfterX64 = res.SqrtPriceX64
+	q.TickAfter = res.Tick
+	q.TickArrayStartIndexes = res.TickArrayStartIndexes
+	return q
+}
@@ -0,0 +1,241 @@
+package clmm
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math/big"
+
+	"raydium-go/anchor"
+
+	bin "github.com/gagliardetto/binary"
+	"github.com/gagliardetto/solana-go"
+	"github.com/gagliardetto/solana-go/rpc"
+)
+
+const (
+	TickArraySize        = 60
+	TickArrayBitmapSize  = 512
+	RewardNum            = 3
+	extensionBitmapCount = 14
+)
+
+var (
+	poolStateDiscriminator       = anchor.AccountDiscriminator("PoolState")
+	ammConfigDiscriminator       = anchor.AccountDiscriminator("AmmConfig")
+	tickArrayDiscriminator       = anchor.AccountDiscriminator("TickArrayState")
+	bitmapExtensionDiscriminator = anchor.AccountDiscriminator("TickArrayBitmapExtension")
+
+	tickArraySeed       = []byte("tick_array")
+	bitmapExtensionSeed = []byte("pool_tick_array_bitmap_extension")
+)
+
+// AmmConfig 对应 Rust 中的 AmmConfig
+type AmmConfig struct {
+	Bump            uint8
+	Index           uint16
+	Owner           solana.PublicKey
+	ProtocolFeeRate uint32
+	TradeFeeRate    uint32
+	TickSpacing     uint16
+	FundFeeRate     uint32
+	PaddingU32      uint32
+	FundOwner       solana.PublicKey
+	Padding         [3]uint64
+}
+
+// RewardInfo 对应 Rust 中的 RewardInfo
+type RewardInfo struct {
+	RewardState           uint8
+	OpenTime              uint64
+	EndTime               uint64
+	LastUpdateTime        uint64
+	EmissionsPerSecondX64 bin.Uint128
+	RewardTotalEmissioned uint64
+	RewardClaimed         uint64
+	TokenMint             solana.PublicKey
+	TokenVault            solana.PublicKey
+	Authority             solana.PublicKey
+	RewardGrowthGlobalX64 bin.Uint128
+}
+
+// PoolState 对应 Rust 中的 PoolState
+type PoolState struct {
+	Bump                   [1]uint8
+	AmmConfig              solana.PublicKey
+	Owner                  solana.PublicKey
+	TokenMint0             solana.PublicKey
+	TokenMint1             solana.PublicKey
+	TokenVault0            solana.PublicKey
+	TokenVault1            solana.PublicKey
+	ObservationKey         solana.PublicKey
+	MintDecimals0          uint8
+	MintDecimals1          uint8
+	TickSpacing            uint16
+	Liquidity              bin.Uint128
+	SqrtPriceX64           bin.Uint128
+	TickCurrent            int32
+	Padding3               uint16
+	Padding4               uint16
+	FeeGrowthGlobal0X64    bin.Uint128
+	FeeGrowthGlobal1X64    bin.Uint128
+	ProtocolFeesToken0     uint64
+	ProtocolFeesToken1     uint64
+	SwapInAmountToken0     bin.Uint128
+	SwapOutAmountToken1    bin.Uint128
+	SwapInAmountToken1     bin.Uint128
+	SwapOutAmountToken0    bin.Uint128
+	Status                 uint8
+	Padding                [7]uint8
+	RewardInfos            [RewardNum]RewardInfo
+	TickArrayBitmap        [16]uint64
+	TotalFeesToken0        uint64
+	TotalFeesClaimedToken0 uint64
+	TotalFeesToken1        uint64
+	TotalFeesClaimedToken1 uint64
+	FundFeesToken0         uint64
+	FundFeesToken1         uint64
+	OpenTime               uint64
+	RecentEpoch            uint64
+	Padding1               [24]uint64
+	Padding2               [32]uint64
+}
+
+// TickState 对应 Rust 中的 TickState
+type TickState struct {
+	Tick                    int32
+	LiquidityNet            bin.Int128
+	LiquidityGross          bin.Uint128
+	FeeGrowthOutside0X64    bin.Uint128
+	FeeGrowthOutside1X64    bin.Uint128
+	RewardGrowthsOutsideX64 [RewardNum]bin.Uint128
+	Padding                 [13]uint32
+}
+
+func (t TickState) IsInitialized() bool {
+	return t.LiquidityGross.Lo != 0 || t.LiquidityGross.Hi != 0
+}
+
+// TickArrayState 对应 Rust 中的 TickArrayState
+type TickArrayState struct {
+	PoolID               solana.PublicKey
+	StartTickIndex       int32
+	Ticks                [TickArraySize]TickState
+	InitializedTickCount uint8
+	RecentEpoch          uint64
+	Padding              [107]uint8
+}
+
+// TickArrayBitmapExtension 记录超出 PoolState.TickArrayBitmap 范围的 tick array
+type TickArrayBitmapExtension struct {
+	PoolID                  solana.PublicKey
+	PositiveTickArrayBitmap [extensionBitmapCount][8]uint64
+	NegativeTickArrayBitmap [extensionBitmapCount][8]uint64
+}
+
+func DecodePoolState(data []byte) (PoolState, error) {
+	var state PoolState
+	err := anchor.DecodeAccount(data, poolStateDiscriminator, &state)
+	return state, err
+}
+
+func DecodeAmmConfig(data []byte) (AmmConfig, error) {
+	var ammConfig AmmConfig
+	err := anchor.DecodeAccount(data, ammConfigDiscriminator, &ammConfig)
+	return ammConfig, err
+}
+
+func DecodeTickArray(data []byte) (TickArrayState, error) {
+	var tickArray TickArrayState
+	err := anchor.DecodeAccount(data, tickArrayDiscriminator, &tickArray)
+	return tickArray, err
+}
+
+func DecodeTickArrayBitmapExtension(data []byte) (TickArrayBitmapExtension, error) {
+	var extension TickArrayBitmapExtension
+	err := anchor.DecodeAccount(data, bitmapExtensionDiscriminator, &extension)
+	return extension, err
+}
+
+func GetPoolState(ctx context.Context, client *rpc.Client, pool solana.PublicKey, commitment rpc.CommitmentType) (PoolState, error) {
+	account, err := client.GetAccountInfoWithOpts(ctx, pool, &rpc.GetAccountInfoOpts{Commitment: commitment})
+	if err != nil {
+		return PoolState{}, err
+	}
+	return DecodePoolState(account.Value.Data.GetBinary())
+}
+
+func GetAmmConfig(ctx context.Context, client *rpc.Client, ammConfig solana.PublicKey, commitment rpc.CommitmentType) (AmmConfig, error) {
+	account, err := client.GetAccountInfoWithOpts(ctx, ammConfig, &rpc.GetAccountInfoOpts{Commitment: commitment})
+	if err != nil {
+		return AmmConfig{}, err
+	}
+	return DecodeAmmConfig(account.Value.Data.GetBinary())
+}
+
+// GetTickArrayBitmapExtension returns nil without error when the pool has no extension account.
+func GetTickArrayBitmapExtension(ctx context.Context, client *rpc.Client, programID solana.PublicKey, pool solana.PublicKey, commitment rpc.CommitmentType) (*TickArrayBitmapExtension, error) {
+	address, _, err := GetTickArrayBitmapExtensionAddress(programID, pool)
+	if err != nil {
+		return nil, err
+	}
+	account, err := client.GetAccountInfoWithOpts(ctx, address, &rpc.GetAccountInfoOpts{Commitment: commitment})
+	if errors.Is(err, rpc.ErrNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	extension, err := DecodeTickArrayBitmapExtension(account.Value.Data.GetBinary())
+	if err != nil {
+		return nil, err
+	}
+	return &extension, nil
+}
+
+// GetTickArrays fetches the tick arrays starting at startIndexes with one getMultipleAccounts call.
+// Missing accounts are skipped.
+func GetTickArrays(ctx context.Context, client *rpc.Client, programID solana.PublicKey, pool solana.PublicKey, startIndexes []int32, commitment rpc.CommitmentType) ([]TickArrayState, error) {
+	if len(startIndexes) == 0 {
+		return nil, nil
+	}
+	addresses := make([]solana.PublicKey, len(startIndexes))
+	for i, start := range startIndexes {
+		address, _, err := GetTickArrayAddress(programID, pool, start)
+		if err != nil {
+			return nil, err
+		}
+		addresses[i] = address
+	}
+	resp, err := client.GetMultipleAccountsWithOpts(ctx, addresses, &rpc.GetMultipleAccountsOpts{Commitment: commitment})
+	if err != nil {
+		return nil, err
+	}
+	var res []TickArrayState
+	for i, account := range resp.Value {
+		if account == nil {
+			continue
+		}
+		tickArray, err := DecodeTickArray(account.Data.GetBinary())
+		if err != nil {
+			return nil, fmt.Errorf("tick array %s: %w", addresses[i], err)
+		}
+		res = append(res, tickArray)
+	}
+	return res, nil
+}
+
+func GetTickArrayAddress(programID solana.PublicKey, pool solana.PublicKey, startIndex int32) (solana.PublicKey, uint8, error) {
+	index := make([]byte, 4)
+	binary.BigEndian.PutUint32(index, uint32(startIndex))
+	return solana.FindProgramAddress([][]byte{tickArraySeed, pool[:], index}, programID)
+}
+
+func GetTickArrayBitmapExtensionAddress(programID solana.PublicKey, pool solana.PublicKey) (solana.PublicKey, uint8, error) {
+	return solana.FindProgramAddress([][]byte{bitmapExtensionSeed, pool[:]}, programID)
+}
+
+func u128(v bin.Uint128) *big.Int {
+	return v.BigInt()
+}
@@ -0,0 +1,192 @@
+package clmm
+
+import (
+	"errors"
+	"math/big"
+)
+
+const FeeRateDenominator = uint64(1000000)
+
+var (
+	ErrInsufficientLiquidity = errors.New("insufficient liquidity")
+
+	maxU64 = new(big.Int).SetUint64(^uint64(0))
+)
+
+// SwapStep 对应链上 swap_math::SwapStep
+type SwapStep struct {
+	SqrtPriceNextX64 *big.Int
+	AmountIn         uint64
+	AmountOut        uint64
+	FeeAmount        uint64
+}
+
+// GetDeltaAmount0 对应链上 get_delta_amount_0_unsigned
+func GetDeltaAmount0(sqrtPriceA *big.Int, sqrtPriceB *big.Int, liquidity *big.Int, roundUp bool) *big.Int {
+	if sqrtPriceA.Cmp(sqrtPriceB) > 0 {
+		sqrtPriceA, sqrtPriceB = sqrtPriceB, sqrtPriceA
+	}
+	numerator1 := new(big.Int).Lsh(liquidity, 64)
+	numerator2 := new(big.Int).Sub(sqrtPriceB, sqrtPriceA)
+	product := new(big.Int).Mul(numerator1, numerator2)
+	if roundUp {
+		return ceilDiv(ceilDiv(product, sqrtPriceB), sqrtPriceA)
+	}
+	product.Quo(product, sqrtPriceB)
+	return product.Quo(product, sqrtPriceA)
+}
+
+// GetDeltaAmount1 对应链上 get_delta_amount_1_unsigned
+func GetDeltaAmount1(sqrtPriceA *big.Int, sqrtPriceB *big.Int, liquidity *big.Int, roundUp bool) *big.Int {
+	if sqrtPriceA.Cmp(sqrtPriceB) > 0 {
+		sqrtPriceA, sqrtPriceB = sqrtPriceB, sqrtPriceA
+	}
+	product := new(big.Int).Mul(liquidity, new(big.Int).Sub(sqrtPriceB, sqrtPriceA))
+	if roundUp {
+		return ceilDiv(product, q64)
+	}
+	return product.Rsh(product, 64)
+}
+
+func nextSqrtPriceFromAmount0RoundingUp(sqrtPrice *big.Int, liquidity *big.Int, amount uint64, add bool) (*big.Int, error) {
+	if amount == 0 {
+		return sqrtPrice, nil
+	}
+	numerator1 := new(big.Int).Lsh(liquidity, 64)
+	product := new(big.Int).Mul(new(big.Int).SetUint64(amount), sqrtPrice)
+	var denominator *big.Int
+	if add {
+		denominator = new(big.Int).Add(numerator1, product)
+	} else {
+		denominator = new(big.Int).Sub(numerator1, product)
+		if denominator.Sign() <= 0 {
+			return nil, ErrInsufficientLiquidity
+		}
+	}
+	return ceilDiv(new(big.Int).Mul(numerator1, sqrtPrice), denominator), nil
+}
+
+func nextSqrtPriceFromAmount1RoundingDown(sqrtPrice *big.Int, liquidity *big.Int, amount uint64, add bool) (*big.Int, error) {
+	shifted := new(big.Int).Lsh(new(big.Int).SetUint64(amount), 64)
+	if add {
+		return shifted.Quo(shifted, liquidity).Add(shifted, sqrtPrice), nil
+	}
+	quotient := ceilDiv(shifted, liquidity)
+	if sqrtPrice.Cmp(quotient) <= 0 {
+		return nil, ErrInsufficientLiquidity
+	}
+	return quotient.Sub(sqrtPrice, quotient), nil
+}
+
+func nextSqrtPriceFromInput(sqrtPrice *big.Int, liquidity *big.Int, amountIn uint64, zeroForOne bool) (*big.Int, error) {
+	if zeroForOne {
+		return nextSqrtPriceFromAmount0RoundingUp(sqrtPrice, liquidity, amountIn, true)
+	}
+	return nextSqrtPriceFromAmount1RoundingDown(sqrtPrice, liquidity, amountIn, true)
+}
+
+func nextSqrtPriceFromOutput(sqrtPrice *big.Int, liquidity *big.Int, amountOut uint64, zeroForOne bool) (*big.Int, error) {
+	if zeroForOne {
+		return nextSqrtPriceFromAmount1RoundingDown(sqrtPrice, liquidity, amountOut, false)
+	}
+	return nextSqrtPriceFromAmount0RoundingUp(sqrtPrice, liquidity, amountOut, false)
+}
+
+// amountInRange 对应链上 calculate_amount_in_range，结果超出 u64 时返回 nil
+func amountInRange(sqrtPriceCurrent *big.Int, sqrtPriceTarget *big.Int, liquidity *big.Int, zeroForOne bool, baseInput bool) *big.Int {
+	var amount *big.Int
+	switch {
+	case zeroForOne && baseInput:
+		amount = GetDeltaAmount0(sqrtPriceTarget, sqrtPriceCurrent, liquidity, true)
+	case zeroForOne:
+		amount = GetDeltaAmount1(sqrtPriceTarget, sqrtPriceCurrent, liquidity, false)
+	case baseInput:
+		amount = GetDeltaAmount1(sqrtPriceCurrent, sqrtPriceTarget, liquidity, true)
+	default:
+		amount = GetDeltaAmount0(sqrtPriceCurrent, sqrtPriceTarget, liquidity, false)
+	}
+	if amount.Cmp(maxU64) > 0 {
+		return nil
+	}
+	return amount
+}
+
+// ComputeSwapStep 对应链上 swap_math::compute_swap_step
+func ComputeSwapStep(sqrtPriceCurrent *big.Int, sqrtPriceTarget *big.Int, liquidity *big.Int, amountRemaining uint64, feeRate uint32, baseInput bool, zeroForOne bool) (SwapStep, error) {
+	var step SwapStep
+	var err error
+	if baseInput {
+		lessFee := new(big.Int).Quo(mul(amountRemaining, FeeRateDenominator-uint64(feeRate)), new(big.Int).SetUint64(FeeRateDenominator)).Uint64()
+		amountIn := amountInRange(sqrtPriceCurrent, sqrtPriceTarget, liquidity, zeroForOne, true)
+		if amountIn != nil {
+			step.AmountIn = amountIn.Uint64()
+		}
+		if amountIn != nil && lessFee >= step.AmountIn {
+			step.SqrtPriceNextX64 = sqrtPriceTarget
+		} else if step.SqrtPriceNextX64, err = nextSqrtPriceFromInput(sqrtPriceCurrent, liquidity, lessFee, zeroForOne); err != nil {
+			return step, err
+		}
+	} else {
+		amountOut := amountInRange(sqrtPriceCurrent, sqrtPriceTarget, liquidity, zeroForOne, false)
+		if amountOut != nil {
+			step.AmountOut = amountOut.Uint64()
+		}
+		if amountOut != nil && amountRemaining >= step.AmountOut {
+			step.SqrtPriceNextX64 = sqrtPriceTarget
+		} else if step.SqrtPriceNextX64, err = nextSqrtPriceFromOutput(sqrtPriceCurrent, liquidity, amountRemaining, zeroForOne); err != nil {
+			return step, err
+		}
+	}
+
+	reached := sqrtPriceTarget.Cmp(step.SqrtPriceNextX64) == 0
+	var amountIn, amountOut *big.Int
+	if zeroForOne {
+		if !(reached && baseInput) {
+			amountIn = GetDeltaAmount0(step.SqrtPriceNextX64, sqrtPriceCurrent, liquidity, true)
+		}
+		if !(reached && !baseInput) {
+			amountOut = GetDeltaAmount1(step.SqrtPriceNextX64, sqrtPriceCurrent, liquidity, false)
+		}
+	} else {
+		if !(reached && baseInput) {
+			amountIn = GetDeltaAmount1(sqrtPriceCurrent, step.SqrtPriceNextX64, liquidity, true)
+		}
+		if !(reached && !baseInput) {
+			amountOut = GetDeltaAmount0(sqrtPriceCurrent, step.SqrtPriceNextX64, liquidity, false)
+		}
+	}
+	if amountIn != nil {
+		if amountIn.Cmp(maxU64) > 0 {
+			return step, ErrInsufficientLiquidity
+		}
+		step.AmountIn = amountIn.Uint64()
+	}
+	if amountOut != nil {
+		if amountOut.Cmp(maxU64) > 0 {
+			return step, ErrInsufficientLiquidity
+		}
+		step.AmountOut = amountOut.Uint64()
+	}
+	if !baseInput && step.AmountOut > amountRemaining {
+		step.AmountOut = amountRemaining
+	}
+
+	if baseInput && !reached {
+		step.FeeAmount = amountRemaining - step.AmountIn
+	} else {
+		step.FeeAmount = ceilDiv(mul(step.AmountIn, uint64(feeRate)), new(big.Int).SetUint64(FeeRateDenominator-uint64(feeRate))).Uint64()
+	}
+	return step, nil
+}
+
+func mul(a uint64, b uint64) *big.Int {
+	return new(big.Int).Mul(new(big.Int).SetUint64(a), new(big.Int).SetUint64(b))
+}
+
+func ceilDiv(a *big.Int, b *big.Int) *big.Int {
+	q, r := new(big.Int).QuoRem(a, b, new(big.Int))
+	if r.Sign() != 0 {
+		q.Add(q, big.NewInt(1))
+	}
+	return q
+}
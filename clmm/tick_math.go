@@ -0,0 +1,170 @@
+package clmm
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+)
+
+const (
+	MinTick = -443636
+	MaxTick = -MinTick
+)
+
+var (
+	MinSqrtPriceX64, _ = new(big.Int).SetString("4295048016", 10)
+	MaxSqrtPriceX64, _ = new(big.Int).SetString("79226673521066979257578248091", 10)
+
+	ErrTickOutOfRange      = errors.New("tick out of range")
+	ErrSqrtPriceOutOfRange = errors.New("sqrt price out of range")
+
+	q64     = new(big.Int).Lsh(big.NewInt(1), 64)
+	maxU128 = new(big.Int).Sub(new(big.Int).Lsh(big.NewInt(1), 128), big.NewInt(1))
+
+	// 1.0001^(-2^i / 2) 的 Q64.64 表示，与链上 get_sqrt_price_at_tick 一致
+	sqrtPriceFactors = []uint64{
+		0xfffcb933bd6fb800,
+		0xfff97272373d4000,
+		0xfff2e50f5f657000,
+		0xffe5caca7e10f000,
+		0xffcb9843d60f7000,
+		0xff973b41fa98e800,
+		0xff2ea16466c9b000,
+		0xfe5dee046a9a3800,
+		0xfcbe86c7900bb000,
+		0xf987a7253ac65800,
+		0xf3392b0822bb6000,
+		0xe7159475a2caf000,
+		0xd097f3bdfd2f2000,
+		0xa9f746462d9f8000,
+		0x70d869a156f31c00,
+		0x31be135f97ed3200,
+		0x9aa508b5b85a500,
+		0x5d6af8dedc582c,
+		0x2216e584f5fa,
+	}
+)
+
+// GetSqrtPriceAtTick 对应链上 tick_math::get_sqrt_price_at_tick
+func GetSqrtPriceAtTick(tick int32) (*big.Int, error) {
+	absTick := tick
+	if absTick < 0 {
+		absTick = -absTick
+	}
+	if absTick > MaxTick {
+		return nil, fmt.Errorf("%w: %d", ErrTickOutOfRange, tick)
+	}
+	ratio := new(big.Int).Set(q64)
+	for i, factor := range sqrtPriceFactors {
+		if absTick&(1<<i) == 0 {
+			continue
+		}
+		if i == 0 {
+			ratio.SetUint64(factor)
+			continue
+		}
+		ratio.Mul(ratio, new(big.Int).SetUint64(factor))
+		ratio.Rsh(ratio, 64)
+	}
+	if tick > 0 {
+		ratio.Quo(maxU128, ratio)
+	}
+	return ratio, nil
+}
+
+// GetTickAtSqrtPrice returns the greatest tick whose sqrt price is not above sqrtPriceX64.
+func GetTickAtSqrtPrice(sqrtPriceX64 *big.Int) (int32, error) {
+	if sqrtPriceX64.Cmp(MinSqrtPriceX64) < 0 || sqrtPriceX64.Cmp(MaxSqrtPriceX64) >= 0 {
+		return 0, ErrSqrtPriceOutOfRange
+	}
+	low, high := int32(MinTick), int32(MaxTick)
+	for low < high {
+		mid := low + (high-low+1)/2
+		price, err := GetSqrtPriceAtTick(mid)
+		if err != nil {
+			return 0, err
+		}
+		if price.Cmp(sqrtPriceX64) <= 0 {
+			low = mid
+		} else {
+			high = mid - 1
+		}
+	}
+	return low, nil
+}
+
+// TickArrayStartIndex 对应链上 TickArrayState::get_array_start_index
+func TickArrayStartIndex(tick int32, tickSpacing uint16) int32 {
+	ticksInArray := TickCount(tickSpacing)
+	start := tick / ticksInArray
+	if tick < 0 && tick%ticksInArray != 0 {
+		start--
+	}
+	return start * ticksInArray
+}
+
+// TickCount 为一个 tick array 覆盖的 tick 数
+func TickCount(tickSpacing uint16) int32 {
+	return TickArraySize * int32(tickSpacing)
+}
+
+func minTickArrayStartIndex(tickSpacing uint16) int32 {
+	return TickArrayStartIndex(MinTick, tickSpacing)
+}
+
+func maxTickArrayStartIndex(tickSpacing uint16) int32 {
+	return TickArrayStartIndex(MaxTick, tickSpacing)
+}
+
+// IsTickArrayInitialized checks the pool bitmap, or the extension for start indexes outside the
+// range of the pool bitmap. A nil extension is treated as empty.
+func IsTickArrayInitialized(poolState PoolState, extension *TickArrayBitmapExtension, startIndex int32) bool {
+	ticksInArray := TickCount(poolState.TickSpacing)
+	boundary := TickArrayBitmapSize * ticksInArray
+	if startIndex >= -boundary && startIndex < boundary {
+		bit := startIndex/ticksInArray + TickArrayBitmapSize
+		return poolState.TickArrayBitmap[bit/64]&(1<<(bit%64)) != 0
+	}
+	if extension == nil {
+		return false
+	}
+	abs := startIndex
+	if abs < 0 {
+		abs = -abs
+	}
+	offset := abs/boundary - 1
+	if startIndex < 0 && abs%boundary == 0 {
+		offset--
+	}
+	if offset < 0 || offset >= extensionBitmapCount {
+		return false
+	}
+	bit := (abs % boundary) / ticksInArray
+	bitmap := extension.PositiveTickArrayBitmap[offset]
+	if startIndex < 0 {
+		if abs%boundary != 0 {
+			bit = TickArrayBitmapSize - bit
+		}
+		bitmap = extension.NegativeTickArrayBitmap[offset]
+	}
+	return bitmap[bit/64]&(1<<(bit%64)) != 0
+}
+
+// NextInitializedTickArrays returns up to count initialized tick array start indexes in swap order,
+// beginning with the array that holds the current tick when it is initialized.
+func NextInitializedTickArrays(poolState PoolState, extension *TickArrayBitmapExtension, zeroForOne bool, count int) []int32 {
+	ticksInArray := TickCount(poolState.TickSpacing)
+	minStart, maxStart := minTickArrayStartIndex(poolState.TickSpacing), maxTickArrayStartIndex(poolState.TickSpacing)
+	var res []int32
+	for start := TickArrayStartIndex(poolState.TickCurrent, poolState.TickSpacing); start >= minStart && start <= maxStart && len(res) < count; {
+		if IsTickArrayInitialized(poolState, extension, start) {
+			res = append(res, start)
+		}
+		if zeroForOne {
+			start -= ticksInArray
+		} else {
+			start += ticksInArray
+		}
+	}
+	return res
+}
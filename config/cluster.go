@@ -0,0 +1,162 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+
+	"raydium-go/consts"
+
+	"github.com/gagliardetto/solana-go"
+	"github.com/gagliardetto/solana-go/rpc"
+)
+
+var ErrUnknownNetwork = errors.New("unknown network")
+
+// Programs 为一个集群上的 Raydium 程序 ID，零值表示该集群上没有此程序
+type Programs struct {
+	AMM       solana.PublicKey `yaml:"amm" toml:"amm"`
+	CPMM      solana.PublicKey `yaml:"cpmm" toml:"cpmm"`
+	CLMM      solana.PublicKey `yaml:"clmm" toml:"clmm"`
+	LaunchLab solana.PublicKey `yaml:"launchlab" toml:"launchlab"`
+	FarmV3    solana.PublicKey `yaml:"farm_v3" toml:"farm_v3"`
+	FarmV5    solana.PublicKey `yaml:"farm_v5" toml:"farm_v5"`
+	OpenBook  solana.PublicKey `yaml:"openbook" toml:"openbook"`
+}
+
+// merge 用 o 中的非零程序覆盖 p
+func (p Programs) merge(o Programs) Programs {
+	for _, f := range []struct{ dst, src *solana.PublicKey }{
+		{&p.AMM, &o.AMM},
+		{&p.CPMM, &o.CPMM},
+		{&p.CLMM, &o.CLMM},
+		{&p.LaunchLab, &o.LaunchLab},
+		{&p.FarmV3, &o.FarmV3},
+		{&p.FarmV5, &o.FarmV5},
+		{&p.OpenBook, &o.OpenBook},
+	} {
+		if !f.src.IsZero() {
+			*f.dst = *f.src
+		}
+	}
+	return p
+}
+
+// Cluster 为一个命名的网络及其默认端点和程序
+type Cluster struct {
+	Name     string   `yaml:"-" toml:"-"`
+	RPC      string   `yaml:"rpc" toml:"rpc"`
+	WS       string   `yaml:"ws" toml:"ws"`
+	Programs Programs `yaml:"programs" toml:"programs"`
+}
+
+func programsOf(network string) Programs {
+	return Programs{
+		AMM:       Raydium_AMM_Program[network],
+		CPMM:      Raydium_CPMM_Program[network],
+		CLMM:      Raydium_CLMM_Program[network],
+		LaunchLab: Raydium_LaunchLab_Program[network],
+		FarmV3:    Raydium_Farm_V3_Program[network],
+		FarmV5:    Raydium_Farm_V5_Program[network],
+		OpenBook:  Raydium_OpenBook_Program[network],
+	}
+}
+
+var (
+	clustersMu sync.RWMutex
+	clusters   = map[string]Cluster{
+		consts.MainNet: {Name: consts.MainNet, RPC: rpc.MainNetBeta_RPC, WS: rpc.MainNetBeta_WS, Programs: programsOf(consts.MainNet)},
+		consts.DevNet:  {Name: consts.DevNet, RPC: rpc.DevNet_RPC, WS: rpc.DevNet_WS, Programs: programsOf(consts.DevNet)},
+		// solana-test-validator 默认端口；程序 ID 与主网相同，对应用 --clone-upgradeable-program 或
+		// --bpf-program 按主网地址加载的程序，部署到其他地址时用 RegisterCluster 覆盖
+		consts.LocalNet: {Name: consts.LocalNet, RPC: rpc.LocalNet_RPC, WS: rpc.LocalNet_WS, Programs: programsOf(consts.MainNet)},
+	}
+)
+
+// RegisterCluster adds a cluster, or updates the named one with the non-empty endpoints and non-zero
+// programs of c.
+func RegisterCluster(c Cluster) error {
+	if c.Name == "" {
+		return errors.New("cluster name required")
+	}
+	clustersMu.Lock()
+	defer clustersMu.Unlock()
+	existing, ok := clusters[c.Name]
+	if !ok {
+		clusters[c.Name] = c
+		return nil
+	}
+	if c.RPC != "" {
+		existing.RPC = c.RPC
+	}
+	if c.WS != "" {
+		existing.WS = c.WS
+	}
+	existing.Programs = existing.Programs.merge(c.Programs)
+	clusters[c.Name] = existing
+	return nil
+}
+
+// GetCluster returns the named cluster, or an error wrapping ErrUnknownNetwork.
+func GetCluster(network string) (Cluster, error) {
+	clustersMu.RLock()
+	defer clustersMu.RUnlock()
+	c, ok := clusters[network]
+	if !ok {
+		return Cluster{}, fmt.Errorf("%w %q", ErrUnknownNetwork, network)
+	}
+	return c, nil
+}
+
+// Clusters returns the registered clusters sorted by name.
+func Clusters() []Cluster {
+	clustersMu.RLock()
+	defer clustersMu.RUnlock()
+	res := make([]Cluster, 0, len(clusters))
+	for _, c := range clusters {
+		res = append(res, c)
+	}
+	sort.Slice(res, func(i, j int) bool { return res[i].Name < res[j].Name })
+	return res
+}
+
+func program(network string, name string, pick func(Programs) solana.PublicKey) (solana.PublicKey, error) {
+	c, err := GetCluster(network)
+	if err != nil {
+		return solana.PublicKey{}, err
+	}
+	programID := pick(c.Programs)
+	if programID.IsZero() {
+		return solana.PublicKey{}, fmt.Errorf("no %s program on network %q", name, network)
+	}
+	return programID, nil
+}
+
+func AMMProgram(network string) (solana.PublicKey, error) {
+	return program(network, "amm", func(p Programs) solana.PublicKey { return p.AMM })
+}
+
+func CPMMProgram(network string) (solana.PublicKey, error) {
+	return program(network, "cpmm", func(p Programs) solana.PublicKey { return p.CPMM })
+}
+
+func CLMMProgram(network string) (solana.PublicKey, error) {
+	return program(network, "clmm", func(p Programs) solana.PublicKey { return p.CLMM })
+}
+
+func LaunchLabProgram(network string) (solana.PublicKey, error) {
+	return program(network, "launchlab", func(p Programs) solana.PublicKey { return p.LaunchLab })
+}
+
+func FarmV3Program(network string) (solana.PublicKey, error) {
+	return program(network, "farm v3", func(p Programs) solana.PublicKey { return p.FarmV3 })
+}
+
+func FarmV5Program(network string) (solana.PublicKey, error) {
+	return program(network, "farm v5", func(p Programs) solana.PublicKey { return p.FarmV5 })
+}
+
+func OpenBookProgram(network string) (solana.PublicKey, error) {
+	return program(network, "openbook", func(p Programs) solana.PublicKey { return p.OpenBook })
+}
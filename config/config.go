@@ -11,6 +11,26 @@ var (
 		consts.MainNet: solana.MustPublicKeyFromBase58("675kPX9MHTjS2zt1qfr1NYHuzeLXfQM9H24wFSUt1Mp8"),
 		consts.DevNet:  solana.MustPublicKeyFromBase58("HWy1jotHpo6UqeQxx49dpYYdQB8wj9Qk9MdxwjLvDHB8"),
 	}
+	Raydium_CPMM_Program = map[string]solana.PublicKey{
+		consts.MainNet: solana.MustPublicKeyFromBase58("CPMMoo8L3F4NbTegBCKVNunggL7H1ZpdTHKxQB5qKP1C"),
+		consts.DevNet:  solana.MustPublicKeyFromBase58("DRaycpLY18LhpbydsBWbVJtxpNv9oXPgjRSfpF2bWpYb"),
+	}
+	Raydium_CLMM_Program = map[string]solana.PublicKey{
+		consts.MainNet: solana.MustPublicKeyFromBase58("CAMMCzo5YL8w4VFF8KVHrK22GGUsp5VTaW7grrKgrWqK"),
+		consts.DevNet:  solana.MustPublicKeyFromBase58("devi51mZmdwUJGU9hjN27vEz64Gps7uUefqxg27EAtH"),
+	}
+	Raydium_LaunchLab_Program = map[string]solana.PublicKey{
+		consts.MainNet: solana.MustPublicKeyFromBase58("LanMV9sAd7wArD4vJFi2qDdfnVhFxYSUg6eADduJ3uj"),
+		consts.DevNet:  solana.MustPublicKeyFromBase58("LanD8FpTBBvzZFXjTxsAoipkFsxPUCDB4qAqKxYDiNP"),
+	}
+	Raydium_Farm_V3_Program = map[string]solana.PublicKey{
+		consts.MainNet: solana.MustPublicKeyFromBase58("EhhTKczWMGQt46ynNeRX1WfeagwwJd7ufHvCDjRxjo5Q"),
+		consts.DevNet:  solana.MustPublicKeyFromBase58("85BFyr98MbCUU9MVTEgzx1nbhWACbJqLzho6zd6DZcWL"),
+	}
+	Raydium_Farm_V5_Program = map[string]solana.PublicKey{
+		consts.MainNet: solana.MustPublicKeyFromBase58("9KEPoZmtHUrBbhWN1v1KWLMkkvwY6WLtAVUCPRtRjP4z"),
+		consts.DevNet:  solana.MustPublicKeyFromBase58("EcLzTrNg9V7qhcdyXDe2qjtPkiGzDM2UbdRaeaadU5r2"),
+	}
 	Raydium_OpenBook_Program = map[string]solana.PublicKey{
 		consts.MainNet: solana.MustPublicKeyFromBase58("srmqPvymJeFKQ4zGQed1GFppgkRHL9kaELCbyksJtPX"),
 		consts.DevNet:  solana.MustPublicKeyFromBase58("EoTcMgcDRTJVZDMZWBoU6rhYHZfkNTVEAfz3uUJRcYGj"),
@@ -0,0 +1,211 @@
+package config
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"raydium-go/rpcpool"
+
+	"github.com/gagliardetto/solana-go"
+)
+
+const testYAML = `
+default: local
+profiles:
+  local:
+    network: localnet
+    rpc: http://127.0.0.1:8899
+    ws: ws://127.0.0.1:8900
+    wallet: ~/keys/local.json
+    programs:
+      amm: HWy1jotHpo6UqeQxx49dpYYdQB8wj9Qk9MdxwjLvDHB8
+    fees:
+      slippage: 0.005
+      compute_unit_limit: 80000
+      priority_fee: 1000
+  main:
+    network: mainnet
+`
+
+const testTOML = `
+default = "main"
+
+[clusters.staging]
+rpc = "http://staging:8899"
+
+[clusters.staging.programs]
+amm = "TokenkegQfeZyiNwAJbNbGKPFXCWuBvf9Ss623VQ5DA"
+
+[profiles.stage]
+network = "staging"
+
+[profiles.main]
+network = "mainnet"
+
+[profiles.main.fees]
+priority_fee_percentile = 75.0
+max_priority_fee = 50000
+
+[profiles.dev]
+network = "devnet"
+rpc = "https://devnet.example.com"
+
+[profiles.pooled]
+network = "mainnet"
+
+[[profiles.pooled.endpoints]]
+url = "https://a.example.com"
+rate_limit = 10.0
+
+[[profiles.pooled.endpoints]]
+url = "https://b.example.com"
+
+[profiles.pooled.pool]
+strategy = "healthiest"
+hedge_delay = "150ms"
+
+[profiles.dev.programs]
+cpmm = "DRaycpLY18LhpbydsBWbVJtxpNv9oXPgjRSfpF2bWpYb"
+`
+
+func writeConfig(t *testing.T, name string, content string) string {
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoadProfile(t *testing.T) {
+	t.Setenv(EnvProfile, "")
+	path := writeConfig(t, "raydium.yaml", testYAML)
+	profile, err := LoadProfile(path, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	amm, err := profile.AMMProgram()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if profile.Name != "local" || profile.Fees.ComputeUnitLimit != 80000 || profile.Slippage() != 0.005 || !amm.Equals(Raydium_AMM_Program["devnet"]) {
+		t.Errorf("unexpected profile %+v", profile)
+	}
+	// localnet 默认使用主网地址
+	if cpmm, err := profile.CPMMProgram(); err != nil || !cpmm.Equals(Raydium_CPMM_Program["mainnet"]) {
+		t.Errorf("localnet cpmm = %s, %v", cpmm, err)
+	}
+	if wallet, _ := profile.WalletPath(); filepath.Base(wallet) != "local.json" || wallet[0] == '~' {
+		t.Errorf("wallet = %s", wallet)
+	}
+
+	t.Setenv("RAYDIUM_RPC_URL", "http://validator:8899")
+	t.Setenv("RAYDIUM_PRIORITY_FEE", "5")
+	t.Setenv("RAYDIUM_AMM_PROGRAM_ID", solana.TokenProgramID.String())
+	profile, err = LoadProfile(path, "local")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if amm, _ := profile.AMMProgram(); profile.RPC != "http://validator:8899" || profile.Fees.PriorityFee != 5 || !amm.Equals(solana.TokenProgramID) {
+		t.Errorf("environment overrides not applied: %+v", profile)
+	}
+	t.Setenv("RAYDIUM_PRIORITY_FEE", "x")
+	if _, err := LoadProfile(path, "local"); err == nil {
+		t.Error("expected an error for an invalid override")
+	}
+	if _, err := LoadProfile(path, "missing"); err == nil {
+		t.Error("expected an error for an unknown profile")
+	}
+}
+
+func TestLoadTOML(t *testing.T) {
+	file, err := LoadFile(writeConfig(t, "raydium.toml", testTOML))
+	if err != nil {
+		t.Fatal(err)
+	}
+	main, err := file.Profile("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if endpoint, _ := main.RPCEndpoint(); main.Fees.PriorityFeePercentile != 75 || endpoint != "https://api.mainnet-beta.solana.com" {
+		t.Errorf("unexpected profile %+v", main)
+	}
+	stage, err := file.Profile("stage")
+	if err != nil {
+		t.Fatal(err)
+	}
+	amm, err := stage.AMMProgram()
+	if err != nil || !amm.Equals(solana.TokenProgramID) {
+		t.Errorf("staging amm = %s, %v", amm, err)
+	}
+	if ws, _ := stage.WSEndpoint(); ws != "ws://staging:8899" {
+		t.Errorf("staging ws = %s", ws)
+	}
+	if _, err := stage.CPMMProgram(); err == nil {
+		t.Error("expected an error for a program missing on the cluster")
+	}
+	pooled, err := file.Profile("pooled")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pooled.Endpoints) != 2 || pooled.Endpoints[0].RateLimit != 10 || pooled.Pool.Strategy != rpcpool.Healthiest || pooled.Pool.HedgeDelay != 150*time.Millisecond {
+		t.Errorf("unexpected pool settings %+v %+v", pooled.Endpoints, pooled.Pool)
+	}
+	if ws, _ := pooled.WSEndpoint(); ws != "wss://a.example.com" {
+		t.Errorf("pooled ws = %s", ws)
+	}
+	pooled.SetRPC("http://a:8899, http://b:8899")
+	if pooled.RPC != "" || len(pooled.Endpoints) != 2 || pooled.Endpoints[1].URL != "http://b:8899" {
+		t.Errorf("SetRPC with two urls: %+v", pooled)
+	}
+	pooled.SetRPC("http://a:8899")
+	if pooled.RPC != "http://a:8899" || len(pooled.Endpoints) != 0 {
+		t.Errorf("SetRPC with one url: %+v", pooled)
+	}
+	dev, err := file.Profile("dev")
+	if err != nil {
+		t.Fatal(err)
+	}
+	cpmm, _ := dev.CPMMProgram()
+	ws, _ := dev.WSEndpoint()
+	if !cpmm.Equals(Raydium_CPMM_Program["devnet"]) || ws != "wss://devnet.example.com" {
+		t.Errorf("cpmm = %s, ws = %s", cpmm, ws)
+	}
+}
+
+func TestClusters(t *testing.T) {
+	if _, err := AMMProgram("nowhere"); !errors.Is(err, ErrUnknownNetwork) {
+		t.Errorf("expected ErrUnknownNetwork, got %v", err)
+	}
+	local, err := GetCluster("localnet")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if local.RPC != "http://127.0.0.1:8899" || !local.Programs.AMM.Equals(Raydium_AMM_Program["mainnet"]) {
+		t.Errorf("unexpected localnet %+v", local)
+	}
+
+	custom := solana.NewWallet().PublicKey()
+	if err := RegisterCluster(Cluster{Name: "test-validator", RPC: "http://localhost:9999", Programs: Programs{AMM: custom}}); err != nil {
+		t.Fatal(err)
+	}
+	if amm, err := AMMProgram("test-validator"); err != nil || !amm.Equals(custom) {
+		t.Errorf("custom amm = %s, %v", amm, err)
+	}
+	if _, err := OpenBookProgram("test-validator"); err == nil {
+		t.Error("expected an error for a program missing on the cluster")
+	}
+	// 已有集群只覆盖非零字段
+	if err := RegisterCluster(Cluster{Name: "test-validator", Programs: Programs{OpenBook: custom}}); err != nil {
+		t.Fatal(err)
+	}
+	c, _ := GetCluster("test-validator")
+	if c.RPC != "http://localhost:9999" || !c.Programs.AMM.Equals(custom) || !c.Programs.OpenBook.Equals(custom) {
+		t.Errorf("unexpected merged cluster %+v", c)
+	}
+	if err := RegisterCluster(Cluster{}); err == nil {
+		t.Error("expected an error for a cluster without a name")
+	}
+}
@@ -0,0 +1,350 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"raydium-go/consts"
+	"raydium-go/rpcpool"
+
+	"github.com/BurntSushi/toml"
+	"github.com/gagliardetto/solana-go"
+	"github.com/gagliardetto/solana-go/rpc"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	// 配置文件路径和 profile 名称的环境变量
+	EnvConfig  = "RAYDIUM_CONFIG"
+	EnvProfile = "RAYDIUM_PROFILE"
+	envPrefix  = "RAYDIUM_"
+
+	DefaultSlippage = 0.01
+)
+
+// FeePolicy 为交易的滑点、compute unit 和优先费设置，零值使用各包的默认值
+type FeePolicy struct {
+	Slippage         float64 `yaml:"slippage" toml:"slippage"`
+	ComputeUnitLimit uint32  `yaml:"compute_unit_limit" toml:"compute_unit_limit"`
+	// 固定的 compute unit 价格（micro-lamports）
+	PriorityFee uint64 `yaml:"priority_fee" toml:"priority_fee"`
+	// 大于 0 时按 getRecentPrioritizationFees 的该百分位估算优先费，优先于 PriorityFee
+	PriorityFeePercentile float64 `yaml:"priority_fee_percentile" toml:"priority_fee_percentile"`
+	MaxPriorityFee        uint64  `yaml:"max_priority_fee" toml:"max_priority_fee"`
+	// 大于 0 时先模拟交易，按实际消耗加上该比例设置 limit，优先于 ComputeUnitLimit
+	ComputeUnitMargin float64 `yaml:"compute_unit_margin" toml:"compute_unit_margin"`
+}
+
+// Profile 为一组命名的连接、程序和钱包设置
+type Profile struct {
+	Name    string `yaml:"-" toml:"-"`
+	Network string `yaml:"network" toml:"network"`
+	RPC     string `yaml:"rpc" toml:"rpc"`
+	// 设置后通过端点池访问这些端点，优先于 RPC
+	Endpoints []rpcpool.Endpoint `yaml:"endpoints" toml:"endpoints"`
+	Pool      rpcpool.Options    `yaml:"pool" toml:"pool"`
+	WS        string             `yaml:"ws" toml:"ws"`
+	// 覆盖集群默认值的程序 ID，零值表示不覆盖
+	Programs Programs  `yaml:"programs" toml:"programs"`
+	Fees     FeePolicy `yaml:"fees" toml:"fees"`
+	// solana-keygen 格式的钱包文件
+	Wallet string `yaml:"wallet" toml:"wallet"`
+}
+
+// File 对应配置文件
+type File struct {
+	Default  string             `yaml:"default" toml:"default"`
+	Profiles map[string]Profile `yaml:"profiles" toml:"profiles"`
+	// 自定义集群，加载时注册到集群表
+	Clusters map[string]Cluster `yaml:"clusters" toml:"clusters"`
+}
+
+// LoadFile reads a YAML (.yaml, .yml) or TOML (.toml) config file and registers its clusters.
+func LoadFile(path string) (*File, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var file File
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(data, &file)
+	case ".toml":
+		err = toml.Unmarshal(data, &file)
+	default:
+		return nil, fmt.Errorf("unsupported config file %s", path)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+	for name, cluster := range file.Clusters {
+		cluster.Name = name
+		if err := RegisterCluster(cluster); err != nil {
+			return nil, err
+		}
+	}
+	return &file, nil
+}
+
+// Profile returns the named profile, or the default one when name is empty, with the RAYDIUM_*
+// environment overrides applied.
+func (f *File) Profile(name string) (Profile, error) {
+	if name == "" {
+		name = f.Default
+	}
+	profile, ok := f.Profiles[name]
+	if !ok {
+		return Profile{}, fmt.Errorf("unknown profile %q", name)
+	}
+	profile.Name = name
+	if err := profile.applyEnv(); err != nil {
+		return Profile{}, err
+	}
+	return profile, nil
+}
+
+// LoadProfile loads a profile from path, or from $RAYDIUM_CONFIG when path is empty. name defaults to
+// $RAYDIUM_PROFILE and then to the default of the file. Without any file the profile only holds the
+// environment overrides on top of mainnet.
+func LoadProfile(path string, name string) (Profile, error) {
+	if path == "" {
+		path = os.Getenv(EnvConfig)
+	}
+	if name == "" {
+		name = os.Getenv(EnvProfile)
+	}
+	if path == "" {
+		profile := Profile{Name: name, Network: consts.MainNet}
+		err := profile.applyEnv()
+		return profile, err
+	}
+	file, err := LoadFile(path)
+	if err != nil {
+		return Profile{}, err
+	}
+	return file.Profile(name)
+}
+
+func (p *Profile) applyEnv() error {
+	texts := map[string]*string{
+		"NETWORK": &p.Network,
+		"WS_URL":  &p.WS,
+		"WALLET":  &p.Wallet,
+	}
+	for key, v := range texts {
+		if s, ok := os.LookupEnv(envPrefix + key); ok {
+			*v = s
+		}
+	}
+	if s, ok := os.LookupEnv(envPrefix + "RPC_URL"); ok {
+		p.SetRPC(s)
+	}
+	programs := map[string]*solana.PublicKey{
+		"AMM_PROGRAM_ID":       &p.Programs.AMM,
+		"CPMM_PROGRAM_ID":      &p.Programs.CPMM,
+		"CLMM_PROGRAM_ID":      &p.Programs.CLMM,
+		"LAUNCHLAB_PROGRAM_ID": &p.Programs.LaunchLab,
+		"FARM_V3_PROGRAM_ID":   &p.Programs.FarmV3,
+		"FARM_V5_PROGRAM_ID":   &p.Programs.FarmV5,
+		"OPENBOOK_PROGRAM_ID":  &p.Programs.OpenBook,
+	}
+	for key, v := range programs {
+		if s, ok := os.LookupEnv(envPrefix + key); ok {
+			programID, err := solana.PublicKeyFromBase58(s)
+			if err != nil {
+				return fmt.Errorf("%s%s: %w", envPrefix, key, err)
+			}
+			*v = programID
+		}
+	}
+	floats := map[string]*float64{
+		"SLIPPAGE":                &p.Fees.Slippage,
+		"PRIORITY_FEE_PERCENTILE": &p.Fees.PriorityFeePercentile,
+		"COMPUTE_UNIT_MARGIN":     &p.Fees.ComputeUnitMargin,
+	}
+	for key, v := range floats {
+		if s, ok := os.LookupEnv(envPrefix + key); ok {
+			f, err := strconv.ParseFloat(s, 64)
+			if err != nil {
+				return fmt.Errorf("%s%s: %w", envPrefix, key, err)
+			}
+			*v = f
+		}
+	}
+	uints := map[string]*uint64{
+		"PRIORITY_FEE":     &p.Fees.PriorityFee,
+		"MAX_PRIORITY_FEE": &p.Fees.MaxPriorityFee,
+	}
+	for key, v := range uints {
+		if s, ok := os.LookupEnv(envPrefix + key); ok {
+			n, err := strconv.ParseUint(s, 10, 64)
+			if err != nil {
+				return fmt.Errorf("%s%s: %w", envPrefix, key, err)
+			}
+			*v = n
+		}
+	}
+	if s, ok := os.LookupEnv(envPrefix + "COMPUTE_UNIT_LIMIT"); ok {
+		n, err := strconv.ParseUint(s, 10, 32)
+		if err != nil {
+			return fmt.Errorf("%sCOMPUTE_UNIT_LIMIT: %w", envPrefix, err)
+		}
+		p.Fees.ComputeUnitLimit = uint32(n)
+	}
+	if p.Network == "" {
+		p.Network = consts.MainNet
+	}
+	return nil
+}
+
+// Slippage returns the slippage of the fee policy, DefaultSlippage when unset.
+func (p Profile) Slippage() float64 {
+	if p.Fees.Slippage == 0 {
+		return DefaultSlippage
+	}
+	return p.Fees.Slippage
+}
+
+// SetNetwork switches the profile to network. The endpoints and programs of the profile belong to its
+// old network and are dropped, the RAYDIUM_* overrides are applied again on top of the new network.
+func (p *Profile) SetNetwork(network string) error {
+	if network == p.Network {
+		return nil
+	}
+	p.RPC, p.Endpoints, p.WS, p.Programs = "", nil, "", Programs{}
+	if err := p.applyEnv(); err != nil {
+		return err
+	}
+	p.Network = network
+	return nil
+}
+
+// SetRPC replaces the endpoints of the profile with a URL, or with a pool of comma-separated URLs.
+func (p *Profile) SetRPC(urls string) {
+	p.RPC, p.Endpoints = "", nil
+	for _, url := range strings.Split(urls, ",") {
+		if url = strings.TrimSpace(url); url != "" {
+			p.Endpoints = append(p.Endpoints, rpcpool.Endpoint{URL: url})
+		}
+	}
+	if len(p.Endpoints) == 1 {
+		p.RPC, p.Endpoints = p.Endpoints[0].URL, nil
+	}
+}
+
+// RPCEndpoint returns the RPC URL of the profile, the first of its endpoints, or the one of its
+// cluster.
+func (p Profile) RPCEndpoint() (string, error) {
+	if len(p.Endpoints) > 0 {
+		return p.Endpoints[0].URL, nil
+	}
+	if p.RPC != "" {
+		return p.RPC, nil
+	}
+	cluster, err := GetCluster(p.Network)
+	if err != nil {
+		return "", err
+	}
+	if cluster.RPC == "" {
+		return "", fmt.Errorf("profile %q has no rpc endpoint for network %q", p.Name, p.Network)
+	}
+	return cluster.RPC, nil
+}
+
+// Client returns a client of the profile's endpoint pool, or of its single RPC endpoint.
+func (p Profile) Client() (*rpc.Client, error) {
+	if len(p.Endpoints) > 0 {
+		return rpcpool.NewClient(p.Endpoints, p.Pool)
+	}
+	endpoint, err := p.RPCEndpoint()
+	if err != nil {
+		return nil, err
+	}
+	return rpc.New(endpoint), nil
+}
+
+// WSEndpoint returns the websocket URL of the profile, else the one of its cluster when the profile
+// keeps the cluster RPC endpoint, else one derived from the RPC URL.
+func (p Profile) WSEndpoint() (string, error) {
+	if p.WS != "" {
+		return p.WS, nil
+	}
+	endpoint, err := p.RPCEndpoint()
+	if err != nil {
+		return "", err
+	}
+	if cluster, err := GetCluster(p.Network); err == nil && cluster.WS != "" && endpoint == cluster.RPC {
+		return cluster.WS, nil
+	}
+	if strings.HasPrefix(endpoint, "https://") {
+		return "wss://" + strings.TrimPrefix(endpoint, "https://"), nil
+	}
+	return "ws://" + strings.TrimPrefix(endpoint, "http://"), nil
+}
+
+// WalletPath returns the wallet of the profile, ~/.config/solana/id.json when unset.
+func (p Profile) WalletPath() (string, error) {
+	path := p.Wallet
+	if path == "" {
+		path = filepath.Join("~", ".config", "solana", "id.json")
+	}
+	if path == "~" || strings.HasPrefix(path, "~/") {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return "", err
+		}
+		path = filepath.Join(home, path[1:])
+	}
+	return path, nil
+}
+
+func (p Profile) PrivateKey() (solana.PrivateKey, error) {
+	path, err := p.WalletPath()
+	if err != nil {
+		return nil, err
+	}
+	privateKey, err := solana.PrivateKeyFromSolanaKeygenFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load wallet %s: %w", path, err)
+	}
+	return privateKey, nil
+}
+
+// AMMProgram returns the AMM program of the profile: the override, else the one of its cluster.
+func (p Profile) AMMProgram() (solana.PublicKey, error) {
+	return p.program(p.Programs.AMM, AMMProgram)
+}
+
+func (p Profile) CPMMProgram() (solana.PublicKey, error) {
+	return p.program(p.Programs.CPMM, CPMMProgram)
+}
+
+func (p Profile) CLMMProgram() (solana.PublicKey, error) {
+	return p.program(p.Programs.CLMM, CLMMProgram)
+}
+
+func (p Profile) LaunchLabProgram() (solana.PublicKey, error) {
+	return p.program(p.Programs.LaunchLab, LaunchLabProgram)
+}
+
+func (p Profile) FarmV3Program() (solana.PublicKey, error) {
+	return p.program(p.Programs.FarmV3, FarmV3Program)
+}
+
+func (p Profile) FarmV5Program() (solana.PublicKey, error) {
+	return p.program(p.Programs.FarmV5, FarmV5Program)
+}
+
+func (p Profile) OpenBookProgram() (solana.PublicKey, error) {
+	return p.program(p.Programs.OpenBook, OpenBookProgram)
+}
+
+func (p Profile) program(override solana.PublicKey, lookup func(string) (solana.PublicKey, error)) (solana.PublicKey, error) {
+	if !override.IsZero() {
+		return override, nil
+	}
+	return lookup(p.Network)
+}
@@ -1,6 +1,7 @@
 package consts
 
 const (
-	MainNet string = "mainnet"
-	DevNet  string = "devnet"
+	MainNet  string = "mainnet"
+	DevNet   string = "devnet"
+	LocalNet string = "localnet"
 )
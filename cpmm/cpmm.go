@@ -0,0 +1,309 @@
+package cpmm
+
+import (
+	"context"
+	"fmt"
+
+	"raydium-go/anchor"
+	"raydium-go/config"
+	"raydium-go/spl"
+	"raydium-go/txn"
+
+	"github.com/gagliardetto/solana-go"
+	"github.com/gagliardetto/solana-go/rpc"
+)
+
+var (
+	computeUnitLimit = uint32(150000)
+	priorityFee      = uint64(100)
+
+	poolStateDiscriminator      = anchor.AccountDiscriminator("PoolState")
+	ammConfigDiscriminator      = anchor.AccountDiscriminator("AmmConfig")
+	swapBaseInputDiscriminator  = anchor.InstructionDiscriminator("swap_base_input")
+	swapBaseOutputDiscriminator = anchor.InstructionDiscriminator("swap_base_output")
+	authoritySeed               = []byte("vault_and_lp_mint_auth_seed")
+)
+
+// AmmConfig 对应 Rust 中的 AmmConfig
+type AmmConfig struct {
+	Bump              uint8
+	DisableCreatePool bool
+	Index             uint16
+	TradeFeeRate      uint64
+	ProtocolFeeRate   uint64
+	FundFeeRate       uint64
+	CreatePoolFee     uint64
+	ProtocolOwner     solana.PublicKey
+	FundOwner         solana.PublicKey
+	CreatorFeeRate    uint64
+	Padding           [15]uint64
+}
+
+// PoolState 对应 Rust 中的 PoolState
+type PoolState struct {
+	AmmConfig          solana.PublicKey
+	PoolCreator        solana.PublicKey
+	Token0Vault        solana.PublicKey
+	Token1Vault        solana.PublicKey
+	LpMint             solana.PublicKey
+	Token0Mint         solana.PublicKey
+	Token1Mint         solana.PublicKey
+	Token0Program      solana.PublicKey
+	Token1Program      solana.PublicKey
+	ObservationKey     solana.PublicKey
+	AuthBump           uint8
+	Status             uint8
+	LpMintDecimals     uint8
+	Mint0Decimals      uint8
+	Mint1Decimals      uint8
+	LpSupply           uint64
+	ProtocolFeesToken0 uint64
+	ProtocolFeesToken1 uint64
+	FundFeesToken0     uint64
+	FundFeesToken1     uint64
+	OpenTime           uint64
+	RecentEpoch        uint64
+	CreatorFeeOn       uint8
+	EnableCreatorFee   bool
+	Padding1           [6]uint8
+	CreatorFeesToken0  uint64
+	CreatorFeesToken1  uint64
+	Padding            [28]uint64
+}
+
+func DecodePoolState(data []byte) (PoolState, error) {
+	var state PoolState
+	err := anchor.DecodeAccount(data, poolStateDiscriminator, &state)
+	return state, err
+}
+
+func DecodeAmmConfig(data []byte) (AmmConfig, error) {
+	var ammConfig AmmConfig
+	err := anchor.DecodeAccount(data, ammConfigDiscriminator, &ammConfig)
+	return ammConfig, err
+}
+
+func GetPoolState(ctx context.Context, client *rpc.Client, pool solana.PublicKey, commitment rpc.CommitmentType) (PoolState, error) {
+	account, err := client.GetAccountInfoWithOpts(ctx, pool, &rpc.GetAccountInfoOpts{Commitment: commitment})
+	if err != nil {
+		return PoolState{}, err
+	}
+	return DecodePoolState(account.Value.Data.GetBinary())
+}
+
+func GetAmmConfig(ctx context.Context, client *rpc.Client, ammConfig solana.PublicKey, commitment rpc.CommitmentType) (AmmConfig, error) {
+	account, err := client.GetAccountInfoWithOpts(ctx, ammConfig, &rpc.GetAccountInfoOpts{Commitment: commitment})
+	if err != nil {
+		return AmmConfig{}, err
+	}
+	return DecodeAmmConfig(account.Value.Data.GetBinary())
+}
+
+func GetAuthority(programID solana.PublicKey) (solana.PublicKey, uint8, error) {
+	return solana.FindProgramAddress([][]byte{authoritySeed}, programID)
+}
+
+// SwapAccounts 为 swap_base_input / swap_base_output 指令的账户
+type SwapAccounts struct {
+	Payer              solana.PublicKey
+	Authority          solana.PublicKey
+	AmmConfig          solana.PublicKey
+	PoolState          solana.PublicKey
+	InputTokenAccount  solana.PublicKey
+	OutputTokenAccount solana.PublicKey
+	InputVault         solana.PublicKey
+	OutputVault        solana.PublicKey
+	InputTokenProgram  solana.PublicKey
+	OutputTokenProgram solana.PublicKey
+	InputTokenMint     solana.PublicKey
+	OutputTokenMint    solana.PublicKey
+	ObservationState   solana.PublicKey
+}
+
+// SwapAccountsFrom fills the swap accounts of pool for a trade of inputMint by payer.
+func SwapAccountsFrom(programID solana.PublicKey, pool solana.PublicKey, poolState PoolState, payer solana.PublicKey, inputMint solana.PublicKey, inputAccount solana.PublicKey, outputAccount solana.PublicKey) (SwapAccounts, error) {
+	authority, _, err := GetAuthority(programID)
+	if err != nil {
+		return SwapAccounts{}, err
+	}
+	accounts := SwapAccounts{
+		Payer:              payer,
+		Authority:          authority,
+		AmmConfig:          poolState.AmmConfig,
+		PoolState:          pool,
+		InputTokenAccount:  inputAccount,
+		OutputTokenAccount: outputAccount,
+		InputVault:         poolState.Token0Vault,
+		OutputVault:        poolState.Token1Vault,
+		InputTokenProgram:  poolState.Token0Program,
+		OutputTokenProgram: poolState.Token1Program,
+		InputTokenMint:     poolState.Token0Mint,
+		OutputTokenMint:    poolState.Token1Mint,
+		ObservationState:   poolState.ObservationKey,
+	}
+	switch {
+	case inputMint.Equals(poolState.Token0Mint):
+	case inputMint.Equals(poolState.Token1Mint):
+		accounts.InputVault, accounts.OutputVault = poolState.Token1Vault, poolState.Token0Vault
+		accounts.InputTokenProgram, accounts.OutputTokenProgram = poolState.Token1Program, poolState.Token0Program
+		accounts.InputTokenMint, accounts.OutputTokenMint = poolState.Token1Mint, poolState.Token0Mint
+	default:
+		return SwapAccounts{}, fmt.Errorf("mint %s is not in pool %s", inputMint, pool)
+	}
+	return accounts, nil
+}
+
+func (a SwapAccounts) metas() []*solana.AccountMeta {
+	return []*solana.AccountMeta{
+		solana.NewAccountMeta(a.Payer, false, true),
+		solana.NewAccountMeta(a.Authority, false, false),
+		solana.NewAccountMeta(a.AmmConfig, false, false),
+		solana.NewAccountMeta(a.PoolState, true, false),
+		solana.NewAccountMeta(a.InputTokenAccount, true, false),
+		solana.NewAccountMeta(a.OutputTokenAccount, true, false),
+		solana.NewAccountMeta(a.InputVault, true, false),
+		solana.NewAccountMeta(a.OutputVault, true, false),
+		solana.NewAccountMeta(a.InputTokenProgram, false, false),
+		solana.NewAccountMeta(a.OutputTokenProgram, false, false),
+		solana.NewAccountMeta(a.InputTokenMint, false, false),
+		solana.NewAccountMeta(a.OutputTokenMint, false, false),
+		solana.NewAccountMeta(a.ObservationState, true, false),
+	}
+}
+
+type SwapBaseInputArgs struct {
+	AmountIn         uint64
+	MinimumAmountOut uint64
+}
+
+type SwapBaseOutputArgs struct {
+	MaxAmountIn uint64
+	AmountOut   uint64
+}
+
+func NewSwapBaseInputInstruction(programID solana.PublicKey, accounts SwapAccounts, amountIn uint64, minimumAmountOut uint64) (solana.Instruction, error) {
+	data, err := anchor.InstructionData(swapBaseInputDiscriminator, SwapBaseInputArgs{AmountIn: amountIn, MinimumAmountOut: minimumAmountOut})
+	if err != nil {
+		return nil, err
+	}
+	return solana.NewInstruction(programID, accounts.metas(), data), nil
+}
+
+func NewSwapBaseOutputInstruction(programID solana.PublicKey, accounts SwapAccounts, maxAmountIn uint64, amountOut uint64) (solana.Instruction, error) {
+	data, err := anchor.InstructionData(swapBaseOutputDiscriminator, SwapBaseOutputArgs{MaxAmountIn: maxAmountIn, AmountOut: amountOut})
+	if err != nil {
+		return nil, err
+	}
+	return solana.NewInstruction(programID, accounts.metas(), data), nil
+}
+
+func Swap(ctx context.Context, client *rpc.Client, network string, poolAddress string, inputTokenAddress string, amountSpecified uint64, baseIn bool, slippage float64, privateKey string) (string, error) {
+	return SwapWithOptions(ctx, client, network, poolAddress, inputTokenAddress, amountSpecified, baseIn, slippage, privateKey, txn.SwapOptions{})
+}
+
+func SwapWithOptions(ctx context.Context, client *rpc.Client, network string, poolAddress string, inputTokenAddress string, amountSpecified uint64, baseIn bool, slippage float64, privateKey string, opts txn.SwapOptions) (string, error) {
+	signer, err := solana.PrivateKeyFromBase58(privateKey)
+	if err != nil {
+		return "", fmt.Errorf("invalid private key: %w", err)
+	}
+	tx, err := BuildSwapTransaction(ctx, client, network, poolAddress, inputTokenAddress, amountSpecified, baseIn, slippage, signer.PublicKey(), opts)
+	if err != nil {
+		return "", err
+	}
+	if err := txn.PartialSign(tx, signer); err != nil {
+		return "", err
+	}
+	txHash, err := client.SendTransaction(ctx, tx)
+	if err != nil {
+		return "", fmt.Errorf("failed to send transaction: %w", err)
+	}
+	return txHash.String(), nil
+}
+
+// BuildSwapTransaction builds the unsigned swap transaction of owner on a CPMM pool. SOL goes through
+// the WSOL account picked by opts.WSOLPolicy.
+func BuildSwapTransaction(ctx context.Context, client *rpc.Client, network string, poolAddress string, inputTokenAddress string, amountSpecified uint64, baseIn bool, slippage float64, owner solana.PublicKey, opts txn.SwapOptions) (*solana.Transaction, error) {
+	programID, err := config.CPMMProgram(network)
+	if err != nil {
+		return nil, err
+	}
+	pool, err := solana.PublicKeyFromBase58(poolAddress)
+	if err != nil {
+		return nil, err
+	}
+	inputMintAddress, err := solana.PublicKeyFromBase58(inputTokenAddress)
+	if err != nil {
+		return nil, err
+	}
+	commitment := opts.StateCommitment()
+	poolState, err := GetPoolState(ctx, client, pool, commitment)
+	if err != nil {
+		return nil, err
+	}
+	ammConfig, err := GetAmmConfig(ctx, client, poolState.AmmConfig, commitment)
+	if err != nil {
+		return nil, err
+	}
+	accounts, err := SwapAccountsFrom(programID, pool, poolState, owner, inputMintAddress, solana.PublicKey{}, solana.PublicKey{})
+	if err != nil {
+		return nil, err
+	}
+	mints, err := spl.GetMints(ctx, client, accounts.InputTokenMint, accounts.OutputTokenMint)
+	if err != nil {
+		return nil, err
+	}
+	inputMint, outputMint := mints[0], mints[1]
+	reserve0, reserve1, err := GetPoolReserves(ctx, client, poolState, commitment)
+	if err != nil {
+		return nil, err
+	}
+	reserveIn, reserveOut := reserve0, reserve1
+	if inputMint.Address.Equals(poolState.Token1Mint) {
+		reserveIn, reserveOut = reserve1, reserve0
+	}
+	var epoch uint64
+	if inputMint.TransferFeeConfig != nil || outputMint.TransferFeeConfig != nil {
+		epochInfo, err := client.GetEpochInfo(ctx, commitment)
+		if err != nil {
+			return nil, err
+		}
+		epoch = epochInfo.Epoch
+	}
+	quote, err := QuoteSwap(poolState.SwapFees(ammConfig, inputMint.Address), reserveIn, reserveOut, inputMint, outputMint, epoch, amountSpecified, baseIn, slippage)
+	if err != nil {
+		return nil, err
+	}
+
+	inputAccount, err := spl.TokenAccountInstructions(ctx, client, commitment, owner, owner, inputMint, quote.MaxAmountIn(baseIn), opts.WSOLPolicy)
+	if err != nil {
+		return nil, err
+	}
+	outputAccount, err := spl.TokenAccountInstructions(ctx, client, commitment, owner, owner, outputMint, 0, opts.WSOLPolicy)
+	if err != nil {
+		return nil, err
+	}
+	accounts.InputTokenAccount = inputAccount.Address
+	accounts.OutputTokenAccount = outputAccount.Address
+	var swapInstruction solana.Instruction
+	instructionType := "swap_base_input"
+	if baseIn {
+		swapInstruction, err = NewSwapBaseInputInstruction(programID, accounts, quote.AmountIn, quote.OtherAmountThreshold)
+	} else {
+		instructionType = "swap_base_output"
+		swapInstruction, err = NewSwapBaseOutputInstruction(programID, accounts, quote.OtherAmountThreshold, quote.ReceivedAmount())
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var instructions []solana.Instruction
+	instructions = append(instructions, inputAccount.Setup...)
+	instructions = append(instructions, outputAccount.Setup...)
+	instructions = append(instructions, swapInstruction)
+	instructions = append(instructions, inputAccount.Cleanup...)
+	instructions = append(instructions, outputAccount.Cleanup...)
+	// 账户的创建、wrap 和关闭改变 compute units，按指令数区分缓存
+	key := txn.CacheKey(pool, fmt.Sprintf("%s/%d", instructionType, len(instructions)))
+	writable := []solana.PublicKey{pool, accounts.InputVault, accounts.OutputVault}
+	return opts.Transaction(ctx, client, key, instructions, owner, writable, computeUnitLimit, priorityFee)
+}
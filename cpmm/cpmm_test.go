@@ -0,0 +1,163 @@
+package cpmm
+
+import (
+	"encoding/base64"
+	"testing"
+
+	"raydium-go/anchor"
+	"raydium-go/config"
+	"raydium-go/spl"
+
+	"github.com/gagliardetto/solana-go"
+)
+
+func TestComputeAmount(t *testing.T) {
+	fees := SwapFees{TradeFeeRate: 2500, CreatorFeeOnInput: true}
+	out, fee, _, err := ComputeAmountOut(fees, 1000000000, 2000000000, 1000000)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out != 1993011 || fee != 2500 {
+		t.Errorf("out = %d, fee = %d", out, fee)
+	}
+	in, _, _, err := ComputeAmountIn(fees, 2000000000, 1000000000, 1000000)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if in != 2007021 {
+		t.Errorf("in = %d", in)
+	}
+	if out, _, _, _ := ComputeAmountOut(fees, 2000000000, 1000000000, in); out < 1000000 {
+		t.Errorf("amount in %d only yields %d", in, out)
+	}
+	if _, _, _, err := ComputeAmountIn(fees, 2000000000, 1000000000, 1000000000); err != ErrInsufficientLiquidity {
+		t.Errorf("expected ErrInsufficientLiquidity, got %v", err)
+	}
+}
+
+func TestCreatorFee(t *testing.T) {
+	token0, token1 := solana.NewWallet().PublicKey(), solana.NewWallet().PublicKey()
+	ammConfig := AmmConfig{TradeFeeRate: 2500, CreatorFeeRate: 1000}
+	pool := PoolState{Token0Mint: token0, Token1Mint: token1, EnableCreatorFee: true, CreatorFeeOn: CreatorFeeOnToken0}
+	if fees := (PoolState{}).SwapFees(ammConfig, token0); fees.CreatorFeeRate != 0 {
+		t.Errorf("creator fee charged while disabled: %+v", fees)
+	}
+	onInput, onOutput := pool.SwapFees(ammConfig, token0), pool.SwapFees(ammConfig, token1)
+	if !onInput.CreatorFeeOnInput || onOutput.CreatorFeeOnInput || onOutput.CreatorFeeRate != 1000 {
+		t.Fatalf("unexpected fees: %+v, %+v", onInput, onOutput)
+	}
+	tests := []struct {
+		name       string
+		fees       SwapFees
+		baseIn     bool
+		amount     uint64
+		tradeFee   uint64
+		creatorFee uint64
+	}{
+		{"input base in", onInput, true, 1991015, 2500, 1000},
+		{"output base in", onOutput, true, 1991017, 2500, 1994},
+		{"input base out", onInput, false, 502009, 1256, 503},
+		{"output base out", onOutput, false, 502008, 1256, 1002},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			compute := ComputeAmountIn
+			if test.baseIn {
+				compute = ComputeAmountOut
+			}
+			amount, tradeFee, creatorFee, err := compute(test.fees, 1000000000, 2000000000, 1000000)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if amount != test.amount || tradeFee != test.tradeFee || creatorFee != test.creatorFee {
+				t.Errorf("amount = %d, trade fee = %d, creator fee = %d", amount, tradeFee, creatorFee)
+			}
+		})
+	}
+	if _, _, _, err := ComputeAmountOut(SwapFees{TradeFeeRate: 600000, CreatorFeeRate: 400000}, 1, 1, 1); err == nil {
+		t.Error("expected an error for fee rates of 100%")
+	}
+}
+
+func TestQuoteSwap(t *testing.T) {
+	fee := &spl.TransferFeeConfig{NewerTransferFee: spl.TransferFee{MaximumFee: 1 << 40, TransferFeeBasisPoints: 100}}
+	input := spl.Mint{Program: solana.Token2022ProgramID, TransferFeeConfig: fee}
+	output := spl.Mint{Program: solana.TokenProgramID}
+	fees := SwapFees{TradeFeeRate: 2500, CreatorFeeRate: 1000, CreatorFeeOnInput: true}
+	quote, err := QuoteSwap(fees, 1000000000, 2000000000, input, output, 0, 1000000, true, 0.01)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected, _, creatorFee, _ := ComputeAmountOut(fees, 1000000000, 2000000000, 990000)
+	if quote.InputTransferFee != 10000 || quote.AmountOut != expected || quote.CreatorFee != creatorFee || quote.OtherAmountThreshold != uint64(float64(expected)*0.99) {
+		t.Errorf("unexpected quote: %+v", quote)
+	}
+}
+
+func TestDecodePoolState(t *testing.T) {
+	state := PoolState{
+		Token0Mint:         solana.NewWallet().PublicKey(),
+		Token1Mint:         solana.NewWallet().PublicKey(),
+		ProtocolFeesToken0: 10,
+		FundFeesToken1:     20,
+		CreatorFeesToken0:  5,
+	}
+	data, err := anchor.InstructionData(poolStateDiscriminator, state)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(data) != 637 {
+		t.Fatalf("pool state size = %d", len(data))
+	}
+	decoded, err := DecodePoolState(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if decoded != state {
+		t.Errorf("decoded = %+v", decoded)
+	}
+	reserve0, reserve1, err := ReservesFrom(decoded, 100, 100)
+	if err != nil || reserve0 != 85 || reserve1 != 80 {
+		t.Errorf("reserves = %d, %d, %v", reserve0, reserve1, err)
+	}
+	if _, err := DecodeAmmConfig(data); err == nil {
+		t.Error("expected discriminator mismatch")
+	}
+}
+
+func TestSwapInstruction(t *testing.T) {
+	programID := config.Raydium_CPMM_Program["mainnet"]
+	state := PoolState{Token0Mint: solana.NewWallet().PublicKey(), Token1Mint: solana.NewWallet().PublicKey(), Token0Vault: solana.NewWallet().PublicKey()}
+	pool, payer := solana.NewWallet().PublicKey(), solana.NewWallet().PublicKey()
+	accounts, err := SwapAccountsFrom(programID, pool, state, payer, state.Token1Mint, solana.PublicKey{}, solana.PublicKey{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !accounts.OutputVault.Equals(state.Token0Vault) || !accounts.InputTokenMint.Equals(state.Token1Mint) {
+		t.Errorf("unexpected accounts: %+v", accounts)
+	}
+	inst, err := NewSwapBaseInputInstruction(programID, accounts, 1000, 990)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, _ := inst.Data()
+	if len(data) != 24 || len(inst.Accounts()) != 13 {
+		t.Errorf("data = %x, accounts = %d", data, len(inst.Accounts()))
+	}
+}
+
+func TestParseSwapEvents(t *testing.T) {
+	event := SwapEvent{PoolID: solana.NewWallet().PublicKey(), InputAmount: 1000, OutputAmount: 1993, BaseInput: true}
+	data, _ := anchor.InstructionData(swapEventDiscriminator, event)
+	logs := []string{
+		"Program CPMMoo8L3F4NbTegBCKVNunggL7H1ZpdTHKxQB5qKP1C invoke [1]",
+		"Program data: " + base64.StdEncoding.EncodeToString(data),
+	}
+	events, err := ParseSwapEvents(logs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(events) != 1 || events[0] != event {
+		t.Errorf("events = %+v", events)
+	}
+}
@@ -0,0 +1,45 @@
+package cpmm
+
+import (
+	"raydium-go/anchor"
+
+	"github.com/gagliardetto/solana-go"
+)
+
+var swapEventDiscriminator = anchor.EventDiscriminator("SwapEvent")
+
+// SwapEvent 对应链上 emit! 的 SwapEvent
+type SwapEvent struct {
+	PoolID            solana.PublicKey
+	InputVaultBefore  uint64
+	OutputVaultBefore uint64
+	InputAmount       uint64
+	OutputAmount      uint64
+	InputTransferFee  uint64
+	OutputTransferFee uint64
+	BaseInput         bool
+}
+
+// ParseSwapEvent decodes a "Program data: " log line. ok is false for lines that are not a SwapEvent.
+func ParseSwapEvent(msg string) (event SwapEvent, ok bool, err error) {
+	ok, err = anchor.DecodeEvent(msg, swapEventDiscriminator, &event)
+	return event, ok, err
+}
+
+// ParseSwapEvents returns the swap events found in the log messages of a transaction.
+func ParseSwapEvents(logs []string) ([]SwapEvent, error) {
+	var events []SwapEvent
+	for _, msg := range logs {
+		if !anchor.IsProgramData(msg) {
+			continue
+		}
+		event, ok, err := ParseSwapEvent(msg)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			events = append(events, event)
+		}
+	}
+	return events, nil
+}
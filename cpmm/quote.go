@@ -0,0 +1,208 @@
+package cpmm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+	"strconv"
+
+	"raydium-go/spl"
+
+	"github.com/gagliardetto/solana-go"
+	"github.com/gagliardetto/solana-go/rpc"
+)
+
+const FeeRateDenominator = uint64(1000000)
+
+var ErrInsufficientLiquidity = errors.New("insufficient liquidity")
+
+type SwapQuote struct {
+	spl.SwapAmounts
+	TradeFee   uint64
+	CreatorFee uint64
+}
+
+// PoolState.CreatorFeeOn 的取值
+const (
+	CreatorFeeOnBothTokens uint8 = iota
+	CreatorFeeOnToken0
+	CreatorFeeOnToken1
+)
+
+// SwapFees 为一次 swap 适用的费率，creator fee 从输入或输出中收取
+type SwapFees struct {
+	TradeFeeRate      uint64
+	CreatorFeeRate    uint64
+	CreatorFeeOnInput bool
+}
+
+// SwapFees returns the fee rates of a swap from inputMint, as PoolState::adjust_creator_fee_rate and
+// is_creator_fee_on_input on chain.
+func (p PoolState) SwapFees(ammConfig AmmConfig, inputMint solana.PublicKey) SwapFees {
+	fees := SwapFees{TradeFeeRate: ammConfig.TradeFeeRate, CreatorFeeOnInput: true}
+	if !p.EnableCreatorFee {
+		return fees
+	}
+	fees.CreatorFeeRate = ammConfig.CreatorFeeRate
+	switch p.CreatorFeeOn {
+	case CreatorFeeOnToken0:
+		fees.CreatorFeeOnInput = inputMint.Equals(p.Token0Mint)
+	case CreatorFeeOnToken1:
+		fees.CreatorFeeOnInput = inputMint.Equals(p.Token1Mint)
+	}
+	return fees
+}
+
+func (f SwapFees) validate() error {
+	if f.TradeFeeRate+f.CreatorFeeRate >= FeeRateDenominator {
+		return fmt.Errorf("invalid fee rates: trade %d, creator %d", f.TradeFeeRate, f.CreatorFeeRate)
+	}
+	return nil
+}
+
+// TradingFee 对应链上 Fees::trading_fee，向上取整；Fees::creator_fee 的算法相同
+func TradingFee(amount uint64, tradeFeeRate uint64) uint64 {
+	return ceilDiv(mul(amount, tradeFeeRate), u(FeeRateDenominator)).Uint64()
+}
+
+// preFeeAmount 对应链上 Fees::calculate_pre_fee_amount，扣除 rate 的费用后剩下 amount
+func preFeeAmount(amount *big.Int, rate uint64) *big.Int {
+	if rate == 0 {
+		return amount
+	}
+	return ceilDiv(new(big.Int).Mul(amount, u(FeeRateDenominator)), u(FeeRateDenominator-rate))
+}
+
+// ComputeAmountOut 对应链上 CurveCalculator::swap_base_input，返回输出数量、交易手续费和 creator fee
+func ComputeAmountOut(fees SwapFees, reserveIn uint64, reserveOut uint64, amountIn uint64) (uint64, uint64, uint64, error) {
+	if err := fees.validate(); err != nil {
+		return 0, 0, 0, err
+	}
+	tradeFee := TradingFee(amountIn, fees.TradeFeeRate)
+	var creatorFee uint64
+	if fees.CreatorFeeOnInput {
+		creatorFee = TradingFee(amountIn, fees.CreatorFeeRate)
+	}
+	if tradeFee+creatorFee > amountIn {
+		return 0, 0, 0, ErrInsufficientLiquidity
+	}
+	afterFee := u(amountIn - tradeFee - creatorFee)
+	denominator := new(big.Int).Add(u(reserveIn), afterFee)
+	if denominator.Sign() == 0 {
+		return 0, 0, 0, ErrInsufficientLiquidity
+	}
+	out := new(big.Int).Quo(new(big.Int).Mul(u(reserveOut), afterFee), denominator).Uint64()
+	if !fees.CreatorFeeOnInput {
+		creatorFee = TradingFee(out, fees.CreatorFeeRate)
+		out -= creatorFee
+	}
+	return out, tradeFee, creatorFee, nil
+}
+
+// ComputeAmountIn 对应链上 CurveCalculator::swap_base_output，返回输入数量、交易手续费和 creator fee
+func ComputeAmountIn(fees SwapFees, reserveIn uint64, reserveOut uint64, amountOut uint64) (uint64, uint64, uint64, error) {
+	if err := fees.validate(); err != nil {
+		return 0, 0, 0, err
+	}
+	// creator fee 从输出收取时池子要多付出这部分
+	swappedOut := u(amountOut)
+	if !fees.CreatorFeeOnInput {
+		swappedOut = preFeeAmount(swappedOut, fees.CreatorFeeRate)
+	}
+	if swappedOut.Cmp(u(reserveOut)) >= 0 {
+		return 0, 0, 0, ErrInsufficientLiquidity
+	}
+	swapped := ceilDiv(new(big.Int).Mul(swappedOut, u(reserveIn)), u(reserveOut-swappedOut.Uint64()))
+	var amountIn *big.Int
+	if fees.CreatorFeeOnInput {
+		amountIn = preFeeAmount(swapped, fees.TradeFeeRate+fees.CreatorFeeRate)
+	} else {
+		amountIn = preFeeAmount(swapped, fees.TradeFeeRate)
+	}
+	if !amountIn.IsUint64() {
+		return 0, 0, 0, ErrInsufficientLiquidity
+	}
+	creatorFee := TradingFee(swappedOut.Uint64(), fees.CreatorFeeRate)
+	if fees.CreatorFeeOnInput {
+		creatorFee = TradingFee(amountIn.Uint64(), fees.CreatorFeeRate)
+	}
+	return amountIn.Uint64(), TradingFee(amountIn.Uint64(), fees.TradeFeeRate), creatorFee, nil
+}
+
+// QuoteSwap computes the amounts and slippage bound of a swap with the fee rates of PoolState.SwapFees,
+// including the creator fee and Token-2022 transfer fees charged on the input and output mints.
+func QuoteSwap(fees SwapFees, reserveIn uint64, reserveOut uint64, inputMint spl.Mint, outputMint spl.Mint, epoch uint64, amountSpecified uint64, baseIn bool, slippage float64) (SwapQuote, error) {
+	var quote SwapQuote
+	if baseIn {
+		quote.AmountIn = amountSpecified
+		quote.InputTransferFee = inputMint.TransferFee(epoch, amountSpecified)
+		amountOut, tradeFee, creatorFee, err := ComputeAmountOut(fees, reserveIn, reserveOut, amountSpecified-quote.InputTransferFee)
+		if err != nil {
+			return quote, err
+		}
+		quote.AmountOut = amountOut
+		quote.TradeFee, quote.CreatorFee = tradeFee, creatorFee
+		quote.OutputTransferFee = outputMint.TransferFee(epoch, amountOut)
+		quote.SetThreshold(true, slippage)
+		return quote, nil
+	}
+	quote.OutputTransferFee = outputMint.InverseTransferFee(epoch, amountSpecified)
+	quote.AmountOut = amountSpecified + quote.OutputTransferFee
+	amountIn, tradeFee, creatorFee, err := ComputeAmountIn(fees, reserveIn, reserveOut, quote.AmountOut)
+	if err != nil {
+		return quote, err
+	}
+	quote.TradeFee, quote.CreatorFee = tradeFee, creatorFee
+	quote.InputTransferFee = inputMint.InverseTransferFee(epoch, amountIn)
+	quote.AmountIn = amountIn + quote.InputTransferFee
+	quote.SetThreshold(false, slippage)
+	return quote, nil
+}
+
+// GetPoolReserves returns the token 0 and token 1 vault balances without the protocol, fund and
+// creator fees that are still held by the vaults.
+func GetPoolReserves(ctx context.Context, client *rpc.Client, poolState PoolState, commitment rpc.CommitmentType) (uint64, uint64, error) {
+	vault0, err := getVaultBalance(ctx, client, poolState.Token0Vault, commitment)
+	if err != nil {
+		return 0, 0, err
+	}
+	vault1, err := getVaultBalance(ctx, client, poolState.Token1Vault, commitment)
+	if err != nil {
+		return 0, 0, err
+	}
+	return ReservesFrom(poolState, vault0, vault1)
+}
+
+func ReservesFrom(poolState PoolState, vault0 uint64, vault1 uint64) (uint64, uint64, error) {
+	fees0 := poolState.ProtocolFeesToken0 + poolState.FundFeesToken0 + poolState.CreatorFeesToken0
+	fees1 := poolState.ProtocolFeesToken1 + poolState.FundFeesToken1 + poolState.CreatorFeesToken1
+	if fees0 > vault0 || fees1 > vault1 {
+		return 0, 0, ErrInsufficientLiquidity
+	}
+	return vault0 - fees0, vault1 - fees1, nil
+}
+
+func getVaultBalance(ctx context.Context, client *rpc.Client, vault solana.PublicKey, commitment rpc.CommitmentType) (uint64, error) {
+	account, err := client.GetTokenAccountBalance(ctx, vault, commitment)
+	if err != nil {
+		return 0, err
+	}
+	return strconv.ParseUint(account.Value.Amount, 10, 64)
+}
+
+func u(v uint64) *big.Int {
+	return new(big.Int).SetUint64(v)
+}
+
+func mul(a uint64, b uint64) *big.Int {
+	return new(big.Int).Mul(u(a), u(b))
+}
+
+func ceilDiv(a *big.Int, b *big.Int) *big.Int {
+	q, r := new(big.Int).QuoRem(a, b, new(big.Int))
+	if r.Sign() != 0 {
+		q.Add(q, big.NewInt(1))
+	}
+	return q
+}
@@ -0,0 +1,150 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"raydium-go/amm"
+	"raydium-go/txn"
+
+	"github.com/gagliardetto/solana-go"
+	"github.com/gagliardetto/solana-go/rpc"
+)
+
+const programLogPrefix = "Program log: "
+
+type rayLogOutput struct {
+	Type string      `json:"type"`
+	Log  interface{} `json:"log"`
+}
+
+func decodeRayLog(msg string) (rayLogOutput, error) {
+	log, err := amm.ParseRayLog(strings.TrimPrefix(msg, programLogPrefix))
+	if err != nil {
+		return rayLogOutput{}, err
+	}
+	out := rayLogOutput{Log: log}
+	switch log.(type) {
+	case amm.SwapBaseInLog:
+		out.Type = "swap_base_in"
+	case amm.SwapBaseOutLog:
+		out.Type = "swap_base_out"
+	}
+	return out, nil
+}
+
+func printRayLog(log rayLogOutput) {
+	fmt.Printf("%s %+v\n", log.Type, log.Log)
+}
+
+func runDecodeLog(args []string) error {
+	opts := new(options)
+	positional, err := opts.parse(newFlagSet("decode-log", opts), args)
+	if err != nil {
+		return err
+	}
+	if len(positional) == 0 {
+		return fmt.Errorf("decode-log needs at least one ray_log message: %w", errUsage)
+	}
+	out := make([]rayLogOutput, len(positional))
+	for i, msg := range positional {
+		if out[i], err = decodeRayLog(msg); err != nil {
+			return fmt.Errorf("%s: %w", msg, err)
+		}
+	}
+	return opts.output(out, func() {
+		for _, log := range out {
+			printRayLog(log)
+		}
+	})
+}
+
+type decodeTxOutput struct {
+	Signature string                 `json:"signature"`
+	Slot      uint64                 `json:"slot"`
+	Swaps     []*amm.SwapInstruction `json:"swaps"`
+	Logs      []rayLogOutput         `json:"logs"`
+}
+
+func runDecodeTx(ctx context.Context, args []string) error {
+	opts := new(options)
+	positional, err := opts.parse(newFlagSet("decode-tx", opts), args)
+	if err != nil {
+		return err
+	}
+	if len(positional) != 1 {
+		return fmt.Errorf("decode-tx needs a transaction signature: %w", errUsage)
+	}
+	signature, err := solana.SignatureFromBase58(positional[0])
+	if err != nil {
+		return fmt.Errorf("invalid signature: %w", err)
+	}
+	programID, err := opts.profile.AMMProgram()
+	if err != nil {
+		return err
+	}
+	client, err := opts.client()
+	if err != nil {
+		return err
+	}
+	maxVersion := uint64(0)
+	result, err := client.GetTransaction(ctx, signature, &rpc.GetTransactionOpts{
+		Encoding:                       solana.EncodingBase64,
+		Commitment:                     rpc.CommitmentConfirmed,
+		MaxSupportedTransactionVersion: &maxVersion,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to fetch transaction: %w", err)
+	}
+	tx, err := result.Transaction.GetTransaction()
+	if err != nil {
+		return err
+	}
+	if tx.Message.IsVersioned() && len(tx.Message.AddressTableLookups) > 0 {
+		tables, err := txn.GetLookupTables(ctx, client, tx.Message.AddressTableLookups.GetTableIDs())
+		if err != nil {
+			return err
+		}
+		if err := tx.Message.SetAddressTables(tables); err != nil {
+			return err
+		}
+	}
+	swaps, err := amm.DecodeSwapInstructions(tx, programID)
+	if err != nil {
+		return err
+	}
+	out := decodeTxOutput{Signature: signature.String(), Slot: result.Slot, Swaps: swaps}
+	if result.Meta != nil {
+		for _, msg := range result.Meta.LogMessages {
+			if !strings.HasPrefix(msg, programLogPrefix+"ray_log: ") {
+				continue
+			}
+			log, err := decodeRayLog(msg)
+			if err != nil {
+				return fmt.Errorf("%s: %w", msg, err)
+			}
+			out.Logs = append(out.Logs, log)
+		}
+	}
+	return opts.output(out, func() {
+		field("signature", out.Signature)
+		field("slot", out.Slot)
+		for i, swap := range out.Swaps {
+			kind, amountIn, amountOut := "swap_base_in", "amount in", "minimum out"
+			if !swap.BaseIn {
+				kind, amountIn, amountOut = "swap_base_out", "maximum in", "amount out"
+			}
+			fmt.Printf("swap #%d %s\n", i, kind)
+			field("  pool", swap.Pool)
+			field("  owner", swap.UserOwner)
+			field("  source", swap.UserSource)
+			field("  destination", swap.UserDestination)
+			field("  "+amountIn, swap.AmountIn)
+			field("  "+amountOut, swap.AmountOut)
+		}
+		for _, log := range out.Logs {
+			printRayLog(log)
+		}
+	})
+}
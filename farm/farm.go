@@ -0,0 +1,372 @@
+package farm
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"fmt"
+	"math/big"
+
+	"raydium-go/config"
+
+	bin "github.com/gagliardetto/binary"
+	"github.com/gagliardetto/solana-go"
+	"github.com/gagliardetto/solana-go/rpc"
+)
+
+const (
+	V3 = 3
+	V5 = 5
+)
+
+var (
+	// perShareReward 的精度，与 SDK 相同：v3 为 1e9，v5 为 1e15
+	rewardMultipliers = map[int]*big.Int{
+		V3: big.NewInt(1000000000),
+		V5: big.NewInt(1000000000000000),
+	}
+
+	ledgerSeed = []byte("staker_info_v2_associated_seed")
+)
+
+// FarmStateV3 对应 Rust 中 v3 的 FarmState，只有一种奖励
+type FarmStateV3 struct {
+	State          uint64
+	Nonce          uint64
+	LpVault        solana.PublicKey
+	RewardVault    solana.PublicKey
+	Owner          solana.PublicKey
+	FeeOwner       solana.PublicKey
+	FeeY           uint64
+	FeeX           uint64
+	TotalReward    uint64
+	PerShareReward bin.Uint128
+	LastSlot       uint64
+	PerSlotReward  uint64
+}
+
+// FarmStateV5 对应 Rust 中 v5 的 FarmState，带两种奖励
+type FarmStateV5 struct {
+	State           uint64
+	Nonce           uint64
+	LpVault         solana.PublicKey
+	RewardVaultA    solana.PublicKey
+	TotalRewardA    uint64
+	PerShareRewardA bin.Uint128
+	PerSlotRewardA  uint64
+	Option          uint8
+	RewardVaultB    solana.PublicKey
+	Padding         [7]uint8
+	TotalRewardB    uint64
+	PerShareRewardB bin.Uint128
+	PerSlotRewardB  uint64
+	LastSlot        uint64
+	Owner           solana.PublicKey
+}
+
+// LedgerV3 对应 Rust 中 v3 的 UserStakeInfo
+type LedgerV3 struct {
+	State             uint64
+	Farm              solana.PublicKey
+	Owner             solana.PublicKey
+	Deposited         uint64
+	RewardDebts       [1]bin.Uint128
+	Padding           uint64
+	VoteLockedBalance uint64
+	Padding1          [15]uint64
+}
+
+// LedgerV5 对应 Rust 中 v5 的 UserStakeInfo
+type LedgerV5 struct {
+	State       uint64
+	Farm        solana.PublicKey
+	Owner       solana.PublicKey
+	Deposited   uint64
+	RewardDebts [2]bin.Uint128
+	Padding     [17]uint64
+}
+
+type Reward struct {
+	Vault          solana.PublicKey
+	TotalReward    uint64
+	PerShareReward *big.Int
+	PerSlotReward  uint64
+}
+
+// Farm 为 v3 和 v5 farm 的统一表示
+type Farm struct {
+	Version   int
+	ProgramID solana.PublicKey
+	Address   solana.PublicKey
+	State     uint64
+	Nonce     uint64
+	LpVault   solana.PublicKey
+	Rewards   []Reward
+	LastSlot  uint64
+}
+
+type Ledger struct {
+	Address     solana.PublicKey
+	Farm        solana.PublicKey
+	Owner       solana.PublicKey
+	Deposited   uint64
+	RewardDebts []*big.Int
+}
+
+// Version returns the farm version of programID on any registered cluster.
+func Version(programID solana.PublicKey) (int, error) {
+	for _, cluster := range config.Clusters() {
+		switch {
+		case programID.IsZero():
+		case cluster.Programs.FarmV3.Equals(programID):
+			return V3, nil
+		case cluster.Programs.FarmV5.Equals(programID):
+			return V5, nil
+		}
+	}
+	return 0, fmt.Errorf("%s is not a known farm program", programID)
+}
+
+func DecodeFarm(version int, programID solana.PublicKey, address solana.PublicKey, data []byte) (Farm, error) {
+	farm := Farm{Version: version, ProgramID: programID, Address: address}
+	switch version {
+	case V3:
+		var state FarmStateV3
+		if err := bin.NewBinDecoder(data).Decode(&state); err != nil {
+			return farm, err
+		}
+		farm.State, farm.Nonce, farm.LpVault, farm.LastSlot = state.State, state.Nonce, state.LpVault, state.LastSlot
+		farm.Rewards = []Reward{
+			{Vault: state.RewardVault, TotalReward: state.TotalReward, PerShareReward: state.PerShareReward.BigInt(), PerSlotReward: state.PerSlotReward},
+		}
+	case V5:
+		var state FarmStateV5
+		if err := bin.NewBinDecoder(data).Decode(&state); err != nil {
+			return farm, err
+		}
+		farm.State, farm.Nonce, farm.LpVault, farm.LastSlot = state.State, state.Nonce, state.LpVault, state.LastSlot
+		farm.Rewards = []Reward{
+			{Vault: state.RewardVaultA, TotalReward: state.TotalRewardA, PerShareReward: state.PerShareRewardA.BigInt(), PerSlotReward: state.PerSlotRewardA},
+			{Vault: state.RewardVaultB, TotalReward: state.TotalRewardB, PerShareReward: state.PerShareRewardB.BigInt(), PerSlotReward: state.PerSlotRewardB},
+		}
+	default:
+		return farm, fmt.Errorf("unsupported farm version %d", version)
+	}
+	return farm, nil
+}
+
+// DecodeLedger decodes a user ledger. Ledgers created before the u128 migration store the reward
+// debts as u64 and are recognised by their size.
+func DecodeLedger(version int, address solana.PublicKey, data []byte) (Ledger, error) {
+	ledger := Ledger{Address: address}
+	rewards := 1
+	if version == V5 {
+		rewards = 2
+	}
+	legacySize := 8 + 32 + 32 + 8 + 8*rewards
+	if len(data) == legacySize {
+		ledger.Farm = solana.PublicKeyFromBytes(data[8:40])
+		ledger.Owner = solana.PublicKeyFromBytes(data[40:72])
+		ledger.Deposited = binary.LittleEndian.Uint64(data[72:80])
+		for i := 0; i < rewards; i++ {
+			ledger.RewardDebts = append(ledger.RewardDebts, new(big.Int).SetUint64(binary.LittleEndian.Uint64(data[80+8*i:])))
+		}
+		return ledger, nil
+	}
+	switch version {
+	case V3:
+		var state LedgerV3
+		if err := bin.NewBinDecoder(data).Decode(&state); err != nil {
+			return ledger, err
+		}
+		ledger.Farm, ledger.Owner, ledger.Deposited = state.Farm, state.Owner, state.Deposited
+		ledger.RewardDebts = []*big.Int{state.RewardDebts[0].BigInt()}
+	case V5:
+		var state LedgerV5
+		if err := bin.NewBinDecoder(data).Decode(&state); err != nil {
+			return ledger, err
+		}
+		ledger.Farm, ledger.Owner, ledger.Deposited = state.Farm, state.Owner, state.Deposited
+		ledger.RewardDebts = []*big.Int{state.RewardDebts[0].BigInt(), state.RewardDebts[1].BigInt()}
+	default:
+		return ledger, fmt.Errorf("unsupported farm version %d", version)
+	}
+	return ledger, nil
+}
+
+// GetFarm fetches a farm and picks its layout from the owning program.
+func GetFarm(ctx context.Context, client *rpc.Client, address solana.PublicKey) (Farm, error) {
+	account, err := client.GetAccountInfo(ctx, address)
+	if err != nil {
+		return Farm{}, err
+	}
+	version, err := Version(account.Value.Owner)
+	if err != nil {
+		return Farm{}, err
+	}
+	return DecodeFarm(version, account.Value.Owner, address, account.Value.Data.GetBinary())
+}
+
+// GetLedger fetches the ledger of owner in farm. A missing ledger returns rpc.ErrNotFound.
+func GetLedger(ctx context.Context, client *rpc.Client, farm Farm, owner solana.PublicKey) (Ledger, error) {
+	address, _, err := GetLedgerAddress(farm.ProgramID, farm.Address, owner)
+	if err != nil {
+		return Ledger{}, err
+	}
+	account, err := client.GetAccountInfo(ctx, address)
+	if err != nil {
+		return Ledger{}, err
+	}
+	return DecodeLedger(farm.Version, address, account.Value.Data.GetBinary())
+}
+
+func GetLedgerAddress(programID solana.PublicKey, farm solana.PublicKey, owner solana.PublicKey) (solana.PublicKey, uint8, error) {
+	return solana.FindProgramAddress([][]byte{farm[:], owner[:], ledgerSeed}, programID)
+}
+
+func GetAuthority(farm Farm) (solana.PublicKey, error) {
+	return solana.CreateProgramAddress([][]byte{farm.Address[:], {uint8(farm.Nonce)}}, farm.ProgramID)
+}
+
+// RewardMultiplier returns the precision of perShareReward for a farm version.
+func RewardMultiplier(version int) *big.Int {
+	if m, ok := rewardMultipliers[version]; ok {
+		return m
+	}
+	return rewardMultipliers[V5]
+}
+
+// PendingRewards 对应 SDK 中的 pending reward 计算：先把 perShareReward 推进到 slot，再减去 reward debt。
+// lpStaked 为 lp vault 的余额。
+func PendingRewards(farm Farm, ledger Ledger, lpStaked uint64, slot uint64) []uint64 {
+	deposited := new(big.Int).SetUint64(ledger.Deposited)
+	multiplier := RewardMultiplier(farm.Version)
+	res := make([]uint64, len(farm.Rewards))
+	for i, reward := range farm.Rewards {
+		perShare := new(big.Int).Set(reward.PerShareReward)
+		if lpStaked > 0 && slot > farm.LastSlot {
+			increment := new(big.Int).SetUint64(slot - farm.LastSlot)
+			increment.Mul(increment, new(big.Int).SetUint64(reward.PerSlotReward))
+			increment.Mul(increment, multiplier)
+			increment.Quo(increment, new(big.Int).SetUint64(lpStaked))
+			perShare.Add(perShare, increment)
+		}
+		pending := new(big.Int).Mul(deposited, perShare)
+		pending.Quo(pending, multiplier)
+		if i < len(ledger.RewardDebts) {
+			pending.Sub(pending, ledger.RewardDebts[i])
+		}
+		if pending.Sign() > 0 && pending.IsUint64() {
+			res[i] = pending.Uint64()
+		}
+	}
+	return res
+}
+
+// GetPendingRewards fetches the lp vault balance and current slot and computes the pending rewards
+// of owner in farm.
+func GetPendingRewards(ctx context.Context, client *rpc.Client, farm Farm, owner solana.PublicKey) ([]uint64, error) {
+	ledger, err := GetLedger(ctx, client, farm, owner)
+	if err != nil {
+		return nil, err
+	}
+	balance, err := client.GetTokenAccountBalance(ctx, farm.LpVault, rpc.CommitmentConfirmed)
+	if err != nil {
+		return nil, err
+	}
+	lpStaked, err := parseAmount(balance.Value.Amount)
+	if err != nil {
+		return nil, err
+	}
+	slot, err := client.GetSlot(ctx, rpc.CommitmentConfirmed)
+	if err != nil {
+		return nil, err
+	}
+	return PendingRewards(farm, ledger, lpStaked, slot), nil
+}
+
+func parseAmount(amount string) (uint64, error) {
+	v, ok := new(big.Int).SetString(amount, 10)
+	if !ok || !v.IsUint64() {
+		return 0, fmt.Errorf("invalid token amount %q", amount)
+	}
+	return v.Uint64(), nil
+}
+
+func instructionCodes(version int) (createLedger uint8, deposit uint8, withdraw uint8) {
+	if version == V3 {
+		return 9, 10, 11
+	}
+	return 10, 11, 12
+}
+
+// NewCreateLedgerInstruction creates the associated ledger of owner, needed before the first deposit.
+func NewCreateLedgerInstruction(farm Farm, owner solana.PublicKey) (solana.Instruction, error) {
+	ledger, _, err := GetLedgerAddress(farm.ProgramID, farm.Address, owner)
+	if err != nil {
+		return nil, err
+	}
+	code, _, _ := instructionCodes(farm.Version)
+	return solana.NewInstruction(farm.ProgramID, []*solana.AccountMeta{
+		solana.NewAccountMeta(farm.Address, true, false),
+		solana.NewAccountMeta(ledger, true, false),
+		solana.NewAccountMeta(owner, false, true),
+		solana.NewAccountMeta(solana.SystemProgramID, false, false),
+		solana.NewAccountMeta(solana.SysVarRentPubkey, false, false),
+	}, []byte{code}), nil
+}
+
+// NewDepositInstruction stakes amount of lp tokens. Pending rewards are paid to rewardAccounts, one
+// per farm reward, as part of every deposit or withdrawal.
+func NewDepositInstruction(farm Farm, owner solana.PublicKey, lpAccount solana.PublicKey, rewardAccounts []solana.PublicKey, amount uint64) (solana.Instruction, error) {
+	_, code, _ := instructionCodes(farm.Version)
+	return newStakeInstruction(farm, code, owner, lpAccount, rewardAccounts, amount)
+}
+
+func NewWithdrawInstruction(farm Farm, owner solana.PublicKey, lpAccount solana.PublicKey, rewardAccounts []solana.PublicKey, amount uint64) (solana.Instruction, error) {
+	_, _, code := instructionCodes(farm.Version)
+	return newStakeInstruction(farm, code, owner, lpAccount, rewardAccounts, amount)
+}
+
+// NewHarvestInstruction 即数量为零的 deposit
+func NewHarvestInstruction(farm Farm, owner solana.PublicKey, lpAccount solana.PublicKey, rewardAccounts []solana.PublicKey) (solana.Instruction, error) {
+	return NewDepositInstruction(farm, owner, lpAccount, rewardAccounts, 0)
+}
+
+func newStakeInstruction(farm Farm, code uint8, owner solana.PublicKey, lpAccount solana.PublicKey, rewardAccounts []solana.PublicKey, amount uint64) (solana.Instruction, error) {
+	if len(rewardAccounts) != len(farm.Rewards) {
+		return nil, fmt.Errorf("farm has %d rewards, got %d reward accounts", len(farm.Rewards), len(rewardAccounts))
+	}
+	authority, err := GetAuthority(farm)
+	if err != nil {
+		return nil, err
+	}
+	ledger, _, err := GetLedgerAddress(farm.ProgramID, farm.Address, owner)
+	if err != nil {
+		return nil, err
+	}
+	data := new(bytes.Buffer)
+	data.WriteByte(code)
+	if err := bin.NewBinEncoder(data).WriteUint64(amount, binary.LittleEndian); err != nil {
+		return nil, err
+	}
+	metas := []*solana.AccountMeta{
+		solana.NewAccountMeta(farm.Address, true, false),
+		solana.NewAccountMeta(authority, false, false),
+		solana.NewAccountMeta(ledger, true, false),
+		solana.NewAccountMeta(owner, false, true),
+		solana.NewAccountMeta(lpAccount, true, false),
+		solana.NewAccountMeta(farm.LpVault, true, false),
+		solana.NewAccountMeta(rewardAccounts[0], true, false),
+		solana.NewAccountMeta(farm.Rewards[0].Vault, true, false),
+		solana.NewAccountMeta(solana.SysVarClockPubkey, false, false),
+		solana.NewAccountMeta(solana.TokenProgramID, false, false),
+	}
+	for i := 1; i < len(farm.Rewards); i++ {
+		metas = append(metas,
+			solana.NewAccountMeta(rewardAccounts[i], true, false),
+			solana.NewAccountMeta(farm.Rewards[i].Vault, true, false),
+		)
+	}
+	return solana.NewInstruction(farm.ProgramID, metas, data.Bytes()), nil
+}
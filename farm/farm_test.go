@@ -0,0 +1,126 @@
+package farm
+
+import (
+	"bytes"
+	"math/big"
+	"testing"
+
+	"raydium-go/config"
+
+	bin "github.com/gagliardetto/binary"
+	"github.com/gagliardetto/solana-go"
+)
+
+func encode(t *testing.T, v interface{}) []byte {
+	buf := new(bytes.Buffer)
+	if err := bin.NewBinEncoder(buf).Encode(v); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestLayoutSizes(t *testing.T) {
+	for name, c := range map[string]struct {
+		v interface{}
+		n int
+	}{
+		"FarmStateV3": {FarmStateV3{}, 200},
+		"FarmStateV5": {FarmStateV5{}, 224},
+		"LedgerV3":    {LedgerV3{}, 232},
+		"LedgerV5":    {LedgerV5{}, 248},
+	} {
+		if got := len(encode(t, c.v)); got != c.n {
+			t.Errorf("%s size = %d, want %d", name, got, c.n)
+		}
+	}
+}
+
+func testFarm(t *testing.T) Farm {
+	programID := config.Raydium_Farm_V5_Program["mainnet"]
+	address := solana.NewWallet().PublicKey()
+	state := FarmStateV5{
+		LpVault:         solana.NewWallet().PublicKey(),
+		RewardVaultA:    solana.NewWallet().PublicKey(),
+		PerShareRewardA: bin.Uint128{Lo: 2000000000000000},
+		PerSlotRewardA:  100,
+		RewardVaultB:    solana.NewWallet().PublicKey(),
+		PerSlotRewardB:  10,
+		LastSlot:        1000,
+	}
+	for nonce := 255; nonce >= 0; nonce-- {
+		if _, err := solana.CreateProgramAddress([][]byte{address[:], {uint8(nonce)}}, programID); err == nil {
+			state.Nonce = uint64(nonce)
+			break
+		}
+	}
+	farm, err := DecodeFarm(V5, programID, address, encode(t, state))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return farm
+}
+
+func TestPendingRewards(t *testing.T) {
+	farm := testFarm(t)
+	if version, err := Version(farm.ProgramID); err != nil || version != V5 {
+		t.Fatalf("version = %d, %v", version, err)
+	}
+	if len(farm.Rewards) != 2 || farm.Rewards[0].PerShareReward.Cmp(big.NewInt(2000000000000000)) != 0 {
+		t.Fatalf("unexpected farm: %+v", farm)
+	}
+
+	ledger, err := DecodeLedger(V5, solana.PublicKey{}, encode(t, LedgerV5{Deposited: 500, RewardDebts: [2]bin.Uint128{{Lo: 400}}}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// v5 的精度为 1e15：500 * 2 - 400 = 600；推进 10 个 slot 后每份额增加 100 * 10 / 1000
+	if got := PendingRewards(farm, ledger, 1000, 1000); got[0] != 600 || got[1] != 0 {
+		t.Errorf("pending = %v", got)
+	}
+	if got := PendingRewards(farm, ledger, 1000, 1010); got[0] != 1100 || got[1] != 50 {
+		t.Errorf("pending after 10 slots = %v", got)
+	}
+
+	v3 := Farm{Version: V3, Rewards: []Reward{{PerShareReward: big.NewInt(2000000000)}}}
+	if got := PendingRewards(v3, Ledger{Deposited: 500, RewardDebts: []*big.Int{big.NewInt(400)}}, 1000, 0); got[0] != 600 {
+		t.Errorf("v3 pending = %v", got)
+	}
+
+	legacy := make([]byte, 96)
+	legacy[72] = 7
+	if ledger, err := DecodeLedger(V5, solana.PublicKey{}, legacy); err != nil || ledger.Deposited != 7 || len(ledger.RewardDebts) != 2 {
+		t.Errorf("legacy ledger = %+v, %v", ledger, err)
+	}
+}
+
+func TestStakeInstructions(t *testing.T) {
+	farm := testFarm(t)
+	owner := solana.NewWallet().PublicKey()
+	rewardAccounts := []solana.PublicKey{solana.NewWallet().PublicKey(), solana.NewWallet().PublicKey()}
+	deposit, err := NewDepositInstruction(farm, owner, solana.PublicKey{}, rewardAccounts, 42)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, _ := deposit.Data()
+	if len(deposit.Accounts()) != 12 || !bytes.Equal(data, []byte{11, 42, 0, 0, 0, 0, 0, 0, 0}) {
+		t.Errorf("deposit accounts = %d, data = %v", len(deposit.Accounts()), data)
+	}
+	harvest, err := NewHarvestInstruction(farm, owner, solana.PublicKey{}, rewardAccounts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if data, _ := harvest.Data(); data[0] != 11 || data[1] != 0 {
+		t.Errorf("harvest data = %v", data)
+	}
+	if _, err := NewWithdrawInstruction(farm, owner, solana.PublicKey{}, rewardAccounts[:1], 1); err == nil {
+		t.Error("expected an error for missing reward accounts")
+	}
+	ledger, _, _ := GetLedgerAddress(farm.ProgramID, farm.Address, owner)
+	create, err := NewCreateLedgerInstruction(farm, owner)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !create.Accounts()[1].PublicKey.Equals(ledger) || !deposit.Accounts()[2].PublicKey.Equals(ledger) {
+		t.Error("ledger address mismatch")
+	}
+}
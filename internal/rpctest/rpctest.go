@@ -0,0 +1,90 @@
+// Package rpctest 提供测试用的 JSON-RPC 服务
+package rpctest
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+)
+
+// Error 作为 handle 的返回值时应答 JSON-RPC 错误
+type Error struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+// Status 作为 handle 的返回值时只应答该 HTTP 状态码
+type Status int
+
+type Server struct {
+	*httptest.Server
+	hits atomic.Int32
+}
+
+// Hits returns the number of requests served.
+func (s *Server) Hits() int {
+	return int(s.hits.Load())
+}
+
+// NewServer serves JSON-RPC requests with the results returned by handle.
+func NewServer(t testing.TB, handle func(method string, params []json.RawMessage) interface{}) *Server {
+	t.Helper()
+	s := new(Server)
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.hits.Add(1)
+		var req struct {
+			ID     json.RawMessage   `json:"id"`
+			Method string            `json:"method"`
+			Params []json.RawMessage `json:"params"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		res := map[string]interface{}{"jsonrpc": "2.0", "id": req.ID}
+		switch out := handle(req.Method, req.Params).(type) {
+		case Status:
+			w.WriteHeader(int(out))
+			return
+		case *Error:
+			res["error"] = out
+		default:
+			res["result"] = out
+		}
+		json.NewEncoder(w).Encode(res)
+	}))
+	t.Cleanup(s.Close)
+	return s
+}
+
+// Slots 为各 commitment 当前处理到的 slot
+type Slots map[string]uint64
+
+// Check returns the error a node answers when the minContextSlot in params is ahead of the slot of the
+// requested commitment, and nil otherwise. Requests without a commitment use finalized, as nodes do.
+func (s Slots) Check(params []json.RawMessage) *Error {
+	if len(params) == 0 {
+		return nil
+	}
+	var config struct {
+		Commitment          string  `json:"commitment"`
+		PreflightCommitment string  `json:"preflightCommitment"`
+		MinContextSlot      *uint64 `json:"minContextSlot"`
+	}
+	if json.Unmarshal(params[len(params)-1], &config) != nil || config.MinContextSlot == nil {
+		return nil
+	}
+	commitment := config.Commitment
+	if commitment == "" {
+		commitment = config.PreflightCommitment
+	}
+	if commitment == "" {
+		commitment = "finalized"
+	}
+	if *config.MinContextSlot > s[commitment] {
+		return &Error{Code: -32016, Message: "Minimum context slot has not been reached"}
+	}
+	return nil
+}
@@ -0,0 +1,162 @@
+package jito
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/rand"
+	"time"
+
+	"github.com/gagliardetto/solana-go"
+	"github.com/gagliardetto/solana-go/programs/system"
+	"github.com/gagliardetto/solana-go/rpc/jsonrpc"
+)
+
+const (
+	MainnetBlockEngine = "https://mainnet.block-engine.jito.wtf/api/v1/bundles"
+	// 单个 bundle 最多包含的交易数
+	MaxBundleTransactions = 5
+)
+
+var (
+	ErrBundleNotLanded = errors.New("bundle not landed")
+
+	// TipAccounts 为主网 block engine 的 tip 账户
+	TipAccounts = []solana.PublicKey{
+		solana.MustPublicKeyFromBase58("96gYZGLnJYVFmbjzopPSU6QiEV5fGqZNyN9nmNhvrZU5"),
+		solana.MustPublicKeyFromBase58("HFqU5x63VTqvQss8hp11i4wVV8bD44PvwucfZ2bU7gRe"),
+		solana.MustPublicKeyFromBase58("Cw8CFyM9FkoMi7K7Crf6HNQqf4uEMzpKw6QNghXLvLkY"),
+		solana.MustPublicKeyFromBase58("ADaUMid9yfUytqMBgopwjb2DTLSokTSzL1zt6iGPaS49"),
+		solana.MustPublicKeyFromBase58("DfXygSm4jCyNCybVYYK6DwvWqjKee8pbDmJGcLWNDXjh"),
+		solana.MustPublicKeyFromBase58("ADuUkR4vqLUMWXxW9gh6D6L8pMSawimctcNZ5pGwDcEt"),
+		solana.MustPublicKeyFromBase58("DttWaMuVvTiduZRnguLF7jNxTgiMBZ1hyAumKUiL2KRL"),
+		solana.MustPublicKeyFromBase58("3AVi9Tg9Uo68tJfuvoKvqKNWKkC5wPdSSdeBnizKZ6jT"),
+	}
+)
+
+// Tip 描述 bundle 的小费和落地等待策略
+type Tip struct {
+	Lamports uint64
+	// 为零值时随机选择一个 TipAccounts
+	Account solana.PublicKey
+	// 等待 bundle 落地的超时时间，零值为 30 秒
+	Timeout time.Duration
+	// 轮询间隔，零值为 1 秒
+	PollInterval time.Duration
+	// bundle 未落地时通过 RPC 发送同一笔交易
+	FallbackToRPC bool
+}
+
+func (t Tip) TipAccount() solana.PublicKey {
+	if t.Account.IsZero() {
+		return TipAccounts[rand.Intn(len(TipAccounts))]
+	}
+	return t.Account
+}
+
+// Instruction returns the SOL transfer paying the tip from payer.
+func (t Tip) Instruction(payer solana.PublicKey) solana.Instruction {
+	return system.NewTransferInstruction(t.Lamports, payer, t.TipAccount()).Build()
+}
+
+type BundleStatus struct {
+	BundleID           string          `json:"bundle_id"`
+	Transactions       []string        `json:"transactions"`
+	Slot               uint64          `json:"slot"`
+	ConfirmationStatus string          `json:"confirmation_status"`
+	Err                json.RawMessage `json:"err"`
+}
+
+// Landed reports whether the bundle reached at least confirmed commitment. A landed bundle may still
+// have failed, see Failed.
+func (s BundleStatus) Landed() bool {
+	return s.ConfirmationStatus == "confirmed" || s.ConfirmationStatus == "finalized"
+}
+
+// Failed reports whether the bundle transactions failed; a successful bundle has err {"Ok": null}.
+func (s BundleStatus) Failed() bool {
+	if len(s.Err) == 0 || string(s.Err) == "null" {
+		return false
+	}
+	var result map[string]json.RawMessage
+	if err := json.Unmarshal(s.Err, &result); err != nil {
+		return true
+	}
+	_, ok := result["Ok"]
+	return !ok
+}
+
+// Client 通过 block engine 兼容的 HTTP JSON-RPC 接口提交 bundle
+type Client struct {
+	rpcClient jsonrpc.RPCClient
+}
+
+func NewClient(endpoint string) *Client {
+	return &Client{rpcClient: jsonrpc.NewClient(endpoint)}
+}
+
+// SendBundle submits signed transactions as one bundle and returns the bundle id.
+func (c *Client) SendBundle(ctx context.Context, txs ...*solana.Transaction) (string, error) {
+	if len(txs) == 0 || len(txs) > MaxBundleTransactions {
+		return "", fmt.Errorf("bundle must contain 1 to %d transactions, got %d", MaxBundleTransactions, len(txs))
+	}
+	encoded := make([]string, len(txs))
+	for i, tx := range txs {
+		data, err := tx.MarshalBinary()
+		if err != nil {
+			return "", err
+		}
+		encoded[i] = base64.StdEncoding.EncodeToString(data)
+	}
+	var bundleID string
+	err := c.rpcClient.CallForInto(ctx, &bundleID, "sendBundle", []interface{}{
+		encoded,
+		map[string]interface{}{"encoding": "base64"},
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to send bundle: %w", err)
+	}
+	return bundleID, nil
+}
+
+// GetBundleStatuses returns the statuses of landed bundles, a nil entry means the bundle is unknown.
+func (c *Client) GetBundleStatuses(ctx context.Context, bundleIDs ...string) ([]*BundleStatus, error) {
+	var out struct {
+		Value []*BundleStatus `json:"value"`
+	}
+	err := c.rpcClient.CallForInto(ctx, &out, "getBundleStatuses", []interface{}{bundleIDs})
+	if err != nil {
+		return nil, err
+	}
+	return out.Value, nil
+}
+
+// WaitForBundle polls the bundle status until it lands, timeout expires or ctx is done.
+func (c *Client) WaitForBundle(ctx context.Context, bundleID string, timeout time.Duration, interval time.Duration) (*BundleStatus, error) {
+	if timeout <= 0 {
+		timeout = 30 * time.Second
+	}
+	if interval <= 0 {
+		interval = time.Second
+	}
+	deadline := time.Now().Add(timeout)
+	for {
+		statuses, err := c.GetBundleStatuses(ctx, bundleID)
+		if err != nil {
+			return nil, err
+		}
+		if len(statuses) > 0 && statuses[0] != nil && statuses[0].Landed() {
+			return statuses[0], nil
+		}
+		if time.Now().Add(interval).After(deadline) {
+			return nil, fmt.Errorf("%s: %w", bundleID, ErrBundleNotLanded)
+		}
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(interval):
+		}
+	}
+}
@@ -0,0 +1,100 @@
+package jito
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gagliardetto/solana-go"
+)
+
+// blockEngine is a local stand-in for the block engine JSON-RPC endpoint.
+type blockEngine struct {
+	bundles map[string]string
+}
+
+func (b *blockEngine) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		ID     json.RawMessage   `json:"id"`
+		Method string            `json:"method"`
+		Params []json.RawMessage `json:"params"`
+	}
+	json.NewDecoder(r.Body).Decode(&req)
+	var result interface{}
+	switch req.Method {
+	case "sendBundle":
+		var txs []string
+		json.Unmarshal(req.Params[0], &txs)
+		result = "bundle-1"
+		b.bundles["bundle-1"] = "confirmed"
+	case "getBundleStatuses":
+		var ids []string
+		json.Unmarshal(req.Params[0], &ids)
+		var value []interface{}
+		for _, id := range ids {
+			status, ok := b.bundles[id]
+			if !ok {
+				value = append(value, nil)
+				continue
+			}
+			value = append(value, map[string]interface{}{
+				"bundle_id":           id,
+				"transactions":        []string{"sig"},
+				"slot":                10,
+				"confirmation_status": status,
+				"err":                 map[string]interface{}{"Ok": nil},
+			})
+		}
+		result = map[string]interface{}{"context": map[string]interface{}{"slot": 10}, "value": value}
+	}
+	json.NewEncoder(w).Encode(map[string]interface{}{"jsonrpc": "2.0", "id": req.ID, "result": result})
+}
+
+func TestSendBundle(t *testing.T) {
+	server := httptest.NewServer(&blockEngine{bundles: map[string]string{}})
+	defer server.Close()
+	client := NewClient(server.URL)
+
+	payer := solana.NewWallet()
+	tip := Tip{Lamports: 1000}
+	tx, err := solana.NewTransaction([]solana.Instruction{tip.Instruction(payer.PublicKey())}, solana.Hash{}, solana.TransactionPayer(payer.PublicKey()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := client.SendBundle(context.Background()); err == nil {
+		t.Error("expected error for empty bundle")
+	}
+	id, err := client.SendBundle(context.Background(), tx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	status, err := client.WaitForBundle(context.Background(), id, time.Second, 10*time.Millisecond)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if status.BundleID != id || status.Slot != 10 || status.Failed() {
+		t.Errorf("unexpected status %+v", status)
+	}
+	_, err = client.WaitForBundle(context.Background(), "unknown", 30*time.Millisecond, 10*time.Millisecond)
+	if !errors.Is(err, ErrBundleNotLanded) {
+		t.Errorf("expected ErrBundleNotLanded, got %v", err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Millisecond)
+	defer cancel()
+	_, err = client.WaitForBundle(ctx, "unknown", time.Minute, 10*time.Millisecond)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected context.DeadlineExceeded, got %v", err)
+	}
+}
+
+func TestBundleStatusFailed(t *testing.T) {
+	for raw, failed := range map[string]bool{``: false, `null`: false, `{"Ok":null}`: false, `{"Err":"InstructionError"}`: true} {
+		if got := (BundleStatus{Err: json.RawMessage(raw)}).Failed(); got != failed {
+			t.Errorf("Failed(%s) = %v", raw, got)
+		}
+	}
+}
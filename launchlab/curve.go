@@ -0,0 +1,331 @@
+package launchlab
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+
+	"raydium-go/spl"
+)
+
+const FeeRateDenominator = uint64(1000000)
+
+var (
+	ErrInsufficientSupply = errors.New("amount exceeds what is left on the curve")
+	ErrUnsupportedCurve   = errors.New("unsupported curve type")
+)
+
+type SwapQuote struct {
+	spl.SwapAmounts
+	Buy bool
+	// protocol、platform 和 share 手续费之和，均以 quote token 计
+	Fee uint64
+}
+
+// Fees 为一次交易收取的费率，分母为 FeeRateDenominator
+type Fees struct {
+	TradeFeeRate    uint64
+	PlatformFeeRate uint64
+	ShareFeeRate    uint64
+}
+
+func (f Fees) total() uint64 {
+	return f.TradeFeeRate + f.PlatformFeeRate + f.ShareFeeRate
+}
+
+// FeesFrom collects the fee rates of a pool. shareFeeRate is the optional referral fee.
+func FeesFrom(globalConfig GlobalConfig, platformConfig PlatformConfig, shareFeeRate uint64) Fees {
+	return Fees{TradeFeeRate: globalConfig.TradeFeeRate, PlatformFeeRate: platformConfig.FeeRate, ShareFeeRate: shareFeeRate}
+}
+
+// curve 对应链上 curve 模块，数量均不含手续费
+type curve interface {
+	// quote 输入对应的 base 输出
+	buyExactIn(amountIn *big.Int) (*big.Int, error)
+	// base 输出需要的 quote 输入
+	buyExactOut(amountOut *big.Int) (*big.Int, error)
+	// base 输入对应的 quote 输出
+	sellExactIn(amountIn *big.Int) (*big.Int, error)
+	// quote 输出需要的 base 输入
+	sellExactOut(amountOut *big.Int) (*big.Int, error)
+}
+
+func curveFrom(poolState PoolState, globalConfig GlobalConfig) (curve, error) {
+	switch globalConfig.CurveType {
+	case CurveConstantProduct:
+		if poolState.RealBase > poolState.VirtualBase {
+			return nil, ErrInsufficientSupply
+		}
+		return constantProductCurve{
+			quote: new(big.Int).Add(u(poolState.VirtualQuote), u(poolState.RealQuote)),
+			base:  u(poolState.VirtualBase - poolState.RealBase),
+		}, nil
+	case CurveFixedPrice:
+		if poolState.VirtualBase == 0 || poolState.VirtualQuote == 0 {
+			return nil, fmt.Errorf("fixed price curve without virtual reserves")
+		}
+		return fixedPriceCurve{quote: u(poolState.VirtualQuote), base: u(poolState.VirtualBase)}, nil
+	case CurveLinearPrice:
+		if poolState.VirtualBase == 0 {
+			return nil, fmt.Errorf("linear price curve without a slope")
+		}
+		return linearPriceCurve{slope: u(poolState.VirtualBase), base: u(poolState.RealBase), quote: u(poolState.RealQuote)}, nil
+	}
+	return nil, fmt.Errorf("%w %d", ErrUnsupportedCurve, globalConfig.CurveType)
+}
+
+// constantProductCurve 的储备含虚拟储备，quote 为买入方向的 quote 储备，base 为剩余的 base 储备
+type constantProductCurve struct {
+	quote *big.Int
+	base  *big.Int
+}
+
+func (c constantProductCurve) buyExactIn(amountIn *big.Int) (*big.Int, error) {
+	out := new(big.Int).Mul(amountIn, c.base)
+	return out.Quo(out, new(big.Int).Add(c.quote, amountIn)), nil
+}
+
+func (c constantProductCurve) buyExactOut(amountOut *big.Int) (*big.Int, error) {
+	if amountOut.Cmp(c.base) >= 0 {
+		return nil, ErrInsufficientSupply
+	}
+	return ceilDiv(new(big.Int).Mul(amountOut, c.quote), new(big.Int).Sub(c.base, amountOut)), nil
+}
+
+func (c constantProductCurve) sellExactIn(amountIn *big.Int) (*big.Int, error) {
+	out := new(big.Int).Mul(amountIn, c.quote)
+	return out.Quo(out, new(big.Int).Add(c.base, amountIn)), nil
+}
+
+func (c constantProductCurve) sellExactOut(amountOut *big.Int) (*big.Int, error) {
+	if amountOut.Cmp(c.quote) >= 0 {
+		return nil, ErrInsufficientSupply
+	}
+	return ceilDiv(new(big.Int).Mul(amountOut, c.base), new(big.Int).Sub(c.quote, amountOut)), nil
+}
+
+// fixedPriceCurve 按 VirtualQuote/VirtualBase 的固定价格成交
+type fixedPriceCurve struct {
+	quote *big.Int
+	base  *big.Int
+}
+
+func (c fixedPriceCurve) buyExactIn(amountIn *big.Int) (*big.Int, error) {
+	out := new(big.Int).Mul(amountIn, c.base)
+	return out.Quo(out, c.quote), nil
+}
+
+func (c fixedPriceCurve) buyExactOut(amountOut *big.Int) (*big.Int, error) {
+	return ceilDiv(new(big.Int).Mul(amountOut, c.quote), c.base), nil
+}
+
+func (c fixedPriceCurve) sellExactIn(amountIn *big.Int) (*big.Int, error) {
+	out := new(big.Int).Mul(amountIn, c.quote)
+	return out.Quo(out, c.base), nil
+}
+
+func (c fixedPriceCurve) sellExactOut(amountOut *big.Int) (*big.Int, error) {
+	return ceilDiv(new(big.Int).Mul(amountOut, c.base), c.quote), nil
+}
+
+// linearPriceCurve 的价格为 slope*base/2^64，slope 为 VirtualBase。已卖出 base 个 base token 时累计
+// 收到的 quote 为 slope*base^2/2^65
+type linearPriceCurve struct {
+	slope *big.Int
+	// 已卖出的 base 和收到的 quote
+	base  *big.Int
+	quote *big.Int
+}
+
+// baseAt 返回累计收到 quote 时卖出的 base，向下取整
+func (c linearPriceCurve) baseAt(quote *big.Int) *big.Int {
+	term := new(big.Int).Lsh(quote, 65)
+	return term.Sqrt(term.Quo(term, c.slope))
+}
+
+// quoteAt 返回卖出 base 时累计收到的 quote，向上取整
+func (c linearPriceCurve) quoteAt(base *big.Int) *big.Int {
+	return ceilDiv(new(big.Int).Mul(c.slope, new(big.Int).Mul(base, base)), new(big.Int).Lsh(big.NewInt(1), 65))
+}
+
+func (c linearPriceCurve) buyExactIn(amountIn *big.Int) (*big.Int, error) {
+	out := new(big.Int).Sub(c.baseAt(new(big.Int).Add(c.quote, amountIn)), c.base)
+	if out.Sign() < 0 {
+		return new(big.Int), nil
+	}
+	return out, nil
+}
+
+func (c linearPriceCurve) buyExactOut(amountOut *big.Int) (*big.Int, error) {
+	in := new(big.Int).Sub(c.quoteAt(new(big.Int).Add(c.base, amountOut)), c.quote)
+	if in.Sign() < 0 {
+		return new(big.Int), nil
+	}
+	return in, nil
+}
+
+func (c linearPriceCurve) sellExactIn(amountIn *big.Int) (*big.Int, error) {
+	if amountIn.Cmp(c.base) > 0 {
+		return nil, ErrInsufficientSupply
+	}
+	out := new(big.Int).Sub(c.quote, c.quoteAt(new(big.Int).Sub(c.base, amountIn)))
+	if out.Sign() < 0 {
+		return new(big.Int), nil
+	}
+	return out, nil
+}
+
+func (c linearPriceCurve) sellExactOut(amountOut *big.Int) (*big.Int, error) {
+	if amountOut.Cmp(c.quote) > 0 {
+		return nil, ErrInsufficientSupply
+	}
+	in := new(big.Int).Sub(c.base, c.baseAt(new(big.Int).Sub(c.quote, amountOut)))
+	if in.Sign() < 0 {
+		return new(big.Int), nil
+	}
+	return in, nil
+}
+
+// BuyExactIn 对应链上 buy_exact_in：手续费先从 quote 输入中扣除，返回 base 输出和手续费
+func BuyExactIn(poolState PoolState, globalConfig GlobalConfig, fees Fees, amountIn uint64) (uint64, uint64, error) {
+	c, err := curveFrom(poolState, globalConfig)
+	if err != nil {
+		return 0, 0, err
+	}
+	fee := calculateFee(amountIn, fees.total())
+	out, err := c.buyExactIn(u(amountIn - fee))
+	if err != nil {
+		return 0, 0, err
+	}
+	if err := checkSupply(poolState, out); err != nil {
+		return 0, 0, err
+	}
+	return out.Uint64(), fee, nil
+}
+
+// BuyExactOut 对应链上 buy_exact_out，返回 quote 输入（含手续费）和手续费
+func BuyExactOut(poolState PoolState, globalConfig GlobalConfig, fees Fees, amountOut uint64) (uint64, uint64, error) {
+	c, err := curveFrom(poolState, globalConfig)
+	if err != nil {
+		return 0, 0, err
+	}
+	if err := checkSupply(poolState, u(amountOut)); err != nil {
+		return 0, 0, err
+	}
+	in, err := c.buyExactOut(u(amountOut))
+	if err != nil {
+		return 0, 0, err
+	}
+	in = ceilDiv(in.Mul(in, u(FeeRateDenominator)), u(FeeRateDenominator-fees.total()))
+	if !in.IsUint64() {
+		return 0, 0, ErrInsufficientSupply
+	}
+	return in.Uint64(), calculateFee(in.Uint64(), fees.total()), nil
+}
+
+// SellExactIn 对应链上 sell_exact_in：手续费从 quote 输出中扣除，返回 quote 输出和手续费
+func SellExactIn(poolState PoolState, globalConfig GlobalConfig, fees Fees, amountIn uint64) (uint64, uint64, error) {
+	c, err := curveFrom(poolState, globalConfig)
+	if err != nil {
+		return 0, 0, err
+	}
+	out, err := c.sellExactIn(u(amountIn))
+	if err != nil {
+		return 0, 0, err
+	}
+	if out.Cmp(u(poolState.RealQuote)) > 0 {
+		return 0, 0, ErrInsufficientSupply
+	}
+	fee := calculateFee(out.Uint64(), fees.total())
+	return out.Uint64() - fee, fee, nil
+}
+
+// SellExactOut 对应链上 sell_exact_out，返回 base 输入和手续费
+func SellExactOut(poolState PoolState, globalConfig GlobalConfig, fees Fees, amountOut uint64) (uint64, uint64, error) {
+	c, err := curveFrom(poolState, globalConfig)
+	if err != nil {
+		return 0, 0, err
+	}
+	beforeFee := ceilDiv(mul(amountOut, FeeRateDenominator), u(FeeRateDenominator-fees.total()))
+	if beforeFee.Cmp(u(poolState.RealQuote)) > 0 {
+		return 0, 0, ErrInsufficientSupply
+	}
+	in, err := c.sellExactOut(beforeFee)
+	if err != nil {
+		return 0, 0, err
+	}
+	if !in.IsUint64() {
+		return 0, 0, ErrInsufficientSupply
+	}
+	return in.Uint64(), beforeFee.Uint64() - amountOut, nil
+}
+
+// QuoteSwap quotes a buy (quote token in, base token out) or a sell on the bonding curve, including
+// the Token-2022 transfer fee of baseMint in epoch. The quote mint has no transfer fee.
+func QuoteSwap(poolState PoolState, globalConfig GlobalConfig, fees Fees, baseMint spl.Mint, epoch uint64, buy bool, amountSpecified uint64, baseIn bool, slippage float64) (SwapQuote, error) {
+	quote := SwapQuote{Buy: buy}
+	var inputMint, outputMint spl.Mint
+	if buy {
+		outputMint = baseMint
+	} else {
+		inputMint = baseMint
+	}
+	var err error
+	if baseIn {
+		quote.AmountIn = amountSpecified
+		quote.InputTransferFee = inputMint.TransferFee(epoch, amountSpecified)
+		amountIn := amountSpecified - quote.InputTransferFee
+		if buy {
+			quote.AmountOut, quote.Fee, err = BuyExactIn(poolState, globalConfig, fees, amountIn)
+		} else {
+			quote.AmountOut, quote.Fee, err = SellExactIn(poolState, globalConfig, fees, amountIn)
+		}
+		if err != nil {
+			return quote, err
+		}
+		quote.OutputTransferFee = outputMint.TransferFee(epoch, quote.AmountOut)
+	} else {
+		quote.OutputTransferFee = outputMint.InverseTransferFee(epoch, amountSpecified)
+		quote.AmountOut = amountSpecified + quote.OutputTransferFee
+		var amountIn uint64
+		if buy {
+			amountIn, quote.Fee, err = BuyExactOut(poolState, globalConfig, fees, quote.AmountOut)
+		} else {
+			amountIn, quote.Fee, err = SellExactOut(poolState, globalConfig, fees, quote.AmountOut)
+		}
+		if err != nil {
+			return quote, err
+		}
+		quote.InputTransferFee = inputMint.InverseTransferFee(epoch, amountIn)
+		quote.AmountIn = amountIn + quote.InputTransferFee
+	}
+	quote.SetThreshold(baseIn, slippage)
+	return quote, nil
+}
+
+func checkSupply(poolState PoolState, amountOut *big.Int) error {
+	if poolState.RealBase > poolState.TotalBaseSell || amountOut.Cmp(u(poolState.TotalBaseSell-poolState.RealBase)) > 0 {
+		return ErrInsufficientSupply
+	}
+	return nil
+}
+
+func calculateFee(amount uint64, feeRate uint64) uint64 {
+	return ceilDiv(mul(amount, feeRate), u(FeeRateDenominator)).Uint64()
+}
+
+func u(v uint64) *big.Int {
+	return new(big.Int).SetUint64(v)
+}
+
+func mul(a uint64, b uint64) *big.Int {
+	return new(big.Int).Mul(u(a), u(b))
+}
+
+func ceilDiv(a *big.Int, b *big.Int) *big.Int {
+	q, r := new(big.Int).QuoRem(a, b, new(big.Int))
+	if r.Sign() != 0 {
+		q.Add(q, big.NewInt(1))
+	}
+	return q
+}
@@ -0,0 +1,265 @@
+package launchlab
+
+import (
+	"context"
+	"fmt"
+
+	"raydium-go/anchor"
+	"raydium-go/config"
+	"raydium-go/spl"
+	"raydium-go/txn"
+
+	"github.com/gagliardetto/solana-go"
+	"github.com/gagliardetto/solana-go/rpc"
+)
+
+var (
+	computeUnitLimit = uint32(150000)
+	priorityFee      = uint64(100)
+
+	buyExactInDiscriminator   = anchor.InstructionDiscriminator("buy_exact_in")
+	buyExactOutDiscriminator  = anchor.InstructionDiscriminator("buy_exact_out")
+	sellExactInDiscriminator  = anchor.InstructionDiscriminator("sell_exact_in")
+	sellExactOutDiscriminator = anchor.InstructionDiscriminator("sell_exact_out")
+)
+
+// TradeAccounts 为 buy/sell 指令共用的账户
+type TradeAccounts struct {
+	Payer             solana.PublicKey
+	Authority         solana.PublicKey
+	GlobalConfig      solana.PublicKey
+	PlatformConfig    solana.PublicKey
+	PoolState         solana.PublicKey
+	UserBaseToken     solana.PublicKey
+	UserQuoteToken    solana.PublicKey
+	BaseVault         solana.PublicKey
+	QuoteVault        solana.PublicKey
+	BaseTokenMint     solana.PublicKey
+	QuoteTokenMint    solana.PublicKey
+	BaseTokenProgram  solana.PublicKey
+	QuoteTokenProgram solana.PublicKey
+	EventAuthority    solana.PublicKey
+}
+
+// TradeAccountsFrom fills the trade accounts of pool. The token programs default to the SPL token
+// program and must be overridden for Token-2022 mints.
+func TradeAccountsFrom(programID solana.PublicKey, pool solana.PublicKey, poolState PoolState, payer solana.PublicKey, baseAccount solana.PublicKey, quoteAccount solana.PublicKey) (TradeAccounts, error) {
+	authority, _, err := GetAuthority(programID)
+	if err != nil {
+		return TradeAccounts{}, err
+	}
+	eventAuthority, _, err := GetEventAuthority(programID)
+	if err != nil {
+		return TradeAccounts{}, err
+	}
+	return TradeAccounts{
+		Payer:             payer,
+		Authority:         authority,
+		GlobalConfig:      poolState.GlobalConfig,
+		PlatformConfig:    poolState.PlatformConfig,
+		PoolState:         pool,
+		UserBaseToken:     baseAccount,
+		UserQuoteToken:    quoteAccount,
+		BaseVault:         poolState.BaseVault,
+		QuoteVault:        poolState.QuoteVault,
+		BaseTokenMint:     poolState.BaseMint,
+		QuoteTokenMint:    poolState.QuoteMint,
+		BaseTokenProgram:  solana.TokenProgramID,
+		QuoteTokenProgram: solana.TokenProgramID,
+		EventAuthority:    eventAuthority,
+	}, nil
+}
+
+func (a TradeAccounts) metas(programID solana.PublicKey) []*solana.AccountMeta {
+	return []*solana.AccountMeta{
+		solana.NewAccountMeta(a.Payer, false, true),
+		solana.NewAccountMeta(a.Authority, false, false),
+		solana.NewAccountMeta(a.GlobalConfig, false, false),
+		solana.NewAccountMeta(a.PlatformConfig, false, false),
+		solana.NewAccountMeta(a.PoolState, true, false),
+		solana.NewAccountMeta(a.UserBaseToken, true, false),
+		solana.NewAccountMeta(a.UserQuoteToken, true, false),
+		solana.NewAccountMeta(a.BaseVault, true, false),
+		solana.NewAccountMeta(a.QuoteVault, true, false),
+		solana.NewAccountMeta(a.BaseTokenMint, false, false),
+		solana.NewAccountMeta(a.QuoteTokenMint, false, false),
+		solana.NewAccountMeta(a.BaseTokenProgram, false, false),
+		solana.NewAccountMeta(a.QuoteTokenProgram, false, false),
+		solana.NewAccountMeta(a.EventAuthority, false, false),
+		solana.NewAccountMeta(programID, false, false),
+	}
+}
+
+type TradeExactInArgs struct {
+	AmountIn         uint64
+	MinimumAmountOut uint64
+	ShareFeeRate     uint64
+}
+
+type TradeExactOutArgs struct {
+	AmountOut       uint64
+	MaximumAmountIn uint64
+	ShareFeeRate    uint64
+}
+
+func NewBuyExactInInstruction(programID solana.PublicKey, accounts TradeAccounts, args TradeExactInArgs) (solana.Instruction, error) {
+	return newTradeInstruction(programID, buyExactInDiscriminator, accounts, args)
+}
+
+func NewBuyExactOutInstruction(programID solana.PublicKey, accounts TradeAccounts, args TradeExactOutArgs) (solana.Instruction, error) {
+	return newTradeInstruction(programID, buyExactOutDiscriminator, accounts, args)
+}
+
+func NewSellExactInInstruction(programID solana.PublicKey, accounts TradeAccounts, args TradeExactInArgs) (solana.Instruction, error) {
+	return newTradeInstruction(programID, sellExactInDiscriminator, accounts, args)
+}
+
+func NewSellExactOutInstruction(programID solana.PublicKey, accounts TradeAccounts, args TradeExactOutArgs) (solana.Instruction, error) {
+	return newTradeInstruction(programID, sellExactOutDiscriminator, accounts, args)
+}
+
+func newTradeInstruction(programID solana.PublicKey, d anchor.Discriminator, accounts TradeAccounts, args interface{}) (solana.Instruction, error) {
+	data, err := anchor.InstructionData(d, args)
+	if err != nil {
+		return nil, err
+	}
+	return solana.NewInstruction(programID, accounts.metas(programID), data), nil
+}
+
+// NewTradeInstruction picks the buy/sell exact in/out instruction matching quote.
+func NewTradeInstruction(programID solana.PublicKey, accounts TradeAccounts, quote SwapQuote, baseIn bool, shareFeeRate uint64) (solana.Instruction, error) {
+	switch {
+	case quote.Buy && baseIn:
+		return NewBuyExactInInstruction(programID, accounts, TradeExactInArgs{AmountIn: quote.AmountIn, MinimumAmountOut: quote.OtherAmountThreshold, ShareFeeRate: shareFeeRate})
+	case quote.Buy:
+		return NewBuyExactOutInstruction(programID, accounts, TradeExactOutArgs{AmountOut: quote.ReceivedAmount(), MaximumAmountIn: quote.OtherAmountThreshold, ShareFeeRate: shareFeeRate})
+	case baseIn:
+		return NewSellExactInInstruction(programID, accounts, TradeExactInArgs{AmountIn: quote.AmountIn, MinimumAmountOut: quote.OtherAmountThreshold, ShareFeeRate: shareFeeRate})
+	default:
+		return NewSellExactOutInstruction(programID, accounts, TradeExactOutArgs{AmountOut: quote.ReceivedAmount(), MaximumAmountIn: quote.OtherAmountThreshold, ShareFeeRate: shareFeeRate})
+	}
+}
+
+// GetSwapQuote loads the configs of pool and quotes a trade of inputMint on its curve. A pool that
+// left the curve returns a *MigrationError.
+func GetSwapQuote(ctx context.Context, client *rpc.Client, pool solana.PublicKey, poolState PoolState, inputMint solana.PublicKey, amountSpecified uint64, baseIn bool, slippage float64, commitment rpc.CommitmentType) (SwapQuote, error) {
+	if err := poolState.Migration(pool); err != nil {
+		return SwapQuote{}, err
+	}
+	var buy bool
+	switch {
+	case inputMint.Equals(poolState.QuoteMint):
+		buy = true
+	case inputMint.Equals(poolState.BaseMint):
+	default:
+		return SwapQuote{}, fmt.Errorf("mint %s is not in pool %s", inputMint, pool)
+	}
+	globalConfig, err := GetGlobalConfig(ctx, client, poolState.GlobalConfig, commitment)
+	if err != nil {
+		return SwapQuote{}, err
+	}
+	platformConfig, err := GetPlatformConfig(ctx, client, poolState.PlatformConfig, commitment)
+	if err != nil {
+		return SwapQuote{}, err
+	}
+	baseMint, err := spl.GetMint(ctx, client, poolState.BaseMint)
+	if err != nil {
+		return SwapQuote{}, err
+	}
+	var epoch uint64
+	if baseMint.TransferFeeConfig != nil {
+		epochInfo, err := client.GetEpochInfo(ctx, commitment)
+		if err != nil {
+			return SwapQuote{}, err
+		}
+		epoch = epochInfo.Epoch
+	}
+	return QuoteSwap(poolState, globalConfig, FeesFrom(globalConfig, platformConfig, 0), baseMint, epoch, buy, amountSpecified, baseIn, slippage)
+}
+
+func Swap(ctx context.Context, client *rpc.Client, network string, poolAddress string, inputTokenAddress string, amountSpecified uint64, baseIn bool, slippage float64, privateKey string) (string, error) {
+	return SwapWithOptions(ctx, client, network, poolAddress, inputTokenAddress, amountSpecified, baseIn, slippage, privateKey, txn.SwapOptions{})
+}
+
+func SwapWithOptions(ctx context.Context, client *rpc.Client, network string, poolAddress string, inputTokenAddress string, amountSpecified uint64, baseIn bool, slippage float64, privateKey string, opts txn.SwapOptions) (string, error) {
+	signer, err := solana.PrivateKeyFromBase58(privateKey)
+	if err != nil {
+		return "", fmt.Errorf("invalid private key: %w", err)
+	}
+	tx, err := BuildSwapTransaction(ctx, client, network, poolAddress, inputTokenAddress, amountSpecified, baseIn, slippage, signer.PublicKey(), opts)
+	if err != nil {
+		return "", err
+	}
+	if err := txn.PartialSign(tx, signer); err != nil {
+		return "", err
+	}
+	txHash, err := client.SendTransaction(ctx, tx)
+	if err != nil {
+		return "", fmt.Errorf("failed to send transaction: %w", err)
+	}
+	return txHash.String(), nil
+}
+
+// BuildSwapTransaction builds the unsigned buy or sell transaction of owner on a LaunchLab pool. The
+// quote token is the input of a buy and the output of a sell.
+func BuildSwapTransaction(ctx context.Context, client *rpc.Client, network string, poolAddress string, inputTokenAddress string, amountSpecified uint64, baseIn bool, slippage float64, owner solana.PublicKey, opts txn.SwapOptions) (*solana.Transaction, error) {
+	programID, err := config.LaunchLabProgram(network)
+	if err != nil {
+		return nil, err
+	}
+	pool, err := solana.PublicKeyFromBase58(poolAddress)
+	if err != nil {
+		return nil, err
+	}
+	inputMintAddress, err := solana.PublicKeyFromBase58(inputTokenAddress)
+	if err != nil {
+		return nil, err
+	}
+	commitment := opts.StateCommitment()
+	poolState, err := GetPoolState(ctx, client, pool, commitment)
+	if err != nil {
+		return nil, err
+	}
+	quote, err := GetSwapQuote(ctx, client, pool, poolState, inputMintAddress, amountSpecified, baseIn, slippage, commitment)
+	if err != nil {
+		return nil, err
+	}
+	maxAmountIn := quote.MaxAmountIn(baseIn)
+	mints, err := spl.GetMints(ctx, client, poolState.BaseMint, poolState.QuoteMint)
+	if err != nil {
+		return nil, err
+	}
+	baseMint, quoteMint := mints[0], mints[1]
+	wrapBase, wrapQuote := uint64(0), maxAmountIn
+	if !quote.Buy {
+		wrapBase, wrapQuote = maxAmountIn, 0
+	}
+	baseAccount, err := spl.TokenAccountInstructions(ctx, client, commitment, owner, owner, baseMint, wrapBase, opts.WSOLPolicy)
+	if err != nil {
+		return nil, err
+	}
+	quoteAccount, err := spl.TokenAccountInstructions(ctx, client, commitment, owner, owner, quoteMint, wrapQuote, opts.WSOLPolicy)
+	if err != nil {
+		return nil, err
+	}
+	accounts, err := TradeAccountsFrom(programID, pool, poolState, owner, baseAccount.Address, quoteAccount.Address)
+	if err != nil {
+		return nil, err
+	}
+	accounts.BaseTokenProgram, accounts.QuoteTokenProgram = baseMint.Program, quoteMint.Program
+	tradeInstruction, err := NewTradeInstruction(programID, accounts, quote, baseIn, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	var instructions []solana.Instruction
+	instructions = append(instructions, baseAccount.Setup...)
+	instructions = append(instructions, quoteAccount.Setup...)
+	instructions = append(instructions, tradeInstruction)
+	instructions = append(instructions, baseAccount.Cleanup...)
+	instructions = append(instructions, quoteAccount.Cleanup...)
+	// 账户的创建、wrap 和关闭改变 compute units，按指令数区分缓存
+	key := txn.CacheKey(pool, fmt.Sprintf("%t/%t/%d", quote.Buy, baseIn, len(instructions)))
+	writable := []solana.PublicKey{pool, poolState.BaseVault, poolState.QuoteVault}
+	return opts.Transaction(ctx, client, key, instructions, owner, writable, computeUnitLimit, priorityFee)
+}
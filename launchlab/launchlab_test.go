@@ -0,0 +1,208 @@
+package launchlab
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"raydium-go/anchor"
+	"raydium-go/config"
+	"raydium-go/spl"
+
+	"github.com/gagliardetto/solana-go"
+)
+
+func TestAccountSizes(t *testing.T) {
+	for name, c := range map[string]struct {
+		d anchor.Discriminator
+		v interface{}
+		n int
+	}{
+		"PoolState":    {poolStateDiscriminator, PoolState{}, 429},
+		"GlobalConfig": {globalConfigDiscriminator, GlobalConfig{}, 371},
+	} {
+		data, err := anchor.InstructionData(c.d, c.v)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(data) != c.n {
+			t.Errorf("%s size = %d, want %d", name, len(data), c.n)
+		}
+	}
+}
+
+func testPool() PoolState {
+	return PoolState{
+		Supply:                1000000000000000,
+		TotalBaseSell:         793100000000000,
+		VirtualBase:           1073025605596382,
+		VirtualQuote:          30000852951,
+		RealBase:              100000000000000,
+		RealQuote:             3500000000,
+		TotalQuoteFundRaising: 85000000000,
+		BaseMint:              solana.NewWallet().PublicKey(),
+		QuoteMint:             solana.NewWallet().PublicKey(),
+	}
+}
+
+func TestCurve(t *testing.T) {
+	pool, globalConfig := testPool(), GlobalConfig{TradeFeeRate: 2500}
+	fees := FeesFrom(globalConfig, PlatformConfig{FeeRate: 5000}, 0)
+
+	out, fee, err := BuyExactIn(pool, globalConfig, fees, 1000000000)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fee != 7500000 || out == 0 {
+		t.Fatalf("buy exact in = %d, fee %d", out, fee)
+	}
+	in, _, err := BuyExactOut(pool, globalConfig, fees, out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if in < 999999990 || in > 1000000010 {
+		t.Errorf("buy exact out of %d = %d, want ~1000000000", out, in)
+	}
+
+	quoteOut, _, err := SellExactIn(pool, globalConfig, fees, out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if quoteOut >= 1000000000-fee {
+		t.Errorf("sell exact in = %d, want less than %d", quoteOut, 1000000000-fee)
+	}
+	baseIn, _, err := SellExactOut(pool, globalConfig, fees, quoteOut)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 一个 quote 最小单位约值 30000 个 base 最小单位，取整误差按比例比较
+	if baseIn < out-out/1000000 || baseIn > out+out/1000000 {
+		t.Errorf("sell exact out of %d = %d, want ~%d", quoteOut, baseIn, out)
+	}
+
+	if _, _, err := BuyExactOut(pool, globalConfig, fees, pool.TotalBaseSell); !errors.Is(err, ErrInsufficientSupply) {
+		t.Errorf("expected ErrInsufficientSupply, got %v", err)
+	}
+	if _, _, err := BuyExactIn(pool, GlobalConfig{CurveType: CurveLinearPrice + 1}, fees, 1); !errors.Is(err, ErrUnsupportedCurve) {
+		t.Errorf("expected ErrUnsupportedCurve, got %v", err)
+	}
+}
+
+func TestCurveTypes(t *testing.T) {
+	fixed := testPool()
+	fixed.VirtualBase, fixed.VirtualQuote = 1000000000000000, 30000000000
+	// 线性曲线的 VirtualBase 为斜率，已卖出 RealBase 时价格约为 3e-5
+	linear := testPool()
+	linear.VirtualBase = 5534
+	linear.RealQuote = linearPriceCurve{slope: u(linear.VirtualBase)}.quoteAt(u(linear.RealBase)).Uint64()
+	fees := Fees{TradeFeeRate: 2500, PlatformFeeRate: 5000}
+	for name, c := range map[string]struct {
+		pool      PoolState
+		curveType uint8
+		// 扣除手续费后的 1e9 quote 买入的 base
+		bought uint64
+	}{
+		"fixed price":  {fixed, CurveFixedPrice, 992500000 * 1000000000000000 / 30000000000},
+		"linear price": {linear, CurveLinearPrice, 0},
+	} {
+		t.Run(name, func(t *testing.T) {
+			globalConfig := GlobalConfig{CurveType: c.curveType}
+			out, fee, err := BuyExactIn(c.pool, globalConfig, fees, 1000000000)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if fee != 7500000 || out == 0 || c.bought != 0 && out != c.bought {
+				t.Fatalf("buy exact in = %d, fee %d", out, fee)
+			}
+			in, _, err := BuyExactOut(c.pool, globalConfig, fees, out)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if in < 999999990 || in > 1000000010 {
+				t.Errorf("buy exact out of %d = %d, want ~1000000000", out, in)
+			}
+			quoteOut, _, err := SellExactIn(c.pool, globalConfig, fees, out)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if quoteOut == 0 || quoteOut >= 1000000000-fee {
+				t.Errorf("sell exact in = %d, want less than %d", quoteOut, 1000000000-fee)
+			}
+			baseIn, _, err := SellExactOut(c.pool, globalConfig, fees, quoteOut)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if baseIn < out-out/1000000 || baseIn > out+out/1000000 {
+				t.Errorf("sell exact out of %d = %d, want ~%d", quoteOut, baseIn, out)
+			}
+		})
+	}
+	if _, _, err := SellExactIn(linear, GlobalConfig{CurveType: CurveLinearPrice}, fees, linear.RealBase+1); !errors.Is(err, ErrInsufficientSupply) {
+		t.Errorf("expected ErrInsufficientSupply, got %v", err)
+	}
+}
+
+func TestQuoteSwapTransferFee(t *testing.T) {
+	pool, globalConfig := testPool(), GlobalConfig{TradeFeeRate: 2500}
+	fees := FeesFrom(globalConfig, PlatformConfig{FeeRate: 5000}, 0)
+	fee := &spl.TransferFeeConfig{NewerTransferFee: spl.TransferFee{MaximumFee: 1 << 40, TransferFeeBasisPoints: 100}}
+	baseMint := spl.Mint{Address: pool.BaseMint, Program: solana.Token2022ProgramID, TransferFeeConfig: fee}
+
+	buy, err := QuoteSwap(pool, globalConfig, fees, baseMint, 0, true, 1000000000, true, 0.01)
+	if err != nil {
+		t.Fatal(err)
+	}
+	out, _, _ := BuyExactIn(pool, globalConfig, fees, 1000000000)
+	if buy.AmountOut != out || buy.OutputTransferFee != baseMint.TransferFee(0, out) || buy.OtherAmountThreshold != uint64(float64(buy.ReceivedAmount())*0.99) {
+		t.Errorf("unexpected buy: %+v", buy)
+	}
+	sell, err := QuoteSwap(pool, globalConfig, fees, baseMint, 0, false, out, true, 0.01)
+	if err != nil {
+		t.Fatal(err)
+	}
+	quoteOut, _, _ := SellExactIn(pool, globalConfig, fees, out-baseMint.TransferFee(0, out))
+	if sell.InputTransferFee != baseMint.TransferFee(0, out) || sell.AmountOut != quoteOut || sell.OutputTransferFee != 0 {
+		t.Errorf("unexpected sell: %+v", sell)
+	}
+	// 买入指定数量时多买转账手续费
+	exact, err := QuoteSwap(pool, globalConfig, fees, baseMint, 0, true, 1000000, false, 0.01)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if exact.ReceivedAmount() != 1000000 || exact.AmountOut <= 1000000 || exact.InputTransferFee != 0 {
+		t.Errorf("unexpected exact out buy: %+v", exact)
+	}
+}
+
+func TestMigration(t *testing.T) {
+	pool := testPool()
+	address := solana.NewWallet().PublicKey()
+	if err := pool.Migration(address); err != nil {
+		t.Fatal(err)
+	}
+	pool.Status, pool.MigrateType = StatusTrade, MigrateToCPMM
+	var migration *MigrationError
+	if err := pool.Migration(address); !errors.As(err, &migration) || migration.MigrateType != MigrateToCPMM || !migration.BaseMint.Equals(pool.BaseMint) {
+		t.Errorf("unexpected migration error %v", err)
+	}
+	if _, err := GetSwapQuote(context.Background(), nil, address, pool, pool.QuoteMint, 1, true, 0.01, ""); !errors.As(err, &migration) {
+		t.Errorf("expected a migration error, got %v", err)
+	}
+}
+
+func TestTradeInstruction(t *testing.T) {
+	programID := config.Raydium_LaunchLab_Program["mainnet"]
+	pool := testPool()
+	accounts, err := TradeAccountsFrom(programID, solana.NewWallet().PublicKey(), pool, solana.NewWallet().PublicKey(), solana.PublicKey{}, solana.PublicKey{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	inst, err := NewTradeInstruction(programID, accounts, SwapQuote{Buy: true, SwapAmounts: spl.SwapAmounts{AmountIn: 1, OtherAmountThreshold: 1}}, true, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, _ := inst.Data()
+	if len(data) != 32 || len(inst.Accounts()) != 15 || [8]byte(data[:8]) != buyExactInDiscriminator {
+		t.Errorf("data = %v, accounts = %d", data, len(inst.Accounts()))
+	}
+}
@@ -0,0 +1,212 @@
+package launchlab
+
+import (
+	"context"
+	"fmt"
+
+	"raydium-go/anchor"
+
+	"github.com/gagliardetto/solana-go"
+	"github.com/gagliardetto/solana-go/rpc"
+)
+
+// 池子状态
+const (
+	// 仍在 bonding curve 上募集
+	StatusFund uint8 = iota
+	// 募集完成，等待迁移
+	StatusMigrate
+	// 已迁移到 AMM/CPMM
+	StatusTrade
+)
+
+// 迁移目标
+const (
+	MigrateToAMM uint8 = iota
+	MigrateToCPMM
+)
+
+const (
+	CurveConstantProduct uint8 = iota
+	CurveFixedPrice
+	CurveLinearPrice
+)
+
+var (
+	poolStateDiscriminator      = anchor.AccountDiscriminator("PoolState")
+	globalConfigDiscriminator   = anchor.AccountDiscriminator("GlobalConfig")
+	platformConfigDiscriminator = anchor.AccountDiscriminator("PlatformConfig")
+
+	poolSeed           = []byte("pool")
+	poolVaultSeed      = []byte("pool_vault")
+	authoritySeed      = []byte("vault_auth_seed")
+	eventAuthoritySeed = []byte("__event_authority")
+)
+
+// GlobalConfig 对应 Rust 中的 GlobalConfig
+type GlobalConfig struct {
+	Epoch               uint64
+	CurveType           uint8
+	Index               uint16
+	MigrateFee          uint64
+	TradeFeeRate        uint64
+	MaxShareFeeRate     uint64
+	MinBaseSupply       uint64
+	MaxLockRate         uint64
+	MinBaseSellRate     uint64
+	MinBaseMigrateRate  uint64
+	MinQuoteFundRaising uint64
+	QuoteMint           solana.PublicKey
+	ProtocolFeeOwner    solana.PublicKey
+	MigrateFeeOwner     solana.PublicKey
+	MigrateToAmmWallet  solana.PublicKey
+	MigrateToCpmmWallet solana.PublicKey
+	Padding             [16]uint64
+}
+
+// PlatformConfig 对应 Rust 中的 PlatformConfig，只解码报价需要的前几个字段
+type PlatformConfig struct {
+	Epoch             uint64
+	PlatformFeeWallet solana.PublicKey
+	PlatformNftWallet solana.PublicKey
+	PlatformScale     uint64
+	CreatorScale      uint64
+	BurnScale         uint64
+	FeeRate           uint64
+	Name              [64]uint8
+	Web               [256]uint8
+	Img               [256]uint8
+}
+
+// VestingSchedule 对应 Rust 中的 VestingSchedule
+type VestingSchedule struct {
+	TotalLockedAmount    uint64
+	CliffPeriod          uint64
+	UnlockPeriod         uint64
+	StartTime            uint64
+	AllocatedShareAmount uint64
+}
+
+// PoolState 对应 Rust 中的 PoolState
+type PoolState struct {
+	Epoch                 uint64
+	AuthBump              uint8
+	Status                uint8
+	BaseDecimals          uint8
+	QuoteDecimals         uint8
+	MigrateType           uint8
+	Supply                uint64
+	TotalBaseSell         uint64
+	VirtualBase           uint64
+	VirtualQuote          uint64
+	RealBase              uint64
+	RealQuote             uint64
+	TotalQuoteFundRaising uint64
+	QuoteProtocolFee      uint64
+	PlatformFee           uint64
+	MigrateFee            uint64
+	VestingSchedule       VestingSchedule
+	GlobalConfig          solana.PublicKey
+	PlatformConfig        solana.PublicKey
+	BaseMint              solana.PublicKey
+	QuoteMint             solana.PublicKey
+	BaseVault             solana.PublicKey
+	QuoteVault            solana.PublicKey
+	Creator               solana.PublicKey
+	Padding               [8]uint64
+}
+
+// MigrationError is returned when a pool no longer trades on its bonding curve. The token then
+// trades on an AMM v4 or CPMM pool of BaseMint and QuoteMint, as given by MigrateType.
+type MigrationError struct {
+	Pool        solana.PublicKey
+	Status      uint8
+	MigrateType uint8
+	BaseMint    solana.PublicKey
+	QuoteMint   solana.PublicKey
+}
+
+func (e *MigrationError) Error() string {
+	target := "AMM"
+	if e.MigrateType == MigrateToCPMM {
+		target = "CPMM"
+	}
+	if e.Status == StatusMigrate {
+		return fmt.Sprintf("launchlab pool %s completed fundraising and is migrating to %s", e.Pool, target)
+	}
+	return fmt.Sprintf("launchlab pool %s migrated to %s", e.Pool, target)
+}
+
+// Migration returns a *MigrationError when the pool can no longer be traded on the curve.
+func (p PoolState) Migration(pool solana.PublicKey) error {
+	if p.Status == StatusFund {
+		return nil
+	}
+	return &MigrationError{Pool: pool, Status: p.Status, MigrateType: p.MigrateType, BaseMint: p.BaseMint, QuoteMint: p.QuoteMint}
+}
+
+// Progress 为已募集的 quote 数量占目标的比例
+func (p PoolState) Progress() float64 {
+	if p.TotalQuoteFundRaising == 0 {
+		return 0
+	}
+	return float64(p.RealQuote) / float64(p.TotalQuoteFundRaising)
+}
+
+func DecodePoolState(data []byte) (PoolState, error) {
+	var state PoolState
+	err := anchor.DecodeAccount(data, poolStateDiscriminator, &state)
+	return state, err
+}
+
+func DecodeGlobalConfig(data []byte) (GlobalConfig, error) {
+	var globalConfig GlobalConfig
+	err := anchor.DecodeAccount(data, globalConfigDiscriminator, &globalConfig)
+	return globalConfig, err
+}
+
+func DecodePlatformConfig(data []byte) (PlatformConfig, error) {
+	var platformConfig PlatformConfig
+	err := anchor.DecodeAccount(data, platformConfigDiscriminator, &platformConfig)
+	return platformConfig, err
+}
+
+func GetPoolState(ctx context.Context, client *rpc.Client, pool solana.PublicKey, commitment rpc.CommitmentType) (PoolState, error) {
+	account, err := client.GetAccountInfoWithOpts(ctx, pool, &rpc.GetAccountInfoOpts{Commitment: commitment})
+	if err != nil {
+		return PoolState{}, err
+	}
+	return DecodePoolState(account.Value.Data.GetBinary())
+}
+
+func GetGlobalConfig(ctx context.Context, client *rpc.Client, globalConfig solana.PublicKey, commitment rpc.CommitmentType) (GlobalConfig, error) {
+	account, err := client.GetAccountInfoWithOpts(ctx, globalConfig, &rpc.GetAccountInfoOpts{Commitment: commitment})
+	if err != nil {
+		return GlobalConfig{}, err
+	}
+	return DecodeGlobalConfig(account.Value.Data.GetBinary())
+}
+
+func GetPlatformConfig(ctx context.Context, client *rpc.Client, platformConfig solana.PublicKey, commitment rpc.CommitmentType) (PlatformConfig, error) {
+	account, err := client.GetAccountInfoWithOpts(ctx, platformConfig, &rpc.GetAccountInfoOpts{Commitment: commitment})
+	if err != nil {
+		return PlatformConfig{}, err
+	}
+	return DecodePlatformConfig(account.Value.Data.GetBinary())
+}
+
+func GetPoolAddress(programID solana.PublicKey, baseMint solana.PublicKey, quoteMint solana.PublicKey) (solana.PublicKey, uint8, error) {
+	return solana.FindProgramAddress([][]byte{poolSeed, baseMint[:], quoteMint[:]}, programID)
+}
+
+func GetVaultAddress(programID solana.PublicKey, pool solana.PublicKey, mint solana.PublicKey) (solana.PublicKey, uint8, error) {
+	return solana.FindProgramAddress([][]byte{poolVaultSeed, pool[:], mint[:]}, programID)
+}
+
+func GetAuthority(programID solana.PublicKey) (solana.PublicKey, uint8, error) {
+	return solana.FindProgramAddress([][]byte{authoritySeed}, programID)
+}
+
+func GetEventAuthority(programID solana.PublicKey) (solana.PublicKey, uint8, error) {
+	return solana.FindProgramAddress([][]byte{eventAuthoritySeed}, programID)
+}
@@ -1,7 +1,181 @@
 package main
 
-import "fmt"
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"strings"
+
+	"raydium-go/config"
+
+	"github.com/gagliardetto/solana-go/rpc"
+)
+
+const usage = `Usage: raydium-go <command> [flags] [args]
+
+Commands:
+  swap         swap on an AMM v4 pool
+  quote        quote a swap without sending it
+  pool show    show the state and reserves of a pool
+  pool find    find the pools trading two mints
+  decode-log   decode ray_log messages
+  decode-tx    decode the swaps and ray_log messages of a transaction
+
+Flags:
+  --config    YAML or TOML config file (default $RAYDIUM_CONFIG)
+  --profile   profile of the config file (default $RAYDIUM_PROFILE, then the default profile)
+  --network   mainnet, devnet, localnet or a cluster of the config file (default mainnet)
+  --rpc       RPC endpoint, or comma-separated endpoints to fail over between, defaults to the
+              endpoint of the network
+  --keypair   solana keygen file (default ~/.config/solana/id.json)
+  --slippage  slippage tolerance, 0.01 is 1% (default 0.01)
+  --json      print JSON instead of text
+
+Flags override the profile, which RAYDIUM_* environment variables override in turn.
+
+swap and quote flags:
+  --pool      AMM v4 pool address
+  --input     input mint
+  --amount    exact input, or exact output with --base-out
+  --base-out  swap for an exact output
+  --verbose   log each stage of the swap to stderr (swap only)
+
+Amounts are in the smallest unit of the token.
+`
+
+var errUsage = errors.New("invalid usage")
+
+// options 为所有子命令共用的参数，未指定的参数取自 profile
+type options struct {
+	config      string
+	profileName string
+	network     string
+	rpc         string
+	keypair     string
+	slippage    float64
+	json        bool
+	profile     config.Profile
+}
+
+func newFlagSet(name string, opts *options) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.Usage = func() { fmt.Fprint(os.Stderr, usage) }
+	fs.StringVar(&opts.config, "config", "", "YAML or TOML config file")
+	fs.StringVar(&opts.profileName, "profile", "", "profile of the config file")
+	fs.StringVar(&opts.network, "network", "", "cluster name")
+	fs.StringVar(&opts.rpc, "rpc", "", "RPC endpoint")
+	fs.StringVar(&opts.keypair, "keypair", "", "solana keygen file")
+	fs.Float64Var(&opts.slippage, "slippage", 0, "slippage tolerance")
+	fs.BoolVar(&opts.json, "json", false, "print JSON")
+	return fs
+}
+
+// parse parses flags placed before, between or after the positional arguments and loads the profile
+// under the flags that were set.
+func (opts *options) parse(fs *flag.FlagSet, args []string) ([]string, error) {
+	var positional []string
+	for {
+		if err := fs.Parse(args); err != nil {
+			return nil, err
+		}
+		if fs.NArg() == 0 {
+			break
+		}
+		positional = append(positional, fs.Arg(0))
+		args = fs.Args()[1:]
+	}
+	profile, err := config.LoadProfile(opts.config, opts.profileName)
+	if err != nil {
+		return nil, err
+	}
+	if opts.network != "" {
+		if err := profile.SetNetwork(opts.network); err != nil {
+			return nil, err
+		}
+	}
+	if opts.rpc != "" {
+		profile.SetRPC(opts.rpc)
+	}
+	if opts.keypair != "" {
+		profile.Wallet = opts.keypair
+	}
+	if opts.slippage != 0 {
+		profile.Fees.Slippage = opts.slippage
+	}
+	opts.profile = profile
+	return positional, nil
+}
+
+func (opts *options) client() (*rpc.Client, error) {
+	return opts.profile.Client()
+}
+
+// output prints v as JSON with --json, otherwise the text lines.
+func (opts *options) output(v interface{}, text func()) error {
+	if !opts.json {
+		text()
+		return nil
+	}
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	return enc.Encode(v)
+}
+
+func field(name string, value interface{}) {
+	fmt.Printf("%-22s %v\n", name+":", value)
+}
+
+func run(ctx context.Context, args []string) error {
+	if len(args) == 0 {
+		return errUsage
+	}
+	command, args := args[0], args[1:]
+	switch command {
+	case "swap":
+		return runSwap(ctx, args)
+	case "quote":
+		return runQuote(ctx, args)
+	case "pool":
+		if len(args) == 0 {
+			return errUsage
+		}
+		switch args[0] {
+		case "show":
+			return runPoolShow(ctx, args[1:])
+		case "find":
+			return runPoolFind(ctx, args[1:])
+		}
+	case "decode-log":
+		return runDecodeLog(args)
+	case "decode-tx":
+		return runDecodeTx(ctx, args)
+	case "help", "-h", "-help", "--help":
+		fmt.Print(usage)
+		return nil
+	}
+	return errUsage
+}
 
 func main() {
-	fmt.Println("vim-go")
+	// Ctrl-C 取消进行中的 RPC 请求
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	err := run(ctx, os.Args[1:])
+	stop()
+	switch {
+	case err == nil:
+	case errors.Is(err, flag.ErrHelp):
+	case errors.Is(err, errUsage):
+		if err != errUsage {
+			fmt.Fprintln(os.Stderr, "error:", err)
+		}
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	default:
+		fmt.Fprintln(os.Stderr, "error:", strings.TrimSpace(err.Error()))
+		os.Exit(1)
+	}
 }
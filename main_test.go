@@ -0,0 +1,53 @@
+package main
+
+import (
+	"os"
+	"reflect"
+	"testing"
+
+	"raydium-go/amm"
+)
+
+func TestParseArgs(t *testing.T) {
+	opts := new(options)
+	t.Setenv("RAYDIUM_CONFIG", "")
+	t.Setenv("RAYDIUM_NETWORK", "mainnet")
+	t.Setenv("RAYDIUM_RPC_URL", "http://127.0.0.1:8899")
+	positional, err := opts.parse(newFlagSet("pool find", opts), []string{"--network", "devnet", "mintA", "--json", "mintB", "--slippage=0.05"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(positional, []string{"mintA", "mintB"}) || opts.profile.Network != "devnet" || !opts.json || opts.profile.Slippage() != 0.05 {
+		t.Errorf("positional = %v, options = %+v", positional, opts)
+	}
+	// 换网络后环境变量的端点仍然生效
+	if endpoint, _ := opts.profile.RPCEndpoint(); endpoint != "http://127.0.0.1:8899" {
+		t.Errorf("endpoint = %s", endpoint)
+	}
+	os.Unsetenv("RAYDIUM_RPC_URL")
+	opts = new(options)
+	if _, err := opts.parse(newFlagSet("pool find", opts), []string{"--network", "devnet"}); err != nil {
+		t.Fatal(err)
+	}
+	if endpoint, _ := opts.profile.RPCEndpoint(); endpoint != "https://api.devnet.solana.com" {
+		t.Errorf("endpoint = %s", endpoint)
+	}
+	opts = new(options)
+	if _, err := opts.parse(newFlagSet("pool find", opts), []string{"--network", "nowhere"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := opts.client(); err == nil {
+		t.Error("expected an error for an unknown network")
+	}
+}
+
+func TestDecodeRayLog(t *testing.T) {
+	out, err := decodeRayLog("Program log: ray_log: A0BCDwAAAAAAS8elcVACAAABAAAAAAAAAEBCDwAAAAAAziWk9e+IKAK1TovGDAAAAHDdYkWSAgAA")
+	if err != nil {
+		t.Fatal(err)
+	}
+	log, ok := out.Log.(amm.SwapBaseInLog)
+	if out.Type != "swap_base_in" || !ok || log.AmountIn != 1000000 {
+		t.Errorf("unexpected log %+v", out)
+	}
+}
@@ -0,0 +1,148 @@
+package metrics
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"raydium-go/amm"
+
+	"github.com/gagliardetto/solana-go/rpc"
+	"github.com/gagliardetto/solana-go/rpc/jsonrpc"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// Metrics collects swap and RPC metrics in its own registry. Feed it with SwapHook and ObserveRPC
+// or InstrumentRPC, and serve it with Handler.
+type Metrics struct {
+	registry     *prometheus.Registry
+	rpcDuration  *prometheus.HistogramVec
+	swaps        *prometheus.CounterVec
+	slippage     prometheus.Histogram
+	priorityFees prometheus.Counter
+	txFees       prometheus.Counter
+	computeUnits prometheus.Histogram
+}
+
+func New() *Metrics {
+	m := &Metrics{
+		registry: prometheus.NewRegistry(),
+		rpcDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "raydium_rpc_duration_seconds",
+			Help:    "Latency of RPC calls by method, endpoint and status.",
+			Buckets: []float64{.01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10},
+		}, []string{"method", "endpoint", "status"}),
+		swaps: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "raydium_swaps_total",
+			Help: "Swaps by pool and status: attempted, sent, confirmed or failed.",
+		}, []string{"pool", "status"}),
+		slippage: prometheus.NewHistogram(prometheus.HistogramOpts{
+			Name:    "raydium_swap_realized_slippage_bps",
+			Help:    "Realized slippage of confirmed swaps against their quote in basis points, negative for price improvement.",
+			Buckets: []float64{-50, -10, -1, 0, 1, 5, 10, 25, 50, 100, 250, 500},
+		}),
+		priorityFees: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "raydium_swap_priority_fees_lamports_total",
+			Help: "Priority fees of confirmed swaps in lamports.",
+		}),
+		txFees: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "raydium_swap_fees_lamports_total",
+			Help: "Transaction fees of confirmed swaps in lamports, including priority fees.",
+		}),
+		computeUnits: prometheus.NewHistogram(prometheus.HistogramOpts{
+			Name:    "raydium_swap_compute_units",
+			Help:    "Compute units consumed by confirmed swaps.",
+			Buckets: prometheus.ExponentialBuckets(10000, 1.5, 10),
+		}),
+	}
+	m.registry.MustRegister(m.rpcDuration, m.swaps, m.slippage, m.priorityFees, m.txFees, m.computeUnits)
+	return m
+}
+
+// Registry returns the registry the metrics are registered in, e.g. to add process collectors.
+func (m *Metrics) Registry() *prometheus.Registry {
+	return m.registry
+}
+
+// Handler serves the metrics in the Prometheus text format, typically at /metrics.
+func (m *Metrics) Handler() http.Handler {
+	return promhttp.HandlerFor(m.registry, promhttp.HandlerOpts{})
+}
+
+// SwapHook returns a hook for amm.SwapOptions.Hooks that counts swaps by stage and records fees,
+// compute units and slippage of confirmed swaps. A swap is attempted once it is signed for sending,
+// so transactions only built, e.g. by BuildSwapTransaction, are not counted, and only attempted swaps
+// count as failed. Swaps are only confirmed when SwapOptions.Commitment.Confirmation is set, otherwise
+// they stop at sent.
+func (m *Metrics) SwapHook() amm.SwapHook {
+	return func(ctx context.Context, e amm.SwapEvent) {
+		pool := e.Pool.String()
+		switch e.Stage {
+		case amm.StageSigned:
+			m.swaps.WithLabelValues(pool, "attempted").Inc()
+		case amm.StageSent:
+			m.swaps.WithLabelValues(pool, "sent").Inc()
+		case amm.StageFailed:
+			// 签名前失败的 swap 没有计入 attempted
+			if !e.Signature.IsZero() {
+				m.swaps.WithLabelValues(pool, "failed").Inc()
+			}
+		case amm.StageConfirmed:
+			m.swaps.WithLabelValues(pool, "confirmed").Inc()
+			m.priorityFees.Add(float64(e.PriorityFeeLamports()))
+			if e.Result == nil {
+				return
+			}
+			m.txFees.Add(float64(e.Result.Fee))
+			if e.Result.ComputeUnitsConsumed > 0 {
+				m.computeUnits.Observe(float64(e.Result.ComputeUnitsConsumed))
+			}
+			if slippage, ok := e.RealizedSlippage(); ok {
+				m.slippage.Observe(slippage * 10000)
+			}
+		}
+	}
+}
+
+// ObserveRPC records one RPC call; it matches rpcpool.Options.Observe.
+func (m *Metrics) ObserveRPC(endpoint string, method string, elapsed time.Duration, err error) {
+	status := "ok"
+	if err != nil {
+		status = "error"
+	}
+	m.rpcDuration.WithLabelValues(method, endpoint, status).Observe(elapsed.Seconds())
+}
+
+// InstrumentRPC wraps a single-endpoint client so its calls are recorded under endpoint, e.g.
+// rpc.NewWithCustomRPCClient(m.InstrumentRPC(url, jsonrpc.NewClient(url))).
+func (m *Metrics) InstrumentRPC(endpoint string, client rpc.JSONRPCClient) rpc.JSONRPCClient {
+	return &instrumented{metrics: m, endpoint: endpoint, client: client}
+}
+
+type instrumented struct {
+	metrics  *Metrics
+	endpoint string
+	client   rpc.JSONRPCClient
+}
+
+func (c *instrumented) CallForInto(ctx context.Context, out interface{}, method string, params []interface{}) error {
+	start := time.Now()
+	err := c.client.CallForInto(ctx, out, method, params)
+	c.metrics.ObserveRPC(c.endpoint, method, time.Since(start), err)
+	return err
+}
+
+func (c *instrumented) CallWithCallback(ctx context.Context, method string, params []interface{}, callback func(*http.Request, *http.Response) error) error {
+	start := time.Now()
+	err := c.client.CallWithCallback(ctx, method, params, callback)
+	c.metrics.ObserveRPC(c.endpoint, method, time.Since(start), err)
+	return err
+}
+
+func (c *instrumented) CallBatch(ctx context.Context, requests jsonrpc.RPCRequests) (jsonrpc.RPCResponses, error) {
+	start := time.Now()
+	res, err := c.client.CallBatch(ctx, requests)
+	c.metrics.ObserveRPC(c.endpoint, "batch", time.Since(start), err)
+	return res, err
+}
@@ -0,0 +1,101 @@
+package metrics
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"raydium-go/amm"
+	"raydium-go/internal/rpctest"
+	"raydium-go/spl"
+
+	"github.com/gagliardetto/solana-go"
+	"github.com/gagliardetto/solana-go/rpc"
+	"github.com/gagliardetto/solana-go/rpc/jsonrpc"
+)
+
+func scrape(t *testing.T, m *Metrics) string {
+	s := httptest.NewServer(m.Handler())
+	defer s.Close()
+	resp, err := http.Get(s.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(body)
+}
+
+func TestSwapHook(t *testing.T) {
+	m := New()
+	hook := m.SwapHook()
+	pool := solana.MustPublicKeyFromBase58("58oQChx4yWmvKdwLLZzBi4ChoCc2fqCUWBkwMihLYQo2")
+	e := amm.SwapEvent{Pool: pool, BaseIn: true, Quote: amm.SwapQuote{SwapAmounts: spl.SwapAmounts{AmountIn: 1000, AmountOut: 10000}}}
+	for _, stage := range []amm.SwapStage{amm.StageStarted, amm.StageTxBuilt, amm.StageSigned, amm.StageSent} {
+		e.Stage = stage
+		if stage == amm.StageSigned {
+			e.Signature = solana.Signature{1}
+		}
+		hook(context.Background(), e)
+	}
+	// 只构建不发送的交易不算尝试，构建失败也不算失败
+	built := solana.NewWallet().PublicKey()
+	for _, stage := range []amm.SwapStage{amm.StageStarted, amm.StageTxBuilt, amm.StageFailed} {
+		hook(context.Background(), amm.SwapEvent{Stage: stage, Pool: built})
+	}
+	e.Stage = amm.StageConfirmed
+	e.ComputeUnitLimit, e.PriorityFee = 200000, 5000
+	e.Result = &amm.SwapResult{Fee: 6000, ComputeUnitsConsumed: 45000, AmountIn: 1000, AmountOut: 9990}
+	hook(context.Background(), e)
+	e.Stage = amm.StageFailed
+	hook(context.Background(), e)
+
+	out := scrape(t, m)
+	for _, want := range []string{
+		`raydium_swaps_total{pool="` + pool.String() + `",status="attempted"} 1`,
+		`raydium_swaps_total{pool="` + pool.String() + `",status="sent"} 1`,
+		`raydium_swaps_total{pool="` + pool.String() + `",status="confirmed"} 1`,
+		`raydium_swaps_total{pool="` + pool.String() + `",status="failed"} 1`,
+		`raydium_swap_priority_fees_lamports_total 1000`,
+		`raydium_swap_fees_lamports_total 6000`,
+		`raydium_swap_compute_units_sum 45000`,
+		// 报价 10000，实际 9990，滑点 10bps
+		`raydium_swap_realized_slippage_bps_sum 10`,
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("missing %s", want)
+		}
+	}
+	if strings.Contains(out, built.String()) {
+		t.Errorf("built transaction counted as a swap")
+	}
+}
+
+func TestInstrumentRPC(t *testing.T) {
+	s := rpctest.NewServer(t, func(string, []json.RawMessage) interface{} { return 42 })
+	m := New()
+	client := rpc.NewWithCustomRPCClient(m.InstrumentRPC("primary", jsonrpc.NewClient(s.URL)))
+	if _, err := client.GetSlot(context.Background(), ""); err != nil {
+		t.Fatal(err)
+	}
+	m.ObserveRPC("backup", "getSlot", time.Millisecond, errors.New("timeout"))
+
+	out := scrape(t, m)
+	for _, want := range []string{
+		`raydium_rpc_duration_seconds_count{endpoint="primary",method="getSlot",status="ok"} 1`,
+		`raydium_rpc_duration_seconds_count{endpoint="backup",method="getSlot",status="error"} 1`,
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("missing %s", want)
+		}
+	}
+}
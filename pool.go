@@ -0,0 +1,142 @@
+package main
+
+import (
+	"context"
+	"fmt"
+
+	"raydium-go/amm"
+
+	"github.com/gagliardetto/solana-go"
+)
+
+type poolOutput struct {
+	Address      string `json:"address"`
+	Status       uint64 `json:"status"`
+	CoinMint     string `json:"coinMint"`
+	PcMint       string `json:"pcMint"`
+	CoinDecimals uint64 `json:"coinDecimals"`
+	PcDecimals   uint64 `json:"pcDecimals"`
+	CoinVault    string `json:"coinVault"`
+	PcVault      string `json:"pcVault"`
+	LpMint       string `json:"lpMint"`
+	LpAmount     uint64 `json:"lpAmount"`
+	Market       string `json:"market"`
+	OpenTime     uint64 `json:"openTime"`
+	SwapFee      string `json:"swapFee"`
+	CoinReserve  uint64 `json:"coinReserve,omitempty"`
+	PcReserve    uint64 `json:"pcReserve,omitempty"`
+}
+
+func poolOutputFrom(address solana.PublicKey, state amm.AmmInfo) poolOutput {
+	return poolOutput{
+		Address:      address.String(),
+		Status:       state.Status,
+		CoinMint:     state.CoinVaultMint.String(),
+		PcMint:       state.PcVaultMint.String(),
+		CoinDecimals: state.CoinDecimals,
+		PcDecimals:   state.PcDecimals,
+		CoinVault:    state.CoinVault.String(),
+		PcVault:      state.PcVault.String(),
+		LpMint:       state.LpMint.String(),
+		LpAmount:     state.LpAmount,
+		Market:       state.Market.String(),
+		OpenTime:     state.StateData.PoolOpenTime,
+		SwapFee:      fmt.Sprintf("%d/%d", state.Fees.SwapFeeNumerator, state.Fees.SwapFeeDenominator),
+	}
+}
+
+func runPoolShow(ctx context.Context, args []string) error {
+	opts := new(options)
+	positional, err := opts.parse(newFlagSet("pool show", opts), args)
+	if err != nil {
+		return err
+	}
+	if len(positional) != 1 {
+		return fmt.Errorf("pool show needs a pool address: %w", errUsage)
+	}
+	pool, err := solana.PublicKeyFromBase58(positional[0])
+	if err != nil {
+		return fmt.Errorf("invalid pool address: %w", err)
+	}
+	client, err := opts.client()
+	if err != nil {
+		return err
+	}
+	state, err := amm.GetPoolState(ctx, client, pool, amm.Commitment{})
+	if err != nil {
+		return err
+	}
+	coinReserve, pcReserve, err := amm.GetPoolReserves(ctx, client, state, amm.Commitment{})
+	if err != nil {
+		return err
+	}
+	out := poolOutputFrom(pool, state)
+	out.CoinReserve, out.PcReserve = coinReserve, pcReserve
+	return opts.output(out, func() {
+		field("address", out.Address)
+		field("status", out.Status)
+		field("coin mint", out.CoinMint)
+		field("pc mint", out.PcMint)
+		field("coin vault", out.CoinVault)
+		field("pc vault", out.PcVault)
+		field("lp mint", out.LpMint)
+		field("lp amount", out.LpAmount)
+		field("market", out.Market)
+		field("open time", out.OpenTime)
+		field("swap fee", out.SwapFee)
+		field("coin reserve", out.CoinReserve)
+		field("pc reserve", out.PcReserve)
+		if coinReserve != 0 {
+			// 按小数位换算后的 pc/coin 价格
+			price := float64(pcReserve) / float64(coinReserve)
+			for i := state.PcDecimals; i < state.CoinDecimals; i++ {
+				price *= 10
+			}
+			for i := state.CoinDecimals; i < state.PcDecimals; i++ {
+				price /= 10
+			}
+			field("price", price)
+		}
+	})
+}
+
+func runPoolFind(ctx context.Context, args []string) error {
+	opts := new(options)
+	positional, err := opts.parse(newFlagSet("pool find", opts), args)
+	if err != nil {
+		return err
+	}
+	if len(positional) != 2 {
+		return fmt.Errorf("pool find needs two mints: %w", errUsage)
+	}
+	mints := make([]solana.PublicKey, 2)
+	for i, arg := range positional {
+		if mints[i], err = solana.PublicKeyFromBase58(arg); err != nil {
+			return fmt.Errorf("invalid mint %s: %w", arg, err)
+		}
+	}
+	programID, err := opts.profile.AMMProgram()
+	if err != nil {
+		return err
+	}
+	client, err := opts.client()
+	if err != nil {
+		return err
+	}
+	pools, err := amm.FindPools(ctx, client, programID, mints[0], mints[1])
+	if err != nil {
+		return err
+	}
+	out := make([]poolOutput, len(pools))
+	for i, pool := range pools {
+		out[i] = poolOutputFrom(pool.Address, pool.State)
+	}
+	return opts.output(out, func() {
+		if len(out) == 0 {
+			fmt.Println("no pools found")
+		}
+		for _, pool := range out {
+			fmt.Printf("%s  coin %s  pc %s  status %d\n", pool.Address, pool.CoinMint, pool.PcMint, pool.Status)
+		}
+	})
+}
@@ -0,0 +1,377 @@
+package router
+
+import (
+	"context"
+	"fmt"
+
+	"raydium-go/amm"
+	"raydium-go/clmm"
+	"raydium-go/config"
+	"raydium-go/cpmm"
+	"raydium-go/spl"
+
+	"github.com/gagliardetto/solana-go"
+	"github.com/gagliardetto/solana-go/rpc"
+)
+
+type Kind string
+
+const (
+	KindAMM  Kind = "amm"
+	KindCPMM Kind = "cpmm"
+	KindCLMM Kind = "clmm"
+)
+
+// PoolKey 为池子注册表中的一项
+type PoolKey struct {
+	Kind    Kind
+	Address solana.PublicKey
+}
+
+// Pool quotes swaps on a pool from state loaded beforehand, so routes can be searched without RPC
+// calls.
+type Pool interface {
+	Key() PoolKey
+	Mints() (spl.Mint, spl.Mint)
+	// Quote 不含滑点。baseIn 时 amountSpecified 为用户转出的数量，否则为到账的数量
+	Quote(inputMint solana.PublicKey, amountSpecified uint64, baseIn bool) (Leg, error)
+	// 用于估算优先费的可写账户
+	WritableAccounts() []solana.PublicKey
+}
+
+// ConstantProductPool is implemented by x*y=k pools, whose reserves drive split allocation.
+type ConstantProductPool interface {
+	Pool
+	// 返回输入和输出储备以及交易费率
+	Reserves(inputMint solana.PublicKey) (uint64, uint64, float64, error)
+}
+
+// Leg 为路由中的一跳
+type Leg struct {
+	Pool       Pool
+	InputMint  spl.Mint
+	OutputMint spl.Mint
+	// 用户转出的数量（含转账手续费）
+	AmountIn uint64
+	// 实际到账的数量（已扣除转账手续费）
+	AmountOut uint64
+	// baseIn 时 amount 为输入、threshold 为最小到账数量；否则 amount 为到账数量、threshold 为最大输入
+	instruction func(owner solana.PublicKey, inputAccount solana.PublicKey, outputAccount solana.PublicKey, baseIn bool, amount uint64, threshold uint64) (solana.Instruction, error)
+}
+
+// Instruction builds the swap instruction of the leg between the owner's token accounts.
+func (l Leg) Instruction(owner solana.PublicKey, inputAccount solana.PublicKey, outputAccount solana.PublicKey, baseIn bool, amount uint64, threshold uint64) (solana.Instruction, error) {
+	return l.instruction(owner, inputAccount, outputAccount, baseIn, amount, threshold)
+}
+
+func orderMints(inputMint solana.PublicKey, mint0 spl.Mint, mint1 spl.Mint) (spl.Mint, spl.Mint, error) {
+	switch {
+	case inputMint.Equals(mint0.Address):
+		return mint0, mint1, nil
+	case inputMint.Equals(mint1.Address):
+		return mint1, mint0, nil
+	default:
+		return spl.Mint{}, spl.Mint{}, fmt.Errorf("mint %s is not in pool", inputMint)
+	}
+}
+
+type ammPool struct {
+	programID   solana.PublicKey
+	address     solana.PublicKey
+	state       amm.AmmInfo
+	market      amm.MarketState
+	coinReserve uint64
+	pcReserve   uint64
+	coinMint    spl.Mint
+	pcMint      spl.Mint
+	epoch       uint64
+}
+
+func NewAMMPool(programID solana.PublicKey, address solana.PublicKey, state amm.AmmInfo, market amm.MarketState, coinReserve uint64, pcReserve uint64, coinMint spl.Mint, pcMint spl.Mint, epoch uint64) Pool {
+	return &ammPool{programID, address, state, market, coinReserve, pcReserve, coinMint, pcMint, epoch}
+}
+
+func (p *ammPool) Key() PoolKey {
+	return PoolKey{Kind: KindAMM, Address: p.address}
+}
+
+func (p *ammPool) Mints() (spl.Mint, spl.Mint) {
+	return p.coinMint, p.pcMint
+}
+
+func (p *ammPool) WritableAccounts() []solana.PublicKey {
+	return []solana.PublicKey{p.address, p.state.OpenOrders, p.state.TargetOrders, p.state.CoinVault, p.state.PcVault, p.state.Market, p.market.Bids, p.market.Asks, p.market.EventQueue}
+}
+
+func (p *ammPool) Reserves(inputMint solana.PublicKey) (uint64, uint64, float64, error) {
+	if p.state.Fees.SwapFeeDenominator == 0 {
+		return 0, 0, 0, fmt.Errorf("invalid swap fee denominator")
+	}
+	fee := float64(p.state.Fees.SwapFeeNumerator) / float64(p.state.Fees.SwapFeeDenominator)
+	switch {
+	case inputMint.Equals(p.coinMint.Address):
+		return p.coinReserve, p.pcReserve, fee, nil
+	case inputMint.Equals(p.pcMint.Address):
+		return p.pcReserve, p.coinReserve, fee, nil
+	default:
+		return 0, 0, 0, fmt.Errorf("mint %s is not in pool", inputMint)
+	}
+}
+
+func (p *ammPool) Quote(inputMint solana.PublicKey, amountSpecified uint64, baseIn bool) (Leg, error) {
+	in, out, err := orderMints(inputMint, p.coinMint, p.pcMint)
+	if err != nil {
+		return Leg{}, err
+	}
+	quote, err := amm.QuoteSwap(p.state, p.coinReserve, p.pcReserve, in, out, p.epoch, amountSpecified, baseIn, 0)
+	if err != nil {
+		return Leg{}, err
+	}
+	return Leg{
+		Pool:       p,
+		InputMint:  in,
+		OutputMint: out,
+		AmountIn:   quote.AmountIn,
+		AmountOut:  quote.ReceivedAmount(),
+		instruction: func(owner solana.PublicKey, inputAccount solana.PublicKey, outputAccount solana.PublicKey, baseIn bool, amount uint64, threshold uint64) (solana.Instruction, error) {
+			if !baseIn {
+				amount += out.InverseTransferFee(p.epoch, amount)
+			}
+			return amm.NewSwapInstruction(p.programID, p.address, p.state, p.market, in.Program, inputAccount, outputAccount, owner, baseIn, amount, threshold)
+		},
+	}, nil
+}
+
+type cpmmPool struct {
+	programID solana.PublicKey
+	address   solana.PublicKey
+	state     cpmm.PoolState
+	ammConfig cpmm.AmmConfig
+	reserve0  uint64
+	reserve1  uint64
+	mint0     spl.Mint
+	mint1     spl.Mint
+	epoch     uint64
+}
+
+func NewCPMMPool(programID solana.PublicKey, address solana.PublicKey, state cpmm.PoolState, ammConfig cpmm.AmmConfig, reserve0 uint64, reserve1 uint64, mint0 spl.Mint, mint1 spl.Mint, epoch uint64) Pool {
+	return &cpmmPool{programID, address, state, ammConfig, reserve0, reserve1, mint0, mint1, epoch}
+}
+
+func (p *cpmmPool) Key() PoolKey {
+	return PoolKey{Kind: KindCPMM, Address: p.address}
+}
+
+func (p *cpmmPool) Mints() (spl.Mint, spl.Mint) {
+	return p.mint0, p.mint1
+}
+
+func (p *cpmmPool) WritableAccounts() []solana.PublicKey {
+	return []solana.PublicKey{p.address, p.state.Token0Vault, p.state.Token1Vault, p.state.ObservationKey}
+}
+
+func (p *cpmmPool) Reserves(inputMint solana.PublicKey) (uint64, uint64, float64, error) {
+	fee := float64(p.ammConfig.TradeFeeRate) / float64(cpmm.FeeRateDenominator)
+	switch {
+	case inputMint.Equals(p.mint0.Address):
+		return p.reserve0, p.reserve1, fee, nil
+	case inputMint.Equals(p.mint1.Address):
+		return p.reserve1, p.reserve0, fee, nil
+	default:
+		return 0, 0, 0, fmt.Errorf("mint %s is not in pool", inputMint)
+	}
+}
+
+func (p *cpmmPool) Quote(inputMint solana.PublicKey, amountSpecified uint64, baseIn bool) (Leg, error) {
+	in, out, err := orderMints(inputMint, p.mint0, p.mint1)
+	if err != nil {
+		return Leg{}, err
+	}
+	reserveIn, reserveOut := p.reserve0, p.reserve1
+	if in.Address.Equals(p.mint1.Address) {
+		reserveIn, reserveOut = p.reserve1, p.reserve0
+	}
+	quote, err := cpmm.QuoteSwap(p.state.SwapFees(p.ammConfig, in.Address), reserveIn, reserveOut, in, out, p.epoch, amountSpecified, baseIn, 0)
+	if err != nil {
+		return Leg{}, err
+	}
+	return Leg{
+		Pool:       p,
+		InputMint:  in,
+		OutputMint: out,
+		AmountIn:   quote.AmountIn,
+		AmountOut:  quote.ReceivedAmount(),
+		instruction: func(owner solana.PublicKey, inputAccount solana.PublicKey, outputAccount solana.PublicKey, baseIn bool, amount uint64, threshold uint64) (solana.Instruction, error) {
+			accounts, err := cpmm.SwapAccountsFrom(p.programID, p.address, p.state, owner, in.Address, inputAccount, outputAccount)
+			if err != nil {
+				return nil, err
+			}
+			if baseIn {
+				return cpmm.NewSwapBaseInputInstruction(p.programID, accounts, amount, threshold)
+			}
+			return cpmm.NewSwapBaseOutputInstruction(p.programID, accounts, threshold, amount)
+		},
+	}, nil
+}
+
+type clmmPool struct {
+	programID  solana.PublicKey
+	address    solana.PublicKey
+	state      clmm.PoolState
+	ammConfig  clmm.AmmConfig
+	extension  *clmm.TickArrayBitmapExtension
+	tickArrays []clmm.TickArrayState
+	mint0      spl.Mint
+	mint1      spl.Mint
+	epoch      uint64
+}
+
+// NewCLMMPool needs the tick arrays a swap in either direction may cross.
+func NewCLMMPool(programID solana.PublicKey, address solana.PublicKey, state clmm.PoolState, ammConfig clmm.AmmConfig, extension *clmm.TickArrayBitmapExtension, tickArrays []clmm.TickArrayState, mint0 spl.Mint, mint1 spl.Mint, epoch uint64) Pool {
+	return &clmmPool{programID, address, state, ammConfig, extension, tickArrays, mint0, mint1, epoch}
+}
+
+func (p *clmmPool) Key() PoolKey {
+	return PoolKey{Kind: KindCLMM, Address: p.address}
+}
+
+func (p *clmmPool) Mints() (spl.Mint, spl.Mint) {
+	return p.mint0, p.mint1
+}
+
+func (p *clmmPool) WritableAccounts() []solana.PublicKey {
+	return []solana.PublicKey{p.address, p.state.TokenVault0, p.state.TokenVault1, p.state.ObservationKey}
+}
+
+func (p *clmmPool) Quote(inputMint solana.PublicKey, amountSpecified uint64, baseIn bool) (Leg, error) {
+	in, out, err := orderMints(inputMint, p.mint0, p.mint1)
+	if err != nil {
+		return Leg{}, err
+	}
+	quote, err := clmm.QuoteSwap(p.state, p.ammConfig, p.extension, p.tickArrays, in, out, p.epoch, amountSpecified, baseIn, 0)
+	if err != nil {
+		return Leg{}, err
+	}
+	return Leg{
+		Pool:       p,
+		InputMint:  in,
+		OutputMint: out,
+		AmountIn:   quote.AmountIn,
+		AmountOut:  quote.ReceivedAmount(),
+		instruction: func(owner solana.PublicKey, inputAccount solana.PublicKey, outputAccount solana.PublicKey, baseIn bool, amount uint64, threshold uint64) (solana.Instruction, error) {
+			accounts, err := clmm.SwapV2AccountsFrom(p.programID, p.address, p.state, owner, quote, inputAccount, outputAccount)
+			if err != nil {
+				return nil, err
+			}
+			return clmm.NewSwapV2Instruction(p.programID, accounts, clmm.SwapV2Args{Amount: amount, OtherAmountThreshold: threshold, IsBaseInput: baseIn})
+		},
+	}, nil
+}
+
+// LoadPools fetches the state needed to quote every pool of the registry on network.
+func LoadPools(ctx context.Context, client *rpc.Client, network string, keys []PoolKey, commitment rpc.CommitmentType) ([]Pool, error) {
+	epochInfo, err := client.GetEpochInfo(ctx, commitment)
+	if err != nil {
+		return nil, err
+	}
+	var pools []Pool
+	for _, key := range keys {
+		pool, err := loadPool(ctx, client, network, key, epochInfo.Epoch, commitment)
+		if err != nil {
+			return nil, fmt.Errorf("%s pool %s: %w", key.Kind, key.Address, err)
+		}
+		pools = append(pools, pool)
+	}
+	return pools, nil
+}
+
+func loadPool(ctx context.Context, client *rpc.Client, network string, key PoolKey, epoch uint64, commitment rpc.CommitmentType) (Pool, error) {
+	switch key.Kind {
+	case KindAMM:
+		programID, err := config.AMMProgram(network)
+		if err != nil {
+			return nil, err
+		}
+		state, err := amm.GetPoolState(ctx, client, key.Address, amm.Commitment{State: commitment})
+		if err != nil {
+			return nil, err
+		}
+		market, err := amm.GetMarketState(ctx, client, state.Market, amm.Commitment{State: commitment})
+		if err != nil {
+			return nil, err
+		}
+		coinReserve, pcReserve, err := amm.GetPoolReserves(ctx, client, state, amm.Commitment{State: commitment})
+		if err != nil {
+			return nil, err
+		}
+		mints, err := spl.GetMints(ctx, client, state.CoinVaultMint, state.PcVaultMint)
+		if err != nil {
+			return nil, err
+		}
+		return NewAMMPool(programID, key.Address, state, market, coinReserve, pcReserve, mints[0], mints[1], epoch), nil
+	case KindCPMM:
+		programID, err := config.CPMMProgram(network)
+		if err != nil {
+			return nil, err
+		}
+		state, err := cpmm.GetPoolState(ctx, client, key.Address, commitment)
+		if err != nil {
+			return nil, err
+		}
+		ammConfig, err := cpmm.GetAmmConfig(ctx, client, state.AmmConfig, commitment)
+		if err != nil {
+			return nil, err
+		}
+		reserve0, reserve1, err := cpmm.GetPoolReserves(ctx, client, state, commitment)
+		if err != nil {
+			return nil, err
+		}
+		mints, err := spl.GetMints(ctx, client, state.Token0Mint, state.Token1Mint)
+		if err != nil {
+			return nil, err
+		}
+		return NewCPMMPool(programID, key.Address, state, ammConfig, reserve0, reserve1, mints[0], mints[1], epoch), nil
+	case KindCLMM:
+		programID, err := config.CLMMProgram(network)
+		if err != nil {
+			return nil, err
+		}
+		state, err := clmm.GetPoolState(ctx, client, key.Address, commitment)
+		if err != nil {
+			return nil, err
+		}
+		ammConfig, err := clmm.GetAmmConfig(ctx, client, state.AmmConfig, commitment)
+		if err != nil {
+			return nil, err
+		}
+		extension, err := clmm.GetTickArrayBitmapExtension(ctx, client, programID, key.Address, commitment)
+		if err != nil {
+			return nil, err
+		}
+		startIndexes := clmm.NextInitializedTickArrays(state, extension, true, maxTickArrays)
+		startIndexes = append(startIndexes, clmm.NextInitializedTickArrays(state, extension, false, maxTickArrays)...)
+		tickArrays, err := clmm.GetTickArrays(ctx, client, programID, key.Address, dedupe(startIndexes), commitment)
+		if err != nil {
+			return nil, err
+		}
+		mints, err := spl.GetMints(ctx, client, state.TokenMint0, state.TokenMint1)
+		if err != nil {
+			return nil, err
+		}
+		return NewCLMMPool(programID, key.Address, state, ammConfig, extension, tickArrays, mints[0], mints[1], epoch), nil
+	default:
+		return nil, fmt.Errorf("unknown pool kind %q", key.Kind)
+	}
+}
+
+func dedupe(indexes []int32) []int32 {
+	seen := make(map[int32]bool, len(indexes))
+	var res []int32
+	for _, index := range indexes {
+		if !seen[index] {
+			seen[index] = true
+			res = append(res, index)
+		}
+	}
+	return res
+}
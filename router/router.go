@@ -0,0 +1,285 @@
+package router
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+
+	"raydium-go/spl"
+	"raydium-go/txn"
+
+	"github.com/gagliardetto/solana-go"
+	"github.com/gagliardetto/solana-go/rpc"
+)
+
+var (
+	// 每跳预留的 compute unit
+	computeUnitsPerHop = uint32(200000)
+	maxComputeUnits    = uint32(1400000)
+	priorityFee        = uint64(100)
+	// CLMM 池子每个方向加载的 tick array 数
+	maxTickArrays = 10
+
+	ErrNoRoute = errors.New("no route found")
+)
+
+// Route 为一条多跳路由，各跳首尾相接
+type Route struct {
+	BaseIn bool
+	Legs   []Leg
+	// 用户转出的输入数量
+	AmountIn uint64
+	// 实际到账的输出数量
+	AmountOut uint64
+}
+
+func (r Route) InputMint() spl.Mint {
+	return r.Legs[0].InputMint
+}
+
+func (r Route) OutputMint() spl.Mint {
+	return r.Legs[len(r.Legs)-1].OutputMint
+}
+
+// Router searches routes over a token graph built from a pool registry.
+type Router struct {
+	MaxHops int
+	graph   map[solana.PublicKey][]Pool
+}
+
+func NewRouter(pools []Pool, maxHops int) *Router {
+	r := &Router{MaxHops: maxHops, graph: make(map[solana.PublicKey][]Pool)}
+	for _, pool := range pools {
+		mint0, mint1 := pool.Mints()
+		r.graph[mint0.Address] = append(r.graph[mint0.Address], pool)
+		r.graph[mint1.Address] = append(r.graph[mint1.Address], pool)
+	}
+	return r
+}
+
+// Paths lists the pool sequences of at most MaxHops hops from inputMint to outputMint that never
+// revisit a mint.
+func (r *Router) Paths(inputMint solana.PublicKey, outputMint solana.PublicKey) [][]Pool {
+	var paths [][]Pool
+	visited := map[solana.PublicKey]bool{inputMint: true}
+	var path []Pool
+	var walk func(mint solana.PublicKey)
+	walk = func(mint solana.PublicKey) {
+		if len(path) == r.MaxHops {
+			return
+		}
+		for _, pool := range r.graph[mint] {
+			next := otherMint(pool, mint)
+			if visited[next] {
+				continue
+			}
+			path = append(path, pool)
+			if next.Equals(outputMint) {
+				paths = append(paths, append([]Pool(nil), path...))
+			} else {
+				visited[next] = true
+				walk(next)
+				visited[next] = false
+			}
+			path = path[:len(path)-1]
+		}
+	}
+	walk(inputMint)
+	return paths
+}
+
+func otherMint(pool Pool, mint solana.PublicKey) solana.PublicKey {
+	mint0, mint1 := pool.Mints()
+	if mint0.Address.Equals(mint) {
+		return mint1.Address
+	}
+	return mint0.Address
+}
+
+// FindRoute returns the path with the largest output for an exact input (baseIn) or the smallest
+// input for an exact output.
+func (r *Router) FindRoute(inputMint solana.PublicKey, outputMint solana.PublicKey, amountSpecified uint64, baseIn bool) (Route, error) {
+	var best *Route
+	for _, path := range r.Paths(inputMint, outputMint) {
+		route, err := QuotePath(path, inputMint, amountSpecified, baseIn)
+		if err != nil {
+			continue
+		}
+		if best == nil || baseIn && route.AmountOut > best.AmountOut || !baseIn && route.AmountIn < best.AmountIn {
+			best = &route
+		}
+	}
+	if best == nil {
+		return Route{}, fmt.Errorf("%w from %s to %s", ErrNoRoute, inputMint, outputMint)
+	}
+	return *best, nil
+}
+
+// QuotePath quotes a path hop by hop, forwards for an exact input and backwards for an exact output.
+func QuotePath(path []Pool, inputMint solana.PublicKey, amountSpecified uint64, baseIn bool) (Route, error) {
+	route := Route{BaseIn: baseIn, Legs: make([]Leg, len(path))}
+	mints := make([]solana.PublicKey, len(path)+1)
+	mints[0] = inputMint
+	for i, pool := range path {
+		mints[i+1] = otherMint(pool, mints[i])
+	}
+	amount := amountSpecified
+	if baseIn {
+		for i, pool := range path {
+			leg, err := pool.Quote(mints[i], amount, true)
+			if err != nil {
+				return route, err
+			}
+			route.Legs[i] = leg
+			amount = leg.AmountOut
+		}
+	} else {
+		for i := len(path) - 1; i >= 0; i-- {
+			leg, err := path[i].Quote(mints[i], amount, false)
+			if err != nil {
+				return route, err
+			}
+			route.Legs[i] = leg
+			amount = leg.AmountIn
+		}
+	}
+	route.AmountIn = route.Legs[0].AmountIn
+	route.AmountOut = route.Legs[len(path)-1].AmountOut
+	return route, nil
+}
+
+// RouteInstructions returns the chained swap instructions of route between token accounts of owner
+// and the most the first hop may spend. Slippage is spread over the hops so that it compounds to
+// slippage: each hop of n gets 1-(1-slippage)^(1/n) with an exact input and (1+slippage)^(1/n)-1 with
+// an exact output. With an exact input a hop spends only the minimum the previous hop guarantees, with
+// an exact output a hop delivers the maximum the next hop may spend, and each hop is quoted again on
+// that amount. A better than minimum fill therefore stays in the intermediate accounts.
+func RouteInstructions(route Route, accounts []solana.PublicKey, slippage float64, owner solana.PublicKey) ([]solana.Instruction, uint64, error) {
+	if len(accounts) != len(route.Legs)+1 {
+		return nil, 0, fmt.Errorf("route of %d hops needs %d token accounts", len(route.Legs), len(route.Legs)+1)
+	}
+	hops := float64(len(route.Legs))
+	instructions := make([]solana.Instruction, len(route.Legs))
+	// baseIn 时 amount 为输入、threshold 为最小到账；否则 amount 为到账、threshold 为最大输入
+	if route.BaseIn {
+		hopSlippage := 1 - math.Pow(1-slippage, 1/hops)
+		amount := route.AmountIn
+		for i, leg := range route.Legs {
+			quote := leg
+			if i > 0 {
+				var err error
+				if quote, err = leg.Pool.Quote(leg.InputMint.Address, amount, true); err != nil {
+					return nil, 0, fmt.Errorf("hop %d: %w", i, err)
+				}
+			}
+			threshold := uint64(float64(quote.AmountOut) * (1 - hopSlippage))
+			var err error
+			if instructions[i], err = leg.Instruction(owner, accounts[i], accounts[i+1], true, amount, threshold); err != nil {
+				return nil, 0, err
+			}
+			amount = threshold
+		}
+		return instructions, route.AmountIn, nil
+	}
+	hopSlippage := math.Pow(1+slippage, 1/hops) - 1
+	last := len(route.Legs) - 1
+	amount := route.AmountOut
+	for i := last; i >= 0; i-- {
+		leg := route.Legs[i]
+		quote := leg
+		if i < last {
+			var err error
+			if quote, err = leg.Pool.Quote(leg.InputMint.Address, amount, false); err != nil {
+				return nil, 0, fmt.Errorf("hop %d: %w", i, err)
+			}
+		}
+		threshold := uint64(float64(quote.AmountIn) * (1 + hopSlippage))
+		var err error
+		if instructions[i], err = leg.Instruction(owner, accounts[i], accounts[i+1], false, amount, threshold); err != nil {
+			return nil, 0, err
+		}
+		amount = threshold
+	}
+	return instructions, amount, nil
+}
+
+// BuildRouteTransaction builds one atomic transaction swapping along route. The owner's ATAs of every
+// mint on the route are created when missing and SOL goes through the WSOL account picked by
+// opts.WSOLPolicy.
+func BuildRouteTransaction(ctx context.Context, client *rpc.Client, route Route, slippage float64, owner solana.PublicKey, opts txn.SwapOptions) (*solana.Transaction, error) {
+	instructions, err := routeTransactionInstructions(ctx, client, route, slippage, owner, opts)
+	if err != nil {
+		return nil, err
+	}
+	return opts.Transaction(ctx, client, routeKey(route.Legs, route.BaseIn, len(instructions)), instructions, owner, writableAccounts(route.Legs), routeComputeUnitLimit(len(route.Legs)), priorityFee)
+}
+
+func routeTransactionInstructions(ctx context.Context, client *rpc.Client, route Route, slippage float64, owner solana.PublicKey, opts txn.SwapOptions) ([]solana.Instruction, error) {
+	mints := []spl.Mint{route.InputMint()}
+	for _, leg := range route.Legs {
+		mints = append(mints, leg.OutputMint)
+	}
+	var setup, cleanup []solana.Instruction
+	addresses := make([]solana.PublicKey, len(mints))
+	for i, mint := range mints {
+		account, err := spl.TokenAccountInstructions(ctx, client, opts.StateCommitment(), owner, owner, mint, 0, opts.WSOLPolicy)
+		if err != nil {
+			return nil, err
+		}
+		addresses[i] = account.Address
+		setup = append(setup, account.Setup...)
+		cleanup = append(cleanup, account.Cleanup...)
+	}
+	swaps, maxAmountIn, err := RouteInstructions(route, addresses, slippage, owner)
+	if err != nil {
+		return nil, err
+	}
+	// exact output 的最大输入要等报价后才知道，SOL 在账户创建后再转入
+	if mints[0].Address.Equals(spl.NativeMint) {
+		wrap, err := spl.WrapInstructions(owner, addresses[0], maxAmountIn)
+		if err != nil {
+			return nil, err
+		}
+		setup = append(setup, wrap...)
+	}
+	instructions := append(setup, swaps...)
+	return append(instructions, cleanup...), nil
+}
+
+func routeComputeUnitLimit(hops int) uint32 {
+	return min(computeUnitsPerHop*uint32(hops), maxComputeUnits)
+}
+
+// routeKey 以经过的池子、方向和指令数作为 compute unit 的缓存 key
+func routeKey(legs []Leg, baseIn bool, instructions int) string {
+	var pools []string
+	for _, leg := range legs {
+		pools = append(pools, leg.Pool.Key().Address.String())
+	}
+	return fmt.Sprintf("%s:%t/%d", strings.Join(pools, ">"), baseIn, instructions)
+}
+
+func writableAccounts(legs []Leg) []solana.PublicKey {
+	var accounts []solana.PublicKey
+	for _, leg := range legs {
+		accounts = append(accounts, leg.Pool.WritableAccounts()...)
+	}
+	return accounts
+}
+
+// BuildSwapTransaction loads the pools of the registry, finds the best route of at most maxHops hops
+// and builds its transaction.
+func BuildSwapTransaction(ctx context.Context, client *rpc.Client, network string, registry []PoolKey, maxHops int, inputMint solana.PublicKey, outputMint solana.PublicKey, amountSpecified uint64, baseIn bool, slippage float64, owner solana.PublicKey, opts txn.SwapOptions) (*solana.Transaction, Route, error) {
+	pools, err := LoadPools(ctx, client, network, registry, opts.StateCommitment())
+	if err != nil {
+		return nil, Route{}, err
+	}
+	route, err := NewRouter(pools, maxHops).FindRoute(inputMint, outputMint, amountSpecified, baseIn)
+	if err != nil {
+		return nil, Route{}, err
+	}
+	tx, err := BuildRouteTransaction(ctx, client, route, slippage, owner, opts)
+	return tx, route, err
+}
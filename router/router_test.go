@@ -0,0 +1,210 @@
+package router
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"raydium-go/config"
+	"raydium-go/cpmm"
+	"raydium-go/internal/rpctest"
+	"raydium-go/spl"
+	"raydium-go/txn"
+
+	"github.com/gagliardetto/solana-go"
+	"github.com/gagliardetto/solana-go/rpc"
+)
+
+func testMint() spl.Mint {
+	return spl.Mint{Address: solana.NewWallet().PublicKey(), Program: solana.TokenProgramID}
+}
+
+func testCPMMPool(mint0 spl.Mint, mint1 spl.Mint, reserve0 uint64, reserve1 uint64) Pool {
+	state := cpmm.PoolState{
+		Token0Mint:    mint0.Address,
+		Token1Mint:    mint1.Address,
+		Token0Vault:   solana.NewWallet().PublicKey(),
+		Token1Vault:   solana.NewWallet().PublicKey(),
+		Token0Program: mint0.Program,
+		Token1Program: mint1.Program,
+	}
+	return NewCPMMPool(config.Raydium_CPMM_Program["mainnet"], solana.NewWallet().PublicKey(), state, cpmm.AmmConfig{TradeFeeRate: 2500}, reserve0, reserve1, mint0, mint1, 0)
+}
+
+// missingAccounts 应答所有账户都不存在的 RPC
+func missingAccounts(t *testing.T) *rpc.Client {
+	server := rpctest.NewServer(t, func(method string, params []json.RawMessage) interface{} {
+		switch method {
+		case "getAccountInfo":
+			return map[string]interface{}{"context": map[string]interface{}{"slot": 1}, "value": nil}
+		case "getMinimumBalanceForRentExemption":
+			return 2039280
+		}
+		t.Errorf("unexpected method %s", method)
+		return nil
+	})
+	return rpc.New(server.URL)
+}
+
+func TestFindRoute(t *testing.T) {
+	a, sol, usdc, b := testMint(), testMint(), testMint(), testMint()
+	direct := testCPMMPool(a, b, 1000000, 1000000)
+	viaSOL := []Pool{testCPMMPool(a, sol, 1000000000, 1000000000), testCPMMPool(sol, b, 1000000000, 1000000000)}
+	viaUSDC := []Pool{testCPMMPool(a, usdc, 1000000000, 500000000), testCPMMPool(usdc, b, 1000000000, 1000000000)}
+	r := NewRouter(append(append([]Pool{direct}, viaSOL...), viaUSDC...), 2)
+
+	if paths := r.Paths(a.Address, b.Address); len(paths) != 3 {
+		t.Fatalf("paths = %d, want 3", len(paths))
+	}
+	route, err := r.FindRoute(a.Address, b.Address, 100000, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(route.Legs) != 2 || route.Legs[0].Pool != viaSOL[0] || route.Legs[1].OutputMint.Address != b.Address {
+		t.Errorf("expected the route via SOL, got %d legs", len(route.Legs))
+	}
+	if route.AmountOut < 99000 || route.AmountOut >= 100000 {
+		t.Errorf("amount out = %d", route.AmountOut)
+	}
+
+	exactOut, err := r.FindRoute(a.Address, b.Address, route.AmountOut, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if exactOut.Legs[0].Pool != viaSOL[0] || exactOut.AmountIn > 100002 || exactOut.AmountIn < 99998 {
+		t.Errorf("exact out amount in = %d", exactOut.AmountIn)
+	}
+
+	if _, err := NewRouter(viaSOL, 1).FindRoute(a.Address, b.Address, 1, true); !errors.Is(err, ErrNoRoute) {
+		t.Errorf("expected ErrNoRoute with one hop, got %v", err)
+	}
+}
+
+func TestRouteInstructions(t *testing.T) {
+	a, sol, b := testMint(), testMint(), testMint()
+	first, second := testCPMMPool(a, sol, 1000000000, 1000000000), testCPMMPool(sol, b, 1000000000, 1000000000)
+	r := NewRouter([]Pool{first, second}, 3)
+	// 第一个池子的价格在报价后变差约 0.4%
+	moved := testCPMMPool(a, sol, 1002000000, 998000000)
+	owner := solana.NewWallet().PublicKey()
+	for _, baseIn := range []bool{true, false} {
+		route, err := r.FindRoute(a.Address, b.Address, 100000, baseIn)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if route.Legs[0].AmountOut != route.Legs[1].AmountIn {
+			t.Fatalf("baseIn %v: legs are not chained: %d -> %d", baseIn, route.Legs[0].AmountOut, route.Legs[1].AmountIn)
+		}
+		// 记录每跳的 amount 和 threshold
+		type swap struct{ amount, threshold uint64 }
+		var swaps [2]swap
+		legs := append([]Leg(nil), route.Legs...)
+		for i := range legs {
+			legs[i].instruction = func(owner solana.PublicKey, inputAccount solana.PublicKey, outputAccount solana.PublicKey, legBaseIn bool, amount uint64, threshold uint64) (solana.Instruction, error) {
+				if legBaseIn != baseIn {
+					t.Errorf("hop %d: baseIn = %v", i, legBaseIn)
+				}
+				swaps[i] = swap{amount, threshold}
+				return nil, nil
+			}
+		}
+		recorded := route
+		recorded.Legs = legs
+		_, maxAmountIn, err := RouteInstructions(recorded, make([]solana.PublicKey, 3), 0.01, owner)
+		if err != nil {
+			t.Fatal(err)
+		}
+		// 按移动后的第一个池子执行，每跳都要满足自己的限制，整体不超过滑点
+		if baseIn {
+			hop0, err := moved.Quote(a.Address, swaps[0].amount, true)
+			if err != nil {
+				t.Fatal(err)
+			}
+			hop1, err := second.Quote(sol.Address, swaps[1].amount, true)
+			if err != nil {
+				t.Fatal(err)
+			}
+			switch {
+			case swaps[0].amount != route.AmountIn || maxAmountIn != route.AmountIn:
+				t.Errorf("amount in = %d, max amount in = %d", swaps[0].amount, maxAmountIn)
+			case hop0.AmountOut < swaps[0].threshold || swaps[1].amount > swaps[0].threshold:
+				t.Errorf("hop 0 delivers %d, min %d, hop 1 spends %d", hop0.AmountOut, swaps[0].threshold, swaps[1].amount)
+			case hop1.AmountOut < swaps[1].threshold:
+				t.Errorf("hop 1 delivers %d, min %d", hop1.AmountOut, swaps[1].threshold)
+			case swaps[1].threshold < uint64(float64(route.AmountOut)*0.99)-1:
+				t.Errorf("min amount out %d below the slippage of %d", swaps[1].threshold, route.AmountOut)
+			}
+		} else {
+			hop0, err := moved.Quote(a.Address, swaps[0].amount, false)
+			if err != nil {
+				t.Fatal(err)
+			}
+			hop1, err := second.Quote(sol.Address, swaps[1].amount, false)
+			if err != nil {
+				t.Fatal(err)
+			}
+			switch {
+			case swaps[1].amount != route.AmountOut || maxAmountIn != swaps[0].threshold:
+				t.Errorf("amount out = %d, max amount in = %d", swaps[1].amount, maxAmountIn)
+			case hop1.AmountIn > swaps[1].threshold || swaps[0].amount < swaps[1].threshold:
+				t.Errorf("hop 1 needs %d, max %d, hop 0 delivers %d", hop1.AmountIn, swaps[1].threshold, swaps[0].amount)
+			case hop0.AmountIn > swaps[0].threshold:
+				t.Errorf("hop 0 needs %d, max %d", hop0.AmountIn, swaps[0].threshold)
+			case maxAmountIn > uint64(float64(route.AmountIn)*1.01)+1:
+				t.Errorf("max amount in %d above the slippage of %d", maxAmountIn, route.AmountIn)
+			}
+		}
+		instructions, err := routeTransactionInstructions(context.Background(), missingAccounts(t), route, 0.01, owner, txn.SwapOptions{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		// 三个 ATA 各一条创建指令、两条 swap
+		if len(instructions) != 5 {
+			t.Errorf("baseIn %v: instructions = %d", baseIn, len(instructions))
+		}
+	}
+}
+
+func TestSplitAllocation(t *testing.T) {
+	a, b := testMint(), testMint()
+	small := testCPMMPool(a, b, 1000000000, 1000000000).(ConstantProductPool)
+	large := testCPMMPool(a, b, 3000000000, 3000000000).(ConstantProductPool)
+	expensive := testCPMMPool(a, b, 1000000000, 500000000).(ConstantProductPool)
+
+	allocation, err := SplitAllocation([]ConstantProductPool{small, large}, a.Address, 400000000)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 价格相同的池子按储备比例分配
+	if allocation[0]+allocation[1] != 400000000 || allocation[0] < 99999999 || allocation[0] > 100000001 {
+		t.Errorf("allocation = %v", allocation)
+	}
+	allocation, err = SplitAllocation([]ConstantProductPool{small, expensive}, a.Address, 1000000)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if allocation[0] != 1000000 || allocation[1] != 0 {
+		t.Errorf("small orders should stay in the cheaper pool, got %v", allocation)
+	}
+
+	r := NewRouter([]Pool{small, large, expensive}, 1)
+	split, err := r.FindSplit(a.Address, b.Address, 400000000)
+	if err != nil {
+		t.Fatal(err)
+	}
+	single, err := r.FindRoute(a.Address, b.Address, 400000000, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(split.Routes) < 2 || split.AmountOut <= single.AmountOut {
+		t.Errorf("split of %d routes gives %d, single route gives %d", len(split.Routes), split.AmountOut, single.AmountOut)
+	}
+	instructions, err := splitTransactionInstructions(context.Background(), missingAccounts(t), split, 0.01, solana.NewWallet().PublicKey(), txn.SwapOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(instructions) != 2+len(split.Routes) {
+		t.Errorf("instructions = %d", len(instructions))
+	}
+}
@@ -0,0 +1,173 @@
+package router
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sort"
+
+	"raydium-go/spl"
+	"raydium-go/txn"
+
+	"github.com/gagliardetto/solana-go"
+	"github.com/gagliardetto/solana-go/rpc"
+)
+
+// Split 为一笔 exact-in 订单在同一交易对的多个池子间的分配
+type Split struct {
+	// 每个使用到的池子一条单跳路由
+	Routes    []Route
+	AmountIn  uint64
+	AmountOut uint64
+}
+
+// SplitAllocation splits amountIn across constant-product pools so that their marginal prices after
+// the trade are equal, which maximises the total output. The result holds one amount per pool.
+//
+// With gamma = 1 - fee, a pool gives out(x) = Rout*gamma*x / (Rin + gamma*x) whose marginal price is
+// k / (Rin + gamma*x)^2 with k = Rout*gamma*Rin. Equalising it to lambda over the pools that receive
+// a share gives x = (sqrt(k/lambda) - Rin) / gamma, and summing to amountIn solves for lambda.
+func SplitAllocation(pools []ConstantProductPool, inputMint solana.PublicKey, amountIn uint64) ([]uint64, error) {
+	type curve struct {
+		index   int
+		rin     float64
+		gamma   float64
+		sqrtK   float64
+		initial float64
+	}
+	curves := make([]curve, 0, len(pools))
+	for i, pool := range pools {
+		reserveIn, reserveOut, fee, err := pool.Reserves(inputMint)
+		if err != nil {
+			return nil, err
+		}
+		if reserveIn == 0 || reserveOut == 0 {
+			continue
+		}
+		rin, rout, gamma := float64(reserveIn), float64(reserveOut), 1-fee
+		curves = append(curves, curve{index: i, rin: rin, gamma: gamma, sqrtK: math.Sqrt(rout * gamma * rin), initial: rout * gamma / rin})
+	}
+	if len(curves) == 0 {
+		return nil, ErrNoRoute
+	}
+	// 按初始边际价格从高到低加入，直到下一个池子的初始价格不高于均衡价格
+	sort.Slice(curves, func(i, j int) bool { return curves[i].initial > curves[j].initial })
+	var sumSqrtK, sumRin, sqrtLambda float64
+	active := 0
+	for active < len(curves) {
+		c := curves[active]
+		sumSqrtK += c.sqrtK / c.gamma
+		sumRin += c.rin / c.gamma
+		sqrtLambda = sumSqrtK / (float64(amountIn) + sumRin)
+		active++
+		// 均衡价格不低于下一个池子的初始边际价格时，后面的池子都分不到数量
+		if active < len(curves) && sqrtLambda*sqrtLambda >= curves[active].initial {
+			break
+		}
+	}
+
+	allocation := make([]uint64, len(pools))
+	var allocated uint64
+	for _, c := range curves[:active] {
+		x := (c.sqrtK/sqrtLambda - c.rin) / c.gamma
+		if x <= 0 {
+			continue
+		}
+		amount := uint64(x)
+		if allocated+amount > amountIn {
+			amount = amountIn - allocated
+		}
+		allocation[c.index] = amount
+		allocated += amount
+	}
+	// 取整剩下的零头给边际价格最高的池子
+	allocation[curves[0].index] += amountIn - allocated
+	return allocation, nil
+}
+
+// FindSplit splits an exact input across the parallel constant-product pools of the pair and returns
+// the split when it beats the best route found by FindRoute; otherwise the split holds that route.
+func (r *Router) FindSplit(inputMint solana.PublicKey, outputMint solana.PublicKey, amountIn uint64) (Split, error) {
+	var parallel []ConstantProductPool
+	for _, pool := range r.graph[inputMint] {
+		if !otherMint(pool, inputMint).Equals(outputMint) {
+			continue
+		}
+		if cp, ok := pool.(ConstantProductPool); ok {
+			parallel = append(parallel, cp)
+		}
+	}
+	best, bestErr := r.FindRoute(inputMint, outputMint, amountIn, true)
+	if len(parallel) < 2 {
+		if bestErr != nil {
+			return Split{}, bestErr
+		}
+		return Split{Routes: []Route{best}, AmountIn: best.AmountIn, AmountOut: best.AmountOut}, nil
+	}
+	allocation, err := SplitAllocation(parallel, inputMint, amountIn)
+	if err != nil {
+		return Split{}, err
+	}
+	split := Split{AmountIn: amountIn}
+	for i, amount := range allocation {
+		if amount == 0 {
+			continue
+		}
+		route, err := QuotePath([]Pool{parallel[i]}, inputMint, amount, true)
+		if err != nil {
+			return Split{}, fmt.Errorf("split of %d into %s: %w", amount, parallel[i].Key().Address, err)
+		}
+		split.Routes = append(split.Routes, route)
+		split.AmountOut += route.AmountOut
+	}
+	if bestErr == nil && best.AmountOut >= split.AmountOut {
+		return Split{Routes: []Route{best}, AmountIn: best.AmountIn, AmountOut: best.AmountOut}, nil
+	}
+	return split, nil
+}
+
+// BuildSplitTransaction builds one transaction executing every route of split from the owner's input
+// account into the owner's output account, each with its own slippage bound.
+func BuildSplitTransaction(ctx context.Context, client *rpc.Client, split Split, slippage float64, owner solana.PublicKey, opts txn.SwapOptions) (*solana.Transaction, error) {
+	instructions, err := splitTransactionInstructions(ctx, client, split, slippage, owner, opts)
+	if err != nil {
+		return nil, err
+	}
+	var legs []Leg
+	for _, route := range split.Routes {
+		legs = append(legs, route.Legs...)
+	}
+	return opts.Transaction(ctx, client, routeKey(legs, true, len(instructions)), instructions, owner, writableAccounts(legs), routeComputeUnitLimit(len(legs)), priorityFee)
+}
+
+func splitTransactionInstructions(ctx context.Context, client *rpc.Client, split Split, slippage float64, owner solana.PublicKey, opts txn.SwapOptions) ([]solana.Instruction, error) {
+	if len(split.Routes) == 1 {
+		return routeTransactionInstructions(ctx, client, split.Routes[0], slippage, owner, opts)
+	}
+	if len(split.Routes) == 0 {
+		return nil, ErrNoRoute
+	}
+	inputMint, outputMint := split.Routes[0].InputMint(), split.Routes[0].OutputMint()
+	inputAccount, err := spl.TokenAccountInstructions(ctx, client, opts.StateCommitment(), owner, owner, inputMint, split.AmountIn, opts.WSOLPolicy)
+	if err != nil {
+		return nil, err
+	}
+	outputAccount, err := spl.TokenAccountInstructions(ctx, client, opts.StateCommitment(), owner, owner, outputMint, 0, opts.WSOLPolicy)
+	if err != nil {
+		return nil, err
+	}
+	var swaps []solana.Instruction
+	for _, route := range split.Routes {
+		routeSwaps, _, err := RouteInstructions(route, []solana.PublicKey{inputAccount.Address, outputAccount.Address}, slippage, owner)
+		if err != nil {
+			return nil, err
+		}
+		swaps = append(swaps, routeSwaps...)
+	}
+	var instructions []solana.Instruction
+	instructions = append(instructions, inputAccount.Setup...)
+	instructions = append(instructions, outputAccount.Setup...)
+	instructions = append(instructions, swaps...)
+	instructions = append(instructions, inputAccount.Cleanup...)
+	return append(instructions, outputAccount.Cleanup...), nil
+}
@@ -0,0 +1,132 @@
+package rpcpool
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gagliardetto/solana-go/rpc"
+	"github.com/gagliardetto/solana-go/rpc/jsonrpc"
+	"golang.org/x/time/rate"
+)
+
+// Strategy 决定读请求选择端点的方式
+type Strategy int
+
+const (
+	// RoundRobin 依次轮换健康的端点（默认）
+	RoundRobin Strategy = iota
+	// Healthiest 选择健康端点中最近延迟最低的
+	Healthiest
+)
+
+func (s Strategy) String() string {
+	if s == Healthiest {
+		return "healthiest"
+	}
+	return "round_robin"
+}
+
+func (s *Strategy) UnmarshalText(text []byte) error {
+	switch strings.ToLower(string(text)) {
+	case "", "round_robin", "round-robin":
+		*s = RoundRobin
+	case "healthiest":
+		*s = Healthiest
+	default:
+		return fmt.Errorf("unknown strategy %q", text)
+	}
+	return nil
+}
+
+// Endpoint 为一个 RPC 端点的设置
+type Endpoint struct {
+	URL     string            `yaml:"url" toml:"url"`
+	Headers map[string]string `yaml:"headers" toml:"headers"`
+	// 令牌桶每秒补充的请求数，0 表示不限
+	RateLimit float64 `yaml:"rate_limit" toml:"rate_limit"`
+	// 令牌桶容量，0 时取 RateLimit 向上取整
+	Burst int `yaml:"burst" toml:"burst"`
+}
+
+// endpoint 为端点的客户端、限流器和健康状态
+type endpoint struct {
+	url     string
+	client  rpc.JSONRPCClient
+	limiter *rate.Limiter
+
+	mu        sync.Mutex
+	failures  int
+	downUntil time.Time
+	// 成功请求延迟的指数移动平均
+	latency time.Duration
+}
+
+func newEndpoint(e Endpoint) *endpoint {
+	res := &endpoint{
+		url:    e.URL,
+		client: jsonrpc.NewClientWithOpts(e.URL, &jsonrpc.RPCClientOpts{CustomHeaders: e.Headers}),
+	}
+	if e.RateLimit > 0 {
+		burst := e.Burst
+		if burst <= 0 {
+			burst = int(e.RateLimit)
+			if float64(burst) < e.RateLimit {
+				burst++
+			}
+		}
+		res.limiter = rate.NewLimiter(rate.Limit(e.RateLimit), burst)
+	}
+	return res
+}
+
+// wait 等待令牌桶放行
+func (e *endpoint) wait(ctx context.Context) error {
+	if e.limiter == nil {
+		return nil
+	}
+	return e.limiter.Wait(ctx)
+}
+
+func (e *endpoint) healthy(now time.Time) bool {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return !now.Before(e.downUntil)
+}
+
+func (e *endpoint) state() (time.Time, time.Duration) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.downUntil, e.latency
+}
+
+// report 记录一次请求的结果。可重试的错误让端点暂停 cooldown，连续失败时按倍数增加到 maxCooldown
+func (e *endpoint) report(err error, elapsed time.Duration, cooldown time.Duration, maxCooldown time.Duration) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if Retryable(err) {
+		e.failures++
+		d := cooldown
+		for i := 1; i < e.failures && d < maxCooldown; i++ {
+			d *= 2
+		}
+		if d > maxCooldown {
+			d = maxCooldown
+		}
+		e.downUntil = time.Now().Add(d)
+		return
+	}
+	if err != nil && !isResponse(err) {
+		// 取消和超时不代表端点的状态
+		return
+	}
+	e.failures = 0
+	e.downUntil = time.Time{}
+	if e.latency == 0 {
+		e.latency = elapsed
+	} else {
+		e.latency = (4*e.latency + elapsed) / 5
+	}
+}
@@ -0,0 +1,373 @@
+package rpcpool
+
+import (
+	"context"
+	stdjson "encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"net"
+	"net/http"
+	"sync/atomic"
+	"time"
+
+	"github.com/gagliardetto/solana-go/rpc"
+	"github.com/gagliardetto/solana-go/rpc/jsonrpc"
+)
+
+var (
+	// 广播到所有端点的方法
+	broadcastMethods = map[string]bool{
+		"sendTransaction": true,
+	}
+	// 不能重试或对冲的方法
+	unsafeMethods = map[string]bool{
+		"requestAirdrop": true,
+	}
+
+	ErrNoEndpoints = errors.New("no rpc endpoints")
+)
+
+// Options 为端点池的重试、对冲和健康检查设置，零值字段使用默认值
+type Options struct {
+	Strategy Strategy `yaml:"strategy" toml:"strategy"`
+	// 读请求遇到 429、5xx 或网络错误时的最大重试次数，默认 3，小于 0 时不重试
+	MaxRetries int `yaml:"max_retries" toml:"max_retries"`
+	// 重试退避的初始值和上限，默认 200ms 和 2s
+	Backoff    time.Duration `yaml:"backoff" toml:"backoff"`
+	MaxBackoff time.Duration `yaml:"max_backoff" toml:"max_backoff"`
+	// 大于 0 时读请求超过该时间未返回就向另一个端点发出同样的请求，取先成功的结果
+	HedgeDelay time.Duration `yaml:"hedge_delay" toml:"hedge_delay"`
+	// 端点出错后暂停使用的时间，连续出错时加倍，默认 1s，上限 30s
+	Cooldown    time.Duration `yaml:"cooldown" toml:"cooldown"`
+	MaxCooldown time.Duration `yaml:"max_cooldown" toml:"max_cooldown"`
+	// 设置后每次对端点的请求结束时调用，用于记录指标，method 在批量请求时为 "batch"
+	Observe func(endpoint string, method string, elapsed time.Duration, err error) `yaml:"-" toml:"-"`
+}
+
+func (o Options) withDefaults() Options {
+	if o.MaxRetries == 0 {
+		o.MaxRetries = 3
+	}
+	if o.MaxRetries < 0 {
+		o.MaxRetries = 0
+	}
+	if o.Backoff <= 0 {
+		o.Backoff = 200 * time.Millisecond
+	}
+	if o.MaxBackoff <= 0 {
+		o.MaxBackoff = 2 * time.Second
+	}
+	if o.Cooldown <= 0 {
+		o.Cooldown = time.Second
+	}
+	if o.MaxCooldown <= 0 {
+		o.MaxCooldown = 30 * time.Second
+	}
+	return o
+}
+
+// Pool is a JSON-RPC client spreading calls over several endpoints. Reads fail over and retry on
+// 429, 5xx and network errors and can be hedged; sendTransaction is broadcast to every endpoint.
+type Pool struct {
+	opts      Options
+	endpoints []*endpoint
+	next      atomic.Uint64
+}
+
+var _ rpc.JSONRPCClient = (*Pool)(nil)
+
+func New(endpoints []Endpoint, opts Options) (*Pool, error) {
+	if len(endpoints) == 0 {
+		return nil, ErrNoEndpoints
+	}
+	p := &Pool{opts: opts.withDefaults()}
+	for _, e := range endpoints {
+		if e.URL == "" {
+			return nil, errors.New("rpc endpoint without url")
+		}
+		p.endpoints = append(p.endpoints, newEndpoint(e))
+	}
+	return p, nil
+}
+
+// NewClient returns an *rpc.Client backed by a pool of endpoints, usable wherever a single-endpoint
+// client is.
+func NewClient(endpoints []Endpoint, opts Options) (*rpc.Client, error) {
+	p, err := New(endpoints, opts)
+	if err != nil {
+		return nil, err
+	}
+	return rpc.NewWithCustomRPCClient(p), nil
+}
+
+// NewClientFromURLs is NewClient for endpoints without headers or rate limits.
+func NewClientFromURLs(urls []string, opts Options) (*rpc.Client, error) {
+	endpoints := make([]Endpoint, len(urls))
+	for i, url := range urls {
+		endpoints[i] = Endpoint{URL: url}
+	}
+	return NewClient(endpoints, opts)
+}
+
+// Retryable reports whether err is a rate limit, server or network error worth retrying elsewhere.
+func Retryable(err error) bool {
+	if err == nil || errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+	var httpErr *jsonrpc.HTTPError
+	if errors.As(err, &httpErr) {
+		return httpErr.Code == http.StatusTooManyRequests || httpErr.Code >= 500
+	}
+	var rpcErr *jsonrpc.RPCError
+	if errors.As(err, &rpcErr) {
+		// -32005 为节点不健康
+		return rpcErr.Code == http.StatusTooManyRequests || rpcErr.Code == -32005
+	}
+	var netErr net.Error
+	return errors.As(err, &netErr)
+}
+
+// isResponse 判断错误是否来自端点的 JSON-RPC 响应
+func isResponse(err error) bool {
+	var rpcErr *jsonrpc.RPCError
+	return errors.As(err, &rpcErr)
+}
+
+// pick 选择一个未尝试过的健康端点；都不健康时选最早恢复的
+func (p *Pool) pick(tried map[*endpoint]bool) *endpoint {
+	candidates := make([]*endpoint, 0, len(p.endpoints))
+	for _, e := range p.endpoints {
+		if !tried[e] {
+			candidates = append(candidates, e)
+		}
+	}
+	if len(candidates) == 0 {
+		candidates = p.endpoints
+	}
+	now := time.Now()
+	var best *endpoint
+	var bestLatency time.Duration
+	switch p.opts.Strategy {
+	case Healthiest:
+		for _, e := range candidates {
+			downUntil, latency := e.state()
+			if now.Before(downUntil) {
+				continue
+			}
+			if best == nil || latency < bestLatency {
+				best, bestLatency = e, latency
+			}
+		}
+	default:
+		start := int(p.next.Add(1) - 1)
+		for i := range candidates {
+			e := candidates[(start+i)%len(candidates)]
+			if e.healthy(now) {
+				best = e
+				break
+			}
+		}
+	}
+	if best != nil {
+		return best
+	}
+	var earliest time.Time
+	for _, e := range candidates {
+		downUntil, _ := e.state()
+		if best == nil || downUntil.Before(earliest) {
+			best, earliest = e, downUntil
+		}
+	}
+	return best
+}
+
+type call func(ctx context.Context, e *endpoint) (interface{}, error)
+
+// call 在端点上执行一次请求并记录结果
+func (p *Pool) call(ctx context.Context, e *endpoint, method string, fn call) (interface{}, error) {
+	if err := e.wait(ctx); err != nil {
+		return nil, err
+	}
+	start := time.Now()
+	res, err := fn(ctx, e)
+	elapsed := time.Since(start)
+	e.report(err, elapsed, p.opts.Cooldown, p.opts.MaxCooldown)
+	if p.opts.Observe != nil {
+		p.opts.Observe(e.url, method, elapsed, err)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", e.url, err)
+	}
+	return res, nil
+}
+
+// do 执行请求，retry 时对可重试的错误换端点退避重试，hedge 时按 HedgeDelay 对冲
+func (p *Pool) do(ctx context.Context, method string, fn call, retry bool, hedge bool) (interface{}, error) {
+	tried := make(map[*endpoint]bool)
+	for attempt := 0; ; attempt++ {
+		e := p.pick(tried)
+		tried[e] = true
+		var res interface{}
+		var err error
+		if hedge && p.opts.HedgeDelay > 0 && len(p.endpoints) > 1 {
+			res, err = p.hedge(ctx, e, tried, method, fn)
+		} else {
+			res, err = p.call(ctx, e, method, fn)
+		}
+		if err == nil || !retry || !Retryable(err) || attempt >= p.opts.MaxRetries {
+			return res, err
+		}
+		if err := sleep(ctx, p.backoff(attempt)); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// hedge 先向 primary 发出请求，HedgeDelay 后仍未返回时再向另一个端点发出，返回先成功的结果
+func (p *Pool) hedge(ctx context.Context, primary *endpoint, tried map[*endpoint]bool, method string, fn call) (interface{}, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	type result struct {
+		res interface{}
+		err error
+	}
+	results := make(chan result, 2)
+	run := func(e *endpoint) {
+		res, err := p.call(ctx, e, method, fn)
+		results <- result{res, err}
+	}
+	go run(primary)
+	timer := time.NewTimer(p.opts.HedgeDelay)
+	defer timer.Stop()
+	pending := 1
+	var firstErr error
+	for {
+		select {
+		case <-timer.C:
+			second := p.pick(tried)
+			if second == primary {
+				continue
+			}
+			tried[second] = true
+			pending++
+			go run(second)
+		case r := <-results:
+			pending--
+			if r.err == nil {
+				return r.res, nil
+			}
+			if firstErr == nil {
+				firstErr = r.err
+			}
+			if pending == 0 {
+				return nil, firstErr
+			}
+		}
+	}
+}
+
+// broadcast 同时向所有端点发出请求，返回第一个成功的结果；全部失败时返回所有错误
+func (p *Pool) broadcast(ctx context.Context, method string, fn call) (interface{}, error) {
+	type result struct {
+		res interface{}
+		err error
+	}
+	results := make(chan result, len(p.endpoints))
+	for _, e := range p.endpoints {
+		go func(e *endpoint) {
+			res, err := p.call(ctx, e, method, fn)
+			results <- result{res, err}
+		}(e)
+	}
+	var errs []error
+	for range p.endpoints {
+		r := <-results
+		if r.err == nil {
+			return r.res, nil
+		}
+		errs = append(errs, r.err)
+	}
+	return nil, fmt.Errorf("broadcast to %d endpoints failed: %w", len(p.endpoints), errors.Join(errs...))
+}
+
+func (p *Pool) backoff(attempt int) time.Duration {
+	d := p.opts.Backoff
+	for i := 0; i < attempt && d < p.opts.MaxBackoff; i++ {
+		d *= 2
+	}
+	if d > p.opts.MaxBackoff {
+		d = p.opts.MaxBackoff
+	}
+	// 在 [d/2, d) 内随机抖动
+	return d/2 + time.Duration(rand.Int63n(int64(d/2)+1))
+}
+
+func sleep(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+func (p *Pool) CallForInto(ctx context.Context, out interface{}, method string, params []interface{}) error {
+	fn := func(ctx context.Context, e *endpoint) (interface{}, error) {
+		var raw stdjson.RawMessage
+		err := e.client.CallForInto(ctx, &raw, method, params)
+		return raw, err
+	}
+	var res interface{}
+	var err error
+	switch {
+	case broadcastMethods[method]:
+		res, err = p.broadcast(ctx, method, fn)
+	case unsafeMethods[method]:
+		res, err = p.do(ctx, method, fn, false, false)
+	default:
+		res, err = p.do(ctx, method, fn, true, true)
+	}
+	if err != nil {
+		return err
+	}
+	// 按 jsonrpc 客户端相同的方式解码
+	return (&jsonrpc.RPCResponse{Result: res.(stdjson.RawMessage)}).GetObject(out)
+}
+
+// CallWithCallback retries failed calls but never hedges them, since callback may have side effects.
+func (p *Pool) CallWithCallback(ctx context.Context, method string, params []interface{}, callback func(*http.Request, *http.Response) error) error {
+	_, err := p.do(ctx, method, func(ctx context.Context, e *endpoint) (interface{}, error) {
+		return nil, e.client.CallWithCallback(ctx, method, params, callback)
+	}, !broadcastMethods[method] && !unsafeMethods[method], false)
+	return err
+}
+
+func (p *Pool) CallBatch(ctx context.Context, requests jsonrpc.RPCRequests) (jsonrpc.RPCResponses, error) {
+	retry := true
+	for _, r := range requests {
+		if broadcastMethods[r.Method] || unsafeMethods[r.Method] {
+			retry = false
+		}
+	}
+	res, err := p.do(ctx, "batch", func(ctx context.Context, e *endpoint) (interface{}, error) {
+		return e.client.CallBatch(ctx, requests)
+	}, retry, false)
+	if err != nil {
+		return nil, err
+	}
+	return res.(jsonrpc.RPCResponses), nil
+}
+
+func (p *Pool) Close() error {
+	var errs []error
+	for _, e := range p.endpoints {
+		if c, ok := e.client.(io.Closer); ok {
+			errs = append(errs, c.Close())
+		}
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,153 @@
+package rpcpool
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"raydium-go/internal/rpctest"
+)
+
+func ok(result interface{}) func(string, []json.RawMessage) interface{} {
+	return func(string, []json.RawMessage) interface{} { return result }
+}
+
+func status(code int) func(string, []json.RawMessage) interface{} {
+	return func(string, []json.RawMessage) interface{} { return rpctest.Status(code) }
+}
+
+func TestFailover(t *testing.T) {
+	limited := rpctest.NewServer(t, status(http.StatusTooManyRequests))
+	healthy := rpctest.NewServer(t, ok(42))
+	client, err := NewClientFromURLs([]string{limited.URL, healthy.URL}, Options{Backoff: time.Millisecond})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 4; i++ {
+		slot, err := client.GetSlot(context.Background(), "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if slot != 42 {
+			t.Errorf("slot = %d", slot)
+		}
+	}
+	// 429 之后端点暂停使用，后续请求都去健康的端点
+	if limited.Hits() != 1 || healthy.Hits() != 4 {
+		t.Errorf("hits = %d, %d", limited.Hits(), healthy.Hits())
+	}
+
+	broken := rpctest.NewServer(t, status(http.StatusBadGateway))
+	client, _ = NewClientFromURLs([]string{broken.URL}, Options{MaxRetries: 2, Backoff: time.Millisecond})
+	if _, err := client.GetSlot(context.Background(), ""); err == nil || !Retryable(err) {
+		t.Errorf("expected a retryable error, got %v", err)
+	}
+	if broken.Hits() != 3 {
+		t.Errorf("broken hits = %d, want 3", broken.Hits())
+	}
+
+	invalid := rpctest.NewServer(t, ok(&rpctest.Error{Code: -32602, Message: "invalid params"}))
+	client, _ = NewClientFromURLs([]string{invalid.URL, healthy.URL}, Options{Backoff: time.Millisecond})
+	if _, err := client.GetSlot(context.Background(), ""); err == nil || Retryable(err) {
+		t.Errorf("expected a non-retryable error, got %v", err)
+	}
+}
+
+func TestBroadcast(t *testing.T) {
+	signature := "5VERv8NMvzbJMEkV8xnrLkEaWRtSz9CosKDYjCJjBRnbJLgp8uirBgmQpjKhoR4tjF3ZpRzrFmBV6UjKdiSZkQUW"
+	servers := []*rpctest.Server{
+		rpctest.NewServer(t, ok(signature)),
+		rpctest.NewServer(t, ok(signature)),
+		rpctest.NewServer(t, status(http.StatusServiceUnavailable)),
+	}
+	var urls []string
+	for _, s := range servers {
+		urls = append(urls, s.URL)
+	}
+	p, err := New([]Endpoint{{URL: urls[0]}, {URL: urls[1]}, {URL: urls[2]}}, Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var out string
+	if err := p.CallForInto(context.Background(), &out, "sendTransaction", []interface{}{"tx"}); err != nil {
+		t.Fatal(err)
+	}
+	if out != signature {
+		t.Errorf("signature = %s", out)
+	}
+	// 等待其他端点的请求完成
+	deadline := time.Now().Add(time.Second)
+	for time.Now().Before(deadline) && (servers[0].Hits() == 0 || servers[1].Hits() == 0 || servers[2].Hits() == 0) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	for i, s := range servers {
+		if s.Hits() != 1 {
+			t.Errorf("server %d hits = %d, want 1", i, s.Hits())
+		}
+	}
+}
+
+func TestHedge(t *testing.T) {
+	slow := rpctest.NewServer(t, func(string, []json.RawMessage) interface{} {
+		time.Sleep(500 * time.Millisecond)
+		return 1
+	})
+	fast := rpctest.NewServer(t, ok(2))
+	p, err := New([]Endpoint{{URL: slow.URL}, {URL: fast.URL}}, Options{HedgeDelay: 20 * time.Millisecond})
+	if err != nil {
+		t.Fatal(err)
+	}
+	start := time.Now()
+	var slot uint64
+	if err := p.CallForInto(context.Background(), &slot, "getSlot", nil); err != nil {
+		t.Fatal(err)
+	}
+	if slot != 2 || time.Since(start) > 300*time.Millisecond {
+		t.Errorf("slot %d after %s", slot, time.Since(start))
+	}
+}
+
+func TestRateLimit(t *testing.T) {
+	s := rpctest.NewServer(t, ok(1))
+	p, err := New([]Endpoint{{URL: s.URL, RateLimit: 20, Burst: 1}}, Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	start := time.Now()
+	var slot uint64
+	for i := 0; i < 4; i++ {
+		if err := p.CallForInto(context.Background(), &slot, "getSlot", nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// 令牌桶容量为 1 时后三次各等待 50ms
+	if elapsed := time.Since(start); elapsed < 140*time.Millisecond {
+		t.Errorf("4 calls at 20/s took %s", elapsed)
+	}
+	if _, err := New(nil, Options{}); err != ErrNoEndpoints {
+		t.Errorf("expected ErrNoEndpoints, got %v", err)
+	}
+}
+
+func TestObserve(t *testing.T) {
+	s := rpctest.NewServer(t, ok(7))
+	var calls []string
+	p, err := New([]Endpoint{{URL: s.URL}}, Options{Observe: func(endpoint string, method string, elapsed time.Duration, err error) {
+		if endpoint != s.URL || err != nil {
+			t.Errorf("observed %s, %v", endpoint, err)
+		}
+		calls = append(calls, method)
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var slot uint64
+	if err := p.CallForInto(context.Background(), &slot, "getSlot", nil); err != nil {
+		t.Fatal(err)
+	}
+	if len(calls) != 1 || calls[0] != "getSlot" {
+		t.Errorf("observed calls = %v", calls)
+	}
+}
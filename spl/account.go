@@ -0,0 +1,171 @@
+package spl
+
+import (
+	"context"
+	"errors"
+
+	"github.com/gagliardetto/solana-go"
+	"github.com/gagliardetto/solana-go/programs/system"
+	"github.com/gagliardetto/solana-go/programs/token"
+	"github.com/gagliardetto/solana-go/rpc"
+)
+
+var NativeMint = solana.MustPublicKeyFromBase58("So11111111111111111111111111111111111111112")
+
+// WSOLPolicy 决定交易中 WSOL 账户的处理方式
+type WSOLPolicy int
+
+const (
+	// WSOLTemporaryAccount 每次创建以随机 seed 派生的临时账户，交易结束时关闭（默认）
+	WSOLTemporaryAccount WSOLPolicy = iota
+	// WSOLWrapUnwrap 使用 owner 的 WSOL ATA，输入时充值，交易结束时关闭 ATA 取回全部 SOL
+	WSOLWrapUnwrap
+	// WSOLKeepWrapped 使用 owner 的 WSOL ATA，只补足差额，交易结束后保持开启
+	WSOLKeepWrapped
+)
+
+// AccountInstructions 为使用一个 token 账户所需的前置和后置指令
+type AccountInstructions struct {
+	Address solana.PublicKey
+	Setup   []solana.Instruction
+	Cleanup []solana.Instruction
+	// 新建账户需要预付的租金，由 payer 支付
+	Rent uint64
+	// 从 owner 转入并 wrap 的 SOL
+	Wrapped uint64
+}
+
+// TokenAccountInstructions returns the account owner trades mint through, with the rent of accounts
+// created here funded by payer. Other mints use the owner's ATA, created when missing and left open.
+// For the native mint policy picks the account, wrap lamports are moved in from owner and only a
+// temporary account or, with WSOLWrapUnwrap, the WSOL ATA is closed at the end. Closing returns
+// everything to owner, who then refunds the rent to a different payer.
+func TokenAccountInstructions(ctx context.Context, client *rpc.Client, commitment rpc.CommitmentType, owner solana.PublicKey, payer solana.PublicKey, mint Mint, wrap uint64, policy WSOLPolicy) (AccountInstructions, error) {
+	native := mint.Address.Equals(NativeMint)
+	if native && policy == WSOLTemporaryAccount {
+		return temporaryAccountInstructions(ctx, client, commitment, owner, payer, wrap)
+	}
+	res, balance, err := associatedTokenAccountInstructions(ctx, client, commitment, owner, payer, mint)
+	if err != nil || !native {
+		return res, err
+	}
+	if policy == WSOLKeepWrapped {
+		wrap -= min(wrap, balance)
+	}
+	if wrap > 0 {
+		wrapInsts, err := WrapInstructions(owner, res.Address, wrap)
+		if err != nil {
+			return res, err
+		}
+		res.Setup = append(res.Setup, wrapInsts...)
+		res.Wrapped = wrap
+	}
+	if policy == WSOLWrapUnwrap {
+		closeInsts, err := closeInstructions(res.Address, owner, payer, res.Rent)
+		if err != nil {
+			return res, err
+		}
+		res.Cleanup = append(res.Cleanup, closeInsts...)
+	}
+	return res, nil
+}
+
+// associatedTokenAccountInstructions creates the owner's ATA of mint when it is missing and returns
+// its token amount otherwise.
+func associatedTokenAccountInstructions(ctx context.Context, client *rpc.Client, commitment rpc.CommitmentType, owner solana.PublicKey, payer solana.PublicKey, mint Mint) (AccountInstructions, uint64, error) {
+	var res AccountInstructions
+	ata, _, err := FindAssociatedTokenAddress(owner, mint.Address, mint.Program)
+	if err != nil {
+		return res, 0, err
+	}
+	res.Address = ata
+	account, err := client.GetAccountInfoWithOpts(ctx, ata, &rpc.GetAccountInfoOpts{Encoding: solana.EncodingBase64, Commitment: commitment})
+	switch {
+	case err == nil:
+		amount, err := TokenAccountAmount(account.Value.Data.GetBinary())
+		return res, amount, err
+	case !errors.Is(err, rpc.ErrNotFound):
+		return res, 0, err
+	}
+	if res.Rent, err = client.GetMinimumBalanceForRentExemption(ctx, AccountSize(mint), commitment); err != nil {
+		return res, 0, err
+	}
+	create, err := NewCreateAssociatedTokenAccountInstruction(payer, owner, mint.Address, mint.Program)
+	if err != nil {
+		return res, 0, err
+	}
+	res.Setup = append(res.Setup, create)
+	return res, 0, nil
+}
+
+// temporaryAccountInstructions creates a WSOL account derived from owner and a random seed. When payer
+// is owner the account is funded with the rent and wrap lamports at once, otherwise payer only funds
+// the rent and owner transfers the wrap lamports.
+func temporaryAccountInstructions(ctx context.Context, client *rpc.Client, commitment rpc.CommitmentType, owner solana.PublicKey, payer solana.PublicKey, wrap uint64) (AccountInstructions, error) {
+	var res AccountInstructions
+	rent, err := client.GetMinimumBalanceForRentExemption(ctx, accountSize, commitment)
+	if err != nil {
+		return res, err
+	}
+	res.Rent, res.Wrapped = rent, wrap
+	lamports := rent
+	if payer.Equals(owner) {
+		lamports += wrap
+	}
+	seed := solana.NewWallet().PublicKey().String()[0:32]
+	account, err := solana.CreateWithSeed(owner, seed, solana.TokenProgramID)
+	if err != nil {
+		return res, err
+	}
+	res.Address = account
+	createInst, err := system.NewCreateAccountWithSeedInstruction(owner, seed, lamports, accountSize, solana.TokenProgramID, payer, account, owner).ValidateAndBuild()
+	if err != nil {
+		return res, err
+	}
+	initInst, err := token.NewInitializeAccountInstruction(account, NativeMint, owner, solana.SysVarRentPubkey).ValidateAndBuild()
+	if err != nil {
+		return res, err
+	}
+	res.Setup = []solana.Instruction{createInst, initInst}
+	if wrap > 0 && !payer.Equals(owner) {
+		wrapInsts, err := WrapInstructions(owner, account, wrap)
+		if err != nil {
+			return res, err
+		}
+		res.Setup = append(res.Setup, wrapInsts...)
+	}
+	if res.Cleanup, err = closeInstructions(account, owner, payer, rent); err != nil {
+		return res, err
+	}
+	return res, nil
+}
+
+// closeInstructions closes account to owner. When payer funded rent for the account in this
+// transaction, owner transfers it back so a sponsored owner does not keep the payer's rent.
+func closeInstructions(account solana.PublicKey, owner solana.PublicKey, payer solana.PublicKey, rent uint64) ([]solana.Instruction, error) {
+	closeInst, err := token.NewCloseAccountInstruction(account, owner, owner, []solana.PublicKey{}).ValidateAndBuild()
+	if err != nil {
+		return nil, err
+	}
+	if payer.Equals(owner) || rent == 0 {
+		return []solana.Instruction{closeInst}, nil
+	}
+	refundInst, err := system.NewTransferInstruction(rent, owner, payer).ValidateAndBuild()
+	if err != nil {
+		return nil, err
+	}
+	return []solana.Instruction{closeInst, refundInst}, nil
+}
+
+// WrapInstructions moves lamports from owner into a WSOL account and syncs its token amount.
+func WrapInstructions(owner solana.PublicKey, account solana.PublicKey, lamports uint64) ([]solana.Instruction, error) {
+	transferInst, err := system.NewTransferInstruction(lamports, owner, account).ValidateAndBuild()
+	if err != nil {
+		return nil, err
+	}
+	syncInst, err := token.NewSyncNativeInstruction(account).ValidateAndBuild()
+	if err != nil {
+		return nil, err
+	}
+	return []solana.Instruction{transferInst, syncInst}, nil
+}
@@ -0,0 +1,297 @@
+package spl
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math/big"
+
+	"github.com/gagliardetto/solana-go"
+	"github.com/gagliardetto/solana-go/rpc"
+)
+
+const (
+	mintSize          = 82
+	accountSize       = 165
+	accountTypeMint   = 1
+	feeBasisPointsMax = 10000
+
+	// Token-2022 扩展类型
+	ExtensionTransferFeeConfig uint16 = 1
+)
+
+var ErrUnsupportedTokenProgram = errors.New("mint is not owned by a token program")
+
+// TransferFee 对应 Token-2022 中的 TransferFee
+type TransferFee struct {
+	Epoch                  uint64
+	MaximumFee             uint64
+	TransferFeeBasisPoints uint16
+}
+
+// TransferFeeConfig 对应 Token-2022 中的 TransferFeeConfig 扩展
+type TransferFeeConfig struct {
+	TransferFeeConfigAuthority solana.PublicKey
+	WithdrawWithheldAuthority  solana.PublicKey
+	WithheldAmount             uint64
+	OlderTransferFee           TransferFee
+	NewerTransferFee           TransferFee
+}
+
+type Mint struct {
+	Address           solana.PublicKey
+	Program           solana.PublicKey
+	Supply            uint64
+	Decimals          uint8
+	TransferFeeConfig *TransferFeeConfig
+}
+
+// IsToken2022 reports whether the mint is owned by the Token-2022 program.
+func (m Mint) IsToken2022() bool {
+	return m.Program.Equals(solana.Token2022ProgramID)
+}
+
+// SwapAmounts 为 swap 报价中的数量，包含输入和输出 token 的 Token-2022 转账手续费
+type SwapAmounts struct {
+	// 用户转出的数量（含输入 token 的转账手续费）
+	AmountIn uint64
+	// 池子转出的数量（未扣除输出 token 的转账手续费）
+	AmountOut         uint64
+	InputTransferFee  uint64
+	OutputTransferFee uint64
+	// baseIn 时为实际到账的最小数量，baseOut 时为最大输入
+	OtherAmountThreshold uint64
+}
+
+// ReceivedAmount is what actually lands in the user's destination account.
+func (a SwapAmounts) ReceivedAmount() uint64 {
+	return a.AmountOut - a.OutputTransferFee
+}
+
+// SetThreshold applies slippage to the received amount of an exact input or to the amount in of an
+// exact output.
+func (a *SwapAmounts) SetThreshold(baseIn bool, slippage float64) {
+	if baseIn {
+		a.OtherAmountThreshold = uint64(float64(a.ReceivedAmount()) * float64(1-slippage))
+	} else {
+		a.OtherAmountThreshold = uint64(float64(a.AmountIn) * float64(1+slippage))
+	}
+}
+
+// MaxAmountIn is the most the user may spend.
+func (a SwapAmounts) MaxAmountIn(baseIn bool) uint64 {
+	if baseIn {
+		return a.AmountIn
+	}
+	return a.OtherAmountThreshold
+}
+
+// TransferFee returns the fee withheld when transferring amount in the given epoch.
+func (m Mint) TransferFee(epoch uint64, amount uint64) uint64 {
+	if m.TransferFeeConfig == nil {
+		return 0
+	}
+	return m.TransferFeeConfig.Fee(epoch, amount)
+}
+
+// InverseTransferFee returns the fee needed so that postFeeAmount arrives after the transfer.
+func (m Mint) InverseTransferFee(epoch uint64, postFeeAmount uint64) uint64 {
+	if m.TransferFeeConfig == nil {
+		return 0
+	}
+	return m.TransferFeeConfig.InverseFee(epoch, postFeeAmount)
+}
+
+func (c TransferFeeConfig) EpochFee(epoch uint64) TransferFee {
+	if epoch >= c.NewerTransferFee.Epoch {
+		return c.NewerTransferFee
+	}
+	return c.OlderTransferFee
+}
+
+func (c TransferFeeConfig) Fee(epoch uint64, amount uint64) uint64 {
+	fee := c.EpochFee(epoch)
+	if fee.TransferFeeBasisPoints == 0 || amount == 0 {
+		return 0
+	}
+	raw := new(big.Int).Mul(new(big.Int).SetUint64(amount), big.NewInt(int64(fee.TransferFeeBasisPoints)))
+	raw.Add(raw, big.NewInt(feeBasisPointsMax-1))
+	raw.Quo(raw, big.NewInt(feeBasisPointsMax))
+	if !raw.IsUint64() || raw.Uint64() > fee.MaximumFee {
+		return fee.MaximumFee
+	}
+	return raw.Uint64()
+}
+
+func (c TransferFeeConfig) InverseFee(epoch uint64, postFeeAmount uint64) uint64 {
+	fee := c.EpochFee(epoch)
+	if fee.TransferFeeBasisPoints == 0 || postFeeAmount == 0 {
+		return 0
+	}
+	if fee.TransferFeeBasisPoints == feeBasisPointsMax {
+		return fee.MaximumFee
+	}
+	denominator := big.NewInt(int64(feeBasisPointsMax - fee.TransferFeeBasisPoints))
+	raw := new(big.Int).Mul(new(big.Int).SetUint64(postFeeAmount), big.NewInt(feeBasisPointsMax))
+	raw.Add(raw, new(big.Int).Sub(denominator, big.NewInt(1)))
+	raw.Quo(raw, denominator)
+	raw.Sub(raw, new(big.Int).SetUint64(postFeeAmount))
+	if !raw.IsUint64() || raw.Uint64() > fee.MaximumFee {
+		return fee.MaximumFee
+	}
+	return raw.Uint64()
+}
+
+// AccountSize returns the size of a token account for mint as created by the ATA program.
+func AccountSize(mint Mint) uint64 {
+	if !mint.IsToken2022() {
+		return accountSize
+	}
+	// account type + ImmutableOwner 扩展
+	size := uint64(accountSize + 1 + 4)
+	if mint.TransferFeeConfig != nil {
+		// TransferFeeAmount 扩展
+		size += 4 + 8
+	}
+	return size
+}
+
+// IsTokenProgram reports whether program is the legacy SPL token program or Token-2022.
+func IsTokenProgram(program solana.PublicKey) bool {
+	return program.Equals(solana.TokenProgramID) || program.Equals(solana.Token2022ProgramID)
+}
+
+// TokenAccountAmount returns the amount held by a token account of either token program.
+func TokenAccountAmount(data []byte) (uint64, error) {
+	if len(data) < accountSize {
+		return 0, fmt.Errorf("invalid token account size %d", len(data))
+	}
+	return binary.LittleEndian.Uint64(data[64:72]), nil
+}
+
+// DecodeMint decodes a mint account owned by program, including Token-2022 extensions.
+func DecodeMint(address solana.PublicKey, program solana.PublicKey, data []byte) (Mint, error) {
+	mint := Mint{Address: address, Program: program}
+	if !IsTokenProgram(program) {
+		return mint, fmt.Errorf("%s: %w", address, ErrUnsupportedTokenProgram)
+	}
+	if len(data) < mintSize {
+		return mint, fmt.Errorf("invalid mint %s: data too short", address)
+	}
+	mint.Supply = binary.LittleEndian.Uint64(data[36:44])
+	mint.Decimals = data[44]
+	if len(data) <= accountSize {
+		return mint, nil
+	}
+	if data[accountSize] != accountTypeMint {
+		return mint, fmt.Errorf("invalid mint %s: unexpected account type %d", address, data[accountSize])
+	}
+	tlv := data[accountSize+1:]
+	for len(tlv) >= 4 {
+		extType := binary.LittleEndian.Uint16(tlv[0:2])
+		length := int(binary.LittleEndian.Uint16(tlv[2:4]))
+		if len(tlv) < 4+length {
+			return mint, fmt.Errorf("invalid mint %s: truncated extension %d", address, extType)
+		}
+		value := tlv[4 : 4+length]
+		if extType == ExtensionTransferFeeConfig {
+			config, err := decodeTransferFeeConfig(value)
+			if err != nil {
+				return mint, err
+			}
+			mint.TransferFeeConfig = &config
+		}
+		tlv = tlv[4+length:]
+	}
+	return mint, nil
+}
+
+func decodeTransferFeeConfig(data []byte) (TransferFeeConfig, error) {
+	var config TransferFeeConfig
+	if len(data) < 108 {
+		return config, errors.New("invalid transfer fee config: data too short")
+	}
+	copy(config.TransferFeeConfigAuthority[:], data[0:32])
+	copy(config.WithdrawWithheldAuthority[:], data[32:64])
+	config.WithheldAmount = binary.LittleEndian.Uint64(data[64:72])
+	config.OlderTransferFee = decodeTransferFee(data[72:90])
+	config.NewerTransferFee = decodeTransferFee(data[90:108])
+	return config, nil
+}
+
+func decodeTransferFee(data []byte) TransferFee {
+	return TransferFee{
+		Epoch:                  binary.LittleEndian.Uint64(data[0:8]),
+		MaximumFee:             binary.LittleEndian.Uint64(data[8:16]),
+		TransferFeeBasisPoints: binary.LittleEndian.Uint16(data[16:18]),
+	}
+}
+
+func GetMint(ctx context.Context, client *rpc.Client, mint solana.PublicKey) (Mint, error) {
+	mints, err := GetMints(ctx, client, mint)
+	if err != nil {
+		return Mint{}, err
+	}
+	return mints[0], nil
+}
+
+// GetMints fetches and decodes several mints with a single getMultipleAccounts call.
+func GetMints(ctx context.Context, client *rpc.Client, mints ...solana.PublicKey) ([]Mint, error) {
+	resp, err := client.GetMultipleAccounts(ctx, mints...)
+	if err != nil {
+		return nil, err
+	}
+	res := make([]Mint, len(mints))
+	for i, account := range resp.Value {
+		if account == nil {
+			return nil, fmt.Errorf("mint %s: %w", mints[i], rpc.ErrNotFound)
+		}
+		res[i], err = DecodeMint(mints[i], account.Owner, account.Data.GetBinary())
+		if err != nil {
+			return nil, err
+		}
+	}
+	return res, nil
+}
+
+// GetTokenProgram returns the token program that owns mint.
+func GetTokenProgram(ctx context.Context, client *rpc.Client, mint solana.PublicKey) (solana.PublicKey, error) {
+	account, err := client.GetAccountInfo(ctx, mint)
+	if err != nil {
+		return solana.PublicKey{}, err
+	}
+	if !IsTokenProgram(account.Value.Owner) {
+		return solana.PublicKey{}, fmt.Errorf("%s: %w", mint, ErrUnsupportedTokenProgram)
+	}
+	return account.Value.Owner, nil
+}
+
+// FindAssociatedTokenAddress derives the ATA of owner for mint under the given token program.
+func FindAssociatedTokenAddress(owner solana.PublicKey, mint solana.PublicKey, tokenProgram solana.PublicKey) (solana.PublicKey, uint8, error) {
+	return solana.FindProgramAddress([][]byte{
+		owner[:],
+		tokenProgram[:],
+		mint[:],
+	}, solana.SPLAssociatedTokenAccountProgramID)
+}
+
+// NewCreateAssociatedTokenAccountInstruction builds a CreateIdempotent instruction of the ATA program.
+func NewCreateAssociatedTokenAccountInstruction(payer solana.PublicKey, owner solana.PublicKey, mint solana.PublicKey, tokenProgram solana.PublicKey) (solana.Instruction, error) {
+	ata, _, err := FindAssociatedTokenAddress(owner, mint, tokenProgram)
+	if err != nil {
+		return nil, err
+	}
+	return solana.NewInstruction(
+		solana.SPLAssociatedTokenAccountProgramID,
+		[]*solana.AccountMeta{
+			solana.NewAccountMeta(payer, true, true),
+			solana.NewAccountMeta(ata, true, false),
+			solana.NewAccountMeta(owner, false, false),
+			solana.NewAccountMeta(mint, false, false),
+			solana.NewAccountMeta(solana.SystemProgramID, false, false),
+			solana.NewAccountMeta(tokenProgram, false, false),
+		},
+		[]byte{1},
+	), nil
+}
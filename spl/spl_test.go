@@ -0,0 +1,195 @@
+package spl
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"testing"
+
+	"raydium-go/internal/rpctest"
+
+	"github.com/gagliardetto/solana-go"
+	"github.com/gagliardetto/solana-go/programs/system"
+	"github.com/gagliardetto/solana-go/rpc"
+)
+
+func TestDecodeMint(t *testing.T) {
+	data := make([]byte, mintSize)
+	binary.LittleEndian.PutUint64(data[36:44], 1000000)
+	data[44] = 6
+	mint, err := DecodeMint(solana.NewWallet().PublicKey(), solana.TokenProgramID, data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if mint.Decimals != 6 || mint.Supply != 1000000 || mint.TransferFeeConfig != nil {
+		t.Errorf("unexpected mint: %+v", mint)
+	}
+}
+
+func TestDecodeMintTransferFee(t *testing.T) {
+	data := make([]byte, accountSize+1+4+108)
+	data[44] = 9
+	data[accountSize] = accountTypeMint
+	tlv := data[accountSize+1:]
+	binary.LittleEndian.PutUint16(tlv[0:2], ExtensionTransferFeeConfig)
+	binary.LittleEndian.PutUint16(tlv[2:4], 108)
+	fees := tlv[4:]
+	binary.LittleEndian.PutUint64(fees[72:80], 0)
+	binary.LittleEndian.PutUint64(fees[80:88], 5000)
+	binary.LittleEndian.PutUint16(fees[88:90], 100)
+	binary.LittleEndian.PutUint64(fees[90:98], 10)
+	binary.LittleEndian.PutUint64(fees[98:106], 1000)
+	binary.LittleEndian.PutUint16(fees[106:108], 50)
+	mint, err := DecodeMint(solana.NewWallet().PublicKey(), solana.Token2022ProgramID, data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !mint.IsToken2022() || mint.TransferFeeConfig == nil {
+		t.Fatalf("expected token-2022 mint with transfer fee, got %+v", mint)
+	}
+	if fee := mint.TransferFee(5, 10001); fee != 101 {
+		t.Errorf("older fee = %d, want 101", fee)
+	}
+	if fee := mint.TransferFee(10, 10001); fee != 51 {
+		t.Errorf("newer fee = %d, want 51", fee)
+	}
+	if fee := mint.TransferFee(10, 1000000000); fee != 1000 {
+		t.Errorf("capped fee = %d, want 1000", fee)
+	}
+	post := uint64(9950)
+	fee := mint.InverseTransferFee(10, post)
+	if got := post + fee - mint.TransferFee(10, post+fee); got < post {
+		t.Errorf("inverse fee %d leaves %d, want at least %d", fee, got, post)
+	}
+}
+
+func TestFindAssociatedTokenAddress(t *testing.T) {
+	owner := solana.NewWallet().PublicKey()
+	mint := solana.NewWallet().PublicKey()
+	legacy, _, err := FindAssociatedTokenAddress(owner, mint, solana.TokenProgramID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected, _, _ := solana.FindAssociatedTokenAddress(owner, mint)
+	if !legacy.Equals(expected) {
+		t.Errorf("legacy ata = %s, want %s", legacy, expected)
+	}
+	token2022, _, _ := FindAssociatedTokenAddress(owner, mint, solana.Token2022ProgramID)
+	if token2022.Equals(legacy) {
+		t.Error("token-2022 ata should differ from legacy ata")
+	}
+}
+
+// accountServer 应答 getAccountInfo，exists 为 false 时账户不存在
+func accountServer(t *testing.T, exists bool, balance uint64) *rpc.Client {
+	server := rpctest.NewServer(t, func(method string, params []json.RawMessage) interface{} {
+		switch method {
+		case "getAccountInfo":
+			var account interface{}
+			if exists {
+				data := make([]byte, accountSize)
+				binary.LittleEndian.PutUint64(data[64:72], balance)
+				account = map[string]interface{}{
+					"data":       []string{base64.StdEncoding.EncodeToString(data), "base64"},
+					"owner":      solana.TokenProgramID.String(),
+					"lamports":   2039280,
+					"executable": false,
+					"rentEpoch":  0,
+				}
+			}
+			return map[string]interface{}{"context": map[string]interface{}{"slot": 1}, "value": account}
+		case "getMinimumBalanceForRentExemption":
+			return 2039280
+		}
+		t.Errorf("unexpected method %s", method)
+		return nil
+	})
+	return rpc.New(server.URL)
+}
+
+func TestWSOLAccountInstructions(t *testing.T) {
+	owner, sponsor := solana.NewWallet().PublicKey(), solana.NewWallet().PublicKey()
+	native := Mint{Address: NativeMint, Program: solana.TokenProgramID}
+	ata, _, _ := FindAssociatedTokenAddress(owner, NativeMint, solana.TokenProgramID)
+	tests := []struct {
+		name    string
+		policy  WSOLPolicy
+		payer   solana.PublicKey
+		exists  bool
+		balance uint64
+		wrap    uint64
+		// 创建、转入和 sync 指令数
+		setup   int
+		wrapped uint64
+		rent    uint64
+		cleanup int
+	}{
+		{"unwrap missing input", WSOLWrapUnwrap, owner, false, 0, 1000000, 3, 1000000, 2039280, 1},
+		{"unwrap existing input", WSOLWrapUnwrap, owner, true, 5000000, 1000000, 2, 1000000, 0, 1},
+		{"unwrap missing output", WSOLWrapUnwrap, owner, false, 0, 0, 1, 0, 2039280, 1},
+		{"unwrap existing output", WSOLWrapUnwrap, owner, true, 5000000, 0, 0, 0, 0, 1},
+		{"unwrap sponsored missing input", WSOLWrapUnwrap, sponsor, false, 0, 1000000, 3, 1000000, 2039280, 2},
+		{"keep missing input", WSOLKeepWrapped, owner, false, 0, 1000000, 3, 1000000, 2039280, 0},
+		{"keep balance below", WSOLKeepWrapped, owner, true, 400000, 1000000, 2, 600000, 0, 0},
+		{"keep balance above", WSOLKeepWrapped, owner, true, 5000000, 1000000, 0, 0, 0, 0},
+		{"keep missing output", WSOLKeepWrapped, owner, false, 0, 0, 1, 0, 2039280, 0},
+		{"keep existing output", WSOLKeepWrapped, owner, true, 400000, 0, 0, 0, 0, 0},
+		// 临时账户一次注入租金和 SOL；sponsor 只付租金，SOL 由 owner 转入，关闭后退还租金
+		{"temporary input", WSOLTemporaryAccount, owner, false, 0, 1000000, 2, 1000000, 2039280, 1},
+		{"temporary sponsored input", WSOLTemporaryAccount, sponsor, false, 0, 1000000, 4, 1000000, 2039280, 2},
+		{"temporary output", WSOLTemporaryAccount, owner, false, 0, 0, 2, 0, 2039280, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res, err := TokenAccountInstructions(context.Background(), accountServer(t, tt.exists, tt.balance), rpc.CommitmentConfirmed, owner, tt.payer, native, tt.wrap, tt.policy)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if temporary := tt.policy == WSOLTemporaryAccount; temporary == res.Address.Equals(ata) {
+				t.Errorf("address = %s, WSOL ATA %s", res.Address, ata)
+			}
+			if len(res.Setup) != tt.setup || res.Wrapped != tt.wrapped || res.Rent != tt.rent || len(res.Cleanup) != tt.cleanup {
+				t.Fatalf("setup = %d, wrapped = %d, rent = %d, cleanup = %d", len(res.Setup), res.Wrapped, res.Rent, len(res.Cleanup))
+			}
+			if tt.policy != WSOLTemporaryAccount && !tt.exists && !res.Setup[0].ProgramID().Equals(solana.SPLAssociatedTokenAccountProgramID) {
+				t.Errorf("missing ATA is not created first")
+			}
+			// 只有 owner 自付的临时账户在创建时直接注入 SOL，其余以 transfer 和 sync 转入
+			if tt.wrapped > 0 && (tt.policy != WSOLTemporaryAccount || !tt.payer.Equals(owner)) {
+				transfer := res.Setup[len(res.Setup)-2]
+				data, _ := transfer.Data()
+				inst, err := system.DecodeInstruction(transfer.Accounts(), data)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if lamports := *inst.Impl.(*system.Transfer).Lamports; lamports != tt.wrapped {
+					t.Errorf("transferred %d lamports, want %d", lamports, tt.wrapped)
+				}
+			}
+			if tt.cleanup == 2 {
+				data, _ := res.Cleanup[1].Data()
+				inst, err := system.DecodeInstruction(res.Cleanup[1].Accounts(), data)
+				if err != nil {
+					t.Fatal(err)
+				}
+				refund := inst.Impl.(*system.Transfer)
+				if *refund.Lamports != tt.rent || !refund.GetRecipientAccount().PublicKey.Equals(sponsor) {
+					t.Errorf("refund of %d lamports to %s", *refund.Lamports, refund.GetRecipientAccount().PublicKey)
+				}
+			}
+		})
+	}
+
+	// 其他 mint 只在 ATA 不存在时创建，从不关闭
+	mint := Mint{Address: solana.NewWallet().PublicKey(), Program: solana.TokenProgramID}
+	for _, exists := range []bool{false, true} {
+		res, err := TokenAccountInstructions(context.Background(), accountServer(t, exists, 1), rpc.CommitmentConfirmed, owner, owner, mint, 1000000, WSOLWrapUnwrap)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if exists && len(res.Setup) != 0 || !exists && len(res.Setup) != 1 || len(res.Cleanup) != 0 || res.Wrapped != 0 {
+			t.Errorf("exists %v: setup = %d, cleanup = %d, wrapped = %d", exists, len(res.Setup), len(res.Cleanup), res.Wrapped)
+		}
+	}
+}
@@ -0,0 +1,144 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+
+	"raydium-go/amm"
+
+	"github.com/gagliardetto/solana-go"
+)
+
+// swapFlags 为 swap 和 quote 共用的参数
+type swapFlags struct {
+	pool    string
+	input   string
+	amount  uint64
+	baseOut bool
+	verbose bool
+}
+
+func parseSwapFlags(name string, args []string) (*options, *swapFlags, error) {
+	opts, swap := new(options), new(swapFlags)
+	fs := newFlagSet(name, opts)
+	fs.StringVar(&swap.pool, "pool", "", "AMM pool address")
+	fs.StringVar(&swap.input, "input", "", "input mint")
+	fs.Uint64Var(&swap.amount, "amount", 0, "input amount, or output amount with --base-out")
+	fs.BoolVar(&swap.baseOut, "base-out", false, "treat --amount as the exact output")
+	fs.BoolVar(&swap.verbose, "verbose", false, "log each stage of the swap")
+	positional, err := opts.parse(fs, args)
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(positional) != 0 || swap.pool == "" || swap.input == "" || swap.amount == 0 {
+		return nil, nil, fmt.Errorf("%s needs --pool, --input and --amount: %w", name, errUsage)
+	}
+	if _, err := opts.profile.AMMProgram(); err != nil {
+		return nil, nil, err
+	}
+	return opts, swap, nil
+}
+
+type quoteOutput struct {
+	Pool                 string `json:"pool"`
+	InputMint            string `json:"inputMint"`
+	OutputMint           string `json:"outputMint"`
+	BaseIn               bool   `json:"baseIn"`
+	Direction            string `json:"direction"`
+	AmountIn             uint64 `json:"amountIn"`
+	AmountOut            uint64 `json:"amountOut"`
+	ReceivedAmount       uint64 `json:"receivedAmount"`
+	InputTransferFee     uint64 `json:"inputTransferFee"`
+	OutputTransferFee    uint64 `json:"outputTransferFee"`
+	OtherAmountThreshold uint64 `json:"otherAmountThreshold"`
+}
+
+func runQuote(ctx context.Context, args []string) error {
+	opts, swap, err := parseSwapFlags("quote", args)
+	if err != nil {
+		return err
+	}
+	pool, err := solana.PublicKeyFromBase58(swap.pool)
+	if err != nil {
+		return fmt.Errorf("invalid pool address: %w", err)
+	}
+	inputMint, err := solana.PublicKeyFromBase58(swap.input)
+	if err != nil {
+		return fmt.Errorf("invalid input mint: %w", err)
+	}
+	client, err := opts.client()
+	if err != nil {
+		return err
+	}
+	poolState, err := amm.GetPoolState(ctx, client, pool, amm.Commitment{})
+	if err != nil {
+		return err
+	}
+	quote, err := amm.GetSwapQuote(ctx, client, poolState, inputMint, swap.amount, !swap.baseOut, opts.profile.Slippage(), amm.Commitment{})
+	if err != nil {
+		return err
+	}
+	outputMint := poolState.PcVaultMint
+	if quote.Direction == amm.PC2Coin {
+		outputMint = poolState.CoinVaultMint
+	}
+	out := quoteOutput{
+		Pool:                 pool.String(),
+		InputMint:            inputMint.String(),
+		OutputMint:           outputMint.String(),
+		BaseIn:               !swap.baseOut,
+		Direction:            string(quote.Direction),
+		AmountIn:             quote.AmountIn,
+		AmountOut:            quote.AmountOut,
+		ReceivedAmount:       quote.ReceivedAmount(),
+		InputTransferFee:     quote.InputTransferFee,
+		OutputTransferFee:    quote.OutputTransferFee,
+		OtherAmountThreshold: quote.OtherAmountThreshold,
+	}
+	return opts.output(out, func() {
+		field("pool", out.Pool)
+		field("input mint", out.InputMint)
+		field("output mint", out.OutputMint)
+		field("direction", out.Direction)
+		field("amount in", out.AmountIn)
+		field("amount out", out.AmountOut)
+		field("received", out.ReceivedAmount)
+		if out.InputTransferFee != 0 || out.OutputTransferFee != 0 {
+			field("input transfer fee", out.InputTransferFee)
+			field("output transfer fee", out.OutputTransferFee)
+		}
+		if out.BaseIn {
+			field("minimum out", out.OtherAmountThreshold)
+		} else {
+			field("maximum in", out.OtherAmountThreshold)
+		}
+	})
+}
+
+func runSwap(ctx context.Context, args []string) error {
+	opts, swap, err := parseSwapFlags("swap", args)
+	if err != nil {
+		return err
+	}
+	privateKey, err := opts.profile.PrivateKey()
+	if err != nil {
+		return err
+	}
+	client, err := opts.client()
+	if err != nil {
+		return err
+	}
+	swapOpts := amm.ProfileOptions(opts.profile)
+	if swap.verbose {
+		swapOpts.Logger = slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: slog.LevelDebug}))
+	}
+	signature, err := amm.SwapWithOptions(ctx, client, opts.profile.Network, swap.pool, swap.input, swap.amount, !swap.baseOut, opts.profile.Slippage(), privateKey.String(), swapOpts)
+	if err != nil {
+		return err
+	}
+	return opts.output(map[string]string{"signature": signature}, func() {
+		field("signature", signature)
+	})
+}
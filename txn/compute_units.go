@@ -0,0 +1,112 @@
+package txn
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/gagliardetto/solana-go"
+	computebudget "github.com/gagliardetto/solana-go/programs/compute-budget"
+	"github.com/gagliardetto/solana-go/rpc"
+)
+
+const MaxComputeUnitLimit = uint32(1400000)
+
+var ErrSimulationFailed = errors.New("transaction simulation failed")
+
+// ComputeUnitEstimator 通过模拟交易读取 unitsConsumed 估算 compute unit limit，
+// 结果按 key（通常为池子地址加指令类型）缓存，热路径无需重复模拟
+type ComputeUnitEstimator struct {
+	// 安全系数，零值为 1.2
+	Margin float64
+
+	mu    sync.RWMutex
+	cache map[string]uint32
+}
+
+func NewComputeUnitEstimator(margin float64) *ComputeUnitEstimator {
+	return &ComputeUnitEstimator{Margin: margin}
+}
+
+// CacheKey joins a pool address and an instruction type into an estimator cache key.
+func CacheKey(pool solana.PublicKey, instructionType string) string {
+	return pool.String() + ":" + instructionType
+}
+
+// Cached returns the cached limit for key, if any.
+func (e *ComputeUnitEstimator) Cached(key string) (uint32, bool) {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	limit, ok := e.cache[key]
+	return limit, ok
+}
+
+// Forget drops the cached limit for key, e.g. after a transaction ran out of compute units.
+func (e *ComputeUnitEstimator) Forget(key string) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	delete(e.cache, key)
+}
+
+// Estimate returns the compute unit limit for instructions paid by payer, simulating only on a cache miss.
+func (e *ComputeUnitEstimator) Estimate(ctx context.Context, client *rpc.Client, key string, instructions []solana.Instruction, payer solana.PublicKey, tables map[solana.PublicKey]solana.PublicKeySlice) (uint32, error) {
+	if limit, ok := e.Cached(key); ok {
+		return limit, nil
+	}
+	units, err := SimulateComputeUnits(ctx, client, instructions, payer, tables)
+	if err != nil {
+		return 0, err
+	}
+	limit := e.withMargin(units)
+	e.mu.Lock()
+	if e.cache == nil {
+		e.cache = make(map[string]uint32)
+	}
+	e.cache[key] = limit
+	e.mu.Unlock()
+	return limit, nil
+}
+
+func (e *ComputeUnitEstimator) withMargin(units uint64) uint32 {
+	margin := e.Margin
+	if margin <= 0 {
+		margin = 1.2
+	}
+	limit := uint64(float64(units) * margin)
+	if limit > uint64(MaxComputeUnitLimit) {
+		return MaxComputeUnitLimit
+	}
+	return uint32(limit)
+}
+
+// SimulateComputeUnits simulates instructions with the maximum compute unit limit and returns unitsConsumed.
+// Signature verification is skipped and the blockhash is replaced by the node, so nothing needs to be signed.
+func SimulateComputeUnits(ctx context.Context, client *rpc.Client, instructions []solana.Instruction, payer solana.PublicKey, tables map[solana.PublicKey]solana.PublicKeySlice) (uint64, error) {
+	limitInstruction := computebudget.NewSetComputeUnitLimitInstruction(MaxComputeUnitLimit).Build()
+	tx, err := NewTransaction(
+		append([]solana.Instruction{limitInstruction}, instructions...),
+		solana.Hash{},
+		payer,
+		tables,
+	)
+	if err != nil {
+		return 0, err
+	}
+	tx.Signatures = make([]solana.Signature, tx.Message.Header.NumRequiredSignatures)
+	resp, err := client.SimulateTransactionWithOpts(ctx, tx, &rpc.SimulateTransactionOpts{
+		SigVerify:              false,
+		ReplaceRecentBlockhash: true,
+		Commitment:             rpc.CommitmentProcessed,
+	})
+	if err != nil {
+		return 0, err
+	}
+	if resp.Value.Err != nil {
+		return 0, fmt.Errorf("%w: %v", ErrSimulationFailed, resp.Value.Err)
+	}
+	if resp.Value.UnitsConsumed == nil {
+		return 0, fmt.Errorf("%w: no unitsConsumed in response", ErrSimulationFailed)
+	}
+	return *resp.Value.UnitsConsumed, nil
+}
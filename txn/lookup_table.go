@@ -0,0 +1,169 @@
+package txn
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"fmt"
+
+	"github.com/gagliardetto/solana-go"
+	addresslookuptable "github.com/gagliardetto/solana-go/programs/address-lookup-table"
+	"github.com/gagliardetto/solana-go/rpc"
+)
+
+var AddressLookupTableProgramID = solana.MustPublicKeyFromBase58("AddressLookupTab1e1111111111111111111111111")
+
+// 单条 ExtendLookupTable 指令最多写入的地址数，保证交易不超过大小限制
+const MaxExtendAddresses = 20
+
+const (
+	lookupTableCreate uint32 = 0
+	lookupTableExtend uint32 = 2
+)
+
+// NewTransaction builds a transaction, emitting a v0 message whenever lookup tables are given.
+func NewTransaction(instructions []solana.Instruction, blockhash solana.Hash, payer solana.PublicKey, tables map[solana.PublicKey]solana.PublicKeySlice) (*solana.Transaction, error) {
+	opts := []solana.TransactionOption{solana.TransactionPayer(payer)}
+	if len(tables) > 0 {
+		opts = append(opts, solana.TransactionAddressTables(tables))
+	}
+	tx, err := solana.NewTransaction(instructions, blockhash, opts...)
+	if err != nil {
+		return nil, err
+	}
+	if len(tables) > 0 {
+		tx.Message.SetVersion(solana.MessageVersionV0)
+	}
+	return tx, nil
+}
+
+// GetLookupTables fetches the addresses stored in each lookup table.
+func GetLookupTables(ctx context.Context, client *rpc.Client, tables []solana.PublicKey) (map[solana.PublicKey]solana.PublicKeySlice, error) {
+	if len(tables) == 0 {
+		return nil, nil
+	}
+	res := make(map[solana.PublicKey]solana.PublicKeySlice, len(tables))
+	for _, table := range tables {
+		state, err := addresslookuptable.GetAddressLookupTable(ctx, client, table)
+		if err != nil {
+			return nil, fmt.Errorf("failed to fetch lookup table %s: %w", table, err)
+		}
+		res[table] = state.Addresses
+	}
+	return res, nil
+}
+
+// FindLookupTableAddress derives the lookup table created by authority at recentSlot.
+func FindLookupTableAddress(authority solana.PublicKey, recentSlot uint64) (solana.PublicKey, uint8, error) {
+	slot := make([]byte, 8)
+	binary.LittleEndian.PutUint64(slot, recentSlot)
+	return solana.FindProgramAddress([][]byte{authority[:], slot}, AddressLookupTableProgramID)
+}
+
+// NewCreateLookupTableInstruction returns the CreateLookupTable instruction and the new table address.
+func NewCreateLookupTableInstruction(authority solana.PublicKey, payer solana.PublicKey, recentSlot uint64) (solana.Instruction, solana.PublicKey, error) {
+	table, bump, err := FindLookupTableAddress(authority, recentSlot)
+	if err != nil {
+		return nil, solana.PublicKey{}, err
+	}
+	data := new(bytes.Buffer)
+	binary.Write(data, binary.LittleEndian, lookupTableCreate)
+	binary.Write(data, binary.LittleEndian, recentSlot)
+	data.WriteByte(bump)
+	return solana.NewInstruction(
+		AddressLookupTableProgramID,
+		[]*solana.AccountMeta{
+			solana.NewAccountMeta(table, true, false),
+			solana.NewAccountMeta(authority, false, true),
+			solana.NewAccountMeta(payer, true, true),
+			solana.NewAccountMeta(solana.SystemProgramID, false, false),
+		},
+		data.Bytes(),
+	), table, nil
+}
+
+func NewExtendLookupTableInstruction(table solana.PublicKey, authority solana.PublicKey, payer solana.PublicKey, addresses []solana.PublicKey) solana.Instruction {
+	data := new(bytes.Buffer)
+	binary.Write(data, binary.LittleEndian, lookupTableExtend)
+	binary.Write(data, binary.LittleEndian, uint64(len(addresses)))
+	for _, address := range addresses {
+		data.Write(address[:])
+	}
+	return solana.NewInstruction(
+		AddressLookupTableProgramID,
+		[]*solana.AccountMeta{
+			solana.NewAccountMeta(table, true, false),
+			solana.NewAccountMeta(authority, false, true),
+			solana.NewAccountMeta(payer, true, true),
+			solana.NewAccountMeta(solana.SystemProgramID, false, false),
+		},
+		data.Bytes(),
+	)
+}
+
+// CreateLookupTable creates a lookup table owned by signer, filled with at most MaxExtendAddresses
+// addresses in the same transaction. Use ExtendLookupTable once it has landed to add more.
+func CreateLookupTable(ctx context.Context, client *rpc.Client, signer solana.PrivateKey, addresses []solana.PublicKey) (solana.PublicKey, string, error) {
+	if len(addresses) > MaxExtendAddresses {
+		return solana.PublicKey{}, "", fmt.Errorf("too many addresses for one transaction: %d > %d", len(addresses), MaxExtendAddresses)
+	}
+	owner := signer.PublicKey()
+	slot, err := client.GetSlot(ctx, rpc.CommitmentFinalized)
+	if err != nil {
+		return solana.PublicKey{}, "", err
+	}
+	createInst, table, err := NewCreateLookupTableInstruction(owner, owner, slot)
+	if err != nil {
+		return solana.PublicKey{}, "", err
+	}
+	instructions := []solana.Instruction{createInst}
+	if len(addresses) > 0 {
+		instructions = append(instructions, NewExtendLookupTableInstruction(table, owner, owner, addresses))
+	}
+	sig, err := signAndSend(ctx, client, signer, instructions)
+	return table, sig, err
+}
+
+// ExtendLookupTable appends addresses to an existing table, one transaction per MaxExtendAddresses.
+func ExtendLookupTable(ctx context.Context, client *rpc.Client, signer solana.PrivateKey, table solana.PublicKey, addresses []solana.PublicKey) ([]string, error) {
+	var sigs []string
+	for start := 0; start < len(addresses); start += MaxExtendAddresses {
+		end := start + MaxExtendAddresses
+		if end > len(addresses) {
+			end = len(addresses)
+		}
+		sig, err := signAndSend(ctx, client, signer, []solana.Instruction{
+			NewExtendLookupTableInstruction(table, signer.PublicKey(), signer.PublicKey(), addresses[start:end]),
+		})
+		if err != nil {
+			return sigs, err
+		}
+		sigs = append(sigs, sig)
+	}
+	return sigs, nil
+}
+
+func signAndSend(ctx context.Context, client *rpc.Client, signer solana.PrivateKey, instructions []solana.Instruction) (string, error) {
+	blockhash, err := client.GetLatestBlockhash(ctx, rpc.CommitmentFinalized)
+	if err != nil {
+		return "", fmt.Errorf("failed to fetch recent blockhash: %w", err)
+	}
+	tx, err := NewTransaction(instructions, blockhash.Value.Blockhash, signer.PublicKey(), nil)
+	if err != nil {
+		return "", err
+	}
+	_, err = tx.Sign(func(key solana.PublicKey) *solana.PrivateKey {
+		if signer.PublicKey().Equals(key) {
+			return &signer
+		}
+		return nil
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to sign transaction: %w", err)
+	}
+	sig, err := client.SendTransaction(ctx, tx)
+	if err != nil {
+		return "", fmt.Errorf("failed to send transaction: %w", err)
+	}
+	return sig.String(), nil
+}
@@ -0,0 +1,114 @@
+package txn
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	bin "github.com/gagliardetto/binary"
+	"github.com/gagliardetto/solana-go"
+	"github.com/gagliardetto/solana-go/programs/system"
+	"github.com/gagliardetto/solana-go/rpc"
+)
+
+const NonceAccountSize = uint64(80)
+
+var ErrNonceNotInitialized = errors.New("nonce account is not initialized")
+
+// DurableNonce 指定用于离线签名的 nonce 账户及其 authority
+type DurableNonce struct {
+	Account   solana.PublicKey
+	Authority solana.PublicKey
+}
+
+// GetNonceAccount fetches and decodes a system nonce account.
+func GetNonceAccount(ctx context.Context, client *rpc.Client, nonceAccount solana.PublicKey) (system.NonceAccount, error) {
+	var nonce system.NonceAccount
+	account, err := client.GetAccountInfo(ctx, nonceAccount)
+	if err != nil {
+		return nonce, err
+	}
+	if !account.Value.Owner.Equals(solana.SystemProgramID) {
+		return nonce, fmt.Errorf("%s is not owned by the system program", nonceAccount)
+	}
+	if err := bin.NewBinDecoder(account.GetBinary()).Decode(&nonce); err != nil {
+		return nonce, err
+	}
+	if nonce.State != 1 {
+		return nonce, fmt.Errorf("%s: %w", nonceAccount, ErrNonceNotInitialized)
+	}
+	return nonce, nil
+}
+
+// GetNonce returns the blockhash currently stored in the nonce account, checking its authority.
+func (n DurableNonce) GetNonce(ctx context.Context, client *rpc.Client) (solana.Hash, error) {
+	nonce, err := GetNonceAccount(ctx, client, n.Account)
+	if err != nil {
+		return solana.Hash{}, err
+	}
+	if !nonce.AuthorizedPubkey.Equals(n.Authority) {
+		return solana.Hash{}, fmt.Errorf("nonce authority mismatch: %s != %s", nonce.AuthorizedPubkey, n.Authority)
+	}
+	return solana.Hash(nonce.Nonce), nil
+}
+
+func (n DurableNonce) AdvanceInstruction() solana.Instruction {
+	return system.NewAdvanceNonceAccountInstruction(n.Account, solana.SysVarRecentBlockHashesPubkey, n.Authority).Build()
+}
+
+// WithNonce prepends AdvanceNonceAccount, which must be the first instruction of a durable nonce transaction.
+func (n DurableNonce) WithNonce(instructions []solana.Instruction) []solana.Instruction {
+	return append([]solana.Instruction{n.AdvanceInstruction()}, instructions...)
+}
+
+// NewCreateNonceAccountInstructions creates and initializes nonceAccount with authority, funded by payer.
+func NewCreateNonceAccountInstructions(ctx context.Context, client *rpc.Client, payer solana.PublicKey, nonceAccount solana.PublicKey, authority solana.PublicKey) ([]solana.Instruction, error) {
+	rent, err := client.GetMinimumBalanceForRentExemption(ctx, NonceAccountSize, rpc.CommitmentConfirmed)
+	if err != nil {
+		return nil, err
+	}
+	createInst, err := system.NewCreateAccountInstruction(rent, NonceAccountSize, solana.SystemProgramID, payer, nonceAccount).ValidateAndBuild()
+	if err != nil {
+		return nil, err
+	}
+	initInst, err := system.NewInitializeNonceAccountInstruction(authority, nonceAccount, solana.SysVarRecentBlockHashesPubkey, solana.SysVarRentPubkey).ValidateAndBuild()
+	if err != nil {
+		return nil, err
+	}
+	return []solana.Instruction{createInst, initInst}, nil
+}
+
+// CreateNonceAccount creates a new nonce account controlled by authority and returns its address
+// and the transaction signature.
+func CreateNonceAccount(ctx context.Context, client *rpc.Client, signer solana.PrivateKey, authority solana.PublicKey) (solana.PublicKey, string, error) {
+	nonceKey := solana.NewWallet().PrivateKey
+	instructions, err := NewCreateNonceAccountInstructions(ctx, client, signer.PublicKey(), nonceKey.PublicKey(), authority)
+	if err != nil {
+		return solana.PublicKey{}, "", err
+	}
+	blockhash, err := client.GetLatestBlockhash(ctx, rpc.CommitmentFinalized)
+	if err != nil {
+		return solana.PublicKey{}, "", fmt.Errorf("failed to fetch recent blockhash: %w", err)
+	}
+	tx, err := NewTransaction(instructions, blockhash.Value.Blockhash, signer.PublicKey(), nil)
+	if err != nil {
+		return solana.PublicKey{}, "", err
+	}
+	_, err = tx.Sign(func(key solana.PublicKey) *solana.PrivateKey {
+		switch {
+		case key.Equals(signer.PublicKey()):
+			return &signer
+		case key.Equals(nonceKey.PublicKey()):
+			return &nonceKey
+		}
+		return nil
+	})
+	if err != nil {
+		return solana.PublicKey{}, "", fmt.Errorf("failed to sign transaction: %w", err)
+	}
+	sig, err := client.SendTransaction(ctx, tx)
+	if err != nil {
+		return solana.PublicKey{}, "", fmt.Errorf("failed to send transaction: %w", err)
+	}
+	return nonceKey.PublicKey(), sig.String(), nil
+}
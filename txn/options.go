@@ -0,0 +1,93 @@
+package txn
+
+import (
+	"context"
+	"fmt"
+
+	"raydium-go/spl"
+
+	"github.com/gagliardetto/solana-go"
+	computebudget "github.com/gagliardetto/solana-go/programs/compute-budget"
+	"github.com/gagliardetto/solana-go/rpc"
+)
+
+// SwapOptions 控制 cpmm、clmm、launchlab 和 router 交易的可选行为，零值字段使用各包的默认值
+type SwapOptions struct {
+	WSOLPolicy spl.WSOLPolicy
+	// 固定的 compute unit 价格（micro-lamports）
+	PriorityFee uint64
+	// 设置后根据交易的可写账户估算 compute unit 价格，优先于 PriorityFee
+	PriorityFeeEstimator *PriorityFeeEstimator
+	// 固定的 compute unit limit
+	ComputeUnitLimit uint32
+	// 设置后先模拟交易再按实际消耗设置 limit，优先于 ComputeUnitLimit
+	ComputeUnitEstimator *ComputeUnitEstimator
+	// 设置后生成使用这些地址查找表的 v0 交易
+	AddressLookupTables []solana.PublicKey
+	// 池子、配置和用户账户读取的 commitment，默认 confirmed
+	Commitment rpc.CommitmentType
+	// 最新 blockhash 的读取，默认 finalized
+	BlockhashCommitment rpc.CommitmentType
+	// 设置后使用 durable nonce 代替最新 blockhash，用于离线签名
+	Nonce *DurableNonce
+}
+
+// StateCommitment returns the commitment of state reads.
+func (o SwapOptions) StateCommitment() rpc.CommitmentType {
+	if o.Commitment == "" {
+		return rpc.CommitmentConfirmed
+	}
+	return o.Commitment
+}
+
+func (o SwapOptions) blockhashCommitment() rpc.CommitmentType {
+	if o.BlockhashCommitment == "" {
+		return rpc.CommitmentFinalized
+	}
+	return o.BlockhashCommitment
+}
+
+// Transaction prepends the compute budget instructions, and the nonce advance when Nonce is set, and
+// builds the transaction paid by payer. key caches the compute unit estimate, writable are the
+// accounts the priority fee is estimated for and limit and fee are the caller's defaults.
+func (o SwapOptions) Transaction(ctx context.Context, client *rpc.Client, key string, instructions []solana.Instruction, payer solana.PublicKey, writable []solana.PublicKey, limit uint32, fee uint64) (*solana.Transaction, error) {
+	var tables map[solana.PublicKey]solana.PublicKeySlice
+	var err error
+	if len(o.AddressLookupTables) > 0 {
+		if tables, err = GetLookupTables(ctx, client, o.AddressLookupTables); err != nil {
+			return nil, err
+		}
+	}
+	switch {
+	case o.ComputeUnitEstimator != nil:
+		if limit, err = o.ComputeUnitEstimator.Estimate(ctx, client, key, instructions, payer, tables); err != nil {
+			return nil, err
+		}
+	case o.ComputeUnitLimit > 0:
+		limit = o.ComputeUnitLimit
+	}
+	switch {
+	case o.PriorityFeeEstimator != nil:
+		if fee, err = o.PriorityFeeEstimator.Estimate(ctx, client, writable, limit); err != nil {
+			return nil, err
+		}
+	case o.PriorityFee > 0:
+		fee = o.PriorityFee
+	}
+	instructions = append([]solana.Instruction{
+		computebudget.NewSetComputeUnitLimitInstruction(limit).Build(),
+		computebudget.NewSetComputeUnitPriceInstruction(fee).Build(),
+	}, instructions...)
+	if o.Nonce != nil {
+		nonce, err := o.Nonce.GetNonce(ctx, client)
+		if err != nil {
+			return nil, fmt.Errorf("failed to fetch durable nonce: %w", err)
+		}
+		return NewTransaction(o.Nonce.WithNonce(instructions), nonce, payer, tables)
+	}
+	latest, err := client.GetLatestBlockhash(ctx, o.blockhashCommitment())
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch recent blockhash: %w", err)
+	}
+	return NewTransaction(instructions, latest.Value.Blockhash, payer, tables)
+}
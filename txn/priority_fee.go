@@ -0,0 +1,69 @@
+package txn
+
+import (
+	"context"
+	"math"
+	"sort"
+
+	"github.com/gagliardetto/solana-go"
+	"github.com/gagliardetto/solana-go/rpc"
+)
+
+// PriorityFeeEstimator 根据 getRecentPrioritizationFees 估算 compute unit 价格（micro-lamports）
+type PriorityFeeEstimator struct {
+	// 取样百分位 (0, 100]，零值为 50
+	Percentile float64
+	// 单位价格下限和上限，MaxFee 为 0 表示不限
+	MinFee uint64
+	MaxFee uint64
+	// 单笔交易的优先费上限（lamports），为 0 表示不限
+	MaxLamports uint64
+}
+
+// Estimate returns the compute unit price for a transaction writing accounts with the given limit.
+func (e PriorityFeeEstimator) Estimate(ctx context.Context, client *rpc.Client, accounts []solana.PublicKey, computeUnitLimit uint32) (uint64, error) {
+	recent, err := client.GetRecentPrioritizationFees(ctx, accounts)
+	if err != nil {
+		return 0, err
+	}
+	fees := make([]uint64, 0, len(recent))
+	for _, r := range recent {
+		fees = append(fees, r.PrioritizationFee)
+	}
+	return e.Cap(Percentile(fees, e.Percentile), computeUnitLimit), nil
+}
+
+// Cap clamps fee to the configured bounds and per-transaction lamport cap.
+func (e PriorityFeeEstimator) Cap(fee uint64, computeUnitLimit uint32) uint64 {
+	if fee < e.MinFee {
+		fee = e.MinFee
+	}
+	if e.MaxFee > 0 && fee > e.MaxFee {
+		fee = e.MaxFee
+	}
+	if e.MaxLamports > 0 && computeUnitLimit > 0 {
+		maxFee := e.MaxLamports * 1000000 / uint64(computeUnitLimit)
+		if fee > maxFee {
+			fee = maxFee
+		}
+	}
+	return fee
+}
+
+// Percentile returns the nearest-rank percentile of fees, p defaults to 50.
+func Percentile(fees []uint64, p float64) uint64 {
+	if len(fees) == 0 {
+		return 0
+	}
+	if p <= 0 {
+		p = 50
+	}
+	if p > 100 {
+		p = 100
+	}
+	sorted := append([]uint64(nil), fees...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	// 先乘后除，避免 p/100 的舍入误差使整数 rank 多进一位
+	rank := int(math.Ceil(p*float64(len(sorted))/100)) - 1
+	return sorted[max(0, min(rank, len(sorted)-1))]
+}
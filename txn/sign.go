@@ -0,0 +1,35 @@
+package txn
+
+import (
+	"fmt"
+
+	"github.com/gagliardetto/solana-go"
+)
+
+// PartialSign adds the signatures of signers to tx and leaves the other signer slots empty,
+// so the transaction can be handed to the remaining signers.
+func PartialSign(tx *solana.Transaction, signers ...solana.PrivateKey) error {
+	_, err := tx.PartialSign(func(key solana.PublicKey) *solana.PrivateKey {
+		for i := range signers {
+			if signers[i].PublicKey().Equals(key) {
+				return &signers[i]
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to sign transaction: %w", err)
+	}
+	return nil
+}
+
+// MissingSigners returns the required signers of tx that have not signed yet.
+func MissingSigners(tx *solana.Transaction) []solana.PublicKey {
+	var missing []solana.PublicKey
+	for i := 0; i < int(tx.Message.Header.NumRequiredSignatures); i++ {
+		if i >= len(tx.Signatures) || tx.Signatures[i].IsZero() {
+			missing = append(missing, tx.Message.AccountKeys[i])
+		}
+	}
+	return missing
+}
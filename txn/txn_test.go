@@ -0,0 +1,211 @@
+package txn
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"sync/atomic"
+	"testing"
+
+	"raydium-go/internal/rpctest"
+
+	"github.com/gagliardetto/solana-go"
+	"github.com/gagliardetto/solana-go/programs/system"
+	"github.com/gagliardetto/solana-go/rpc"
+)
+
+func TestPercentile(t *testing.T) {
+	fees := []uint64{0, 0, 100, 5000, 200, 300, 0, 1000, 50, 10}
+	cases := map[float64]uint64{0: 50, 50: 50, 75: 300, 90: 1000, 100: 5000, 10: 0}
+	for p, want := range cases {
+		if got := Percentile(fees, p); got != want {
+			t.Errorf("Percentile(%v) = %d, want %d", p, got, want)
+		}
+	}
+	// 1 到 100 的第 p 百分位就是 p，比 rank 大一点点也要进到下一位
+	var ranks []uint64
+	for i := uint64(100); i > 0; i-- {
+		ranks = append(ranks, i)
+	}
+	for p, want := range map[float64]uint64{7: 7, 29: 29, 57: 57, 10.000001: 11, 0.5: 1, 99.5: 100} {
+		if got := Percentile(ranks, p); got != want {
+			t.Errorf("Percentile(1..100, %v) = %d, want %d", p, got, want)
+		}
+	}
+	if got := Percentile(nil, 50); got != 0 {
+		t.Errorf("Percentile(nil) = %d", got)
+	}
+}
+
+func TestPriorityFeeCap(t *testing.T) {
+	e := PriorityFeeEstimator{MinFee: 100, MaxFee: 50000, MaxLamports: 1000}
+	if got := e.Cap(10, 200000); got != 100 {
+		t.Errorf("min cap = %d", got)
+	}
+	if got := e.Cap(100000, 10000); got != 50000 {
+		t.Errorf("max cap = %d", got)
+	}
+	// 1000 lamports over 200000 CU allows at most 5000 micro-lamports per CU
+	if got := e.Cap(20000, 200000); got != 5000 {
+		t.Errorf("per-transaction cap = %d", got)
+	}
+}
+
+func TestComputeUnitEstimator(t *testing.T) {
+	var calls int32
+	server := rpctest.NewServer(t, func(method string, params []json.RawMessage) interface{} {
+		if method != "simulateTransaction" {
+			t.Errorf("unexpected method %s", method)
+		}
+		atomic.AddInt32(&calls, 1)
+		return map[string]interface{}{
+			"context": map[string]interface{}{"slot": 1},
+			"value":   map[string]interface{}{"err": nil, "logs": []string{}, "unitsConsumed": 50000},
+		}
+	})
+	client := rpc.New(server.URL)
+	payer := solana.NewWallet().PublicKey()
+	transfer := system.NewTransferInstruction(1, payer, solana.NewWallet().PublicKey()).Build()
+	estimator := NewComputeUnitEstimator(1.2)
+	key := CacheKey(payer, "transfer")
+	for i := 0; i < 2; i++ {
+		limit, err := estimator.Estimate(context.Background(), client, key, []solana.Instruction{transfer}, payer, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if limit != 60000 {
+			t.Errorf("limit = %d, want 60000", limit)
+		}
+	}
+	if calls != 1 {
+		t.Errorf("simulated %d times, want 1", calls)
+	}
+	estimator.Forget(key)
+	if _, ok := estimator.Cached(key); ok {
+		t.Error("expected cache miss after Forget")
+	}
+}
+
+func TestNewTransactionWithLookupTables(t *testing.T) {
+	payer := solana.NewWallet().PublicKey()
+	recipients := []solana.PublicKey{solana.NewWallet().PublicKey(), solana.NewWallet().PublicKey()}
+	instructions := []solana.Instruction{
+		system.NewTransferInstruction(1, payer, recipients[0]).Build(),
+		system.NewTransferInstruction(1, payer, recipients[1]).Build(),
+	}
+	legacy, err := NewTransaction(instructions, solana.Hash{}, payer, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if legacy.Message.IsVersioned() {
+		t.Error("expected legacy message without lookup tables")
+	}
+	table, _, err := FindLookupTableAddress(payer, 42)
+	if err != nil {
+		t.Fatal(err)
+	}
+	v0, err := NewTransaction(instructions, solana.Hash{}, payer, map[solana.PublicKey]solana.PublicKeySlice{table: recipients})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !v0.Message.IsVersioned() || len(v0.Message.GetAddressTableLookups()) != 1 {
+		t.Fatalf("expected v0 message with one lookup, got version %d", v0.Message.GetVersion())
+	}
+	if len(v0.Message.AccountKeys) >= len(legacy.Message.AccountKeys) {
+		t.Errorf("lookup table did not shrink static keys: %d >= %d", len(v0.Message.AccountKeys), len(legacy.Message.AccountKeys))
+	}
+}
+
+func TestLookupTableInstructions(t *testing.T) {
+	authority := solana.NewWallet().PublicKey()
+	create, table, err := NewCreateLookupTableInstruction(authority, authority, 7)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, _ := create.Data()
+	if len(data) != 13 || data[0] != 0 || data[4] != 7 {
+		t.Errorf("unexpected create data %v", data)
+	}
+	extend := NewExtendLookupTableInstruction(table, authority, authority, []solana.PublicKey{authority})
+	data, _ = extend.Data()
+	if len(data) != 4+8+32 || data[0] != 2 || data[4] != 1 {
+		t.Errorf("unexpected extend data %v", data)
+	}
+}
+
+func TestDurableNonce(t *testing.T) {
+	authority := solana.NewWallet().PublicKey()
+	nonceAccount := solana.NewWallet().PublicKey()
+	stored := solana.NewWallet().PublicKey()
+	data := make([]byte, NonceAccountSize)
+	data[4] = 1
+	copy(data[8:40], authority[:])
+	copy(data[40:72], stored[:])
+	server := rpctest.NewServer(t, func(method string, params []json.RawMessage) interface{} {
+		return map[string]interface{}{
+			"context": map[string]interface{}{"slot": 1},
+			"value": map[string]interface{}{
+				"lamports":   1447680,
+				"owner":      solana.SystemProgramID.String(),
+				"data":       []string{base64.StdEncoding.EncodeToString(data), "base64"},
+				"executable": false,
+				"rentEpoch":  0,
+			},
+		}
+	})
+	client := rpc.New(server.URL)
+	nonce := DurableNonce{Account: nonceAccount, Authority: authority}
+	hash, err := nonce.GetNonce(context.Background(), client)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if hash != solana.Hash(stored) {
+		t.Errorf("nonce = %s, want %s", hash, stored)
+	}
+	if _, err := (DurableNonce{Account: nonceAccount, Authority: stored}).GetNonce(context.Background(), client); err == nil {
+		t.Error("expected authority mismatch error")
+	}
+	payer := solana.NewWallet().PublicKey()
+	instructions := nonce.WithNonce([]solana.Instruction{system.NewTransferInstruction(1, payer, authority).Build()})
+	if !instructions[0].ProgramID().Equals(solana.SystemProgramID) || len(instructions) != 2 {
+		t.Fatal("expected AdvanceNonceAccount first")
+	}
+	advance, _ := instructions[0].Data()
+	if advance[0] != 4 {
+		t.Errorf("unexpected advance nonce data %v", advance)
+	}
+	// SwapOptions 的交易以 nonce 代替 blockhash，advance 在 compute budget 之前
+	tx, err := SwapOptions{Nonce: &nonce}.Transaction(context.Background(), client, "nonce", []solana.Instruction{system.NewTransferInstruction(1, payer, authority).Build()}, payer, nil, 200000, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tx.Message.RecentBlockhash != solana.Hash(stored) || len(tx.Message.Instructions) != 4 || !tx.Message.AccountKeys[tx.Message.Instructions[0].ProgramIDIndex].Equals(solana.SystemProgramID) {
+		t.Errorf("unexpected nonce transaction %s", tx)
+	}
+}
+
+func TestPartialSign(t *testing.T) {
+	feePayer := solana.NewWallet().PrivateKey
+	owner := solana.NewWallet().PrivateKey
+	instruction := system.NewTransferInstruction(1, owner.PublicKey(), solana.NewWallet().PublicKey()).Build()
+	tx, err := NewTransaction([]solana.Instruction{instruction}, solana.Hash{}, feePayer.PublicKey(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := PartialSign(tx, feePayer); err != nil {
+		t.Fatal(err)
+	}
+	missing := MissingSigners(tx)
+	if len(missing) != 1 || !missing[0].Equals(owner.PublicKey()) {
+		t.Fatalf("missing signers = %v, want owner", missing)
+	}
+	if err := PartialSign(tx, owner); err != nil {
+		t.Fatal(err)
+	}
+	if len(MissingSigners(tx)) != 0 {
+		t.Error("expected fully signed transaction")
+	}
+	if err := tx.VerifySignatures(); err != nil {
+		t.Error(err)
+	}
+}